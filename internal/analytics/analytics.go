@@ -316,6 +316,59 @@ func QueryCheckFailures(database DB, since string) ([]CheckFailure, error) {
 	return results, nil
 }
 
+// CheckWeight holds resource usage stats for a check, from the runs that
+// recorded usage.
+type CheckWeight struct {
+	Check          string  `json:"check"`
+	Runs           int     `json:"runs"`
+	MaxMemoryMB    float64 `json:"max_memory_mb"`
+	AvgMemoryMB    float64 `json:"avg_memory_mb"`
+	AvgCPUSeconds  float64 `json:"avg_cpu_seconds"`
+	AvgWallSeconds float64 `json:"avg_wall_seconds"`
+}
+
+// QueryHeaviestChecks returns checks ordered by peak memory, then CPU time.
+func QueryHeaviestChecks(database DB, since string) ([]CheckWeight, error) {
+	query := `
+		SELECT check_name,
+			COUNT(*) AS runs,
+			COALESCE(MAX(peak_memory_bytes), 0) AS max_mem,
+			COALESCE(AVG(peak_memory_bytes), 0) AS avg_mem,
+			COALESCE(AVG(cpu_ms), 0) AS avg_cpu,
+			COALESCE(AVG(duration_ms), 0) AS avg_wall
+		FROM check_runs
+		WHERE (peak_memory_bytes IS NOT NULL OR cpu_ms IS NOT NULL)`
+
+	args := []interface{}{}
+	if since != "" {
+		query += ` AND timestamp >= $1`
+		args = append(args, since)
+	}
+	query += ` GROUP BY check_name ORDER BY max_mem DESC, avg_cpu DESC`
+
+	rows, err := database.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query heaviest checks: %w", err)
+	}
+	defer rows.Close()
+
+	var results []CheckWeight
+	for rows.Next() {
+		var w CheckWeight
+		var maxMem int64
+		var avgMem, avgCPU, avgWall float64
+		if err := rows.Scan(&w.Check, &w.Runs, &maxMem, &avgMem, &avgCPU, &avgWall); err != nil {
+			return nil, fmt.Errorf("scan check weight: %w", err)
+		}
+		w.MaxMemoryMB = math.Round(float64(maxMem)/(1<<20)*10) / 10
+		w.AvgMemoryMB = math.Round(avgMem/(1<<20)*10) / 10
+		w.AvgCPUSeconds = math.Round(avgCPU/100) / 10
+		w.AvgWallSeconds = math.Round(avgWall/100) / 10
+		results = append(results, w)
+	}
+	return results, rows.Err()
+}
+
 // FixRoundDist holds fix round distribution for a stage.
 type FixRoundDist struct {
 	Stage     string  `json:"stage"`
@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VariantRun is the result of one stage attempt rendered from a prompt
+// variant, as recorded in a prompt_variant pipeline event.
+type VariantRun struct {
+	Variant   string
+	Template  string
+	Outcome   string // success, fail, escalate
+	FixRounds int
+	FirstPass bool
+	Duration  time.Duration
+}
+
+// FormatVariantDetail encodes a run as the detail of a prompt_variant event.
+func FormatVariantDetail(r VariantRun) string {
+	return fmt.Sprintf("variant=%s template=%s outcome=%s rounds=%d first_pass=%t duration_s=%d",
+		r.Variant, r.Template, r.Outcome, r.FixRounds, r.FirstPass, int(r.Duration.Seconds()))
+}
+
+// ParseVariantDetail decodes a prompt_variant event detail. ok is false when
+// the detail names no variant.
+func ParseVariantDetail(detail string) (r VariantRun, ok bool) {
+	for _, field := range strings.Fields(detail) {
+		k, v, _ := strings.Cut(field, "=")
+		switch k {
+		case "variant":
+			r.Variant = v
+		case "template":
+			r.Template = v
+		case "outcome":
+			r.Outcome = v
+		case "rounds":
+			r.FixRounds, _ = strconv.Atoi(v)
+		case "first_pass":
+			r.FirstPass = v == "true"
+		case "duration_s":
+			secs, _ := strconv.Atoi(v)
+			r.Duration = time.Duration(secs) * time.Second
+		}
+	}
+	return r, r.Variant != ""
+}
+
+// VariantStats compares one prompt variant against the others of a stage.
+type VariantStats struct {
+	Variant      string  `json:"variant"`
+	Template     string  `json:"template"`
+	Runs         int     `json:"runs"`
+	FirstPass    float64 `json:"first_pass_pct"`
+	AvgFixRounds float64 `json:"avg_fix_rounds"`
+	AvgDuration  float64 `json:"avg_minutes"`
+	P50Duration  float64 `json:"p50_minutes"`
+	OnFail       float64 `json:"on_fail_pct"` // attempts that failed and were routed via on_fail, retried or escalated
+}
+
+// SummarizeVariants aggregates runs per variant, sorted by variant name.
+func SummarizeVariants(runs []VariantRun) []VariantStats {
+	byVariant := make(map[string][]VariantRun)
+	for _, r := range runs {
+		byVariant[r.Variant] = append(byVariant[r.Variant], r)
+	}
+
+	var results []VariantStats
+	for name, rs := range byVariant {
+		var firstPass, failed, rounds int
+		var minutes []float64
+		for _, r := range rs {
+			if r.FirstPass {
+				firstPass++
+			}
+			if r.Outcome != "success" {
+				failed++
+			}
+			rounds += r.FixRounds
+			if r.Duration > 0 {
+				minutes = append(minutes, r.Duration.Minutes())
+			}
+		}
+		sort.Float64s(minutes)
+		results = append(results, VariantStats{
+			Variant:      name,
+			Template:     rs[len(rs)-1].Template,
+			Runs:         len(rs),
+			FirstPass:    pct(firstPass, len(rs)),
+			AvgFixRounds: float64(int(float64(rounds)/float64(len(rs))*100+0.5)) / 100,
+			AvgDuration:  avg(minutes),
+			P50Duration:  percentile(minutes, 50),
+			OnFail:       pct(failed, len(rs)),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Variant < results[j].Variant
+	})
+	return results
+}
+
+// QueryExperiment compares the prompt variants of a stage using the
+// prompt_variant events recorded at the end of each attempt.
+func QueryExperiment(database DB, stage string, since string) ([]VariantStats, error) {
+	query := `SELECT detail FROM pipeline_events WHERE event = 'prompt_variant' AND stage = $1`
+	args := []interface{}{stage}
+	if since != "" {
+		query += ` AND timestamp >= $2`
+		args = append(args, since)
+	}
+
+	rows, err := database.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query prompt variants: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []VariantRun
+	for rows.Next() {
+		var detail string
+		if err := rows.Scan(&detail); err != nil {
+			return nil, fmt.Errorf("scan prompt variant: %w", err)
+		}
+		if r, ok := ParseVariantDetail(detail); ok {
+			runs = append(runs, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return SummarizeVariants(runs), nil
+}
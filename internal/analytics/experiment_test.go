@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVariantDetail_RoundTrip(t *testing.T) {
+	in := VariantRun{
+		Variant: "terse", Template: "implement-terse.md", Outcome: "fail",
+		FixRounds: 2, FirstPass: false, Duration: 95 * time.Second,
+	}
+	out, ok := ParseVariantDetail(FormatVariantDetail(in))
+	if !ok {
+		t.Fatal("ParseVariantDetail() ok = false")
+	}
+	if out != in {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+
+	if _, ok := ParseVariantDetail("outcome=success"); ok {
+		t.Error("ParseVariantDetail() without variant: ok = true")
+	}
+}
+
+func TestSummarizeVariants(t *testing.T) {
+	runs := []VariantRun{
+		{Variant: "a", Template: "a.md", Outcome: "success", FirstPass: true, Duration: 10 * time.Minute},
+		{Variant: "a", Template: "a.md", Outcome: "success", FixRounds: 1, Duration: 20 * time.Minute},
+		{Variant: "b", Template: "b.md", Outcome: "fail", FixRounds: 2, Duration: 30 * time.Minute},
+	}
+	got := SummarizeVariants(runs)
+	if len(got) != 2 {
+		t.Fatalf("got %d variants, want 2", len(got))
+	}
+	a, b := got[0], got[1]
+	if a.Variant != "a" || a.Runs != 2 || a.FirstPass != 50 || a.AvgFixRounds != 0.5 || a.OnFail != 0 {
+		t.Errorf("variant a = %+v", a)
+	}
+	if a.AvgDuration != 15 {
+		t.Errorf("variant a avg duration = %v, want 15", a.AvgDuration)
+	}
+	if b.Variant != "b" || b.Runs != 1 || b.FirstPass != 0 || b.AvgFixRounds != 2 || b.OnFail != 100 {
+		t.Errorf("variant b = %+v", b)
+	}
+}
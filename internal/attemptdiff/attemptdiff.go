@@ -0,0 +1,187 @@
+// Package attemptdiff compares two attempts of the same pipeline stage: the
+// rendered prompts, the per-check results, the outcomes, and the code each
+// attempt left on the branch.
+package attemptdiff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+// Side holds everything recorded for a single stage attempt.
+type Side struct {
+	Attempt int
+	Prompt  string
+	Outcome *pipeline.StageOutcome
+	Patch   string
+	Checks  []db.CheckRun
+}
+
+// Load reads the on-disk artifacts (prompt, outcome, patch) for an attempt.
+// Check runs live in the DB and must be filled in by the caller.
+func Load(store *pipeline.Store, issue int, stage string, attempt int) Side {
+	side := Side{Attempt: attempt}
+	side.Prompt, _ = store.GetPrompt(issue, stage, attempt)
+	side.Outcome, _ = store.GetStageOutcome(issue, stage, attempt)
+	side.Patch, _ = store.GetAttemptDiff(issue, stage, attempt)
+	return side
+}
+
+// CheckTransition describes how a single check's final result changed
+// between two attempts. Before/After are "pass", "fail", or "" if not run.
+type CheckTransition struct {
+	Check         string `json:"check"`
+	Before        string `json:"before"`
+	After         string `json:"after"`
+	FixRoundsA    int    `json:"fix_rounds_a"`
+	FixRoundsB    int    `json:"fix_rounds_b"`
+	BeforeSummary string `json:"before_summary,omitempty"`
+	AfterSummary  string `json:"after_summary,omitempty"`
+}
+
+// Changed reports whether the check's final result differs between attempts.
+func (t CheckTransition) Changed() bool {
+	return t.Before != t.After
+}
+
+// Label renders the transition as "before → after", using "-" for checks
+// that did not run in one of the attempts.
+func (t CheckTransition) Label() string {
+	return fmt.Sprintf("%s → %s", orDash(t.Before), orDash(t.After))
+}
+
+// Result is the full comparison of attempt A against attempt B.
+type Result struct {
+	Issue      int                    `json:"issue"`
+	Stage      string                 `json:"stage"`
+	A          int                    `json:"a"`
+	B          int                    `json:"b"`
+	PromptDiff string                 `json:"prompt_diff"`
+	Checks     []CheckTransition      `json:"checks"`
+	OutcomeA   *pipeline.StageOutcome `json:"outcome_a,omitempty"`
+	OutcomeB   *pipeline.StageOutcome `json:"outcome_b,omitempty"`
+	FilesOnlyA []string               `json:"files_only_a,omitempty"`
+	FilesOnlyB []string               `json:"files_only_b,omitempty"`
+	PatchA     string                 `json:"patch_a,omitempty"`
+	PatchB     string                 `json:"patch_b,omitempty"`
+	CodeDiff   string                 `json:"code_diff"`
+}
+
+// Compare builds the comparison of two attempts of the same stage.
+func Compare(issue int, stage string, a, b Side) *Result {
+	labelA := fmt.Sprintf("attempt-%d", a.Attempt)
+	labelB := fmt.Sprintf("attempt-%d", b.Attempt)
+
+	res := &Result{
+		Issue:      issue,
+		Stage:      stage,
+		A:          a.Attempt,
+		B:          b.Attempt,
+		PromptDiff: Unified(labelA+"/prompt.md", labelB+"/prompt.md", a.Prompt, b.Prompt),
+		Checks:     checkTransitions(a.Checks, b.Checks),
+		OutcomeA:   a.Outcome,
+		OutcomeB:   b.Outcome,
+		PatchA:     a.Patch,
+		PatchB:     b.Patch,
+		CodeDiff:   Unified(labelA+"/diff.patch", labelB+"/diff.patch", a.Patch, b.Patch),
+	}
+	res.FilesOnlyA, res.FilesOnlyB = fileSetDiff(filesOf(a.Outcome), filesOf(b.Outcome))
+	return res
+}
+
+// finalCheckState returns the last recorded run of each check, which is the
+// state the attempt ended in after any fix rounds.
+func finalCheckState(runs []db.CheckRun) map[string]db.CheckRun {
+	final := make(map[string]db.CheckRun)
+	for _, r := range runs {
+		prev, ok := final[r.CheckName]
+		if !ok || r.FixRound > prev.FixRound || (r.FixRound == prev.FixRound && r.ID > prev.ID) {
+			final[r.CheckName] = r
+		}
+	}
+	return final
+}
+
+func checkTransitions(a, b []db.CheckRun) []CheckTransition {
+	finalA := finalCheckState(a)
+	finalB := finalCheckState(b)
+
+	names := make(map[string]bool)
+	for n := range finalA {
+		names[n] = true
+	}
+	for n := range finalB {
+		names[n] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	var result []CheckTransition
+	for _, n := range sorted {
+		t := CheckTransition{Check: n}
+		if r, ok := finalA[n]; ok {
+			t.Before = passFail(r.Passed)
+			t.FixRoundsA = r.FixRound
+			t.BeforeSummary = r.Summary
+		}
+		if r, ok := finalB[n]; ok {
+			t.After = passFail(r.Passed)
+			t.FixRoundsB = r.FixRound
+			t.AfterSummary = r.Summary
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func filesOf(o *pipeline.StageOutcome) []string {
+	if o == nil {
+		return nil
+	}
+	return o.FilesChanged
+}
+
+// fileSetDiff returns the files present only in a and only in b, sorted.
+func fileSetDiff(a, b []string) (onlyA, onlyB []string) {
+	inA := make(map[string]bool, len(a))
+	for _, f := range a {
+		inA[f] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, f := range b {
+		inB[f] = true
+	}
+	for f := range inA {
+		if !inB[f] {
+			onlyA = append(onlyA, f)
+		}
+	}
+	for f := range inB {
+		if !inA[f] {
+			onlyB = append(onlyB, f)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	return onlyA, onlyB
+}
+
+func passFail(passed bool) string {
+	if passed {
+		return "pass"
+	}
+	return "fail"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
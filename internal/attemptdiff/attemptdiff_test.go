@@ -0,0 +1,144 @@
+package attemptdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+func TestUnified_Identical(t *testing.T) {
+	if got := Unified("a", "b", "same\ntext\n", "same\ntext\n"); got != "" {
+		t.Errorf("expected empty diff for identical input, got %q", got)
+	}
+}
+
+func TestUnified_SingleChange(t *testing.T) {
+	from := "one\ntwo\nthree\nfour\nfive\n"
+	to := "one\ntwo\nTHREE\nfour\nfive\n"
+
+	got := Unified("a.md", "b.md", from, to)
+	want := "--- a.md\n+++ b.md\n@@ -1,5 +1,5 @@\n one\n two\n-three\n+THREE\n four\n five\n"
+	if got != want {
+		t.Errorf("diff mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnified_SeparateHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 30; i++ {
+		line := string(rune('a'+i%26)) + "-line"
+		a = append(a, line)
+		b = append(b, line)
+	}
+	b[2] = "changed-early"
+	b[25] = "changed-late"
+
+	got := Unified("a", "b", strings.Join(a, "\n"), strings.Join(b, "\n"))
+	if n := strings.Count(got, "@@ -"); n != 2 {
+		t.Errorf("expected 2 hunks, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(got, "+changed-early") || !strings.Contains(got, "+changed-late") {
+		t.Errorf("missing changes in diff:\n%s", got)
+	}
+}
+
+func TestUnified_InsertOnly(t *testing.T) {
+	got := Unified("a", "b", "x\ny\n", "x\nnew\ny\n")
+	if !strings.Contains(got, "@@ -1,2 +1,3 @@") {
+		t.Errorf("unexpected hunk header:\n%s", got)
+	}
+	if !strings.Contains(got, "+new\n") {
+		t.Errorf("missing inserted line:\n%s", got)
+	}
+}
+
+func TestCompare_CheckTransitions(t *testing.T) {
+	a := Side{Attempt: 1, Checks: []db.CheckRun{
+		{ID: 1, CheckName: "lint", FixRound: 0, Passed: false},
+		{ID: 2, CheckName: "test", FixRound: 0, Passed: false},
+		{ID: 3, CheckName: "lint", FixRound: 1, Passed: true},
+		{ID: 4, CheckName: "test", FixRound: 1, Passed: false, Summary: "2 failed"},
+	}}
+	b := Side{Attempt: 2, Checks: []db.CheckRun{
+		{ID: 10, CheckName: "lint", FixRound: 0, Passed: true},
+		{ID: 11, CheckName: "test", FixRound: 0, Passed: true},
+		{ID: 12, CheckName: "typecheck", FixRound: 0, Passed: false},
+	}}
+
+	res := Compare(42, "implement", a, b)
+	if len(res.Checks) != 3 {
+		t.Fatalf("expected 3 checks, got %d", len(res.Checks))
+	}
+
+	byName := make(map[string]CheckTransition)
+	for _, c := range res.Checks {
+		byName[c.Check] = c
+	}
+
+	if lint := byName["lint"]; lint.Changed() || lint.Label() != "pass → pass" || lint.FixRoundsA != 1 {
+		t.Errorf("lint = %+v (label %q)", lint, lint.Label())
+	}
+	if tst := byName["test"]; !tst.Changed() || tst.Label() != "fail → pass" || tst.BeforeSummary != "2 failed" {
+		t.Errorf("test = %+v (label %q)", tst, tst.Label())
+	}
+	if tc := byName["typecheck"]; tc.Label() != "- → fail" {
+		t.Errorf("typecheck label = %q", tc.Label())
+	}
+}
+
+func TestCompare_OutcomesAndPatches(t *testing.T) {
+	a := Side{
+		Attempt: 1,
+		Prompt:  "# Implement\nDo it\n",
+		Outcome: &pipeline.StageOutcome{Status: "fail", FilesChanged: []string{"a.go", "b.go"}},
+		Patch:   "+old\n",
+	}
+	b := Side{
+		Attempt: 2,
+		Prompt:  "# Implement\nDo it\n## Previous Check Failures\n- test\n",
+		Outcome: &pipeline.StageOutcome{Status: "success", FilesChanged: []string{"b.go", "c.go"}},
+		Patch:   "+new\n",
+	}
+
+	res := Compare(42, "implement", a, b)
+	if !strings.Contains(res.PromptDiff, "+## Previous Check Failures") {
+		t.Errorf("prompt diff missing added section:\n%s", res.PromptDiff)
+	}
+	if !strings.Contains(res.PromptDiff, "--- attempt-1/prompt.md") {
+		t.Errorf("prompt diff missing labels:\n%s", res.PromptDiff)
+	}
+	if !strings.Contains(res.CodeDiff, "-+old") || !strings.Contains(res.CodeDiff, "++new") {
+		t.Errorf("code diff missing patch changes:\n%s", res.CodeDiff)
+	}
+	if len(res.FilesOnlyA) != 1 || res.FilesOnlyA[0] != "a.go" {
+		t.Errorf("FilesOnlyA = %v, want [a.go]", res.FilesOnlyA)
+	}
+	if len(res.FilesOnlyB) != 1 || res.FilesOnlyB[0] != "c.go" {
+		t.Errorf("FilesOnlyB = %v, want [c.go]", res.FilesOnlyB)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	store := pipeline.NewStore(t.TempDir())
+	if _, err := store.Create(pipeline.CreateOpts{Issue: 7, Title: "T", Branch: "b", Worktree: "/w", FirstStage: "implement"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_ = store.SavePrompt(7, "implement", 2, "prompt two")
+	_ = store.SaveStageOutcome(7, "implement", 2, &pipeline.StageOutcome{Status: "success"})
+	_ = store.SaveAttemptDiff(7, "implement", 2, "+patch")
+
+	side := Load(store, 7, "implement", 2)
+	if side.Attempt != 2 || side.Prompt != "prompt two" || side.Patch != "+patch" {
+		t.Errorf("unexpected side: %+v", side)
+	}
+	if side.Outcome == nil || side.Outcome.Status != "success" {
+		t.Errorf("outcome = %+v", side.Outcome)
+	}
+
+	missing := Load(store, 7, "implement", 9)
+	if missing.Prompt != "" || missing.Outcome != nil || missing.Patch != "" {
+		t.Errorf("expected empty side for missing attempt, got %+v", missing)
+	}
+}
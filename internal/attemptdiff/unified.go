@@ -0,0 +1,207 @@
+package attemptdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// maxLCSCells caps the size of the LCS table. Inputs whose differing middle
+// section is larger than this are reported as a full replacement instead.
+const maxLCSCells = 4_000_000
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	text string
+	// 1-based line numbers in the old and new text (0 when not applicable).
+	oldLine, newLine int
+}
+
+// Unified returns a unified diff between from and to, labelled with the given
+// names. It returns "" when the inputs are identical.
+func Unified(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks(ops) {
+		writeHunk(&sb, ops[h[0]:h[1]])
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a line-level edit script turning a into b.
+func diffLines(a, b []string) []op {
+	// Trim the common prefix and suffix so the LCS table only covers the
+	// region that actually changed.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var ops []op
+	for i := 0; i < pre; i++ {
+		ops = append(ops, op{kind: opEqual, text: a[i], oldLine: i + 1, newLine: i + 1})
+	}
+
+	midA := a[pre : len(a)-suf]
+	midB := b[pre : len(b)-suf]
+	ops = append(ops, diffMiddle(midA, midB, pre, pre)...)
+
+	for i := 0; i < suf; i++ {
+		ai := len(a) - suf + i
+		bi := len(b) - suf + i
+		ops = append(ops, op{kind: opEqual, text: a[ai], oldLine: ai + 1, newLine: bi + 1})
+	}
+	return ops
+}
+
+// diffMiddle runs a classic LCS over the changed region. offA and offB are the
+// number of lines preceding the region in each input.
+func diffMiddle(a, b []string, offA, offB int) []op {
+	n, m := len(a), len(b)
+	if n*m > maxLCSCells {
+		var ops []op
+		for i, l := range a {
+			ops = append(ops, op{kind: opDelete, text: l, oldLine: offA + i + 1})
+		}
+		for j, l := range b {
+			ops = append(ops, op{kind: opInsert, text: l, newLine: offB + j + 1})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{kind: opEqual, text: a[i], oldLine: offA + i + 1, newLine: offB + j + 1})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{kind: opDelete, text: a[i], oldLine: offA + i + 1})
+			i++
+		default:
+			ops = append(ops, op{kind: opInsert, text: b[j], newLine: offB + j + 1})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{kind: opDelete, text: a[i], oldLine: offA + i + 1})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{kind: opInsert, text: b[j], newLine: offB + j + 1})
+	}
+	return ops
+}
+
+// hunks groups the edit script into [start, end) ranges, each containing at
+// least one change plus up to contextLines of surrounding equal lines.
+func hunks(ops []op) [][2]int {
+	var result [][2]int
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			continue
+		}
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		// Extend while the gap between changes is small enough to share context.
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end += contextLines
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+		// Merge with the previous hunk when their context overlaps.
+		if len(result) > 0 && start <= result[len(result)-1][1] {
+			result[len(result)-1][1] = end
+		} else {
+			result = append(result, [2]int{start, end})
+		}
+		i = end
+	}
+	return result
+}
+
+func writeHunk(sb *strings.Builder, ops []op) {
+	oldStart, newStart := 0, 0
+	oldCount, newCount := 0, 0
+	for _, o := range ops {
+		if o.kind != opInsert {
+			if oldStart == 0 {
+				oldStart = o.oldLine
+			}
+			oldCount++
+		}
+		if o.kind != opDelete {
+			if newStart == 0 {
+				newStart = o.newLine
+			}
+			newCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, o := range ops {
+		sb.WriteByte(byte(o.kind))
+		sb.WriteString(o.text)
+		sb.WriteByte('\n')
+	}
+}
@@ -0,0 +1,319 @@
+// Package cgroup caps the memory, CPU and process count of check commands and
+// agent sessions with cgroups v2, and reports what they consumed.
+//
+// Groups are created under a delegated parent: $FACTORY_CGROUP when set,
+// otherwise the cgroup factory itself runs in (e.g. a systemd service with
+// Delegate=yes). Where cgroups v2 is unavailable, commands run without limits
+// and usage falls back to what the kernel reports for the waited-for process.
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+// Root is where the cgroup v2 hierarchy is mounted.
+const Root = "/sys/fs/cgroup"
+
+// ParentEnv names the delegated cgroup to create groups under, as a path
+// below Root or an absolute path.
+const ParentEnv = "FACTORY_CGROUP"
+
+// controllers are the ones a parent must delegate for limits to apply.
+var controllers = []string{"memory", "cpu", "pids"}
+
+// supervisor is the leaf a delegated parent's own processes move to.
+const supervisor = "supervisor"
+
+// cpuPeriod is the cpu.max period in microseconds.
+const cpuPeriod = 100000
+
+// Limits caps a process tree. Zero fields are unlimited.
+type Limits struct {
+	MemoryBytes int64
+	CPUs        float64 // cores
+	Pids        int
+}
+
+// IsZero reports whether l limits nothing.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// LimitsFrom converts configured limits; nil or unparsable fields are
+// unlimited, since config validation has already reported them.
+func LimitsFrom(l *config.Limits) Limits {
+	if l == nil {
+		return Limits{}
+	}
+	mem, _ := l.MemoryBytes()
+	cpus, _ := l.CPUCores()
+	return Limits{MemoryBytes: mem, CPUs: cpus, Pids: l.Pids}
+}
+
+// Usage is what a process tree consumed.
+type Usage struct {
+	PeakMemoryBytes int64
+	CPUTime         time.Duration
+	OOMKilled       bool // a process was killed for exceeding the memory limit
+}
+
+// Group is the path of a cgroup directory.
+type Group string
+
+var (
+	parentOnce sync.Once
+	parentDir  string
+	parentErr  error
+)
+
+// Wanted reports whether a command with limits should get its own cgroup:
+// when it has limits, or when $FACTORY_CGROUP asks for accounting of every
+// command. Otherwise the hierarchy is left alone and usage comes from
+// RusageOf.
+func Wanted(limits Limits) bool {
+	return !limits.IsZero() || os.Getenv(ParentEnv) != ""
+}
+
+// Parent returns the cgroup groups are created under, enabling the
+// controllers factory needs on first use. The error says why limits cannot
+// be enforced on this host.
+func Parent() (string, error) {
+	parentOnce.Do(func() {
+		parentDir, parentErr = findParent()
+		if parentErr == nil {
+			parentErr = Delegate(parentDir)
+		}
+		if parentErr != nil {
+			parentErr = fmt.Errorf("cgroups v2 unavailable: %w", parentErr)
+		}
+	})
+	return parentDir, parentErr
+}
+
+// findParent locates $FACTORY_CGROUP or the cgroup this process is in. A
+// process started by factory inherits its supervisor leaf, whose parent is
+// the one factory delegated.
+func findParent() (string, error) {
+	if p := os.Getenv(ParentEnv); p != "" {
+		if !filepath.IsAbs(p) || !strings.HasPrefix(p, Root+"/") {
+			p = filepath.Join(Root, p)
+		}
+		return p, nil
+	}
+	if _, err := os.Stat(filepath.Join(Root, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%s is not a cgroup v2 mount", Root)
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			dir := filepath.Join(Root, path)
+			if filepath.Base(dir) == supervisor {
+				dir = filepath.Dir(dir)
+			}
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+// Delegate enables the memory, cpu and pids controllers for dir's children.
+// A cgroup with controllers enabled for its children may not hold processes
+// itself, so any in dir are first moved to a "supervisor" leaf.
+func Delegate(dir string) error {
+	available := fields(filepath.Join(dir, "cgroup.controllers"))
+	enabled := fields(filepath.Join(dir, "cgroup.subtree_control"))
+	var missing []string
+	for _, c := range controllers {
+		if !available[c] {
+			return fmt.Errorf("%s: %s controller not delegated", dir, c)
+		}
+		if !enabled[c] {
+			missing = append(missing, "+"+c)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if err := evacuate(dir); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(missing, " ")), 0o644); err != nil {
+		return fmt.Errorf("enable controllers in %s: %w", dir, err)
+	}
+	return nil
+}
+
+// evacuate moves the processes in dir to its supervisor leaf. The root
+// cgroup is exempt from the rule, so its processes stay where they are.
+func evacuate(dir string) error {
+	if filepath.Clean(dir) == Root {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return err
+	}
+	pids := strings.Fields(string(data))
+	if len(pids) == 0 {
+		return nil
+	}
+	leaf := Group(filepath.Join(dir, supervisor))
+	if err := os.Mkdir(string(leaf), 0o755); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("create %s: %w", leaf, err)
+	}
+	for _, p := range pids {
+		pid, err := strconv.Atoi(p)
+		if err != nil {
+			continue
+		}
+		// A process may exit between the read and the move.
+		if err := leaf.Add(pid); err != nil && processExists(pid) {
+			return fmt.Errorf("move process %d to %s: %w", pid, leaf, err)
+		}
+	}
+	return nil
+}
+
+func processExists(pid int) bool {
+	_, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	return err == nil
+}
+
+// fields returns the space-separated words of a cgroup file as a set.
+func fields(path string) map[string]bool {
+	set := make(map[string]bool)
+	data, _ := os.ReadFile(path)
+	for _, f := range strings.Fields(string(data)) {
+		set[f] = true
+	}
+	return set
+}
+
+// New creates the group name under parent with limits applied. An existing
+// group of that name is reused.
+func New(parent, name string, limits Limits) (Group, error) {
+	if name == "" || strings.ContainsAny(name, "/\x00") || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid cgroup name %q", name)
+	}
+	g := Group(filepath.Join(parent, name))
+	if err := os.Mkdir(string(g), 0o755); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("create cgroup: %w", err)
+	}
+	if err := g.SetLimits(limits); err != nil {
+		g.Remove()
+		return "", err
+	}
+	return g, nil
+}
+
+// SetLimits writes limits to the group's controller files.
+func (g Group) SetLimits(l Limits) error {
+	if l.MemoryBytes > 0 {
+		if err := g.write("memory.max", strconv.FormatInt(l.MemoryBytes, 10)); err != nil {
+			return err
+		}
+		// Without this the limit only pushes the tree into swap.
+		_ = g.write("memory.swap.max", "0")
+	}
+	if l.CPUs > 0 {
+		quota := max(int64(l.CPUs*cpuPeriod), 1000)
+		if err := g.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			return err
+		}
+	}
+	if l.Pids > 0 {
+		if err := g.write("pids.max", strconv.Itoa(l.Pids)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g Group) write(file, value string) error {
+	if err := os.WriteFile(filepath.Join(string(g), file), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("set %s: %w", file, err)
+	}
+	return nil
+}
+
+// Add moves process pid, and the children it starts from then on, into g.
+func (g Group) Add(pid int) error {
+	return g.write("cgroup.procs", strconv.Itoa(pid))
+}
+
+// Usage reads what the group has consumed so far. memory.peak needs Linux
+// 5.19 or later; on older kernels PeakMemoryBytes is 0.
+func (g Group) Usage() Usage {
+	var u Usage
+	if data, err := os.ReadFile(filepath.Join(string(g), "memory.peak")); err == nil {
+		u.PeakMemoryBytes, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+	if usec := statValue(filepath.Join(string(g), "cpu.stat"), "usage_usec"); usec > 0 {
+		u.CPUTime = time.Duration(usec) * time.Microsecond
+	}
+	u.OOMKilled = statValue(filepath.Join(string(g), "memory.events"), "oom_kill") > 0
+	return u
+}
+
+// statValue returns the value of key in a flat-keyed cgroup file.
+func statValue(path, key string) int64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), " ")
+		if ok && k == key {
+			n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// Kill kills every process left in the group. It needs Linux 5.14 or later.
+func (g Group) Kill() {
+	_ = g.write("cgroup.kill", "1")
+}
+
+// Remove deletes the group once its processes have exited, waiting briefly
+// for ones that are still being torn down.
+func (g Group) Remove() error {
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.Remove(string(g)); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("remove cgroup: %w", err)
+}
+
+// Lookup returns the group name under the parent if that group exists. Unlike
+// Parent it does not delegate controllers, so looking up a group that was
+// never created leaves the hierarchy alone.
+func Lookup(name string) (Group, error) {
+	parent, err := findParent()
+	if err != nil {
+		return "", err
+	}
+	g := Group(filepath.Join(parent, name))
+	if _, err := os.Stat(string(g)); err != nil {
+		return "", err
+	}
+	return g, nil
+}
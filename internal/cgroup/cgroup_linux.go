@@ -0,0 +1,31 @@
+package cgroup
+
+import (
+	"os"
+	"syscall"
+)
+
+// Attach makes the process started with attr begin life in g, so none of its
+// children escape the limits. Call the returned function once it has started.
+func (g Group) Attach(attr *syscall.SysProcAttr) (func(), error) {
+	fd, err := syscall.Open(string(g), syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: string(g), Err: err}
+	}
+	attr.UseCgroupFD = true
+	attr.CgroupFD = fd
+	return func() { syscall.Close(fd) }, nil
+}
+
+// RusageOf returns the usage the kernel reported for a waited-for process
+// and the descendants it waited for, for when it ran outside a group.
+func RusageOf(ps *os.ProcessState) Usage {
+	if ps == nil {
+		return Usage{}
+	}
+	u := Usage{CPUTime: ps.UserTime() + ps.SystemTime()}
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		u.PeakMemoryBytes = ru.Maxrss * 1024 // kilobytes on Linux
+	}
+	return u
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package cgroup
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Attach is unsupported off Linux: cgroups are a Linux feature.
+func (g Group) Attach(attr *syscall.SysProcAttr) (func(), error) {
+	return nil, errors.New("cgroups require Linux")
+}
+
+// RusageOf returns the CPU time of a waited-for process and the descendants
+// it waited for. Peak memory is not reported off Linux.
+func RusageOf(ps *os.ProcessState) Usage {
+	if ps == nil {
+		return Usage{}
+	}
+	return Usage{CPUTime: ps.UserTime() + ps.SystemTime()}
+}
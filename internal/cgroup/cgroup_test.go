@@ -0,0 +1,120 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNew_WritesLimits(t *testing.T) {
+	parent := t.TempDir()
+	g, err := New(parent, "check-1", Limits{MemoryBytes: 512 << 20, CPUs: 1.5, Pids: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{
+		"memory.max":      "536870912",
+		"memory.swap.max": "0",
+		"cpu.max":         "150000 100000",
+		"pids.max":        "64",
+	} {
+		if got := readFile(t, filepath.Join(string(g), file)); got != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestNew_UnlimitedWritesNothing(t *testing.T) {
+	g, err := New(t.TempDir(), "check-1", Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(string(g)); len(entries) != 0 {
+		t.Errorf("expected no limit files, got %v", entries)
+	}
+}
+
+func TestNew_RejectsPathNames(t *testing.T) {
+	for _, name := range []string{"", "..", "a/b"} {
+		if _, err := New(t.TempDir(), name, Limits{}); err == nil {
+			t.Errorf("expected error for name %q", name)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	g := Group(t.TempDir())
+	os.WriteFile(filepath.Join(string(g), "memory.peak"), []byte("104857600\n"), 0o644)
+	os.WriteFile(filepath.Join(string(g), "cpu.stat"), []byte("usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n"), 0o644)
+	os.WriteFile(filepath.Join(string(g), "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0o644)
+
+	u := g.Usage()
+	if u.PeakMemoryBytes != 100<<20 || u.CPUTime != 2500*time.Millisecond || !u.OOMKilled {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+	if u := Group(t.TempDir()).Usage(); u != (Usage{}) {
+		t.Errorf("expected zero usage without accounting files, got %+v", u)
+	}
+}
+
+func TestDelegate(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("cpuset cpu io memory pids\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("cpu\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "cgroup.procs"), nil, 0o644)
+
+	if err := Delegate(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(dir, "cgroup.subtree_control")); got != "+memory +pids" {
+		t.Errorf("subtree_control = %q", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte("cpu pids\n"), 0o644)
+	if err := Delegate(dir); err == nil {
+		t.Error("expected an error when memory is not delegated")
+	}
+}
+
+func TestLimitsFrom(t *testing.T) {
+	got := LimitsFrom(&config.Limits{Memory: "2g", CPU: "0.5", Pids: 100})
+	if want := (Limits{MemoryBytes: 2 << 30, CPUs: 0.5, Pids: 100}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !LimitsFrom(nil).IsZero() {
+		t.Error("nil config limits should be unlimited")
+	}
+}
+
+func TestWanted(t *testing.T) {
+	t.Setenv(ParentEnv, "")
+	if Wanted(Limits{}) {
+		t.Error("a command without limits should not get a cgroup")
+	}
+	if !Wanted(Limits{Pids: 10}) {
+		t.Error("a command with limits should get a cgroup")
+	}
+	t.Setenv(ParentEnv, "factory.slice")
+	if !Wanted(Limits{}) {
+		t.Errorf("every command should get a cgroup when %s is set", ParentEnv)
+	}
+}
+
+func TestLookup_Missing(t *testing.T) {
+	t.Setenv(ParentEnv, t.TempDir())
+	if _, err := Lookup("session-none"); err == nil {
+		t.Error("expected an error for a group that does not exist")
+	}
+}
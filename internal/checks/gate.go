@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
 )
 
 // GateCheckResult holds the result of a single check within a gate run.
@@ -13,6 +15,7 @@ type GateCheckResult struct {
 	AutoFixed bool   `json:"auto_fixed,omitempty"`
 	Runs      int    `json:"runs"`
 	Summary   string `json:"summary,omitempty"`
+	Scope     string `json:"scope,omitempty"` // how an impact-narrowed check was narrowed
 }
 
 // GateFailure describes a remaining failure after a gate run.
@@ -29,6 +32,7 @@ type GateResult struct {
 	Passed            bool                   `json:"passed"`
 	Checks            []GateCheckResult      `json:"checks"`
 	RemainingFailures map[string]GateFailure `json:"remaining_failures,omitempty"`
+	Skipped           []SkippedCheck         `json:"skipped,omitempty"` // checks the change could not affect
 }
 
 // JSON returns the gate result as indented JSON.
@@ -49,6 +53,7 @@ type GateOpts struct {
 	Worktree   string
 	Checks     []GateCheckConfig
 	Continue   bool // run all checks even if some fail
+	Skipped    []SkippedCheck // checks left out by SelectChecks, recorded in the result
 }
 
 // GateCheckConfig holds the config for a single check within a gate.
@@ -59,6 +64,11 @@ type GateCheckConfig struct {
 	Timeout    time.Duration
 	AutoFix    bool
 	FixCommand string
+	Limits     cgroup.Limits
+	Paths      []string // run only when a changed file matches; see SelectChecks
+	Impact     string   // "go": run only for affected packages; see SelectChecks
+	Scope      string   // set by SelectChecks when it narrowed Command
+	Coverage   CoverageOptions
 }
 
 // RunGate executes all checks for a stage and returns a structured result.
@@ -71,6 +81,7 @@ func (r *Runner) RunGate(dir string, opts GateOpts) (*GateResult, []*Result, err
 		Passed:            true,
 		Checks:            []GateCheckResult{},
 		RemainingFailures: make(map[string]GateFailure),
+		Skipped:           opts.Skipped,
 	}
 
 	var allResults []*Result
@@ -83,6 +94,8 @@ func (r *Runner) RunGate(dir string, opts GateOpts) (*GateResult, []*Result, err
 			Timeout:    chk.Timeout,
 			AutoFix:    chk.AutoFix,
 			FixCommand: chk.FixCommand,
+			Limits:     chk.Limits,
+			Coverage:   chk.Coverage,
 		}
 
 		result, err := r.Run(dir, cfg)
@@ -102,6 +115,7 @@ func (r *Runner) RunGate(dir string, opts GateOpts) (*GateResult, []*Result, err
 			AutoFixed: result.AutoFixed,
 			Runs:      runs,
 			Summary:   result.Summary,
+			Scope:     chk.Scope,
 		}
 		gate.Checks = append(gate.Checks, gc)
 
@@ -268,3 +268,20 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestRunGate_RecordsSkipped(t *testing.T) {
+	runner := NewRunner(&mockCmd{results: []mockResult{{Stdout: "ok"}}})
+	gate, _, err := runner.RunGate(t.TempDir(), GateOpts{
+		Checks:  []GateCheckConfig{{Name: "lint", Command: "lint", Scope: "2 affected package(s)"}},
+		Skipped: []SkippedCheck{{Check: "web", Reason: "no changed file matches its paths"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gate.Passed || len(gate.Skipped) != 1 || gate.Skipped[0].Check != "web" {
+		t.Errorf("gate = %+v, want passed with web skipped", gate)
+	}
+	if gate.Checks[0].Scope != "2 affected package(s)" {
+		t.Errorf("scope = %q, want it carried into the result", gate.Checks[0].Scope)
+	}
+}
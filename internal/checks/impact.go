@@ -0,0 +1,219 @@
+package checks
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SkippedCheck is a check a gate left out because the change cannot affect it.
+type SkippedCheck struct {
+	Check  string `json:"check"`
+	Reason string `json:"reason"`
+}
+
+// goModuleFiles change what every package builds against.
+var goModuleFiles = map[string]bool{"go.mod": true, "go.sum": true, "go.work": true, "go.work.sum": true}
+
+// SelectChecks returns the checks that files, the paths changed in dir,
+// can affect. A check with paths runs only when a file matches one of
+// them. A check with impact "go" runs only when a Go package's code or
+// tests depend on a changed package, and "./..." in its command is replaced
+// with those packages. When the Go packages cannot be worked out, e.g. a
+// file lies outside every package, the check runs unchanged.
+func SelectChecks(dir string, files []string, cks []GateCheckConfig) ([]GateCheckConfig, []SkippedCheck) {
+	var (
+		run     []GateCheckConfig
+		skipped []SkippedCheck
+		goPkgs  []string
+		goErr   error
+		goDone  bool
+	)
+	for _, chk := range cks {
+		if len(chk.Paths) > 0 && !anyMatch(chk.Paths, files) {
+			skipped = append(skipped, SkippedCheck{Check: chk.Name, Reason: "no changed file matches its paths"})
+			continue
+		}
+		if chk.Impact == "go" && !touchesGoModule(files) {
+			if !goDone {
+				goPkgs, goErr = AffectedGoPackages(dir, files)
+				goDone = true
+			}
+			switch {
+			case goErr != nil:
+				chk.Scope = fmt.Sprintf("all packages (%v)", goErr)
+			case len(goPkgs) == 0:
+				skipped = append(skipped, SkippedCheck{Check: chk.Name, Reason: "no Go package affected"})
+				continue
+			case strings.Contains(chk.Command, "./..."):
+				chk.Command = strings.ReplaceAll(chk.Command, "./...", strings.Join(goPkgs, " "))
+				chk.Scope = fmt.Sprintf("%d affected package(s)", len(goPkgs))
+			}
+		}
+		run = append(run, chk)
+	}
+	return run, skipped
+}
+
+func touchesGoModule(files []string) bool {
+	for _, f := range files {
+		if goModuleFiles[path.Base(f)] {
+			return true
+		}
+	}
+	return false
+}
+
+func anyMatch(patterns, files []string) bool {
+	for _, f := range files {
+		for _, p := range patterns {
+			if MatchPath(p, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchPath reports whether the slash-separated file matches pattern. A
+// pattern without a slash matches the file's base name in any directory
+// ("*.go", "go.mod"). Otherwise it is matched against the whole path, with
+// "**" standing for any number of directories ("web/**", "**/testdata/*").
+func MatchPath(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	file = strings.TrimPrefix(filepath.ToSlash(file), "./")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// goListFormat prints, per package, its import path, directory, transitive
+// dependencies, and the imports of its tests.
+const goListFormat = `{{.ImportPath}}	{{.Dir}}	{{join .Deps " "}}	{{join .TestImports " "}} {{join .XTestImports " "}}`
+
+// AffectedGoPackages returns the import paths of the packages in dir's
+// module whose code or tests depend on a changed package, using `go list`.
+// A changed file belongs to the package in its directory or, failing that,
+// the nearest directory above it, so testdata, golden files and //go:embed
+// assets count for the package that reads them. It returns an error when a
+// file belongs to no package, and nil when files is empty.
+func AffectedGoPackages(dir string, files []string) ([]string, error) {
+	// Compare with the directories go list reports, which have symlinks resolved.
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	cmd := exec.Command("go", "list", "-e", "-f", goListFormat, "./...")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	type goPkg struct {
+		importPath  string
+		deps, tests []string
+	}
+	var pkgs []goPkg
+	pkgDirs := make(map[string]string) // directory -> import path
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		p := goPkg{importPath: fields[0], deps: strings.Fields(fields[2]), tests: strings.Fields(fields[3])}
+		pkgs = append(pkgs, p)
+		pkgDirs[fields[1]] = p.importPath
+	}
+	changed := make(map[string]bool)
+	for _, f := range files {
+		fdir := filepath.Join(dir, filepath.Dir(filepath.FromSlash(f)))
+		if _, ok := pkgDirs[fdir]; !ok && strings.HasSuffix(f, ".go") {
+			if _, err := os.Stat(fdir); err != nil {
+				return nil, fmt.Errorf("package %s was removed", fdir)
+			}
+		}
+		pkg, ok := owningPackage(dir, fdir, pkgDirs)
+		if !ok {
+			return nil, fmt.Errorf("no package contains %s", f)
+		}
+		changed[pkg] = true
+	}
+
+	// Packages whose code depends on a change, then those whose tests import one.
+	depends := make(map[string]bool)
+	for _, p := range pkgs {
+		if changed[p.importPath] || anyIn(p.deps, changed) {
+			depends[p.importPath] = true
+		}
+	}
+	var affected []string
+	for _, p := range pkgs {
+		if depends[p.importPath] || anyIn(p.tests, depends) || anyIn(p.tests, changed) {
+			affected = append(affected, p.importPath)
+		}
+	}
+	sort.Strings(affected)
+	return affected, nil
+}
+
+// owningPackage returns the package in fdir or the nearest directory above
+// it, stopping at the module root.
+func owningPackage(root, fdir string, pkgDirs map[string]string) (string, bool) {
+	for d := fdir; ; d = filepath.Dir(d) {
+		if pkg, ok := pkgDirs[d]; ok {
+			return pkg, true
+		}
+		if d == root || !strings.HasPrefix(d, root+string(filepath.Separator)) {
+			return "", false
+		}
+	}
+}
+
+func anyIn(items []string, set map[string]bool) bool {
+	for _, it := range items {
+		if set[it] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package checks
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, file string
+		want          bool
+	}{
+		{"*.go", "internal/checks/gate.go", true},
+		{"*.go", "README.md", false},
+		{"go.mod", "go.mod", true},
+		{"go.mod", "tools/go.mod", true},
+		{"web/**", "web/src/app.tsx", true},
+		{"web/**", "web", true},
+		{"web/**", "internal/web/server.go", false},
+		{"**/testdata/*", "internal/qa/testdata/page.html", true},
+		{"**/testdata/*", "testdata/page.html", true},
+		{"docs/*.md", "docs/guide.md", true},
+		{"docs/*.md", "docs/api/guide.md", false},
+		{"./internal/**/*.go", "internal/checks/gate.go", true},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestSelectChecks_Paths(t *testing.T) {
+	cks := []GateCheckConfig{
+		{Name: "lint", Command: "golangci-lint run"},
+		{Name: "web", Command: "npm test", Paths: []string{"web/**"}},
+		{Name: "docs", Command: "vale docs", Paths: []string{"docs/**", "*.md"}},
+	}
+	run, skipped := SelectChecks(t.TempDir(), []string{"README.md", "internal/x.go"}, cks)
+
+	var names []string
+	for _, c := range run {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"lint", "docs"}) {
+		t.Errorf("run = %v, want lint and docs", names)
+	}
+	if len(skipped) != 1 || skipped[0].Check != "web" || skipped[0].Reason == "" {
+		t.Errorf("skipped = %+v, want web with a reason", skipped)
+	}
+}
+
+// writeModule lays out a module where api imports store, and web's tests
+// import api.
+func writeModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":               "module example.com/m\n\ngo 1.21\n",
+		"store/store.go":       "package store\n\nfunc Get() int { return 1 }\n",
+		"api/api.go":           "package api\n\nimport \"example.com/m/store\"\n\nfunc Get() int { return store.Get() }\n",
+		"web/web.go":           "package web\n",
+		"web/web_test.go":      "package web\n\nimport (\n\t\"testing\"\n\n\t\"example.com/m/api\"\n)\n\nfunc TestGet(t *testing.T) { _ = api.Get() }\n",
+		"tools/tools.go":       "package tools\n",
+		"store/testdata/x.txt": "fixture\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestAffectedGoPackages(t *testing.T) {
+	dir := writeModule(t)
+
+	got, err := AffectedGoPackages(dir, []string{"store/store.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com/m/api", "example.com/m/store", "example.com/m/web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("store change: got %v, want %v", got, want)
+	}
+
+	got, err = AffectedGoPackages(dir, []string{"web/web.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"example.com/m/web"}) {
+		t.Errorf("web change: got %v", got)
+	}
+
+	// Fixtures and embedded assets belong to the package above them.
+	for _, f := range []string{"store/testdata/x.txt", "web/templates/index.html"} {
+		got, err = AffectedGoPackages(dir, []string{f})
+		if err != nil {
+			t.Fatalf("%s: %v", f, err)
+		}
+		if f == "store/testdata/x.txt" && !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", f, got, want)
+		}
+		if f == "web/templates/index.html" && !reflect.DeepEqual(got, []string{"example.com/m/web"}) {
+			t.Errorf("%s: got %v", f, got)
+		}
+	}
+
+	if _, err := AffectedGoPackages(dir, []string{"README.md"}); err == nil {
+		t.Error("expected an error for a file outside every package")
+	}
+	if got, err := AffectedGoPackages(dir, nil); err != nil || got != nil {
+		t.Errorf("no change: got %v, %v", got, err)
+	}
+
+	if _, err := AffectedGoPackages(dir, []string{"gone/gone.go"}); err == nil {
+		t.Error("expected an error for a removed package")
+	}
+}
+
+func TestSelectChecks_GoImpact(t *testing.T) {
+	dir := writeModule(t)
+	test := GateCheckConfig{Name: "test", Command: "go test -race ./...", Impact: "go"}
+
+	run, skipped := SelectChecks(dir, []string{"api/api.go"}, []GateCheckConfig{test})
+	if len(skipped) != 0 || len(run) != 1 {
+		t.Fatalf("run = %+v skipped = %+v", run, skipped)
+	}
+	if run[0].Command != "go test -race example.com/m/api example.com/m/web" {
+		t.Errorf("command = %q", run[0].Command)
+	}
+	if run[0].Scope != "2 affected package(s)" {
+		t.Errorf("scope = %q", run[0].Scope)
+	}
+
+	run, skipped = SelectChecks(dir, []string{"docs/guide.md"}, []GateCheckConfig{test})
+	if len(skipped) != 0 || run[0].Command != test.Command || !strings.HasPrefix(run[0].Scope, "all packages") {
+		t.Errorf("a file outside every package should run the check unnarrowed: run = %+v skipped = %+v", run, skipped)
+	}
+
+	run, _ = SelectChecks(dir, []string{"web/templates/index.html"}, []GateCheckConfig{test})
+	if run[0].Command != "go test -race example.com/m/web" {
+		t.Errorf("embedded asset change: command = %q", run[0].Command)
+	}
+
+	run, _ = SelectChecks(dir, []string{"go.mod", "api/api.go"}, []GateCheckConfig{test})
+	if run[0].Command != test.Command {
+		t.Errorf("go.mod change should run everything, got %q", run[0].Command)
+	}
+}
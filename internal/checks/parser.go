@@ -11,3 +11,11 @@ type ParseResult struct {
 type Parser interface {
 	Parse(stdout string, stderr string, exitCode int) ParseResult
 }
+
+// WorktreeParser is a Parser that also reads the worktree the check ran in,
+// e.g. a report file the command wrote. The runner prefers ParseWorktree
+// when a parser implements it.
+type WorktreeParser interface {
+	Parser
+	ParseWorktree(dir string, cfg CheckConfig, stdout string, stderr string, exitCode int) ParseResult
+}
@@ -0,0 +1,458 @@
+package checks
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+// CoverageOptions mirrors config.CoverageConfig for the coverage parser.
+type CoverageOptions struct {
+	Report    string  // file the command writes, relative to the worktree; "" reads stdout
+	Format    string  // "go", "lcov" or "cobertura"; "" detects it
+	Threshold float64 // percent of changed lines that must be covered
+	BaseRef   string  // ref the branch is diffed against, e.g. origin/develop; "" tries local main, then master
+}
+
+// CoverageFrom converts a check's coverage config, filling in defaults.
+// baseRef is the pipeline's base ref (PipelineState.BaseRef).
+func CoverageFrom(c *config.CoverageConfig, baseRef string) CoverageOptions {
+	opts := CoverageOptions{Threshold: c.MinChanged(), BaseRef: baseRef}
+	if c != nil {
+		opts.Report, opts.Format = c.Report, c.Format
+	}
+	return opts
+}
+
+// DiffSource provides a worktree's diff against its base branch.
+// context.GitRunner satisfies it.
+type DiffSource interface {
+	Diff(dir, base string) (string, error)
+}
+
+// CoverageParser reads a Go coverprofile, lcov or Cobertura XML report and
+// measures coverage of the lines the branch added or changed. It fails
+// when that is below the check's threshold and lists the uncovered lines.
+type CoverageParser struct {
+	git DiffSource
+}
+
+// NewCoverageParser creates a CoverageParser that diffs with git.
+func NewCoverageParser(git DiffSource) *CoverageParser {
+	return &CoverageParser{git: git}
+}
+
+// maxUncoveredFiles caps how many files the summary lists; the findings
+// have them all.
+const maxUncoveredFiles = 10
+
+type coverageGap struct {
+	File  string `json:"file"`
+	Lines string `json:"lines"` // e.g. "12-15,20"
+	Count int    `json:"count"`
+}
+
+type coverageResult struct {
+	Format       string        `json:"format"`
+	Threshold    float64       `json:"threshold"`
+	ChangedLines int           `json:"changed_lines"` // changed lines the report covers or could cover
+	Covered      int           `json:"covered"`
+	Percent      float64       `json:"percent"`
+	TotalPercent float64       `json:"total_percent"` // whole report
+	Uncovered    []coverageGap `json:"uncovered,omitempty"`
+}
+
+// Parse measures coverage for the current directory with the default threshold.
+func (p *CoverageParser) Parse(stdout string, stderr string, exitCode int) ParseResult {
+	return p.ParseWorktree(".", CheckConfig{Coverage: CoverageFrom(nil, "")}, stdout, stderr, exitCode)
+}
+
+// ParseWorktree measures coverage of the lines changed in dir.
+func (p *CoverageParser) ParseWorktree(dir string, cfg CheckConfig, stdout string, stderr string, exitCode int) ParseResult {
+	if exitCode != 0 {
+		// The tests failed; their output matters more than the coverage.
+		return (&GenericParser{}).Parse(stdout, stderr, exitCode)
+	}
+	opts := cfg.Coverage
+	fail := func(format string, args ...interface{}) ParseResult {
+		msg := fmt.Sprintf(format, args...)
+		return ParseResult{Passed: false, Summary: msg, Findings: msg}
+	}
+
+	report := stdout
+	if opts.Report != "" {
+		data, err := os.ReadFile(filepath.Join(dir, opts.Report))
+		if err != nil {
+			return fail("coverage report: %v", err)
+		}
+		report = string(data)
+	}
+	format := opts.Format
+	if format == "" {
+		format = detectCoverageFormat(report)
+	}
+	profile, err := parseCoverage(dir, format, report)
+	if err != nil {
+		return fail("coverage report: %v", err)
+	}
+
+	diff, err := p.git.Diff(dir, opts.BaseRef)
+	if err != nil {
+		return fail("coverage: diff against the base branch: %v", err)
+	}
+	result := measureCoverage(profile, ChangedLines(diff))
+	result.Format = format
+	result.Threshold = opts.Threshold
+
+	passed := result.ChangedLines == 0 || result.Percent >= opts.Threshold
+	return ParseResult{Passed: passed, Summary: coverageSummary(result), Findings: result}
+}
+
+func coverageSummary(r coverageResult) string {
+	if r.ChangedLines == 0 {
+		return fmt.Sprintf("no changed lines to cover; total %.1f%%", r.TotalPercent)
+	}
+	summary := fmt.Sprintf("changed lines %.1f%% covered (%d of %d, need %g%%); total %.1f%%",
+		r.Percent, r.Covered, r.ChangedLines, r.Threshold, r.TotalPercent)
+	if r.Percent >= r.Threshold || len(r.Uncovered) == 0 {
+		return summary
+	}
+	var gaps []string
+	for i, g := range r.Uncovered {
+		if i == maxUncoveredFiles {
+			gaps = append(gaps, fmt.Sprintf("and %d more files", len(r.Uncovered)-i))
+			break
+		}
+		gaps = append(gaps, g.File+":"+g.Lines)
+	}
+	return summary + "; add tests for " + strings.Join(gaps, ", ")
+}
+
+// lineCoverage maps a line number to whether any test executed it. Lines
+// with no code are absent.
+type lineCoverage map[int]bool
+
+func (lc lineCoverage) mark(line int, covered bool) {
+	lc[line] = lc[line] || covered
+}
+
+func detectCoverageFormat(report string) string {
+	trimmed := strings.TrimSpace(report)
+	switch {
+	case strings.HasPrefix(trimmed, "mode:"):
+		return "go"
+	case strings.HasPrefix(trimmed, "<?xml") || strings.HasPrefix(trimmed, "<coverage") || strings.Contains(trimmed, "<!DOCTYPE coverage"):
+		return "cobertura"
+	default:
+		return "lcov"
+	}
+}
+
+// parseCoverage returns per-line coverage keyed by slash-separated path,
+// relative to dir where the report allows.
+func parseCoverage(dir, format, report string) (map[string]lineCoverage, error) {
+	var (
+		profile map[string]lineCoverage
+		err     error
+	)
+	switch format {
+	case "go":
+		profile, err = parseGoCoverage(report, goModulePath(dir))
+	case "lcov":
+		profile, err = parseLcov(dir, report)
+	case "cobertura":
+		profile, err = parseCobertura(dir, report)
+	default:
+		return nil, fmt.Errorf("unrecognized format %q", format)
+	}
+	if err == nil && len(profile) == 0 {
+		err = fmt.Errorf("no files in %s report", format)
+	}
+	return profile, err
+}
+
+// parseGoCoverage reads a coverprofile. Its file names are import paths,
+// which become module-relative by removing modulePath.
+func parseGoCoverage(report, modulePath string) (map[string]lineCoverage, error) {
+	profile := make(map[string]lineCoverage)
+	sc := bufio.NewScanner(strings.NewReader(report))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// name.go:startLine.startCol,endLine.endCol numStmts count
+		name, block, ok := strings.Cut(line, ":")
+		fields := strings.Fields(block)
+		if !ok || len(fields) != 3 {
+			return nil, fmt.Errorf("malformed coverprofile line %q", line)
+		}
+		start, end, ok := strings.Cut(fields[0], ",")
+		startLine, err1 := strconv.Atoi(strings.Split(start, ".")[0])
+		endLine, err2 := strconv.Atoi(strings.Split(end, ".")[0])
+		count, err3 := strconv.ParseInt(fields[2], 10, 64)
+		if !ok || err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("malformed coverprofile line %q", line)
+		}
+		if modulePath != "" {
+			name = strings.TrimPrefix(name, modulePath+"/")
+		}
+		lc := profile[name]
+		if lc == nil {
+			lc = make(lineCoverage)
+			profile[name] = lc
+		}
+		for l := startLine; l <= endLine; l++ {
+			lc.mark(l, count > 0)
+		}
+	}
+	return profile, sc.Err()
+}
+
+// parseLcov reads SF (source file) and DA (line hits) records.
+func parseLcov(dir, report string) (map[string]lineCoverage, error) {
+	profile := make(map[string]lineCoverage)
+	var lc lineCoverage
+	sc := bufio.NewScanner(strings.NewReader(report))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			name := reportPath(dir, strings.TrimPrefix(line, "SF:"))
+			if lc = profile[name]; lc == nil {
+				lc = make(lineCoverage)
+				profile[name] = lc
+			}
+		case strings.HasPrefix(line, "DA:") && lc != nil:
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("malformed lcov line %q", line)
+			}
+			n, err1 := strconv.Atoi(fields[0])
+			hits, err2 := strconv.ParseFloat(fields[1], 64) // some tools write "1.0"
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("malformed lcov line %q", line)
+			}
+			lc.mark(n, hits > 0)
+		case line == "end_of_record":
+			lc = nil
+		}
+	}
+	return profile, sc.Err()
+}
+
+type coberturaReport struct {
+	Sources []string         `xml:"sources>source"`
+	Classes []coberturaClass `xml:"packages>package>classes>class"`
+}
+
+type coberturaClass struct {
+	Filename string `xml:"filename,attr"`
+	Lines    []struct {
+		Number int     `xml:"number,attr"`
+		Hits   float64 `xml:"hits,attr"`
+	} `xml:"lines>line"`
+}
+
+// parseCobertura reads class line hits. Class file names are relative to
+// one of the report's sources.
+func parseCobertura(dir, report string) (map[string]lineCoverage, error) {
+	var r coberturaReport
+	if err := xml.Unmarshal([]byte(report), &r); err != nil {
+		return nil, fmt.Errorf("parse cobertura XML: %w", err)
+	}
+	profile := make(map[string]lineCoverage)
+	for _, c := range r.Classes {
+		name := reportPath(dir, c.Filename)
+		for _, src := range r.Sources {
+			p := filepath.Join(strings.TrimSpace(src), c.Filename)
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			if _, err := os.Stat(p); err == nil {
+				name = reportPath(dir, p)
+				break
+			}
+		}
+		lc := profile[name]
+		if lc == nil {
+			lc = make(lineCoverage)
+			profile[name] = lc
+		}
+		for _, l := range c.Lines {
+			lc.mark(l.Number, l.Hits > 0)
+		}
+	}
+	return profile, nil
+}
+
+// reportPath makes a report's file name relative to dir when it is inside it.
+func reportPath(dir, name string) string {
+	name = filepath.Clean(name)
+	if filepath.IsAbs(name) {
+		absDir, _ := filepath.Abs(dir)
+		if rel, err := filepath.Rel(absDir, name); err == nil && filepath.IsLocal(rel) {
+			name = rel
+		} else if resolved, err := filepath.EvalSymlinks(absDir); err == nil {
+			if rel, err := filepath.Rel(resolved, name); err == nil && filepath.IsLocal(rel) {
+				name = rel
+			}
+		}
+	}
+	return filepath.ToSlash(name)
+}
+
+// goModulePath returns the module path declared in dir's go.mod, or "".
+func goModulePath(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+// ChangedLines returns the line numbers a unified diff adds or changes,
+// by new-file path. Deleted files and removed lines are not included.
+func ChangedLines(diff string) map[string][]int {
+	changed := make(map[string][]int)
+	var (
+		file    string
+		newLine int
+		inHunk  bool
+	)
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file, inHunk = "", false
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			}
+		case strings.HasPrefix(line, "@@ "):
+			// @@ -old,count +new,count @@
+			inHunk = false
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				continue
+			}
+			start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+			n, err := strconv.Atoi(start)
+			if err != nil {
+				continue
+			}
+			newLine, inHunk = n, true
+		case !inHunk:
+		case strings.HasPrefix(line, "+"):
+			if file != "" {
+				changed[file] = append(changed[file], newLine)
+			}
+			newLine++
+		case strings.HasPrefix(line, " "):
+			newLine++
+		}
+	}
+	return changed
+}
+
+// measureCoverage compares the changed lines with the profile. Changed
+// lines the report has no entry for (comments, declarations, files it does
+// not cover) are left out.
+func measureCoverage(profile map[string]lineCoverage, changed map[string][]int) coverageResult {
+	var r coverageResult
+	total, covered := 0, 0
+	for _, lc := range profile {
+		for _, c := range lc {
+			total++
+			if c {
+				covered++
+			}
+		}
+	}
+	if total > 0 {
+		r.TotalPercent = float64(covered) * 100 / float64(total)
+	}
+
+	files := make([]string, 0, len(changed))
+	for f := range changed {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		lc := lookupCoverage(profile, f)
+		if lc == nil {
+			continue
+		}
+		var missed []int
+		for _, n := range changed[f] {
+			c, ok := lc[n]
+			if !ok {
+				continue
+			}
+			r.ChangedLines++
+			if c {
+				r.Covered++
+			} else {
+				missed = append(missed, n)
+			}
+		}
+		if len(missed) > 0 {
+			r.Uncovered = append(r.Uncovered, coverageGap{File: f, Lines: lineRanges(missed), Count: len(missed)})
+		}
+	}
+	if r.ChangedLines > 0 {
+		r.Percent = float64(r.Covered) * 100 / float64(r.ChangedLines)
+	}
+	return r
+}
+
+// lookupCoverage finds file in the profile. Reports whose paths have a
+// different root, such as a subdirectory the tests ran in, match when one
+// path ends with the other and no other entry does.
+func lookupCoverage(profile map[string]lineCoverage, file string) lineCoverage {
+	if lc, ok := profile[file]; ok {
+		return lc
+	}
+	var found lineCoverage
+	for name, lc := range profile {
+		if strings.HasSuffix(name, "/"+file) || strings.HasSuffix(file, "/"+name) {
+			if found != nil {
+				return nil // ambiguous
+			}
+			found = lc
+		}
+	}
+	return found
+}
+
+// lineRanges renders sorted line numbers compactly, e.g. "12-15,20".
+func lineRanges(lines []int) string {
+	var parts []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(lines[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
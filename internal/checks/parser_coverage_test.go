@@ -0,0 +1,190 @@
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeDiff struct {
+	diff     string
+	err      error
+	wantBase string // when set, any other base is an error
+}
+
+func (f fakeDiff) Diff(dir, base string) (string, error) {
+	if f.wantBase != "" && base != f.wantBase {
+		return "", fmt.Errorf("diff against %q, want %q", base, f.wantBase)
+	}
+	return f.diff, f.err
+}
+
+// storeDiff adds lines 10-13 to internal/store/store.go and deletes util.go.
+const storeDiff = `diff --git a/internal/store/store.go b/internal/store/store.go
+index 1111111..2222222 100644
+--- a/internal/store/store.go
++++ b/internal/store/store.go
+@@ -8,3 +8,7 @@ func Open() {
+ 	a := 1
+ 	b := 2
++	if a > b {
++		return
++	}
++	// done
+ }
+diff --git a/util.go b/util.go
+deleted file mode 100644
+--- a/util.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package x
+-func f() {}`
+
+func TestChangedLines(t *testing.T) {
+	got := ChangedLines(storeDiff)
+	want := map[string][]int{"internal/store/store.go": {10, 11, 12, 13}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedLines = %v, want %v", got, want)
+	}
+}
+
+func coverageDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestCoverageParser_GoProfile(t *testing.T) {
+	dir := coverageDir(t)
+	// Lines 10-12 are one block that never ran; line 13 is a comment.
+	profile := "mode: set\n" +
+		"example.com/app/internal/store/store.go:8.2,9.9 2 1\n" +
+		"example.com/app/internal/store/store.go:10.2,12.3 1 0\n"
+	p := NewCoverageParser(fakeDiff{diff: storeDiff})
+	r := p.ParseWorktree(dir, CheckConfig{Coverage: CoverageOptions{Threshold: 80}}, profile, "", 0)
+	if r.Passed {
+		t.Fatal("expected the check to fail with no changed lines covered")
+	}
+	res := r.Findings.(coverageResult)
+	if res.Format != "go" || res.ChangedLines != 3 || res.Covered != 0 {
+		t.Errorf("result = %+v, want 3 uncovered changed lines of a go profile", res)
+	}
+	if len(res.Uncovered) != 1 || res.Uncovered[0].File != "internal/store/store.go" || res.Uncovered[0].Lines != "10-12" {
+		t.Errorf("uncovered = %+v", res.Uncovered)
+	}
+	if !strings.Contains(r.Summary, "add tests for internal/store/store.go:10-12") {
+		t.Errorf("summary should name the uncovered lines, got %q", r.Summary)
+	}
+}
+
+func TestCoverageParser_UsesBaseRef(t *testing.T) {
+	dir := coverageDir(t)
+	profile := "mode: set\n" +
+		"example.com/app/internal/store/store.go:10.2,12.3 1 1\n"
+	p := NewCoverageParser(fakeDiff{diff: storeDiff, wantBase: "origin/develop"})
+	opts := CoverageFrom(nil, "origin/develop")
+	r := p.ParseWorktree(dir, CheckConfig{Coverage: opts}, profile, "", 0)
+	if !r.Passed {
+		t.Fatalf("expected a pass diffing against origin/develop, got %q", r.Summary)
+	}
+}
+
+func TestCoverageParser_LcovReportFile(t *testing.T) {
+	dir := coverageDir(t)
+	lcov := "TN:\nSF:" + filepath.Join(dir, "internal/store/store.go") + "\nDA:10,3\nDA:11,1\nDA:12,0\nDA:20,0\nend_of_record\n"
+	if err := os.WriteFile(filepath.Join(dir, "lcov.info"), []byte(lcov), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewCoverageParser(fakeDiff{diff: storeDiff})
+	r := p.ParseWorktree(dir, CheckConfig{Coverage: CoverageOptions{Report: "lcov.info", Threshold: 60}}, "", "", 0)
+	if !r.Passed {
+		t.Fatalf("2 of 3 changed lines covered should pass a 60%% threshold: %s", r.Summary)
+	}
+	res := r.Findings.(coverageResult)
+	if res.Format != "lcov" || res.Covered != 2 || res.TotalPercent != 50 {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestCoverageParser_Cobertura(t *testing.T) {
+	dir := coverageDir(t)
+	xml := `<?xml version="1.0" ?>
+<coverage line-rate="0.5">
+  <sources><source>internal</source></sources>
+  <packages><package name="store"><classes>
+    <class name="store" filename="store/store.go">
+      <lines><line number="10" hits="0"/><line number="11" hits="2"/><line number="13" hits="0"/></lines>
+    </class>
+  </classes></package></packages>
+</coverage>`
+	p := NewCoverageParser(fakeDiff{diff: storeDiff})
+	r := p.ParseWorktree(dir, CheckConfig{Coverage: CoverageOptions{Threshold: 50}}, xml, "", 0)
+	res := r.Findings.(coverageResult)
+	if res.Format != "cobertura" || res.ChangedLines != 3 || res.Covered != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+	if r.Passed || res.Uncovered[0].Lines != "10,13" {
+		t.Errorf("expected a failure listing lines 10,13, got %q", r.Summary)
+	}
+}
+
+func TestCoverageParser_NoChangedCode(t *testing.T) {
+	p := NewCoverageParser(fakeDiff{diff: ""})
+	r := p.ParseWorktree(coverageDir(t), CheckConfig{Coverage: CoverageOptions{Threshold: 80}}, "mode: set\nexample.com/app/a.go:1.1,2.2 1 1\n", "", 0)
+	if !r.Passed || !strings.HasPrefix(r.Summary, "no changed lines") {
+		t.Errorf("got passed=%v summary=%q", r.Passed, r.Summary)
+	}
+}
+
+func TestCoverageParser_Failures(t *testing.T) {
+	dir := coverageDir(t)
+	profile := "mode: set\nexample.com/app/a.go:1.1,2.2 1 1\n"
+	tests := []struct {
+		name    string
+		parser  *CoverageParser
+		opts    CoverageOptions
+		stdout  string
+		exit    int
+		summary string
+	}{
+		{"tests failed", NewCoverageParser(fakeDiff{}), CoverageOptions{}, "--- FAIL: TestX", 1, "exit code 1"},
+		{"missing report", NewCoverageParser(fakeDiff{}), CoverageOptions{Report: "c.out"}, "", 0, "coverage report:"},
+		{"empty report", NewCoverageParser(fakeDiff{}), CoverageOptions{}, "", 0, "no files in lcov report"},
+		{"no diff", NewCoverageParser(fakeDiff{err: errors.New("no main branch")}), CoverageOptions{}, profile, 0, "diff against the base branch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.parser.ParseWorktree(dir, CheckConfig{Coverage: tt.opts}, tt.stdout, "", tt.exit)
+			if r.Passed || !strings.Contains(r.Summary, tt.summary) {
+				t.Errorf("got passed=%v summary=%q, want a failure containing %q", r.Passed, r.Summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestRunner_UsesWorktreeParser(t *testing.T) {
+	dir := coverageDir(t)
+	cmd := &mockCmd{results: []mockResult{{Stdout: "mode: set\nexample.com/app/internal/store/store.go:10.2,13.3 1 4\n"}}}
+	r := NewRunner(cmd)
+	r.parsers["coverage"] = NewCoverageParser(fakeDiff{diff: storeDiff})
+	result, err := r.Run(dir, CheckConfig{Name: "coverage", Command: "go test -coverprofile=/dev/stdout ./...", Parser: "coverage", Coverage: CoverageOptions{Threshold: 80}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed || !strings.HasPrefix(result.Summary, "changed lines 100.0% covered (4 of 4") {
+		t.Errorf("got passed=%v summary=%q", result.Passed, result.Summary)
+	}
+}
+
+func TestLineRanges(t *testing.T) {
+	if got := lineRanges([]int{3, 4, 5, 9, 11, 12}); got != "3-5,9,11-12" {
+		t.Errorf("lineRanges = %q", got)
+	}
+}
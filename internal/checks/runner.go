@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
+	appctx "github.com/lucasnoah/taintfactory/internal/context"
 )
 
 // Result holds the structured output of a check run.
@@ -21,6 +25,10 @@ type Result struct {
 	Findings   string `json:"findings"`
 	Stdout     string `json:"stdout,omitempty"`
 	Stderr     string `json:"stderr,omitempty"`
+
+	// Resources the command used, when the runner reports them.
+	PeakMemoryBytes int64 `json:"peak_memory_bytes,omitempty"`
+	CPUMs           int   `json:"cpu_ms,omitempty"`
 }
 
 // CheckConfig mirrors config.Check with the fields the runner needs.
@@ -31,6 +39,8 @@ type CheckConfig struct {
 	Timeout    time.Duration
 	AutoFix    bool
 	FixCommand string
+	Limits     cgroup.Limits
+	Coverage   CoverageOptions // used by the coverage parser
 }
 
 // CommandRunner abstracts command execution for testability.
@@ -38,14 +48,33 @@ type CommandRunner interface {
 	Run(ctx context.Context, dir string, command string) (stdout string, stderr string, exitCode int, err error)
 }
 
+// MeteredRunner is a CommandRunner that can cap a command's resources and
+// report what it used. Checks with limits run through RunMetered when the
+// runner supports it.
+type MeteredRunner interface {
+	CommandRunner
+	RunMetered(ctx context.Context, dir string, command string, limits cgroup.Limits) (stdout string, stderr string, exitCode int, usage cgroup.Usage, err error)
+}
+
 // ExecRunner implements CommandRunner by shelling out.
 type ExecRunner struct{}
 
 func (e *ExecRunner) Run(ctx context.Context, dir string, command string) (string, string, int, error) {
+	stdout, stderr, exitCode, _, err := e.RunMetered(ctx, dir, command, cgroup.Limits{})
+	return stdout, stderr, exitCode, err
+}
+
+// RunMetered runs command in its own cgroup with limits applied when it has
+// limits (or $FACTORY_CGROUP is set) and cgroups v2 is available, and without
+// one otherwise.
+func (e *ExecRunner) RunMetered(ctx context.Context, dir string, command string, limits cgroup.Limits) (string, string, int, cgroup.Usage, error) {
 	cmd := exec.CommandContext(ctx, "sh", "-c", command)
 	cmd.Dir = dir
 	// Use process group so we can kill child processes on timeout
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	group, release := checkGroup(cmd.SysProcAttr, limits)
+	defer release()
 	cmd.Cancel = func() error {
 		// Send SIGTERM to the process group first
 		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
@@ -57,15 +86,48 @@ func (e *ExecRunner) Run(ctx context.Context, dir string, command string) (strin
 	cmd.Stderr = &stderrBuf
 
 	err := cmd.Run()
+	usage := cgroup.RusageOf(cmd.ProcessState)
+	if group != "" {
+		usage = group.Usage()
+		// Daemons that left the process group would otherwise outlive the check.
+		group.Kill()
+	}
 	exitCode := 0
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			exitCode = exitErr.ExitCode()
 		} else {
-			return stdoutBuf.String(), stderrBuf.String(), -1, fmt.Errorf("exec: %w", err)
+			return stdoutBuf.String(), stderrBuf.String(), -1, usage, fmt.Errorf("exec: %w", err)
 		}
 	}
-	return stdoutBuf.String(), stderrBuf.String(), exitCode, nil
+	return stdoutBuf.String(), stderrBuf.String(), exitCode, usage, nil
+}
+
+// checkGroup creates a cgroup for one check command and attaches attr to it.
+// It returns "" when the check does not need one (see cgroup.Wanted) or
+// cgroups v2 is unavailable. release removes the group once the command has
+// exited.
+func checkGroup(attr *syscall.SysProcAttr, limits cgroup.Limits) (group cgroup.Group, release func()) {
+	if !cgroup.Wanted(limits) {
+		return "", func() {}
+	}
+	parent, err := cgroup.Parent()
+	if err != nil {
+		return "", func() {}
+	}
+	g, err := cgroup.New(parent, fmt.Sprintf("check-%d-%d", os.Getpid(), time.Now().UnixNano()), limits)
+	if err != nil {
+		return "", func() {}
+	}
+	closeFD, err := g.Attach(attr)
+	if err != nil {
+		g.Remove()
+		return "", func() {}
+	}
+	return g, func() {
+		closeFD()
+		g.Remove()
+	}
 }
 
 // Runner executes checks and parses their output.
@@ -85,10 +147,17 @@ func NewRunner(cmd CommandRunner) *Runner {
 	r.parsers["typescript"] = &TypeScriptParser{}
 	r.parsers["vitest"] = &VitestParser{}
 	r.parsers["npm-audit"] = &NPMAuditParser{}
+	r.parsers["coverage"] = NewCoverageParser(&appctx.ExecGit{})
 	r.parsers["generic"] = &GenericParser{}
 	return r
 }
 
+// WithCommandRunner returns a Runner with the same parsers that executes
+// commands through cmd, e.g. inside a sandbox container.
+func (r *Runner) WithCommandRunner(cmd CommandRunner) *Runner {
+	return &Runner{cmd: cmd, parsers: r.parsers}
+}
+
 // Run executes a single check in the given directory.
 func (r *Runner) Run(dir string, cfg CheckConfig) (*Result, error) {
 	timeout := cfg.Timeout
@@ -106,7 +175,7 @@ func (r *Runner) Run(dir string, cfg CheckConfig) (*Result, error) {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 		// Run fix command (ignore exit code — fix commands often exit non-zero)
-		_, _, _, _ = r.cmd.Run(ctx, dir, cfg.FixCommand)
+		_, _, _, _, _ = r.run(ctx, dir, cfg.FixCommand, cfg.Limits)
 
 		// Re-run the check
 		recheck, err := r.runOnce(dir, cfg, timeout)
@@ -126,8 +195,9 @@ func (r *Runner) runOnce(dir string, cfg CheckConfig, timeout time.Duration) (*R
 	defer cancel()
 
 	start := time.Now()
-	stdout, stderr, exitCode, err := r.cmd.Run(ctx, dir, cfg.Command)
+	stdout, stderr, exitCode, usage, err := r.run(ctx, dir, cfg.Command, cfg.Limits)
 	durationMs := int(time.Since(start).Milliseconds())
+	peakMemory, cpuMs := usage.PeakMemoryBytes, int(usage.CPUTime.Milliseconds())
 
 	if err != nil {
 		// Context deadline exceeded → timeout
@@ -140,6 +210,9 @@ func (r *Runner) runOnce(dir string, cfg CheckConfig, timeout time.Duration) (*R
 				Summary:    fmt.Sprintf("timeout after %s", timeout),
 				Stdout:     stdout,
 				Stderr:     stderr,
+
+				PeakMemoryBytes: peakMemory,
+				CPUMs:           cpuMs,
 			}, nil
 		}
 		return nil, fmt.Errorf("run check %q: %w", cfg.Name, err)
@@ -151,7 +224,12 @@ func (r *Runner) runOnce(dir string, cfg CheckConfig, timeout time.Duration) (*R
 		parser = r.parsers["generic"]
 	}
 
-	parsed := parser.Parse(stdout, stderr, exitCode)
+	var parsed ParseResult
+	if wp, ok := parser.(WorktreeParser); ok {
+		parsed = wp.ParseWorktree(dir, cfg, stdout, stderr, exitCode)
+	} else {
+		parsed = parser.Parse(stdout, stderr, exitCode)
+	}
 
 	// Convert findings to string for DB storage.
 	// If findings is already a string, use it directly to avoid JSON-escaping.
@@ -163,15 +241,32 @@ func (r *Runner) runOnce(dir string, cfg CheckConfig, timeout time.Duration) (*R
 		findingsStr = string(findingsJSON)
 	}
 
+	summary := parsed.Summary
+	if usage.OOMKilled {
+		summary = fmt.Sprintf("killed: exceeded memory limit of %d MiB", cfg.Limits.MemoryBytes>>20)
+	}
+
 	return &Result{
 		CheckName:  cfg.Name,
-		Passed:     exitCode == 0 && parsed.Passed,
+		Passed:     exitCode == 0 && parsed.Passed && !usage.OOMKilled,
 		ExitCode:   exitCode,
 		DurationMs: durationMs,
-		Summary:    parsed.Summary,
+		Summary:    summary,
 		Findings:   findingsStr,
 		Stdout:     stdout,
 		Stderr:     stderr,
+
+		PeakMemoryBytes: peakMemory,
+		CPUMs:           cpuMs,
 	}, nil
 }
 
+// run executes command, through RunMetered when the command runner supports it.
+func (r *Runner) run(ctx context.Context, dir, command string, limits cgroup.Limits) (string, string, int, cgroup.Usage, error) {
+	if m, ok := r.cmd.(MeteredRunner); ok {
+		return m.RunMetered(ctx, dir, command, limits)
+	}
+	stdout, stderr, exitCode, err := r.cmd.Run(ctx, dir, command)
+	return stdout, stderr, exitCode, cgroup.Usage{}, err
+}
+
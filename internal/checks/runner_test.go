@@ -3,8 +3,12 @@ package checks
 import (
 	"context"
 	"fmt"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
 )
 
 // mockCmd records calls and returns configured results.
@@ -257,3 +261,71 @@ func TestRunner_Run_DefaultTimeout(t *testing.T) {
 		t.Errorf("expected passed=true")
 	}
 }
+
+func TestRunner_WithCommandRunner(t *testing.T) {
+	host := &mockCmd{}
+	other := &mockCmd{results: []mockResult{{ExitCode: 1, Stdout: "boom"}}}
+	r := NewRunner(host).WithCommandRunner(other)
+
+	result, err := r.Run("/tmp/worktree", CheckConfig{Name: "test", Command: "go test ./...", Parser: "generic"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Passed {
+		t.Error("expected failure from the replacement runner")
+	}
+	if len(host.calls) != 0 || len(other.calls) != 1 || other.calls[0].Command != "go test ./..." {
+		t.Errorf("host calls %v, replacement calls %v", host.calls, other.calls)
+	}
+}
+
+type meteredCmd struct {
+	mockCmd
+	limits []cgroup.Limits
+	usage  cgroup.Usage
+}
+
+func (m *meteredCmd) RunMetered(ctx context.Context, dir string, command string, limits cgroup.Limits) (string, string, int, cgroup.Usage, error) {
+	m.limits = append(m.limits, limits)
+	stdout, stderr, exitCode, err := m.Run(ctx, dir, command)
+	return stdout, stderr, exitCode, m.usage, err
+}
+
+func TestRunner_MeteredRunner(t *testing.T) {
+	cmd := &meteredCmd{usage: cgroup.Usage{PeakMemoryBytes: 300 << 20, CPUTime: 1500 * time.Millisecond}}
+	limits := cgroup.Limits{MemoryBytes: 1 << 30, Pids: 128}
+	result, err := NewRunner(cmd).Run("/tmp/worktree", CheckConfig{Name: "test", Command: "go test ./...", Parser: "generic", Limits: limits})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cmd.limits) != 1 || cmd.limits[0] != limits {
+		t.Errorf("limits passed = %v", cmd.limits)
+	}
+	if !result.Passed || result.PeakMemoryBytes != 300<<20 || result.CPUMs != 1500 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestRunner_OOMKilledFails(t *testing.T) {
+	cmd := &meteredCmd{usage: cgroup.Usage{PeakMemoryBytes: 1 << 30, OOMKilled: true}}
+	result, err := NewRunner(cmd).Run("/tmp/worktree", CheckConfig{Name: "test", Command: "go test ./...", Parser: "generic", Limits: cgroup.Limits{MemoryBytes: 1 << 30}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Passed || !strings.Contains(result.Summary, "memory limit of 1024 MiB") {
+		t.Errorf("expected an out-of-memory failure, got %+v", result)
+	}
+}
+
+func TestExecRunner_ReportsUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peak memory is only reported on Linux")
+	}
+	_, _, exitCode, usage, err := (&ExecRunner{}).RunMetered(context.Background(), t.TempDir(), "true", cgroup.Limits{})
+	if err != nil || exitCode != 0 {
+		t.Fatalf("exit %d, err %v", exitCode, err)
+	}
+	if usage.PeakMemoryBytes <= 0 {
+		t.Errorf("expected peak memory to be reported, got %+v", usage)
+	}
+}
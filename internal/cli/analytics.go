@@ -136,6 +136,36 @@ var analyticsCheckFailuresCmd = &cobra.Command{
 	},
 }
 
+var analyticsHeaviestChecksCmd = &cobra.Command{
+	Use:   "heaviest-checks",
+	Short: "Checks ranked by peak memory and CPU time",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d, err := openAnalyticsDB()
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+
+		since, _ := cmd.Flags().GetString("since")
+		results, err := analytics.QueryHeaviestChecks(d, since)
+		if err != nil {
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "json" {
+			return writeJSON(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tRUNS\tMAX MEM\tAVG MEM\tAVG CPU\tAVG WALL")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%d\t%.1f MB\t%.1f MB\t%.1fs\t%.1fs\n", r.Check, r.Runs, r.MaxMemoryMB, r.AvgMemoryMB, r.AvgCPUSeconds, r.AvgWallSeconds)
+		}
+		return w.Flush()
+	},
+}
+
 var analyticsFixRoundsCmd = &cobra.Command{
 	Use:   "fix-rounds",
 	Short: "Distribution of fix rounds per stage",
@@ -237,13 +267,67 @@ var analyticsIssueDetailCmd = &cobra.Command{
 	},
 }
 
+// minExperimentRuns is the sample size below which a variant's numbers are
+// flagged as too noisy to compare.
+const minExperimentRuns = 10
+
+var analyticsExperimentCmd = &cobra.Command{
+	Use:   "experiment <stage>",
+	Short: "Compare the prompt_variants of a stage",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d, err := openAnalyticsDB()
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+
+		since, _ := cmd.Flags().GetString("since")
+		results, err := analytics.QueryExperiment(d, args[0], since)
+		if err != nil {
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "json" {
+			return writeJSON(cmd, results)
+		}
+		if len(results) == 0 {
+			fmt.Fprintf(cmd.OutOrStdout(), "No prompt variant runs recorded for stage %q.\n", args[0])
+			return nil
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VARIANT\tTEMPLATE\tRUNS\tFIRST PASS\tAVG FIX ROUNDS\tAVG (min)\tP50 (min)\tON_FAIL")
+		few := false
+		for _, r := range results {
+			runs := strconv.Itoa(r.Runs)
+			if r.Runs < minExperimentRuns {
+				runs += "*"
+				few = true
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.1f%%\t%.2f\t%.1f\t%.1f\t%.1f%%\n",
+				r.Variant, r.Template, runs, r.FirstPass, r.AvgFixRounds, r.AvgDuration, r.P50Duration, r.OnFail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if few {
+			fmt.Fprintf(cmd.OutOrStdout(), "\n* fewer than %d runs; differences are likely noise.\n", minExperimentRuns)
+		}
+		return nil
+	},
+}
+
 func init() {
 	sinceCommands := []*cobra.Command{
 		analyticsStageDurationCmd,
 		analyticsCheckFailureRateCmd,
 		analyticsCheckFailuresCmd,
+		analyticsHeaviestChecksCmd,
 		analyticsFixRoundsCmd,
 		analyticsPipelineThroughputCmd,
+		analyticsExperimentCmd,
 	}
 	for _, cmd := range sinceCommands {
 		cmd.Flags().String("format", "text", "Output format: text or json")
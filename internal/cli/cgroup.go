@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var cgroupCmd = &cobra.Command{
+	Use:   "cgroup",
+	Short: "Inspect the cgroups that enforce check and session limits",
+}
+
+var cgroupStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether resource limits can be enforced on this host",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parent, err := cgroup.Parent()
+		if err != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "limits not enforced: %v\n", err)
+			fmt.Fprintf(cmd.OutOrStdout(), "set %s to a delegated cgroup, or run factory as a systemd service with Delegate=yes\n", cgroup.ParentEnv)
+			return nil
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "limits enforced under %s\n", parent)
+		return nil
+	},
+}
+
+// cgroupExecCmd is the prefix sessions with limits launch claude through:
+// it moves itself into the session's cgroup and then execs the command, so
+// everything the command starts is capped.
+var cgroupExecCmd = &cobra.Command{
+	Use:    "exec --name <group> [flags] -- <command> [args...]",
+	Short:  "Run a command in a new cgroup with limits applied",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		parent, _ := cmd.Flags().GetString("parent")
+		memory, _ := cmd.Flags().GetString("memory")
+		cpu, _ := cmd.Flags().GetString("cpu")
+		pids, _ := cmd.Flags().GetInt("pids")
+
+		limits := &config.Limits{Memory: memory, CPU: cpu, Pids: pids}
+		if _, err := limits.MemoryBytes(); err != nil {
+			return fmt.Errorf("--memory %w", err)
+		}
+		if _, err := limits.CPUCores(); err != nil {
+			return fmt.Errorf("--cpu %w", err)
+		}
+
+		if err := enterGroup(parent, name, cgroup.LimitsFrom(limits)); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "[cgroup] warning: running without limits: %v\n", err)
+		}
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			return err
+		}
+		return syscall.Exec(path, args, os.Environ())
+	},
+}
+
+// enterGroup moves this process into the group name under parent, or under
+// cgroup.Parent() when parent is "".
+func enterGroup(parent, name string, limits cgroup.Limits) error {
+	if parent == "" {
+		var err error
+		if parent, err = cgroup.Parent(); err != nil {
+			return err
+		}
+	}
+	g, err := cgroup.New(parent, name, limits)
+	if err != nil {
+		return err
+	}
+	return g.Add(os.Getpid())
+}
+
+func init() {
+	cgroupExecCmd.Flags().String("name", "", "Cgroup to create under the parent")
+	cgroupExecCmd.Flags().String("parent", "", "Delegated parent cgroup (default: detect)")
+	cgroupExecCmd.Flags().String("memory", "", "Memory limit, e.g. 4g")
+	cgroupExecCmd.Flags().String("cpu", "", "CPU limit in cores, e.g. 1.5")
+	cgroupExecCmd.Flags().Int("pids", 0, "Maximum number of processes")
+	cgroupExecCmd.MarkFlagRequired("name")
+
+	cgroupCmd.AddCommand(cgroupStatusCmd)
+	cgroupCmd.AddCommand(cgroupExecCmd)
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
 	"github.com/lucasnoah/taintfactory/internal/checks"
 	"github.com/lucasnoah/taintfactory/internal/config"
 	"github.com/lucasnoah/taintfactory/internal/db"
@@ -62,6 +63,8 @@ var checkRunCmd = &cobra.Command{
 				Timeout:    timeout,
 				AutoFix:    fix && checkCfg.AutoFix,
 				FixCommand: checkCfg.FixCommand,
+				Limits:     cgroup.LimitsFrom(checkCfg.Limits),
+				Coverage:   checks.CoverageFrom(checkCfg.Coverage, ps.BaseRef()),
 			}
 
 			result, err := runner.Run(ps.Worktree, rc)
@@ -73,10 +76,11 @@ var checkRunCmd = &cobra.Command{
 			saveRawOutput(store, issue, ps.CurrentStage, ps.CurrentAttempt, name, result)
 
 			// Log to DB
-			if err := d.LogCheckRun(
+			if err := d.LogCheckRunUsage(
 				ps.Namespace, issue, ps.CurrentStage, ps.CurrentAttempt, ps.CurrentFixRound,
 				name, result.Passed, result.AutoFixed, result.ExitCode,
 				result.DurationMs, result.Summary, result.Findings,
+				db.CheckUsage{PeakMemoryBytes: result.PeakMemoryBytes, CPUMs: result.CPUMs},
 			); err != nil {
 				return fmt.Errorf("log check run: %w", err)
 			}
@@ -151,6 +155,8 @@ var checkGateCmd = &cobra.Command{
 				Timeout:    parseDuration(chk.Timeout, 2*time.Minute),
 				AutoFix:    chk.AutoFix,
 				FixCommand: chk.FixCommand,
+				Limits:     cgroup.LimitsFrom(chk.Limits),
+				Coverage:   checks.CoverageFrom(chk.Coverage, ps.BaseRef()),
 			})
 		}
 
@@ -171,10 +177,11 @@ var checkGateCmd = &cobra.Command{
 		// Log each check result to DB and save raw output
 		for i, result := range results {
 			saveRawOutput(store, issue, stage, ps.CurrentAttempt, result.CheckName, result)
-			if err := d.LogCheckRun(
+			if err := d.LogCheckRunUsage(
 				ps.Namespace, issue, stage, ps.CurrentAttempt, fixRound,
 				result.CheckName, result.Passed, result.AutoFixed, result.ExitCode,
 				result.DurationMs, result.Summary, result.Findings,
+				db.CheckUsage{PeakMemoryBytes: result.PeakMemoryBytes, CPUMs: result.CPUMs},
 			); err != nil {
 				return fmt.Errorf("log check run %d: %w", i, err)
 			}
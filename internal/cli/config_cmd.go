@@ -2,8 +2,14 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/prompt"
+	"github.com/lucasnoah/taintfactory/internal/triage"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -17,7 +23,7 @@ var configCmd = &cobra.Command{
 
 var configValidateCmd = &cobra.Command{
 	Use:   "validate",
-	Short: "Validate the pipeline configuration file",
+	Short: "Validate the pipeline configuration file, including check, on_fail, and template references",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := loadConfig()
 		if err != nil {
@@ -25,6 +31,14 @@ var configValidateCmd = &cobra.Command{
 		}
 
 		errs := config.Validate(cfg)
+		tmplErrs, warnings := validateTemplates(cfg)
+		errs = append(errs, tmplErrs...)
+		if len(warnings) > 0 {
+			cmd.Println("Warnings:")
+			for _, w := range warnings {
+				cmd.Printf("  - %s: %s\n", w.Field, w.Message)
+			}
+		}
 		if len(errs) == 0 {
 			cmd.Println("Configuration is valid.")
 			return nil
@@ -42,6 +56,10 @@ var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show the resolved configuration with defaults merged",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if resolved, _ := cmd.Flags().GetBool("resolved"); resolved {
+			return showResolvedConfig(cmd)
+		}
+
 		cfg, err := loadConfig()
 		if err != nil {
 			return err
@@ -57,6 +75,151 @@ var configShowCmd = &cobra.Command{
 	},
 }
 
+// showResolvedConfig prints the config after extends/include merging, with
+// each value annotated with the file it came from.
+func showResolvedConfig(cmd *cobra.Command) error {
+	path := configFile
+	if path == "" {
+		path = config.ResolvedConfigPath()
+	}
+	if path == "" {
+		return fmt.Errorf("no pipeline config found; pass -f")
+	}
+
+	root, sources, err := config.LoadResolved(path)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintln(out, "# Merged from (later files win):")
+	for _, s := range sources {
+		fmt.Fprintf(out, "#   %s\n", s)
+	}
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("marshalling config: %w", err)
+	}
+	return enc.Close()
+}
+
+// validateTemplates checks that every agent stage's prompt template resolves
+// the way the stage engine will load it: relative to the repository root
+// (the engine looks in the pipeline's worktree, a checkout of the same repo),
+// then ~/.factory/templates, then the built-ins. A template under a directory
+// the repo does not have, such as the example config's templates/, is only a
+// warning: the config is meant for a repo that provides it.
+func validateTemplates(cfg *config.PipelineConfig) ([]config.ValidationError, []config.ValidationWarning) {
+	var errs []config.ValidationError
+	var warnings []config.ValidationWarning
+	root := templateRoot(cfg.Dir)
+	check := func(field, tmpl string) {
+		err := checkTemplate(tmpl, root)
+		if err == nil {
+			return
+		}
+		if dir := filepath.Dir(tmpl); dir != "." && !isDir(filepath.Join(root, dir)) {
+			warnings = append(warnings, config.ValidationWarning{
+				Field:   field,
+				Message: fmt.Sprintf("%v; %s has no %s/ directory", err, root, dir),
+			})
+			return
+		}
+		errs = append(errs, config.ValidationError{Field: field, Message: err.Error()})
+	}
+	for i, s := range cfg.Pipeline.Stages {
+		if s.Type == "checks_only" || s.Type == "merge" || s.Type == "migration_check" || s.Type == "browser_qa" {
+			continue
+		}
+		if s.Type == "panel" && s.Panel != nil {
+			for j, r := range s.Panel.Reviewers {
+				tmpl := r.PromptTemplate
+				if tmpl == "" {
+					tmpl = s.PromptTemplate
+				}
+				if tmpl == "" {
+					tmpl = "panel-review.md"
+				}
+				check(fmt.Sprintf("pipeline.stages[%d].panel.reviewers[%d].prompt_template", i, j), tmpl)
+			}
+			continue
+		}
+		if len(s.PromptVariants) > 0 {
+			for j, v := range s.PromptVariants {
+				if v.Template == "" {
+					continue // reported by config validation
+				}
+				check(fmt.Sprintf("pipeline.stages[%d].prompt_variants[%d].template", i, j), v.Template)
+			}
+			continue
+		}
+		tmpl := s.PromptTemplate
+		if tmpl == "" {
+			tmpl = s.ID + ".md"
+		}
+		check(fmt.Sprintf("pipeline.stages[%d].prompt_template", i), tmpl)
+	}
+	if cfg.Deploy != nil {
+		for i, s := range cfg.Deploy.Stages {
+			if s.PromptTemplate == "" {
+				continue
+			}
+			check(fmt.Sprintf("deploy.stages[%d].prompt_template", i), s.PromptTemplate)
+		}
+	}
+	return errs, warnings
+}
+
+// isDir reports whether path exists and is a directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// templateRoot returns the top of the git repository containing dir, which is
+// where a pipeline worktree's templates sit. A worktree only holds committed
+// files, so an uncommitted template passes here but is missing at runtime.
+// Falls back to dir when it is not inside a repository.
+func templateRoot(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return dir
+	}
+	if top := strings.TrimSpace(string(out)); top != "" {
+		return top
+	}
+	return dir
+}
+
+// checkTemplate loads a template and checks its syntax.
+func checkTemplate(path, dir string) error {
+	content, err := prompt.LoadTemplate(path, dir)
+	if err != nil {
+		return err
+	}
+	if err := prompt.CheckSyntax(content); err != nil {
+		return fmt.Errorf("template %q: %w", path, err)
+	}
+	return nil
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for pipeline.yaml (or triage.yaml with --triage)",
+	Long: `Print a JSON Schema for editor autocompletion and validation, e.g. for the
+YAML language server:
+
+  factory config schema > .factory/pipeline.schema.json
+  # yaml-language-server: $schema=.factory/pipeline.schema.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if t, _ := cmd.Flags().GetBool("triage"); t {
+			return writeJSON(cmd, triage.Schema())
+		}
+		return writeJSON(cmd, config.PipelineSchema())
+	},
+}
+
 func loadConfig() (*config.PipelineConfig, error) {
 	if configFile != "" {
 		return config.Load(configFile)
@@ -66,6 +229,9 @@ func loadConfig() (*config.PipelineConfig, error) {
 
 func init() {
 	configCmd.PersistentFlags().StringVarP(&configFile, "file", "f", "", "path to pipeline config file")
+	configShowCmd.Flags().Bool("resolved", false, "Show the extends/include merge result with each value's origin file")
 	configCmd.AddCommand(configValidateCmd)
+	configSchemaCmd.Flags().Bool("triage", false, "Print the schema for triage.yaml instead of pipeline.yaml")
 	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configSchemaCmd)
 }
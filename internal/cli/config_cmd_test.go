@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+func TestTemplateRoot_RepoTopLevel(t *testing.T) {
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Skipf("git init: %v: %s", err, out)
+	}
+	sub := filepath.Join(repo, "deploy")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := filepath.EvalSymlinks(repo)
+	got, _ := filepath.EvalSymlinks(templateRoot(sub))
+	if got != want {
+		t.Errorf("templateRoot = %q, want the repo root %q", got, want)
+	}
+}
+
+func TestTemplateRoot_OutsideRepo(t *testing.T) {
+	dir := t.TempDir()
+	if got := templateRoot(dir); got != dir {
+		t.Errorf("templateRoot = %q, want %q", got, dir)
+	}
+}
+
+func TestValidateTemplates_MissingDirIsWarning(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "prompts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.PipelineConfig{Dir: dir}
+	cfg.Pipeline.Stages = []config.Stage{
+		{ID: "scaffold", Type: "agent", PromptTemplate: "templates/scaffold.md"},
+		{ID: "style", Type: "agent", PromptTemplate: "prompts/style.md"},
+		{ID: "plan", Type: "agent"},
+	}
+	errs, warnings := validateTemplates(cfg)
+	if len(warnings) != 1 || warnings[0].Field != "pipeline.stages[0].prompt_template" {
+		t.Errorf("warnings = %v, want one for the missing templates/ directory", warnings)
+	}
+	if len(errs) != 2 || errs[0].Field != "pipeline.stages[1].prompt_template" || errs[1].Field != "pipeline.stages[2].prompt_template" {
+		t.Errorf("errs = %v, want errors for prompts/style.md and plan.md", errs)
+	}
+}
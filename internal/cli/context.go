@@ -2,13 +2,17 @@ package cli
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	appctx "github.com/lucasnoah/taintfactory/internal/context"
 	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/lessons"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
 	"github.com/lucasnoah/taintfactory/internal/prompt"
+	"github.com/lucasnoah/taintfactory/internal/repoindex"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
 	"github.com/spf13/cobra"
 )
 
@@ -50,6 +54,11 @@ var contextBuildCmd = &cobra.Command{
 		}
 
 		builder := appctx.NewBuilder(store, &appctx.ExecGit{})
+		builder.SetRetriever(repoindex.NewProvider(filepath.Join(config.DataDir(), "index")))
+		if d, cleanupDB, err := openDB(); err == nil {
+			defer cleanupDB()
+			builder.SetLessons(lessons.NewProvider(d, cfg.Pipeline.Lessons))
+		}
 		result, err := builder.Build(ps, appctx.BuildOpts{
 			Issue:     issue,
 			Stage:     stage,
@@ -66,13 +75,17 @@ var contextBuildCmd = &cobra.Command{
 			return fmt.Errorf("load template %q: %w", result.Template, err)
 		}
 
-		rendered, err := prompt.Render(tmplContent, result.Vars)
+		rendered, err := prompt.RenderWith(tmplContent, result.Vars, prompt.RenderOpts{
+			Data:     result.Data,
+			Partials: prompt.TemplatePartials(result.Template, ps.Worktree),
+		})
 		if err != nil {
 			return fmt.Errorf("render template: %w", err)
 		}
+		rendered += appctx.BudgetNote(result.Budget, result.Truncations)
 
 		// Save rendered prompt to disk
-		if err := store.SavePrompt(issue, stage, ps.CurrentAttempt, rendered); err != nil {
+		if err := store.SavePrompt(issue, stage, ps.CurrentAttempt, secrets.RedactorFor(cfg).Redact(rendered)); err != nil {
 			return fmt.Errorf("save prompt: %w", err)
 		}
 
@@ -211,13 +224,15 @@ var contextRenderCmd = &cobra.Command{
 			}
 		}
 
-		rendered, err := prompt.Render(tmplContent, vars)
+		rendered, err := prompt.RenderWith(tmplContent, vars, prompt.RenderOpts{
+			Partials: prompt.TemplatePartials(templatePath, ps.Worktree),
+		})
 		if err != nil {
 			return fmt.Errorf("render template: %w", err)
 		}
 
 		if save {
-			if err := store.SavePrompt(issue, stage, ps.CurrentAttempt, rendered); err != nil {
+			if err := store.SavePrompt(issue, stage, ps.CurrentAttempt, secrets.RedactorFor(cfg).Redact(rendered)); err != nil {
 				return fmt.Errorf("save prompt: %w", err)
 			}
 			fmt.Fprintln(cmd.ErrOrStderr(), "Prompt saved.")
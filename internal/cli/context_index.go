@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/repoindex"
+	"github.com/spf13/cobra"
+)
+
+var contextIndexCmd = &cobra.Command{
+	Use:   "index [dir]",
+	Short: "Index a repository's symbols and preview what {{relevant_context}} would pick",
+	Long: `Builds (or refreshes) the symbol index for dir, defaulting to the current
+directory. With --query, ranks the indexed files against the text and lists
+related past changes, as the relevant_files stage setting does.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		query, _ := cmd.Flags().GetString("query")
+		changed, _ := cmd.Flags().GetStringSlice("changed")
+		limit, _ := cmd.Flags().GetInt("limit")
+		format, _ := cmd.Flags().GetString("format")
+
+		idx, err := repoindex.Build(dir, filepath.Join(config.DataDir(), "index"))
+		if err != nil {
+			return fmt.Errorf("index %s: %w", dir, err)
+		}
+
+		if query == "" && len(changed) == 0 {
+			symbols := 0
+			for _, f := range idx.Files {
+				symbols += len(f.Symbols)
+			}
+			if format == "json" {
+				return writeJSON(cmd, map[string]int{"files": len(idx.Files), "symbols": symbols})
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Indexed %d files, %d symbols in %s\n", len(idx.Files), symbols, idx.Root)
+			return nil
+		}
+
+		results := idx.Search(query, changed, limit)
+		files := append([]string(nil), changed...)
+		for _, r := range results {
+			files = append(files, r.Path)
+		}
+		changes := repoindex.RelatedChanges(idx.Root, query, files, max(limit/2, 3))
+		if format == "json" {
+			return writeJSON(cmd, map[string]interface{}{"files": results, "changes": changes})
+		}
+		out := repoindex.Format(results, changes)
+		if strings.TrimSpace(out) == "" {
+			out = "No matching files."
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), out)
+		return nil
+	},
+}
+
+func init() {
+	contextIndexCmd.Flags().String("query", "", "Issue text to rank files against")
+	contextIndexCmd.Flags().StringSlice("changed", nil, "Files already changed on the branch")
+	contextIndexCmd.Flags().Int("limit", 8, "Number of files to list")
+	contextIndexCmd.Flags().String("format", "text", "Output format: text or json")
+	contextCmd.AddCommand(contextIndexCmd)
+}
@@ -20,19 +20,12 @@ var deployCmd = &cobra.Command{
 }
 
 var deployCreateCmd = &cobra.Command{
-	Use:   "create <commit-sha>",
-	Short: "Create a new deploy pipeline for a commit",
-	Args:  cobra.ExactArgs(1),
+	Use:   "create [commit-sha]",
+	Short: "Create a new deploy pipeline for a commit (default: tip of the base branch)",
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		sha := args[0]
 		namespace, _ := cmd.Flags().GetString("namespace")
 
-		// Normalize SHA via git rev-parse (ADR 0018)
-		fullSHA, err := normalizeCommitSHA(sha)
-		if err != nil {
-			return fmt.Errorf("invalid commit SHA %q: %w", sha, err)
-		}
-
 		cfg, err := config.LoadDefault()
 		if err != nil {
 			return fmt.Errorf("load config: %w", err)
@@ -51,13 +44,31 @@ var deployCreateCmd = &cobra.Command{
 
 		firstStage := cfg.Deploy.Stages[0].ID
 
-		// Determine previous SHA from latest completed deploy
 		d, cleanupDB, err := openDB()
 		if err != nil {
 			return err
 		}
 		defer cleanupDB()
 
+		// Without an explicit commit, deploy the tip of the repo's base branch.
+		sha := ""
+		if len(args) > 0 {
+			sha = args[0]
+		} else {
+			branch, remote := deployBase(d, namespace, cfg)
+			if err := fetchBranch(repoDir, remote, branch); err != nil {
+				return err
+			}
+			sha = remote + "/" + branch
+		}
+
+		// Normalize SHA via git rev-parse (ADR 0018)
+		fullSHA, err := normalizeCommitSHA(sha)
+		if err != nil {
+			return fmt.Errorf("invalid commit SHA %q: %w", sha, err)
+		}
+
+		// Determine previous SHA from latest completed deploy
 		previousSHA := ""
 		if prev, err := d.DeployGetLatestCompleted(namespace); err == nil {
 			previousSHA = prev.CommitSHA
@@ -105,6 +116,34 @@ func normalizeCommitSHA(ref string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
+// deployBase returns the branch and remote a deploy defaults to: the repo
+// record's when namespace is registered, otherwise the pipeline config's.
+func deployBase(d *db.DB, namespace string, cfg *config.PipelineConfig) (string, string) {
+	branch, remote := cfg.Pipeline.BaseBranchName(), cfg.Pipeline.RemoteName()
+	if namespace == "" {
+		return branch, remote
+	}
+	if repo, err := d.RepoGetByNamespace(namespace); err == nil {
+		if repo.BaseBranch != "" {
+			branch = repo.BaseBranch
+		}
+		if repo.Remote != "" {
+			remote = repo.Remote
+		}
+	}
+	return branch, remote
+}
+
+// fetchBranch updates the remote-tracking ref for branch.
+func fetchBranch(dir, remote, branch string) error {
+	cmd := exec.Command("git", "fetch", remote, branch)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch %s %s: %w\n%s", remote, branch, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func shortSHA(sha string) string {
 	if len(sha) > 7 {
 		return sha[:7]
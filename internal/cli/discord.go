@@ -12,6 +12,7 @@ import (
 	"github.com/lucasnoah/taintfactory/internal/db"
 	"github.com/lucasnoah/taintfactory/internal/discord"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
 	"github.com/spf13/cobra"
 )
 
@@ -88,15 +89,10 @@ func handleDiscordEvent(d *db.DB, store *pipeline.Store, evt db.PipelineEvent) e
 		return nil
 	}
 
-	// Load queue item to get config_path.
-	qi, err := d.GetQueueItem(evt.Issue)
-	if err != nil || qi == nil || qi.ConfigPath == "" {
+	cfg := loadEventConfig(d, evt)
+	if cfg == nil {
 		return nil // no config, skip silently
 	}
-	cfg, err := config.Load(qi.ConfigPath)
-	if err != nil {
-		return nil // can't load config, skip silently
-	}
 	webhookURL := cfg.Pipeline.Notifications.Discord.WebhookURL
 	if webhookURL == "" {
 		return nil // project not configured for Discord
@@ -114,7 +110,7 @@ func handleDiscordEvent(d *db.DB, store *pipeline.Store, evt db.PipelineEvent) e
 	case "completed", "failed":
 		payload = buildDiscordCompletionPayload(ps, evt)
 	case "stage_advanced", "escalated":
-		payload = buildDiscordStagePayload(store, ps, evt)
+		payload = buildDiscordStagePayload(store, ps, evt, secrets.RedactorFor(cfg))
 	default:
 		return nil
 	}
@@ -128,7 +124,24 @@ func handleDiscordEvent(d *db.DB, store *pipeline.Store, evt db.PipelineEvent) e
 	return nil
 }
 
-func buildDiscordStagePayload(store *pipeline.Store, ps *pipeline.PipelineState, evt db.PipelineEvent) discord.WebhookPayload {
+// loadEventConfig loads the pipeline config for the issue an event belongs to,
+// via the queue item's config_path. Returns nil if none can be loaded.
+func loadEventConfig(d *db.DB, evt db.PipelineEvent) *config.PipelineConfig {
+	qi, err := d.GetQueueItem(evt.Issue)
+	if err != nil || qi == nil || qi.ConfigPath == "" {
+		return nil
+	}
+	cfg, err := config.Load(qi.ConfigPath)
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// buildDiscordStagePayload builds the embed for a completed stage. The session
+// log and diff are redacted before summarisation, and the summary again after,
+// so secret values never reach Claude or Discord.
+func buildDiscordStagePayload(store *pipeline.Store, ps *pipeline.PipelineState, evt db.PipelineEvent, redactor *secrets.Redactor) discord.WebhookPayload {
 	// For stage_advanced: evt.Stage is the NEXT stage; completed stage is in
 	// evt.Detail as "from=<stage>". Extract the completed stage from there.
 	completedStage := evt.Stage
@@ -156,8 +169,11 @@ func buildDiscordStagePayload(store *pipeline.Store, ps *pipeline.PipelineState,
 	// Agent stages — generate Claude summary.
 	sessionLog, _ := store.GetSessionLog(ps.Issue, completedStage, entry.Attempt)
 	gitDiff := discordGetGitDiff(ps.Worktree)
-	prompt := discord.BuildSummaryPrompt(completedStage, sessionLog, gitDiff)
+	prompt := discord.BuildSummaryPrompt(completedStage, redactor.Redact(sessionLog), redactor.Redact(gitDiff))
 	summary := discord.GenerateSummary(prompt)
+	summary.Summary = redactor.Redact(summary.Summary)
+	summary.Changes = redactor.Redact(summary.Changes)
+	summary.OpenQuestions = redactor.Redact(summary.OpenQuestions)
 
 	stageIndex, totalStages := discordStagePosition(ps, completedStage)
 
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/discord"
+	"github.com/lucasnoah/taintfactory/internal/orchestrator"
+	"github.com/spf13/cobra"
+)
+
+var discordBotCmd = &cobra.Command{
+	Use:   "bot",
+	Short: "Serve the Discord interactions endpoint for /factory slash commands",
+	Long: `Serve an HTTP endpoint for Discord's interactions API. Point the application's
+"Interactions Endpoint URL" at it (e.g. via a tunnel or reverse proxy).
+
+Supported commands:
+  /factory status <issue>
+  /factory retry <issue> [reason]
+  /factory approve <issue> <stage>
+  /factory queue add <issue> [intent]
+
+Settings come from notifications.discord.bot in --config. Only the Discord user
+and role IDs listed in allowed_users / allowed_roles may run commands.
+With --register, the /factory command is (re)registered using DISCORD_BOT_TOKEN.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, _ := cmd.Flags().GetString("listen")
+		register, _ := cmd.Flags().GetBool("register")
+		configFlag, _ := cmd.Flags().GetString("config")
+
+		configPath, err := resolveConfigPath(configFlag)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		botCfg := cfg.Pipeline.Notifications.Discord.Bot
+		if botCfg.PublicKey == "" {
+			return fmt.Errorf("notifications.discord.bot.public_key is required")
+		}
+		key, err := discord.ParsePublicKey(botCfg.PublicKey)
+		if err != nil {
+			return err
+		}
+		if len(botCfg.AllowedUsers) == 0 && len(botCfg.AllowedRoles) == 0 {
+			fmt.Fprintln(os.Stderr, "warning: no allowed_users or allowed_roles configured; every command will be rejected")
+		}
+
+		bot := &discord.Bot{
+			PublicKey: key,
+			Auth:      discord.Authorizer{Users: botCfg.AllowedUsers, Roles: botCfg.AllowedRoles},
+			Handle:    discordBotHandler(configPath),
+		}
+
+		if register {
+			token := os.Getenv("DISCORD_BOT_TOKEN")
+			if token == "" || botCfg.ApplicationID == "" {
+				return fmt.Errorf("--register needs DISCORD_BOT_TOKEN and notifications.discord.bot.application_id")
+			}
+			if err := bot.RegisterCommands(botCfg.ApplicationID, botCfg.GuildID, token); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Registered /factory slash commands")
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/interactions", bot)
+		log.Printf("Discord bot listening on %s/interactions", listen)
+		return http.ListenAndServe(listen, mux)
+	},
+}
+
+// discordBotHandler executes slash commands through the same orchestrator and
+// queue functions the CLI uses. configPath is used for /factory queue add.
+func discordBotHandler(configPath string) discord.CommandHandler {
+	return func(c discord.Command) (string, error) {
+		issue, err := c.Int("issue")
+		if err != nil {
+			return "", err
+		}
+		log.Printf("discord bot: %s (%s) ran /factory %s #%d", c.Username, c.UserID, c.Name, issue)
+
+		switch c.Name {
+		case "queue add":
+			return discordQueueAdd(issue, c.Options["intent"], configPath)
+		case "status", "retry", "approve":
+		default:
+			return "", fmt.Errorf("unknown command %q", c.Name)
+		}
+
+		orch, cleanup, err := newOrchestrator()
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+
+		by := fmt.Sprintf("via Discord by %s", c.Username)
+		switch c.Name {
+		case "status":
+			info, err := orch.Status(issue)
+			if err != nil {
+				return "", err
+			}
+			var sb strings.Builder
+			writePipelineStatus(&sb, info)
+			return "```\n" + sb.String() + "```", nil
+		case "retry":
+			reason := by
+			if r := c.Options["reason"]; r != "" {
+				reason = r + " (" + by + ")"
+			}
+			if err := orch.Retry(orchestrator.RetryOpts{Issue: issue, Reason: reason}); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Pipeline #%d: retry queued", issue), nil
+		default: // approve
+			result, err := orch.Approve(orchestrator.ApproveOpts{Issue: issue, Stage: c.Options["stage"], Reason: by})
+			if err != nil {
+				return "", err
+			}
+			return approveMessage(result), nil
+		}
+	}
+}
+
+func discordQueueAdd(issue int, intent, configPath string) (string, error) {
+	items, err := buildQueueItems(io.Discard, []int{issue}, intent, nil, configPath)
+	if err != nil {
+		return "", err
+	}
+
+	connStr, err := db.DefaultConnStr()
+	if err != nil {
+		return "", err
+	}
+	d, err := db.Open(connStr)
+	if err != nil {
+		return "", err
+	}
+	defer d.Close()
+
+	if err := d.Migrate(); err != nil {
+		return "", err
+	}
+	if err := d.QueueAdd(items); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Added #%d to the queue: %s", issue, items[0].FeatureIntent), nil
+}
+
+func init() {
+	discordBotCmd.Flags().String("listen", ":17433", "Address to serve the interactions endpoint on")
+	discordBotCmd.Flags().String("config", "", "Path to the project's pipeline.yaml (required)")
+	discordBotCmd.Flags().Bool("register", false, "Register the /factory slash commands before serving")
+	discordCmd.AddCommand(discordBotCmd)
+}
@@ -2,8 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/session"
 	"github.com/spf13/cobra"
 )
 
@@ -28,6 +30,16 @@ var eventLogCmd = &cobra.Command{
 			exitCode = &v
 		}
 
+		// Inside a sandbox there is no database; leave the event for the host.
+		if spool := os.Getenv(session.SpoolEnv); spool != "" {
+			ev := session.SpooledEvent{Session: sessionID, Event: event, Issue: issue, Stage: stage, ExitCode: exitCode, Metadata: metadata}
+			if err := session.WriteSpooledEvent(spool, ev); err != nil {
+				return fmt.Errorf("spool event: %w", err)
+			}
+			fmt.Printf("Spooled event: session=%s event=%s\n", sessionID, event)
+			return nil
+		}
+
 		connStr, err := db.DefaultConnStr()
 		if err != nil {
 			return err
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/lessons"
+	"github.com/spf13/cobra"
+)
+
+var lessonsCmd = &cobra.Command{
+	Use:   "lessons",
+	Short: "Curate the lessons from past pipelines injected into prompts as {{lessons}}",
+}
+
+var lessonsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List lessons, in effect and candidate",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		all, _ := cmd.Flags().GetBool("all")
+		format, _ := cmd.Flags().GetString("format")
+
+		d, cleanup, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		list, err := d.LessonList(namespace, all)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			return writeJSON(cmd, list)
+		}
+		if len(list) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No lessons recorded.")
+			return nil
+		}
+
+		minOcc := lessons.DefaultMinOccurrences
+		if cfg, err := config.LoadDefault(); err == nil && cfg.Pipeline.Lessons.MinOccurrences > 0 {
+			minOcc = cfg.Pipeline.Lessons.MinOccurrences
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTATE\tSOURCE\tHITS\tSCOPE\tLESSON")
+		for _, l := range list {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", l.ID, lessonState(l, minOcc), l.Source, l.Hits, lessonScope(l), l.Text)
+		}
+		return w.Flush()
+	},
+}
+
+func lessonState(l db.LessonRecord, minOcc int) string {
+	switch {
+	case l.Dismissed:
+		return "dismissed"
+	case l.Pinned:
+		return "pinned"
+	case lessons.Active(l, minOcc):
+		return "active"
+	}
+	return "candidate"
+}
+
+func lessonScope(l db.LessonRecord) string {
+	switch {
+	case l.Stage != "" && l.CheckName != "":
+		return "stage " + l.Stage + ", check " + l.CheckName
+	case l.Stage != "":
+		return "stage " + l.Stage
+	case l.CheckName != "":
+		return "check " + l.CheckName
+	}
+	return "all stages"
+}
+
+var lessonsAddCmd = &cobra.Command{
+	Use:   "add <text>",
+	Short: "Add a lesson; it applies to new prompts immediately",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		stage, _ := cmd.Flags().GetString("stage")
+		check, _ := cmd.Flags().GetString("check")
+
+		d, cleanup, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		id, err := d.LessonAdd(db.LessonRecord{Namespace: namespace, Text: args[0], Stage: stage, CheckName: check})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Added lesson %d\n", id)
+		return nil
+	},
+}
+
+var lessonsRmCmd = &cobra.Command{
+	Use:   "rm <id>",
+	Short: "Dismiss a lesson so it is neither injected nor harvested again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setLessonFlags(cmd, args[0], nil, boolPtr(true), "Dismissed")
+	},
+}
+
+var lessonsPinCmd = &cobra.Command{
+	Use:   "pin <id>",
+	Short: "Put a candidate lesson into effect without waiting for it to recur",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setLessonFlags(cmd, args[0], boolPtr(true), boolPtr(false), "Pinned")
+	},
+}
+
+func setLessonFlags(cmd *cobra.Command, arg string, pinned, dismissed *bool, verb string) error {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return fmt.Errorf("invalid lesson ID %q", arg)
+	}
+	namespace, _ := cmd.Flags().GetString("namespace")
+
+	d, cleanup, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := d.LessonSetFlags(namespace, id, pinned, dismissed); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s lesson %d\n", verb, id)
+	return nil
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func init() {
+	for _, c := range []*cobra.Command{lessonsListCmd, lessonsAddCmd, lessonsRmCmd, lessonsPinCmd} {
+		c.Flags().String("namespace", "", "Project namespace (org/repo); empty for the default pipeline config")
+		lessonsCmd.AddCommand(c)
+	}
+	lessonsListCmd.Flags().Bool("all", false, "Include dismissed lessons")
+	lessonsListCmd.Flags().String("format", "text", "Output format: text or json")
+	lessonsAddCmd.Flags().String("stage", "", "Only inject into this stage")
+	lessonsAddCmd.Flags().String("check", "", "Only inject into stages that run this check")
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/discord"
+	"github.com/lucasnoah/taintfactory/internal/notify"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Slack, webhook, and email notification sinks",
+}
+
+var notifyRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Poll pipeline events and deliver them to the configured sinks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		interval, _ := cmd.Flags().GetDuration("interval")
+
+		connStr, err := db.DefaultConnStr()
+		if err != nil {
+			return err
+		}
+		d, err := db.Open(connStr)
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+
+		store, err := pipeline.DefaultStore()
+		if err != nil {
+			return err
+		}
+
+		cursor, err := loadNotifyCursor()
+		if err != nil {
+			return fmt.Errorf("load cursor: %w", err)
+		}
+
+		fmt.Printf("Notification poller started (interval: %s, cursor: %d)\n", interval, cursor.LastEventID)
+
+		for {
+			if err := notifyPollOnce(d, store, cursor); err != nil {
+				fmt.Fprintf(os.Stderr, "poll error: %v\n", err)
+			}
+			time.Sleep(interval)
+		}
+	},
+}
+
+var notifyDeadLettersCmd = &cobra.Command{
+	Use:   "dead-letters",
+	Short: "List notifications that failed delivery after all retries",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d, err := openAnalyticsDB()
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+
+		limit, _ := cmd.Flags().GetInt("limit")
+		letters, err := d.NotificationDeadLetterList(limit)
+		if err != nil {
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "json" {
+			return writeJSON(cmd, letters)
+		}
+
+		if len(letters) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No dead letters.")
+			return nil
+		}
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tSINK\tISSUE\tEVENT\tATTEMPTS\tERROR")
+		for _, l := range letters {
+			fmt.Fprintf(w, "%s\t%s\t#%d\t%s\t%d\t%s\n", l.CreatedAt, l.Sink, l.Issue, l.Event, l.Attempts, l.Error)
+		}
+		return w.Flush()
+	},
+}
+
+// handleNotifyEvent fans a pipeline event out to the Slack, webhook, and email
+// sinks configured for its project. Undeliverable events are dead-lettered.
+func handleNotifyEvent(d *db.DB, store *pipeline.Store, evt db.PipelineEvent) error {
+	cfg := loadEventConfig(d, evt)
+	if cfg == nil {
+		return nil
+	}
+	dispatcher := notify.FromConfig(cfg.Pipeline.Notifications, d)
+	if dispatcher == nil {
+		return nil // project has no outbound sinks configured
+	}
+
+	ps, _ := store.Get(evt.Issue) // title is optional enrichment
+	return dispatcher.Dispatch(notify.FromPipelineEvent(evt, ps))
+}
+
+// loadNotifyCursor loads the sink poller's cursor. The first time, it starts
+// where the Discord poller is, which used to deliver to the sinks too, so
+// upgrading does not resend old events.
+func loadNotifyCursor() (*discord.Cursor, error) {
+	path := filepath.Join(config.DataDir(), "notify_cursor.json")
+	cursor, err := discord.LoadCursor(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+		if dc, err := discord.LoadCursor(filepath.Join(config.DataDir(), "discord_cursor.json")); err == nil {
+			cursor.LastEventID = dc.LastEventID
+		}
+	}
+	return cursor, nil
+}
+
+func notifyPollOnce(d *db.DB, store *pipeline.Store, cursor *discord.Cursor) error {
+	events, err := d.GetPipelineEventsSince(cursor.LastEventID, 50)
+	if err != nil {
+		return err
+	}
+	for _, evt := range events {
+		if err := handleNotifyEvent(d, store, evt); err != nil {
+			fmt.Fprintf(os.Stderr, "event %d notify error: %v\n", evt.ID, err)
+		}
+		cursor.LastEventID = evt.ID
+		_ = cursor.Save()
+	}
+	return nil
+}
+
+// notifyPollTick runs one pass of the sink poller, for orchestrator check-in.
+func notifyPollTick() error {
+	connStr, err := db.DefaultConnStr()
+	if err != nil {
+		return err
+	}
+	d, err := db.Open(connStr)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	store, err := pipeline.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	cursor, err := loadNotifyCursor()
+	if err != nil {
+		return err
+	}
+	return notifyPollOnce(d, store, cursor)
+}
+
+func init() {
+	notifyRunCmd.Flags().Duration("interval", 15*time.Second, "How often to poll for new events")
+	notifyCmd.AddCommand(notifyRunCmd)
+	notifyDeadLettersCmd.Flags().Int("limit", 50, "Maximum number of dead letters to show")
+	notifyDeadLettersCmd.Flags().String("format", "text", "Output format: text or json")
+	notifyCmd.AddCommand(notifyDeadLettersCmd)
+}
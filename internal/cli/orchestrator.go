@@ -40,6 +40,9 @@ Designed to be called on a cron schedule (e.g. every 5 minutes).`,
 		if err := discordPollTick(); err != nil {
 			fmt.Fprintf(os.Stderr, "discord poll: %v\n", err)
 		}
+		if err := notifyPollTick(); err != nil {
+			fmt.Fprintf(os.Stderr, "notify poll: %v\n", err)
+		}
 
 		format, _ := cmd.Flags().GetString("format")
 		if format == "json" {
@@ -1,20 +1,26 @@
 package cli
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
+	"github.com/lucasnoah/taintfactory/internal/attemptdiff"
 	"github.com/lucasnoah/taintfactory/internal/checks"
 	"github.com/lucasnoah/taintfactory/internal/config"
 	appctx "github.com/lucasnoah/taintfactory/internal/context"
 	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/dbprov"
 	"github.com/lucasnoah/taintfactory/internal/github"
+	"github.com/lucasnoah/taintfactory/internal/lessons"
 	"github.com/lucasnoah/taintfactory/internal/orchestrator"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/repoindex"
 	"github.com/lucasnoah/taintfactory/internal/session"
 	"github.com/lucasnoah/taintfactory/internal/stage"
 	"github.com/lucasnoah/taintfactory/internal/triage"
@@ -43,7 +49,12 @@ var pipelineCreateCmd = &cobra.Command{
 		}
 		defer cleanup()
 
-		ps, err := orch.Create(orchestrator.CreateOpts{Issue: issue})
+		baseBranch, _ := cmd.Flags().GetString("base-branch")
+		if baseBranch != "" && !config.ValidGitName(baseBranch) {
+			return fmt.Errorf("invalid base branch %q", baseBranch)
+		}
+
+		ps, err := orch.Create(orchestrator.CreateOpts{Issue: issue, BaseBranch: baseBranch})
 		if err != nil {
 			return err
 		}
@@ -52,6 +63,7 @@ var pipelineCreateCmd = &cobra.Command{
 		fmt.Fprintf(w, "Pipeline #%d created\n", ps.Issue)
 		fmt.Fprintf(w, "  Title:    %s\n", ps.Title)
 		fmt.Fprintf(w, "  Branch:   %s\n", ps.Branch)
+		fmt.Fprintf(w, "  Base:     %s\n", ps.BaseRef())
 		fmt.Fprintf(w, "  Worktree: %s\n", ps.Worktree)
 		fmt.Fprintf(w, "  Stage:    %s\n", ps.CurrentStage)
 		return nil
@@ -171,42 +183,47 @@ var pipelineStatusCmd = &cobra.Command{
 			return nil
 		}
 
-		w := cmd.OutOrStdout()
-		fmt.Fprintf(w, "Pipeline #%d: %s\n", info.Issue, info.Title)
-		fmt.Fprintf(w, "  Status:        %s\n", info.Status)
-		fmt.Fprintf(w, "  Branch:        %s\n", info.Branch)
-		fmt.Fprintf(w, "  Current Stage: %s (attempt %d)\n", info.Stage, info.Attempt)
-		if info.Session != "" {
-			fmt.Fprintf(w, "  Session:       %s (%s)\n", info.Session, info.SessionState)
-		}
-		if info.FixRound > 0 {
-			fmt.Fprintf(w, "  Fix Round:     %d\n", info.FixRound)
-		}
-
-		if len(info.GoalGates) > 0 {
-			fmt.Fprintln(w, "  Goal Gates:")
-			for k, v := range info.GoalGates {
-				status := v
-				if status == "" {
-					status = "pending"
-				}
-				fmt.Fprintf(w, "    %s: %s\n", k, status)
+		writePipelineStatus(cmd.OutOrStdout(), info)
+		return nil
+	},
+}
+
+// writePipelineStatus renders the human-readable status block used by
+// `factory pipeline status` and the Discord bot's /factory status command.
+func writePipelineStatus(w io.Writer, info *orchestrator.StatusInfo) {
+	fmt.Fprintf(w, "Pipeline #%d: %s\n", info.Issue, info.Title)
+	fmt.Fprintf(w, "  Status:        %s\n", info.Status)
+	fmt.Fprintf(w, "  Branch:        %s\n", info.Branch)
+	fmt.Fprintf(w, "  Current Stage: %s (attempt %d)\n", info.Stage, info.Attempt)
+	if info.Session != "" {
+		fmt.Fprintf(w, "  Session:       %s (%s)\n", info.Session, info.SessionState)
+	}
+	if info.FixRound > 0 {
+		fmt.Fprintf(w, "  Fix Round:     %d\n", info.FixRound)
+	}
+
+	if len(info.GoalGates) > 0 {
+		fmt.Fprintln(w, "  Goal Gates:")
+		for k, v := range info.GoalGates {
+			status := v
+			if status == "" {
+				status = "pending"
 			}
+			fmt.Fprintf(w, "    %s: %s\n", k, status)
 		}
+	}
 
-		if len(info.StageHistory) > 0 {
-			fmt.Fprintln(w, "  Stage History:")
-			for _, h := range info.StageHistory {
-				firstPass := ""
-				if h.ChecksFirstPass {
-					firstPass = " (first-pass)"
-				}
-				fmt.Fprintf(w, "    %s attempt %d: %s (%s, %d fix rounds%s)\n",
-					h.Stage, h.Attempt, h.Outcome, h.Duration, h.FixRounds, firstPass)
+	if len(info.StageHistory) > 0 {
+		fmt.Fprintln(w, "  Stage History:")
+		for _, h := range info.StageHistory {
+			firstPass := ""
+			if h.ChecksFirstPass {
+				firstPass = " (first-pass)"
 			}
+			fmt.Fprintf(w, "    %s attempt %d: %s (%s, %d fix rounds%s)\n",
+				h.Stage, h.Attempt, h.Outcome, h.Duration, h.FixRounds, firstPass)
 		}
-		return nil
-	},
+	}
 }
 
 var pipelineRetryCmd = &cobra.Command{
@@ -236,6 +253,49 @@ var pipelineRetryCmd = &cobra.Command{
 	},
 }
 
+var pipelineApproveCmd = &cobra.Command{
+	Use:   "approve [issue-number] [stage]",
+	Short: "Approve the current stage of a pipeline and advance past it",
+	Long: `Record a human sign-off for the pipeline's current stage and advance to the
+next stage. Use this to release a pipeline that escalated (blocked) at a stage
+you have reviewed manually. The stage argument must match the current stage.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issue, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid issue number: %s", args[0])
+		}
+
+		reason, _ := cmd.Flags().GetString("reason")
+
+		orch, cleanup, err := newOrchestrator()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		result, err := orch.Approve(orchestrator.ApproveOpts{Issue: issue, Stage: args[1], Reason: reason})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout(), approveMessage(result))
+		return nil
+	},
+}
+
+// approveMessage summarises the outcome of an approval.
+func approveMessage(result *orchestrator.AdvanceResult) string {
+	switch result.Action {
+	case "advanced":
+		return fmt.Sprintf("Pipeline #%d: %s approved, advanced to %s", result.Issue, result.Stage, result.NextStage)
+	case "completed":
+		return fmt.Sprintf("Pipeline #%d: %s approved, pipeline completed", result.Issue, result.Stage)
+	default:
+		return fmt.Sprintf("Pipeline #%d: %s approved (%s: %s)", result.Issue, result.Stage, result.Action, result.Message)
+	}
+}
+
 var pipelineFailCmd = &cobra.Command{
 	Use:   "fail [issue-number]",
 	Short: "Mark a pipeline as failed",
@@ -343,23 +403,129 @@ var pipelineCleanupCmd = &cobra.Command{
 	},
 }
 
+var pipelineDiffCmd = &cobra.Command{
+	Use:   "diff <issue-number> <stage> <attempt-a> <attempt-b>",
+	Short: "Compare prompts, checks, outcomes, and code between two stage attempts",
+	Args:  cobra.ExactArgs(4),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issue, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid issue number: %s", args[0])
+		}
+		stageID := args[1]
+		a, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid attempt number: %s", args[2])
+		}
+		b, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("invalid attempt number: %s", args[3])
+		}
+
+		store, err := pipeline.DefaultStore()
+		if err != nil {
+			return fmt.Errorf("open store: %w", err)
+		}
+		ps, err := store.Get(issue)
+		if err != nil {
+			return err
+		}
+
+		sideA := attemptdiff.Load(store, issue, stageID, a)
+		sideB := attemptdiff.Load(store, issue, stageID, b)
+
+		// Check runs live in the DB; the rest of the diff is still useful without it.
+		if d, err := openAnalyticsDB(); err == nil {
+			defer d.Close()
+			sideA.Checks, _ = d.GetAttemptCheckRuns(ps.Namespace, issue, stageID, a)
+			sideB.Checks, _ = d.GetAttemptCheckRuns(ps.Namespace, issue, stageID, b)
+		} else {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: check results unavailable: %v\n", err)
+		}
+
+		res := attemptdiff.Compare(issue, stageID, sideA, sideB)
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "json" {
+			return writeJSON(cmd, res)
+		}
+
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Pipeline #%d: %s attempt %d vs attempt %d\n", issue, stageID, a, b)
+
+		fmt.Fprintln(w, "\n== Outcome ==")
+		fmt.Fprintf(w, "  attempt %d: %s\n", a, outcomeLine(res.OutcomeA))
+		fmt.Fprintf(w, "  attempt %d: %s\n", b, outcomeLine(res.OutcomeB))
+		if len(res.FilesOnlyA) > 0 {
+			fmt.Fprintf(w, "  files only in attempt %d: %s\n", a, strings.Join(res.FilesOnlyA, ", "))
+		}
+		if len(res.FilesOnlyB) > 0 {
+			fmt.Fprintf(w, "  files only in attempt %d: %s\n", b, strings.Join(res.FilesOnlyB, ", "))
+		}
+
+		fmt.Fprintln(w, "\n== Checks ==")
+		if len(res.Checks) == 0 {
+			fmt.Fprintln(w, "  (no check runs recorded)")
+		}
+		for _, c := range res.Checks {
+			marker := " "
+			if c.Changed() {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s %-20s %s\n", marker, c.Check, c.Label())
+		}
+
+		fmt.Fprintln(w, "\n== Prompt ==")
+		if res.PromptDiff == "" {
+			fmt.Fprintln(w, "  (identical)")
+		} else {
+			fmt.Fprint(w, res.PromptDiff)
+		}
+
+		fmt.Fprintln(w, "\n== Code ==")
+		if res.CodeDiff == "" {
+			fmt.Fprintln(w, "  (identical)")
+		} else {
+			fmt.Fprint(w, res.CodeDiff)
+		}
+		return nil
+	},
+}
+
+// outcomeLine formats a stage outcome as a one-line status summary.
+func outcomeLine(o *pipeline.StageOutcome) string {
+	if o == nil {
+		return "(no outcome recorded)"
+	}
+	summary := strings.TrimSpace(strings.ReplaceAll(o.Summary, "\n", "; "))
+	if summary == "" {
+		return o.Status
+	}
+	return fmt.Sprintf("%s — %s", o.Status, summary)
+}
+
 func init() {
 	pipelineCmd.AddCommand(pipelineCreateCmd)
 	pipelineCmd.AddCommand(pipelineAdvanceCmd)
 	pipelineCmd.AddCommand(pipelineListCmd)
 	pipelineCmd.AddCommand(pipelineStatusCmd)
 	pipelineCmd.AddCommand(pipelineRetryCmd)
+	pipelineCmd.AddCommand(pipelineApproveCmd)
 	pipelineCmd.AddCommand(pipelineFailCmd)
 	pipelineCmd.AddCommand(pipelineAbortCmd)
 	pipelineCmd.AddCommand(pipelineCleanupCmd)
+	pipelineCmd.AddCommand(pipelineDiffCmd)
 
+	pipelineCreateCmd.Flags().String("base-branch", "", "Branch to start from and merge into (overrides target-branch: labels and config)")
 	pipelineListCmd.Flags().String("status", "", "Filter by status (pending, in_progress, completed, failed, blocked)")
 	pipelineStatusCmd.Flags().String("format", "text", "Output format: text or json")
 	pipelineAdvanceCmd.Flags().String("format", "text", "Output format: text or json")
 	pipelineRetryCmd.Flags().String("reason", "", "Reason for retry")
+	pipelineApproveCmd.Flags().String("reason", "", "Reason for approval")
 	pipelineFailCmd.Flags().String("reason", "", "Reason for failure")
 	pipelineAbortCmd.Flags().Bool("remove-worktree", false, "Remove the worktree after aborting")
 	pipelineCleanupCmd.Flags().Bool("all", false, "Clean up all completed and failed pipelines")
+	pipelineDiffCmd.Flags().String("format", "text", "Output format: text or json")
 }
 
 // newOrchestrator builds a fully-wired Orchestrator from default paths/config.
@@ -404,8 +570,12 @@ func newOrchestrator() (*orchestrator.Orchestrator, func(), error) {
 
 	checker := checks.NewRunner(&checks.ExecRunner{})
 	builder := appctx.NewBuilder(store, &appctx.ExecGit{})
+	builder.SetSummarizer(github.DefaultClaudeFn)
+	builder.SetRetriever(repoindex.NewProvider(filepath.Join(config.DataDir(), "index")))
+	builder.SetLessons(lessons.NewProvider(database, cfg.Pipeline.Lessons))
 	engine := stage.NewEngine(sessions, checker, builder, store, database, cfg)
 	engine.SetProgress(os.Stderr)
+	engine.SetWorktrees(wt)
 
 	orch := orchestrator.NewOrchestrator(store, database, ghClient, wt, sessions, engine, builder, cfg)
 	orch.SetClaudeFn(github.DefaultClaudeFn)
@@ -428,6 +598,20 @@ func newOrchestrator() (*orchestrator.Orchestrator, func(), error) {
 		}
 	}
 
-	cleanup := func() { database.Close() }
+	// Admin connection for per-pipeline databases (opened lazily by database/sql)
+	var adminConn *sql.DB
+	if adminStr, err := dbprov.AdminConnStr(connStr); err == nil {
+		if adminConn, err = sql.Open("pgx", adminStr); err == nil {
+			orch.SetDatabaseAdmin(adminConn)
+			engine.SetDatabaseAdmin(adminConn)
+		}
+	}
+
+	cleanup := func() {
+		if adminConn != nil {
+			adminConn.Close()
+		}
+		database.Close()
+	}
 	return orch, cleanup, nil
 }
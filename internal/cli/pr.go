@@ -37,7 +37,7 @@ var prCreateCmd = &cobra.Command{
 
 		// Push branch first
 		runner := &github.ExecRunner{}
-		gh := github.NewClientWithGit(runner, runner)
+		gh := github.NewClientWithGit(runner, runner).WithRemote(ps.RemoteName())
 		if err := gh.PushBranch(ps.Worktree, ps.Branch); err != nil {
 			return fmt.Errorf("push branch: %w", err)
 		}
@@ -50,7 +50,7 @@ var prCreateCmd = &cobra.Command{
 			Title:  title,
 			Body:   body,
 			Branch: ps.Branch,
-			Base:   "main",
+			Base:   ps.BaseBranchName(),
 		})
 		if err != nil {
 			return err
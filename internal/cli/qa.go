@@ -62,7 +62,7 @@ var qaDetectBrowserCmd = &cobra.Command{
 			ps, psErr := store.Get(issue)
 			if psErr == nil && ps.Worktree != "" {
 				git := &appctx.ExecGit{}
-				if output, err := git.FilesChanged(ps.Worktree); err == nil && output != "" {
+				if output, err := git.FilesChanged(ps.Worktree, ps.BaseRef()); err == nil && output != "" {
 					output = strings.TrimRight(output, "\n")
 					filesChanged = strings.Split(output, "\n")
 				}
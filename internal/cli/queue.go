@@ -3,6 +3,7 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -15,17 +16,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// repoToNamespace derives "owner/repo" from a pipeline repo URL.
-func repoToNamespace(repo string) string {
-	repo = strings.TrimPrefix(repo, "https://")
-	repo = strings.TrimPrefix(repo, "http://")
-	parts := strings.SplitN(repo, "/", 3)
-	if len(parts) == 3 {
-		return parts[1] + "/" + parts[2]
-	}
-	return repo
-}
-
 var queueCmd = &cobra.Command{
 	Use:   "queue",
 	Short: "Manage the issue processing queue",
@@ -39,6 +29,7 @@ var queueAddCmd = &cobra.Command{
 		intent, _ := cmd.Flags().GetString("intent")
 		dependsOnStr, _ := cmd.Flags().GetString("depends-on")
 		configFlag, _ := cmd.Flags().GetString("config")
+		priority, _ := cmd.Flags().GetInt("priority")
 
 		// Resolve config path to absolute and validate it exists
 		resolvedConfigPath, err := resolveConfigPath(configFlag)
@@ -46,14 +37,6 @@ var queueAddCmd = &cobra.Command{
 			return err
 		}
 
-		// Derive namespace from the pipeline config
-		var ns string
-		if resolvedConfigPath != "" {
-			if cfg, cfgErr := config.Load(resolvedConfigPath); cfgErr == nil {
-				ns = repoToNamespace(cfg.Pipeline.Repo)
-			}
-		}
-
 		// Parse --depends-on flag
 		var dependsOn []int
 		if dependsOnStr != "" {
@@ -86,35 +69,21 @@ var queueAddCmd = &cobra.Command{
 			}
 		}
 
-		ghClient := github.NewClient(&github.ExecRunner{})
-
-		items := make([]db.QueueAddItem, 0, len(args))
+		issues := make([]int, 0, len(args))
 		for _, arg := range args {
 			n, err := strconv.Atoi(arg)
 			if err != nil || n <= 0 {
 				return fmt.Errorf("invalid issue number %q: must be a positive integer", arg)
 			}
+			issues = append(issues, n)
+		}
 
-			itemIntent := intent
-			if itemIntent == "" {
-				// Fetch issue and derive intent via LLM
-				issue, err := ghClient.GetIssue(n)
-				if err != nil {
-					return fmt.Errorf("issue #%d: failed to fetch from GitHub: %w", n, err)
-				}
-				fmt.Fprintf(cmd.OutOrStdout(), "issue #%d: deriving feature intent...\n", n)
-				derived, err := github.DeriveFeatureIntent(issue, github.DefaultClaudeFn)
-				if err != nil {
-					return fmt.Errorf("issue #%d: intent derivation failed: %w", n, err)
-				}
-				if derived == "" {
-					return fmt.Errorf("issue #%d: could not derive feature intent — pass --intent or ensure the issue describes clear user-facing value", n)
-				}
-				fmt.Fprintf(cmd.OutOrStdout(), "issue #%d: %s\n", n, derived)
-				itemIntent = derived
-			}
-
-			items = append(items, db.QueueAddItem{Namespace: ns, Issue: n, FeatureIntent: itemIntent, DependsOn: dependsOn, ConfigPath: resolvedConfigPath})
+		items, err := buildQueueItems(cmd.OutOrStdout(), issues, intent, dependsOn, resolvedConfigPath)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].Priority = priority
 		}
 
 		connStr, err := db.DefaultConnStr()
@@ -140,6 +109,47 @@ var queueAddCmd = &cobra.Command{
 	},
 }
 
+// buildQueueItems prepares queue rows for the given issues. When intent is
+// empty, each issue is fetched from GitHub and its feature intent derived via
+// the LLM, with progress written to out. Shared by `factory queue add` and the
+// Discord bot.
+func buildQueueItems(out io.Writer, issues []int, intent string, dependsOn []int, configPath string) ([]db.QueueAddItem, error) {
+	// Derive namespace from the pipeline config
+	var ns string
+	if configPath != "" {
+		if cfg, cfgErr := config.Load(configPath); cfgErr == nil {
+			ns = config.RepoNamespace(cfg.Pipeline.Repo)
+		}
+	}
+
+	ghClient := github.NewClient(&github.ExecRunner{})
+
+	items := make([]db.QueueAddItem, 0, len(issues))
+	for _, n := range issues {
+		itemIntent := intent
+		if itemIntent == "" {
+			// Fetch issue and derive intent via LLM
+			issue, err := ghClient.GetIssue(n)
+			if err != nil {
+				return nil, fmt.Errorf("issue #%d: failed to fetch from GitHub: %w", n, err)
+			}
+			fmt.Fprintf(out, "issue #%d: deriving feature intent...\n", n)
+			derived, err := github.DeriveFeatureIntent(issue, github.DefaultClaudeFn)
+			if err != nil {
+				return nil, fmt.Errorf("issue #%d: intent derivation failed: %w", n, err)
+			}
+			if derived == "" {
+				return nil, fmt.Errorf("issue #%d: could not derive feature intent — pass --intent or ensure the issue describes clear user-facing value", n)
+			}
+			fmt.Fprintf(out, "issue #%d: %s\n", n, derived)
+			itemIntent = derived
+		}
+
+		items = append(items, db.QueueAddItem{Namespace: ns, Issue: n, FeatureIntent: itemIntent, DependsOn: dependsOn, ConfigPath: configPath})
+	}
+	return items, nil
+}
+
 var queueSetIntentCmd = &cobra.Command{
 	Use:   "set-intent <issue> <intent>",
 	Short: "Set or update the feature intent for a queued issue",
@@ -222,7 +232,7 @@ var queueListCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
-		fmt.Fprintln(w, "POS\tISSUE\tSTATUS\tINTENT\tDEPS\tADDED")
+		fmt.Fprintln(w, "POS\tPRI\tISSUE\tSTATUS\tINTENT\tDEPS\tADDED")
 		for _, item := range items {
 			intent := item.FeatureIntent
 			if len(intent) > 50 {
@@ -239,7 +249,7 @@ var queueListCmd = &cobra.Command{
 				}
 				deps = fmt.Sprintf("[waits: %s]", strings.Join(parts, ", "))
 			}
-			fmt.Fprintf(w, "%d\t#%d\t%s\t%s\t%s\t%s\n", item.Position, item.Issue, item.Status, intent, deps, item.AddedAt)
+			fmt.Fprintf(w, "%d\t%d\t#%d\t%s\t%s\t%s\t%s\n", item.Position, item.Priority, item.Issue, item.Status, intent, deps, item.AddedAt)
 		}
 		return w.Flush()
 	},
@@ -342,6 +352,7 @@ func init() {
 	queueAddCmd.Flags().String("intent", "", "Feature intent: what value this brings to the end user")
 	queueAddCmd.Flags().String("depends-on", "", "Comma-separated issue numbers this must wait for (e.g. --depends-on 133,134 or --depends-on #133,#134)")
 	queueAddCmd.Flags().String("config", "", "Path to the project's pipeline.yaml (required)")
+	queueAddCmd.Flags().Int("priority", 0, "Queue priority: higher values are started before lower ones")
 	queueListCmd.Flags().String("format", "table", "Output format: table or json")
 	queueClearCmd.Flags().Bool("confirm", false, "Confirm clearing the entire queue")
 
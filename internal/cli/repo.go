@@ -3,15 +3,19 @@ package cli
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/lucasnoah/taintfactory/internal/config"
 	"github.com/lucasnoah/taintfactory/internal/db"
 	"github.com/lucasnoah/taintfactory/internal/dbprov"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
 	"github.com/spf13/cobra"
 )
 
@@ -31,10 +35,15 @@ var repoAddCmd = &cobra.Command{
 		pollLabel, _ := cmd.Flags().GetString("label")
 		pollInterval, _ := cmd.Flags().GetInt("poll-interval")
 		namespace, _ := cmd.Flags().GetString("namespace")
+		baseBranch, _ := cmd.Flags().GetString("base-branch")
+		remote, _ := cmd.Flags().GetString("remote")
 
 		if namespace == "" {
 			namespace = repoURLToNamespace(repoURL)
 		}
+		if err := checkGitNames(baseBranch, remote); err != nil {
+			return err
+		}
 
 		d, cleanup, err := openDB()
 		if err != nil {
@@ -50,6 +59,8 @@ var repoAddCmd = &cobra.Command{
 			PollLabel:    pollLabel,
 			PollInterval: pollInterval,
 			Active:       true,
+			BaseBranch:   baseBranch,
+			Remote:       remote,
 		}); err != nil {
 			return err
 		}
@@ -102,13 +113,17 @@ var repoListCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-		fmt.Fprintln(w, "NAMESPACE\tREPO URL\tLABEL\tINTERVAL\tACTIVE")
+		fmt.Fprintln(w, "NAMESPACE\tREPO URL\tLABEL\tINTERVAL\tACTIVE\tBASE")
 		for _, r := range repos {
 			label := r.PollLabel
 			if label == "" {
 				label = "-"
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\t%ds\t%v\n", r.Namespace, r.RepoURL, label, r.PollInterval, r.Active)
+			base := "-"
+			if r.BaseBranch != "" || r.Remote != "" {
+				base = fmt.Sprintf("%s/%s", orDefault(r.Remote, "(config)"), orDefault(r.BaseBranch, "(config)"))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%ds\t%v\t%s\n", r.Namespace, r.RepoURL, label, r.PollInterval, r.Active, base)
 		}
 		return w.Flush()
 	},
@@ -158,6 +173,26 @@ var repoUpdateCmd = &cobra.Command{
 			b, _ := strconv.ParseBool(v)
 			opts.Active = &b
 		}
+		if cmd.Flags().Changed("base-branch") {
+			v, _ := cmd.Flags().GetString("base-branch")
+			opts.BaseBranch = &v
+		}
+		if cmd.Flags().Changed("remote") {
+			v, _ := cmd.Flags().GetString("remote")
+			opts.Remote = &v
+		}
+		if opts.BaseBranch != nil || opts.Remote != nil {
+			var branch, remote string
+			if opts.BaseBranch != nil {
+				branch = *opts.BaseBranch
+			}
+			if opts.Remote != nil {
+				remote = *opts.Remote
+			}
+			if err := checkGitNames(branch, remote); err != nil {
+				return err
+			}
+		}
 
 		if err := d.RepoUpdate(args[0], opts); err != nil {
 			return err
@@ -243,6 +278,133 @@ var repoProvisionDBCmd = &cobra.Command{
 	},
 }
 
+var repoDBGCCmd = &cobra.Command{
+	Use:   "db-gc [namespace]",
+	Short: "Drop per-pipeline databases whose pipeline no longer exists",
+	Long:  "For repos with per_pipeline database isolation, drops databases cloned for issues that have no pipeline state. Databases younger than --min-age are kept. Requires DATABASE_URL env var.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		databaseURL := os.Getenv("DATABASE_URL")
+		if databaseURL == "" {
+			fmt.Fprintln(os.Stderr, "error: DATABASE_URL environment variable not set")
+			os.Exit(1)
+		}
+
+		adminStr, err := dbprov.AdminConnStr(databaseURL)
+		if err != nil {
+			return fmt.Errorf("parse DATABASE_URL: %w", err)
+		}
+
+		adminConn, err := sql.Open("pgx", adminStr)
+		if err != nil {
+			return fmt.Errorf("connect to admin database: %w", err)
+		}
+		defer adminConn.Close()
+
+		d, cleanup, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		repos, err := d.RepoList()
+		if err != nil {
+			return err
+		}
+
+		store, err := pipeline.DefaultStore()
+		if err != nil {
+			return fmt.Errorf("open store: %w", err)
+		}
+
+		var filterNS string
+		if len(args) > 0 {
+			filterNS = args[0]
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		minAge, _ := cmd.Flags().GetDuration("min-age")
+		failures := 0
+		dropped := 0
+		for _, r := range repos {
+			if filterNS != "" && r.Namespace != filterNS {
+				continue
+			}
+			if r.ConfigPath == "" {
+				continue
+			}
+			cfg, err := config.Load(r.ConfigPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %s: failed to load config: %v\n", r.Namespace, err)
+				failures++
+				continue
+			}
+			if !cfg.Pipeline.Database.PerPipeline() {
+				continue
+			}
+			ns := r.Namespace
+			// Only a confirmed-missing pipeline.json frees a database; a state
+			// file that fails to read or parse still belongs to a live pipeline.
+			keep := func(issue int) bool {
+				_, err := store.GetForNamespace(ns, issue)
+				return !errors.Is(err, fs.ErrNotExist)
+			}
+			if dryRun {
+				names, err := dbprov.Orphans(adminConn, cfg.Pipeline.Database, minAge, keep)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "error: %s: %v\n", ns, err)
+					failures++
+					continue
+				}
+				for _, name := range names {
+					fmt.Printf("would drop %s/%s\n", ns, name)
+				}
+				dropped += len(names)
+				continue
+			}
+			names, err := dbprov.GC(adminConn, cfg.Pipeline.Database, minAge, keep)
+			for _, name := range names {
+				fmt.Printf("dropped %s/%s\n", ns, name)
+			}
+			dropped += len(names)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s: %v\n", ns, err)
+				failures++
+			}
+		}
+
+		if failures > 0 {
+			fmt.Fprintf(os.Stderr, "%d dropped, %d failed\n", dropped, failures)
+			os.Exit(2)
+		}
+		if dryRun {
+			fmt.Fprintf(os.Stderr, "%d databases would be dropped\n", dropped)
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "%d databases dropped\n", dropped)
+		return nil
+	},
+}
+
+// checkGitNames rejects base branch and remote overrides git would not accept.
+// Empty values are allowed and mean "use the pipeline config".
+func checkGitNames(baseBranch, remote string) error {
+	if baseBranch != "" && !config.ValidGitName(baseBranch) {
+		return fmt.Errorf("invalid base branch %q", baseBranch)
+	}
+	if remote != "" && !config.ValidGitName(remote) {
+		return fmt.Errorf("invalid remote %q", remote)
+	}
+	return nil
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
 // repoURLToNamespace extracts "owner/repo" from a GitHub URL.
 func repoURLToNamespace(url string) string {
 	for _, prefix := range []string{"https://github.com/", "github.com/"} {
@@ -259,12 +421,15 @@ func init() {
 	repoCmd.AddCommand(repoRemoveCmd)
 	repoCmd.AddCommand(repoUpdateCmd)
 	repoCmd.AddCommand(repoProvisionDBCmd)
+	repoCmd.AddCommand(repoDBGCCmd)
 
 	repoAddCmd.Flags().String("local-path", "", "Local path to cloned repo")
 	repoAddCmd.Flags().String("config", "", "Path to pipeline.yaml")
 	repoAddCmd.Flags().String("label", "", "GitHub label to poll for")
 	repoAddCmd.Flags().Int("poll-interval", 120, "Poll interval in seconds")
 	repoAddCmd.Flags().String("namespace", "", "Namespace (default: derived from repo URL)")
+	repoAddCmd.Flags().String("base-branch", "", "Branch pipelines start from and merge into (default: pipeline.base_branch)")
+	repoAddCmd.Flags().String("remote", "", "Git remote to fetch and push (default: pipeline.remote)")
 	_ = repoAddCmd.MarkFlagRequired("local-path")
 	_ = repoAddCmd.MarkFlagRequired("config")
 
@@ -273,4 +438,9 @@ func init() {
 	repoUpdateCmd.Flags().String("label", "", "GitHub label to poll for")
 	repoUpdateCmd.Flags().Int("poll-interval", 120, "Poll interval in seconds")
 	repoUpdateCmd.Flags().String("active", "", "Enable/disable repo (true/false)")
+	repoUpdateCmd.Flags().String("base-branch", "", "Branch pipelines start from and merge into (\"\" = use the config)")
+	repoUpdateCmd.Flags().String("remote", "", "Git remote to fetch and push (\"\" = use the config)")
+
+	repoDBGCCmd.Flags().Bool("dry-run", false, "List orphaned databases without dropping them")
+	repoDBGCCmd.Flags().Duration("min-age", time.Hour, "Keep databases younger than this; a new pipeline's database exists before its state does")
 }
@@ -44,6 +44,10 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(triageCmd)
 	rootCmd.AddCommand(discordCmd)
+	rootCmd.AddCommand(notifyCmd)
+	rootCmd.AddCommand(secretCmd)
 	rootCmd.AddCommand(repoCmd)
 	rootCmd.AddCommand(deployCmd)
+	rootCmd.AddCommand(lessonsCmd)
+	rootCmd.AddCommand(cgroupCmd)
 }
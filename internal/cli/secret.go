@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage the local encrypted secret store (referenced as secret://<name>)",
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Create or replace a secret; reads the value from stdin when omitted",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else {
+			// Reading from stdin keeps the value out of shell history.
+			data, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("read value from stdin: %w", err)
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+		if value == "" {
+			return fmt.Errorf("secret value cannot be empty")
+		}
+
+		if err := secrets.DefaultStore().Set(args[0], value); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Stored secret %q (use secret://%s in pipeline.env)\n", args[0], args[0])
+		return nil
+	},
+}
+
+var secretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret's value",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		value, err := secrets.DefaultStore().Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), value)
+		return nil
+	},
+}
+
+var secretListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secret names (values are never shown)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, err := secrets.DefaultStore().List()
+		if err != nil {
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "json" {
+			return writeJSON(cmd, names)
+		}
+		if len(names) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No secrets stored.")
+			return nil
+		}
+		for _, n := range names {
+			fmt.Fprintln(cmd.OutOrStdout(), n)
+		}
+		return nil
+	},
+}
+
+var secretRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := secrets.DefaultStore().Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed secret %q\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	secretListCmd.Flags().String("format", "text", "Output format: text or json")
+
+	secretCmd.AddCommand(secretSetCmd)
+	secretCmd.AddCommand(secretGetCmd)
+	secretCmd.AddCommand(secretListCmd)
+	secretCmd.AddCommand(secretRemoveCmd)
+}
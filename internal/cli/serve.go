@@ -52,6 +52,10 @@ interval, combining the web UI and the automation loop in a single process.`,
 			defer cleanup()
 
 			go runOrchestratorLoop(orch, time.Duration(orchInterval)*time.Second)
+			// Sinks retry with backoff, so they get their own loop rather than delaying check-ins.
+			go runNotifyLoop(time.Duration(orchInterval) * time.Second)
+			// Pool setup can take minutes; keep it off the check-in path too.
+			go runPoolLoop(orch, time.Duration(orchInterval)*time.Second)
 		}
 
 		triageDir, _ := triage.DefaultTriageDir()
@@ -74,6 +78,26 @@ func runOrchestratorLoop(orch *orchestrator.Orchestrator, interval time.Duration
 	}
 }
 
+func runNotifyLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := notifyPollTick(); err != nil {
+			log.Printf("notify poll: %v", err)
+		}
+	}
+}
+
+// runPoolLoop refreshes the worktree pools now and then every interval.
+func runPoolLoop(orch *orchestrator.Orchestrator, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		orch.RefreshPools()
+		<-ticker.C
+	}
+}
+
 func init() {
 	serveCmd.Flags().Int("port", 17432, "Port to listen on")
 	serveCmd.Flags().Bool("with-orchestrator", false, "Run orchestrator check-in loop alongside web server")
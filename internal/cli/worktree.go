@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"text/tabwriter"
+	"time"
 
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
 	"github.com/lucasnoah/taintfactory/internal/worktree"
@@ -114,7 +116,123 @@ var worktreePathCmd = &cobra.Command{
 	},
 }
 
+var worktreePoolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "Manage the pool of pre-set-up worktrees (pipeline.worktree_pool)",
+}
+
+var worktreePoolStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List the pooled worktrees",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		orch, cleanup, err := newOrchestrator()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		opts, slots, err := orch.PoolStatus(namespace)
+		if err != nil {
+			return err
+		}
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "Pool for %s/%s: %d of %d slots\n", opts.Remote, opts.Branch, len(slots), opts.Size)
+		if len(slots) == 0 {
+			return nil
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SLOT\tSTATE\tCOMMIT\tUPDATED\tPATH")
+		for _, s := range slots {
+			state := "ready"
+			switch {
+			case s.Busy:
+				state = "busy"
+			case s.Error != "":
+				state = "setup failed"
+			case !s.Ready:
+				state = "not ready"
+			}
+			updated := "-"
+			if !s.UpdatedAt.IsZero() {
+				updated = s.UpdatedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", s.Index, state, displaySHA(s.Commit), updated, s.Path)
+		}
+		return tw.Flush()
+	},
+}
+
+var worktreePoolRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Create, update and set up pooled worktrees until the pool is current",
+	Long: `Create, update and set up pooled worktrees until the pool is current.
+
+With --watch, every pool (the default config's and each active repo's) is
+refreshed every --interval until interrupted. Run it alongside cron-driven
+check-ins; factory serve --with-orchestrator runs the same loop itself.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		watch, _ := cmd.Flags().GetBool("watch")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		orch, cleanup, err := newOrchestrator()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		if watch {
+			orch.SetProgress(cmd.OutOrStdout())
+			runPoolLoop(orch, interval)
+			return nil
+		}
+
+		actions, err := orch.RefreshPool(namespace)
+		for _, a := range actions {
+			fmt.Fprintln(cmd.OutOrStdout(), a)
+		}
+		if err != nil {
+			return err
+		}
+		if len(actions) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "Pool is up to date")
+		}
+		return nil
+	},
+}
+
+var worktreePoolDrainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Remove all idle pooled worktrees",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		orch, cleanup, err := newOrchestrator()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		n, err := orch.DrainPool(namespace)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed %d pooled worktree(s)\n", n)
+		return nil
+	},
+}
+
 func init() {
+	for _, c := range []*cobra.Command{worktreePoolStatusCmd, worktreePoolRefreshCmd, worktreePoolDrainCmd} {
+		c.Flags().String("namespace", "", "Project namespace (org/repo); empty for the default pipeline config")
+		worktreePoolCmd.AddCommand(c)
+	}
+	worktreePoolRefreshCmd.Flags().Bool("watch", false, "Keep refreshing every pool until interrupted")
+	worktreePoolRefreshCmd.Flags().Duration("interval", 2*time.Minute, "With --watch, time between refreshes")
+	worktreeCmd.AddCommand(worktreePoolCmd)
+
 	worktreeCreateCmd.Flags().String("branch", "", "Override the auto-generated branch name")
 	worktreeRemoveCmd.Flags().Bool("delete-branch", true, "Also delete the git branch")
 
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Defaults for pipeline.base_branch and pipeline.remote.
+const (
+	DefaultBaseBranch = "main"
+	DefaultRemote     = "origin"
+)
+
+// TargetBranchLabel is the issue label prefix that overrides the base branch
+// for one pipeline, e.g. "target-branch:release-2.3".
+const TargetBranchLabel = "target-branch:"
+
+// BaseBranchName returns the branch pipelines start from and merge into,
+// defaulting to main.
+func (p Pipeline) BaseBranchName() string {
+	if p.BaseBranch == "" {
+		return DefaultBaseBranch
+	}
+	return p.BaseBranch
+}
+
+// RemoteName returns the git remote pipelines fetch from and push to,
+// defaulting to origin.
+func (p Pipeline) RemoteName() string {
+	if p.Remote == "" {
+		return DefaultRemote
+	}
+	return p.Remote
+}
+
+// gitNameRe is a conservative subset of valid git branch and remote names
+// that cannot be mistaken for a command-line flag.
+var gitNameRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._/-]*$`)
+
+// ValidGitName reports whether name is usable as a base branch or remote.
+func ValidGitName(name string) bool {
+	return gitNameRe.MatchString(name) && !strings.Contains(name, "..") &&
+		!strings.HasSuffix(name, "/") && !strings.HasSuffix(name, ".lock")
+}
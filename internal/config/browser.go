@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BrowserQAConfig configures a `type: browser_qa` stage. The stage starts the
+// app from the pipeline worktree, waits for ready_url to answer, and visits
+// the routes the issue and its changes touch in headless Chromium.
+//
+//	stages:
+//	  - id: browser-qa
+//	    type: browser_qa
+//	    on_fail: implement
+//	    browser_qa:
+//	      start: ["npm run build && npm start -- --port $FACTORY_PORT"]
+//	      ready_url: http://localhost:${FACTORY_PORT}/
+//	      routes: ["/"]
+//	      visual: {threshold: 0.5}
+type BrowserQAConfig struct {
+	Start         []string      `yaml:"start"`          // long-running commands that serve the app; stopped when the stage ends
+	ReadyURL      string        `yaml:"ready_url"`      // polled until it answers below 500; may use ${FACTORY_PORT}
+	ReadyTimeout  string        `yaml:"ready_timeout"`  // default 2m
+	BaseURL       string        `yaml:"base_url"`       // routes resolve against it; default ready_url's origin; may use ${FACTORY_PORT}
+	Routes        []string      `yaml:"routes"`         // always visited, in addition to the detected routes
+	Runner        string        `yaml:"runner"`         // replaces the built-in Playwright script; see README
+	IgnoreConsole []string      `yaml:"ignore_console"` // console errors containing any of these are not failures
+	Visual        *VisualConfig `yaml:"visual"`         // compare screenshots with the base ref; off when unset
+}
+
+// VisualConfig turns on visual regression snapshots for a browser_qa stage:
+// the routes are also captured on the base ref and the screenshots diffed.
+type VisualConfig struct {
+	BaseRef   string  `yaml:"base_ref"`  // default the pipeline's <remote>/<base_branch>
+	Threshold float64 `yaml:"threshold"` // percent of a route's pixels that may change without approval; default 0.5
+}
+
+// DefaultVisualThreshold is the percent of changed pixels above which a
+// route needs human approval.
+const DefaultVisualThreshold = 0.5
+
+// MaxChange returns the approval threshold in percent of pixels.
+func (v *VisualConfig) MaxChange() float64 {
+	if v == nil || v.Threshold == 0 {
+		return DefaultVisualThreshold
+	}
+	return v.Threshold
+}
+
+// VisualBaseRef returns the ref to capture baseline screenshots on:
+// base_ref if set, otherwise pipelineBase.
+func (v *VisualConfig) VisualBaseRef(pipelineBase string) string {
+	if v != nil && v.BaseRef != "" {
+		return v.BaseRef
+	}
+	return pipelineBase
+}
+
+// DefaultBrowserReadyTimeout is how long the app gets to answer ready_url.
+const DefaultBrowserReadyTimeout = 2 * time.Minute
+
+// ReadyWait returns how long to wait for the app, defaulting to
+// DefaultBrowserReadyTimeout.
+func (b *BrowserQAConfig) ReadyWait() time.Duration {
+	if b == nil || b.ReadyTimeout == "" {
+		return DefaultBrowserReadyTimeout
+	}
+	d, err := time.ParseDuration(b.ReadyTimeout)
+	if err != nil || d <= 0 {
+		return DefaultBrowserReadyTimeout
+	}
+	return d
+}
+
+// BrowserPortVar is set to a free port for each launch of a browser_qa app,
+// so concurrent pipelines do not serve on the same port.
+const BrowserPortVar = "FACTORY_PORT"
+
+// ForPort returns a copy of b with $FACTORY_PORT and ${FACTORY_PORT} in
+// ready_url and base_url replaced by port.
+func (b *BrowserQAConfig) ForPort(port int) *BrowserQAConfig {
+	c := *b
+	c.ReadyURL = expandPort(b.ReadyURL, port)
+	c.BaseURL = expandPort(b.BaseURL, port)
+	return &c
+}
+
+func expandPort(s string, port int) string {
+	p := strconv.Itoa(port)
+	s = strings.ReplaceAll(s, "${"+BrowserPortVar+"}", p)
+	return strings.ReplaceAll(s, "$"+BrowserPortVar, p)
+}
+
+// Base returns the URL routes resolve against: base_url, else the origin of
+// ready_url.
+func (b *BrowserQAConfig) Base() string {
+	if b.BaseURL != "" {
+		return strings.TrimSuffix(b.BaseURL, "/")
+	}
+	u, err := url.Parse(b.ReadyURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// validateBrowserQA checks that a browser_qa stage can start and reach the app.
+func validateBrowserQA(s Stage, index int, errs *[]ValidationError) {
+	field := fmt.Sprintf("pipeline.stages[%d]", index)
+	if s.Type != "browser_qa" {
+		if s.BrowserQA != nil {
+			*errs = append(*errs, ValidationError{Field: field + ".browser_qa", Message: "only applies to stages with type: browser_qa"})
+		}
+		return
+	}
+	b := s.BrowserQA
+	field += ".browser_qa"
+	if b == nil {
+		*errs = append(*errs, ValidationError{Field: field, Message: "browser_qa stage requires a browser_qa section"})
+		return
+	}
+	if len(b.Start) == 0 {
+		*errs = append(*errs, ValidationError{Field: field + ".start", Message: "is required"})
+	}
+	if b.ReadyURL == "" {
+		*errs = append(*errs, ValidationError{Field: field + ".ready_url", Message: "is required"})
+	} else if !httpURL(expandPort(b.ReadyURL, 1)) {
+		*errs = append(*errs, ValidationError{Field: field + ".ready_url", Message: fmt.Sprintf("must be an http(s) URL, got %q", b.ReadyURL)})
+	}
+	if b.BaseURL != "" && !httpURL(expandPort(b.BaseURL, 1)) {
+		*errs = append(*errs, ValidationError{Field: field + ".base_url", Message: fmt.Sprintf("must be an http(s) URL, got %q", b.BaseURL)})
+	}
+	if b.ReadyTimeout != "" {
+		if d, err := time.ParseDuration(b.ReadyTimeout); err != nil || d <= 0 {
+			*errs = append(*errs, ValidationError{Field: field + ".ready_timeout", Message: fmt.Sprintf("invalid duration %q", b.ReadyTimeout)})
+		}
+	}
+	for i, r := range b.Routes {
+		if !strings.HasPrefix(r, "/") {
+			*errs = append(*errs, ValidationError{Field: fmt.Sprintf("%s.routes[%d]", field, i), Message: fmt.Sprintf("must start with /, got %q", r)})
+		}
+	}
+	if b.Visual != nil && (b.Visual.Threshold < 0 || b.Visual.Threshold > 100) {
+		*errs = append(*errs, ValidationError{Field: field + ".visual.threshold", Message: fmt.Sprintf("must be a percentage between 0 and 100, got %v", b.Visual.Threshold)})
+	}
+}
+
+func httpURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
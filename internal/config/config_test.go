@@ -1,10 +1,16 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 const validConfig = `
@@ -502,6 +508,83 @@ pipeline:
 	}
 }
 
+func TestNotificationsConfig_Sinks(t *testing.T) {
+	yaml := `
+pipeline:
+  name: test
+  repo: github.com/test/test
+  stages:
+    - id: s1
+  notifications:
+    slack:
+      webhook_url: "https://hooks.slack.com/services/T/B/X"
+      events: [failed, blocked, merged]
+    webhooks:
+      - name: ops
+        url: "https://ops.example.com/hook"
+        secret: s3cret
+    email:
+      host: smtp.example.com
+      from: factory@example.com
+      to: [team@example.com]
+    retry:
+      max_attempts: 5
+      backoff: 2s
+`
+	cfg, err := LoadFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := cfg.Pipeline.Notifications
+	if n.Slack.WebhookURL == "" || len(n.Slack.Events) != 3 {
+		t.Errorf("unexpected slack config: %+v", n.Slack)
+	}
+	if len(n.Webhooks) != 1 || n.Webhooks[0].Secret != "s3cret" {
+		t.Errorf("unexpected webhooks: %+v", n.Webhooks)
+	}
+	if n.Email.Host != "smtp.example.com" || len(n.Email.To) != 1 {
+		t.Errorf("unexpected email config: %+v", n.Email)
+	}
+	if n.Retry.MaxAttempts != 5 || n.Retry.Backoff != "2s" {
+		t.Errorf("unexpected retry config: %+v", n.Retry)
+	}
+	if errs := Validate(cfg); len(errs) != 0 {
+		t.Errorf("expected no validation errors, got %v", errs)
+	}
+}
+
+func TestValidateNotifications(t *testing.T) {
+	yaml := `
+pipeline:
+  name: test
+  repo: github.com/test/test
+  stages:
+    - id: s1
+  notifications:
+    webhooks:
+      - name: missing-url
+    email:
+      host: smtp.example.com
+    retry:
+      backoff: soon
+`
+	cfg, err := LoadFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fields := validationFields(cfg)
+	for _, want := range []string{
+		"pipeline.notifications.webhooks[0].url",
+		"pipeline.notifications.email.from",
+		"pipeline.notifications.email.to",
+		"pipeline.notifications.retry.backoff",
+	} {
+		if !fields[want] {
+			t.Errorf("expected validation error for %s, got %v", want, fields)
+		}
+	}
+}
+
 func TestNotificationsConfig_Empty(t *testing.T) {
 	yaml := `
 pipeline:
@@ -737,6 +820,11 @@ func TestValidateDatabaseConfig(t *testing.T) {
 		{"invalid name", &DatabaseConfig{Name: "123bad", User: "myuser"}, 1, "pipeline.database.name"},
 		{"invalid user", &DatabaseConfig{Name: "mydb", User: "bad-user"}, 1, "pipeline.database.user"},
 		{"underscores ok", &DatabaseConfig{Name: "_db_1", User: "_user_2"}, 0, ""},
+		{"per_pipeline", &DatabaseConfig{Name: "mydb", User: "myuser", Isolation: "per_pipeline", Template: "mydb_tmpl"}, 0, ""},
+		{"bad isolation", &DatabaseConfig{Name: "mydb", User: "myuser", Isolation: "per_issue"}, 1, "pipeline.database.isolation"},
+		{"template without isolation", &DatabaseConfig{Name: "mydb", User: "myuser", Template: "mydb_tmpl"}, 1, "pipeline.database.template"},
+		{"invalid template", &DatabaseConfig{Name: "mydb", User: "myuser", Isolation: "per_pipeline", Template: "bad-tmpl"}, 1, "pipeline.database.template"},
+		{"per_pipeline name too long", &DatabaseConfig{Name: strings.Repeat("d", 49), User: "myuser", Isolation: "per_pipeline"}, 1, "pipeline.database.name"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -763,6 +851,35 @@ func TestValidateDatabaseConfig(t *testing.T) {
 	}
 }
 
+func TestForIssue(t *testing.T) {
+	shared := &PipelineConfig{Pipeline: Pipeline{
+		Database: &DatabaseConfig{Name: "mydb", User: "myuser", Password: "pass"},
+	}}
+	if got := shared.ForIssue(42); got != shared {
+		t.Error("shared database: expected config unchanged")
+	}
+
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Database: &DatabaseConfig{Name: "mydb", User: "myuser", Password: "pass", Isolation: DatabasePerPipeline},
+	}}
+	got := cfg.ForIssue(42)
+	if got.Pipeline.Database.Name != "mydb_issue_42" {
+		t.Errorf("Name = %q, want mydb_issue_42", got.Pipeline.Database.Name)
+	}
+	if got.Pipeline.Database.Template != "mydb" {
+		t.Errorf("Template = %q, want mydb", got.Pipeline.Database.Template)
+	}
+	if !strings.Contains(got.Pipeline.Database.URL(), "/mydb_issue_42?") {
+		t.Errorf("URL() = %q, want the pipeline database", got.Pipeline.Database.URL())
+	}
+	if cfg.Pipeline.Database.Name != "mydb" {
+		t.Error("ForIssue modified the original config")
+	}
+	if again := got.ForIssue(42); again.Pipeline.Database.Name != "mydb_issue_42" {
+		t.Errorf("ForIssue not idempotent: Name = %q", again.Pipeline.Database.Name)
+	}
+}
+
 func TestValidateEnvKeys(t *testing.T) {
 	cfg := &PipelineConfig{Pipeline: Pipeline{
 		Name:   "test",
@@ -782,6 +899,25 @@ func TestValidateEnvKeys(t *testing.T) {
 	}
 }
 
+func TestValidateEnvSecretRefs(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name:   "test",
+		Repo:   "owner/repo",
+		Env:    map[string]string{"API_KEY": "secret://api-key", "EMPTY_REF": "secret://"},
+		Stages: []Stage{{ID: "s1"}},
+	}}
+	errs := Validate(cfg)
+	var envErrs []ValidationError
+	for _, e := range errs {
+		if strings.HasPrefix(e.Field, "pipeline.env.") {
+			envErrs = append(envErrs, e)
+		}
+	}
+	if len(envErrs) != 1 || envErrs[0].Field != "pipeline.env.EMPTY_REF" {
+		t.Errorf("expected 1 error for EMPTY_REF, got %v", envErrs)
+	}
+}
+
 func TestValidateWithWarnings_DatabaseAndEnvURL(t *testing.T) {
 	cfg := &PipelineConfig{Pipeline: Pipeline{
 		Name:     "test",
@@ -824,7 +960,7 @@ func TestStageFields(t *testing.T) {
 	}
 
 	implement := cfg.Pipeline.Stages[1]
-	if implement.OnFail != "scaffold" {
+	if implement.OnFail.Target() != "scaffold" {
 		t.Errorf("OnFail = %v, want %q", implement.OnFail, "scaffold")
 	}
 	if len(implement.ExtraChecks) != 1 || implement.ExtraChecks[0] != "test" {
@@ -874,7 +1010,7 @@ deploy:
 	if cfg.Deploy.Stages[0].ID != "deploy" {
 		t.Errorf("first deploy stage ID = %q, want %q", cfg.Deploy.Stages[0].ID, "deploy")
 	}
-	if cfg.Deploy.Stages[0].OnFail != "rollback" {
+	if cfg.Deploy.Stages[0].OnFail.Target() != "rollback" {
 		t.Errorf("first deploy stage OnFail = %v, want %q", cfg.Deploy.Stages[0].OnFail, "rollback")
 	}
 }
@@ -969,7 +1105,7 @@ func TestValidateDeployInvalidOnFailTarget(t *testing.T) {
 		Pipeline: Pipeline{Name: "test", Repo: "github.com/x/y", Stages: []Stage{{ID: "impl"}}},
 		Deploy: &DeployPipeline{
 			Stages: []Stage{
-				{ID: "deploy", OnFail: "nonexistent"},
+				{ID: "deploy", OnFail: OnFail{"default": "nonexistent"}},
 				{ID: "rollback"},
 			},
 		},
@@ -991,7 +1127,7 @@ func TestValidateDeployValidConfig(t *testing.T) {
 		Pipeline: Pipeline{Name: "test", Repo: "github.com/x/y", Stages: []Stage{{ID: "impl"}}},
 		Deploy: &DeployPipeline{
 			Stages: []Stage{
-				{ID: "deploy", OnFail: "rollback"},
+				{ID: "deploy", OnFail: OnFail{"default": "rollback"}},
 				{ID: "smoke-test"},
 				{ID: "rollback"},
 			},
@@ -1009,3 +1145,713 @@ func TestValidateDeployValidConfig(t *testing.T) {
 		t.Errorf("expected no deploy validation errors, got: %v", deployErrs)
 	}
 }
+
+// ---- extends / include ----
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var inheritanceFiles = map[string]string{
+	"org/base.yaml": `
+pipeline:
+  name: base
+  defaults:
+    model: sonnet
+  vars:
+    team: platform
+    style: strict
+  checks:
+    lint:
+      command: go vet ./...
+      parser: generic
+  stages:
+    - id: implement
+      type: agent
+      checks_after: [lint]
+    - id: review
+      type: agent
+`,
+	"org/checks.yaml": `
+pipeline:
+  checks:
+    test:
+      command: go test ./...
+      parser: generic
+`,
+	"repo/pipeline.yaml": `
+extends: ../org/base.yaml
+include:
+  - ../org/checks.yaml
+pipeline:
+  name: widgets
+  vars:
+    style: relaxed
+  checks:
+    lint:
+      command: golangci-lint run
+  stages:
+    - id: implement
+      model: opus
+    - id: merge
+      type: merge
+`,
+}
+
+func TestLoad_ExtendsAndInclude(t *testing.T) {
+	dir := writeConfigFiles(t, inheritanceFiles)
+	cfg, err := Load(filepath.Join(dir, "repo", "pipeline.yaml"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	p := cfg.Pipeline
+
+	if p.Name != "widgets" {
+		t.Errorf("Name = %q, want widgets", p.Name)
+	}
+	// vars overlay key by key
+	if p.Vars["team"] != "platform" || p.Vars["style"] != "relaxed" {
+		t.Errorf("Vars = %v", p.Vars)
+	}
+	// checks merge by name, field by field
+	if c := p.Checks["lint"]; c.Command != "golangci-lint run" || c.Parser != "generic" {
+		t.Errorf("lint = %+v", c)
+	}
+	if _, ok := p.Checks["test"]; !ok {
+		t.Error("included check 'test' missing")
+	}
+	// stages merge by id, new stages appended
+	var ids []string
+	for _, s := range p.Stages {
+		ids = append(ids, s.ID)
+	}
+	if strings.Join(ids, ",") != "implement,review,merge" {
+		t.Fatalf("stage order = %v", ids)
+	}
+	impl := p.Stages[0]
+	if impl.Model != "opus" || impl.Type != "agent" || len(impl.ChecksAfter) != 1 {
+		t.Errorf("implement = %+v", impl)
+	}
+	// defaults from the base still apply to stages
+	if p.Stages[1].Model != "sonnet" {
+		t.Errorf("review model = %q, want sonnet from base defaults", p.Stages[1].Model)
+	}
+	if cfg.Dir != filepath.Join(dir, "repo") {
+		t.Errorf("Dir = %q", cfg.Dir)
+	}
+}
+
+func TestLoad_ExtendsCycle(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml": "extends: b.yaml\npipeline:\n  name: a\n",
+		"b.yaml": "extends: a.yaml\npipeline:\n  name: b\n",
+	})
+	_, err := Load(filepath.Join(dir, "a.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "extends or includes itself") {
+		t.Errorf("err = %v, want cycle error", err)
+	}
+}
+
+func TestLoad_ExtendsMissingFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{"a.yaml": "extends: nope.yaml\n"})
+	if _, err := Load(filepath.Join(dir, "a.yaml")); err == nil {
+		t.Error("expected error for missing base")
+	}
+}
+
+func TestLoadFromBytes_RejectsExtends(t *testing.T) {
+	if _, err := LoadFromBytes([]byte("extends: base.yaml\npipeline:\n  name: x\n")); err == nil {
+		t.Error("expected error for extends without a file location")
+	}
+}
+
+func TestLoadResolved_AnnotatesOrigins(t *testing.T) {
+	dir := writeConfigFiles(t, inheritanceFiles)
+	root, sources, err := LoadResolved(filepath.Join(dir, "repo", "pipeline.yaml"))
+	if err != nil {
+		t.Fatalf("LoadResolved: %v", err)
+	}
+	want := []string{filepath.Join("..", "org", "base.yaml"), filepath.Join("..", "org", "checks.yaml"), "pipeline.yaml"}
+	if strings.Join(sources, ",") != strings.Join(want, ",") {
+		t.Errorf("sources = %v, want %v", sources, want)
+	}
+
+	origin := func(path ...string) string {
+		n := root
+		for _, key := range path {
+			i := mappingIndex(n, key)
+			if i < 0 {
+				t.Fatalf("key %v not found", path)
+			}
+			n = n.Content[i+1]
+		}
+		return n.LineComment
+	}
+	if got := origin("pipeline", "checks", "lint", "command"); got != "pipeline.yaml" {
+		t.Errorf("lint.command origin = %q", got)
+	}
+	if got := origin("pipeline", "checks", "lint", "parser"); got != want[0] {
+		t.Errorf("lint.parser origin = %q", got)
+	}
+	if got := origin("pipeline", "checks", "test", "command"); got != want[1] {
+		t.Errorf("test.command origin = %q", got)
+	}
+}
+
+func TestLoad_UnknownKeysReportPosition(t *testing.T) {
+	path := writeTestConfig(t, `
+pipeline:
+  name: test
+  repo: github.com/test/test
+  stages:
+    - id: implement
+      type: agent
+      check_after: [lint]
+      timout: 5m
+`)
+	_, err := Load(path)
+	var keyErrs UnknownKeysError
+	if !errors.As(err, &keyErrs) {
+		t.Fatalf("Load() error = %v, want UnknownKeysError", err)
+	}
+	if len(keyErrs) != 2 {
+		t.Fatalf("got %d unknown keys, want 2: %v", len(keyErrs), err)
+	}
+	first := keyErrs[0]
+	if first.Key != "check_after" || first.Line != 8 || first.Column != 7 || first.Path != "pipeline.stages[0]" {
+		t.Errorf("first = %+v", first)
+	}
+	msg := err.Error()
+	for _, want := range []string{path + ":8:7", `did you mean "checks_after"`, `did you mean "timeout"`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q missing %q", msg, want)
+		}
+	}
+}
+
+func TestLoadFromBytes_UnknownTopLevelKey(t *testing.T) {
+	_, err := LoadFromBytes([]byte("pipeline:\n  name: x\npipelines: {}\n"))
+	if err == nil || !strings.Contains(err.Error(), `3:1: unknown key "pipelines" at top level`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestOnFail_Decode(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    OnFail
+		wantErr bool
+	}{
+		{"absent", "id: s", nil, false},
+		{"null", "on_fail: null", nil, false},
+		{"stage id", "on_fail: implement", OnFail{"default": "implement"}, false},
+		{"map", "on_fail: {lint_fail: s1, default: escalate}", OnFail{"lint_fail": "s1", "default": "escalate"}, false},
+		{"list", "on_fail: [a, b]", nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var s Stage
+			err := yaml.Unmarshal([]byte(tc.yaml), &s)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(s.OnFail, tc.want) {
+				t.Errorf("OnFail = %v, want %v", s.OnFail, tc.want)
+			}
+		})
+	}
+
+	if got := (OnFail{"default": "targeted_fix"}).Target(); got != "targeted_fix" {
+		t.Errorf("Target() = %q", got)
+	}
+	out, err := yaml.Marshal(Stage{ID: "s", OnFail: OnFail{"default": "implement"}})
+	if err != nil || !strings.Contains(string(out), "on_fail: implement\n") {
+		t.Errorf("Marshal = %q, %v", out, err)
+	}
+}
+
+func TestPipelineSchema(t *testing.T) {
+	s := PipelineSchema()
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+
+	props := s["properties"].(map[string]interface{})
+	for _, key := range []string{"pipeline", "deploy", "extends", "include"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("schema missing top-level %q", key)
+		}
+	}
+	if _, ok := props["Dir"]; ok {
+		t.Error("yaml:\"-\" fields must not appear in the schema")
+	}
+
+	stage := props["pipeline"].(map[string]interface{})["properties"].(map[string]interface{})["stages"].(map[string]interface{})["items"].(map[string]interface{})
+	if stage["additionalProperties"] != false {
+		t.Error("stage objects should reject unknown keys")
+	}
+	stageProps := stage["properties"].(map[string]interface{})
+	if _, ok := stageProps["on_fail"].(map[string]interface{})["oneOf"]; !ok {
+		t.Error("on_fail should accept a stage ID or a map")
+	}
+	if !strings.Contains(string(data), `"checks_after"`) {
+		t.Error("schema missing checks_after")
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	s := Stage{ID: "implement", PromptVariants: []PromptVariant{
+		{Template: "implement.md", Weight: 3},
+		{Name: "terse", Template: "implement-terse.md"},
+	}}
+
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("ns#%d/implement", i)
+		v, ok := s.PickVariant(key)
+		if !ok {
+			t.Fatal("PickVariant() ok = false")
+		}
+		again, _ := s.PickVariant(key)
+		if again.VariantName() != v.VariantName() {
+			t.Fatalf("PickVariant(%q) not stable: %q then %q", key, v.VariantName(), again.VariantName())
+		}
+		counts[v.VariantName()]++
+	}
+	if share := float64(counts["implement"]) / 2000; share < 0.68 || share > 0.82 {
+		t.Errorf("implement share = %.2f, want ~0.75 (counts %v)", share, counts)
+	}
+	if counts["terse"] == 0 {
+		t.Errorf("terse never picked: %v", counts)
+	}
+
+	if _, ok := (&Stage{}).PickVariant("x"); ok {
+		t.Error("PickVariant() on stage without variants: ok = true")
+	}
+}
+
+func TestValidatePromptVariants(t *testing.T) {
+	yaml := `
+pipeline:
+  name: test
+  repo: github.com/test/test
+  stages:
+    - id: implement
+      type: agent
+      prompt_template: implement.md
+      prompt_variants:
+        - template: a.md
+        - name: a
+          template: other.md
+        - name: b
+        - name: c
+          template: c.md
+          weight: -1
+`
+	cfg, err := LoadFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() error: %v", err)
+	}
+	found := validationFields(cfg)
+	for _, f := range []string{
+		"pipeline.stages[0].prompt_variants",
+		"pipeline.stages[0].prompt_variants[1].name",
+		"pipeline.stages[0].prompt_variants[2].template",
+		"pipeline.stages[0].prompt_variants[3].weight",
+	} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+}
+
+func TestValidatePanel(t *testing.T) {
+	yaml := `
+pipeline:
+  name: test
+  repo: github.com/test/test
+  stages:
+    - id: review
+      type: panel
+      panel:
+        rule: unanimous
+        threshold: 1.5
+        reviewers:
+          - name: security
+          - name: security
+          - name: "two words"
+          - weight: -1
+    - id: implement
+      type: agent
+      panel:
+        reviewers:
+          - name: x
+    - id: review2
+      type: panel
+`
+	cfg, err := LoadFromBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("LoadFromBytes() error: %v", err)
+	}
+	found := validationFields(cfg)
+	for _, f := range []string{
+		"pipeline.stages[0].panel.rule",
+		"pipeline.stages[0].panel.threshold",
+		"pipeline.stages[0].panel.reviewers[1].name",
+		"pipeline.stages[0].panel.reviewers[2].name",
+		"pipeline.stages[0].panel.reviewers[3].name",
+		"pipeline.stages[0].panel.reviewers[3].weight",
+		"pipeline.stages[1].panel",
+		"pipeline.stages[2].panel.reviewers",
+	} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+}
+
+func TestValidateCandidates(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		Stages: []Stage{
+			{ID: "implement", Type: "agent", Candidates: 3},
+			{ID: "lint", Type: "checks_only", Checks: []string{}, Candidates: 2},
+			{ID: "review", Candidates: -1},
+		},
+	}}
+	found := validationFields(cfg)
+	if found["pipeline.stages[0].candidates"] {
+		t.Error("unexpected error for candidates on an agent stage")
+	}
+	for _, f := range []string{"pipeline.stages[1].candidates", "pipeline.stages[2].candidates"} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+}
+
+func TestValidateMigrationCheck(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		Database: &DatabaseConfig{Name: "mydb", User: "myuser", Migrate: "make migrate", Rollback: "make rollback"},
+		Stages: []Stage{
+			{ID: "migrations", Type: "migration_check", MigrationCheck: &MigrationCheckConfig{AllowDestructive: true}},
+			{ID: "implement", MigrationCheck: &MigrationCheckConfig{}},
+		},
+	}}
+	found := validationFields(cfg)
+	if found["pipeline.stages[0].type"] || found["pipeline.stages[0].migration_check"] {
+		t.Error("unexpected error for a configured migration_check stage")
+	}
+	if !found["pipeline.stages[1].migration_check"] {
+		t.Error("expected validation error for migration_check on an agent stage")
+	}
+
+	cfg.Pipeline.Database.Rollback = ""
+	found = validationFields(cfg)
+	if !found["pipeline.stages[0].type"] {
+		t.Error("expected validation error for migration_check without a rollback command")
+	}
+
+	cfg.Pipeline.Database = nil
+	found = validationFields(cfg)
+	if !found["pipeline.stages[0].type"] {
+		t.Error("expected validation error for migration_check without a database")
+	}
+}
+
+func TestMigrationBaseRef(t *testing.T) {
+	var m *MigrationCheckConfig
+	if got := m.MigrationBaseRef("upstream/trunk"); got != "upstream/trunk" {
+		t.Errorf("nil: got %q", got)
+	}
+	m = &MigrationCheckConfig{BaseRef: "origin/develop"}
+	if got := m.MigrationBaseRef("origin/main"); got != "origin/develop" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestBaseBranchDefaults(t *testing.T) {
+	var p Pipeline
+	if p.BaseBranchName() != "main" || p.RemoteName() != "origin" {
+		t.Errorf("defaults: got %q %q", p.BaseBranchName(), p.RemoteName())
+	}
+	p = Pipeline{BaseBranch: "develop", Remote: "upstream"}
+	if p.BaseBranchName() != "develop" || p.RemoteName() != "upstream" {
+		t.Errorf("got %q %q", p.BaseBranchName(), p.RemoteName())
+	}
+}
+
+func TestValidGitName(t *testing.T) {
+	valid := []string{"main", "develop", "release-2.3", "release/2.3", "upstream", "feature_x"}
+	for _, name := range valid {
+		if !ValidGitName(name) {
+			t.Errorf("ValidGitName(%q) = false, want true", name)
+		}
+	}
+	invalid := []string{"", "-main", "--delete", "a..b", "release/", "main.lock", "has space", "a:b", ".hidden"}
+	for _, name := range invalid {
+		if ValidGitName(name) {
+			t.Errorf("ValidGitName(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestValidateBaseBranch(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		BaseBranch: "--force", Remote: "up stream",
+		Stages: []Stage{{ID: "implement"}},
+	}}
+	found := validationFields(cfg)
+	if !found["pipeline.base_branch"] || !found["pipeline.remote"] {
+		t.Errorf("expected base_branch and remote errors, got %v", found)
+	}
+
+	cfg.Pipeline.BaseBranch, cfg.Pipeline.Remote = "release/2.3", "upstream"
+	for _, e := range Validate(cfg) {
+		if e.Field == "pipeline.base_branch" || e.Field == "pipeline.remote" {
+			t.Errorf("unexpected error: %v", e)
+		}
+	}
+}
+
+func TestValidateWorktreePool(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		WorktreePool: &WorktreePoolConfig{Size: 2, Lockfiles: []string{"go.sum", "web/package-lock.json"}},
+		Stages:       []Stage{{ID: "implement"}},
+	}}
+	for _, e := range Validate(cfg) {
+		if strings.HasPrefix(e.Field, "pipeline.worktree_pool") {
+			t.Errorf("unexpected error: %v", e)
+		}
+	}
+
+	cfg.Pipeline.WorktreePool = &WorktreePoolConfig{Size: 100, Lockfiles: []string{"../go.sum", "/etc/passwd"}}
+	found := validationFields(cfg)
+	for _, f := range []string{"pipeline.worktree_pool.size", "pipeline.worktree_pool.lockfiles[0]", "pipeline.worktree_pool.lockfiles[1]"} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+}
+
+func TestWorktreePoolDefaults(t *testing.T) {
+	var p *WorktreePoolConfig
+	if p.PoolSize() != 0 {
+		t.Errorf("nil pool size = %d, want 0", p.PoolSize())
+	}
+	if len(p.LockfileList()) != len(DefaultLockfiles) {
+		t.Errorf("nil pool lockfiles = %v", p.LockfileList())
+	}
+	p = &WorktreePoolConfig{Size: 3, Lockfiles: []string{"go.sum"}}
+	if p.PoolSize() != 3 || len(p.LockfileList()) != 1 {
+		t.Errorf("got size %d lockfiles %v", p.PoolSize(), p.LockfileList())
+	}
+}
+
+func TestValidateSandbox(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		Sandbox: &SandboxConfig{Image: "agent:latest", Network: "agents", CPUs: "1.5", Memory: "4g", PidsLimit: 512},
+		Stages:  []Stage{{ID: "implement"}},
+	}}
+	for _, e := range Validate(cfg) {
+		if strings.HasPrefix(e.Field, "pipeline.sandbox") {
+			t.Errorf("unexpected error: %v", e)
+		}
+	}
+	if !cfg.Pipeline.Sandbox.Enabled() || cfg.Pipeline.Sandbox.RuntimeName() != "podman" {
+		t.Error("expected an enabled podman sandbox")
+	}
+
+	cfg.Pipeline.Stages = append(cfg.Pipeline.Stages, Stage{ID: "qa", Type: "browser_qa", BrowserQA: &BrowserQAConfig{Start: []string{"npm start"}, ReadyURL: "http://localhost:3000"}})
+	if !validationFields(cfg)["pipeline.stages[1].type"] {
+		t.Error("expected browser_qa to be rejected with the sandbox on")
+	}
+
+	cfg.Pipeline.Sandbox = &SandboxConfig{Runtime: "lxc", Network: "host", CPUs: "0", Memory: "lots", PidsLimit: -1}
+	found := validationFields(cfg)
+	for _, f := range []string{"image", "runtime", "network", "cpus", "memory", "pids_limit"} {
+		if !found["pipeline.sandbox."+f] {
+			t.Errorf("expected validation error for pipeline.sandbox.%s", f)
+		}
+	}
+	if cfg.Pipeline.Sandbox.Enabled() {
+		t.Error("a sandbox without an image should not be enabled")
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		Defaults: StageDefaults{Limits: &Limits{Memory: "8g"}},
+		Checks:   map[string]Check{"test": {Command: "go test ./...", Limits: &Limits{Memory: "512m", CPU: "1.5", Pids: 256}}},
+		Stages:   []Stage{{ID: "implement"}, {ID: "review", Limits: &Limits{CPU: "0.5"}}},
+	}}
+	for _, e := range Validate(cfg) {
+		if strings.HasSuffix(e.Field, ".limits") || strings.Contains(e.Field, ".limits.") {
+			t.Errorf("unexpected error: %v", e)
+		}
+	}
+	if n, _ := cfg.Pipeline.Checks["test"].Limits.MemoryBytes(); n != 512<<20 {
+		t.Errorf("memory = %d, want %d", n, 512<<20)
+	}
+	if got := cfg.Pipeline.SessionLimits(&cfg.Pipeline.Stages[0]); got.Memory != "8g" {
+		t.Errorf("implement should fall back to defaults.limits, got %+v", got)
+	}
+	if got := cfg.Pipeline.SessionLimits(&cfg.Pipeline.Stages[1]); got.CPU != "0.5" {
+		t.Errorf("review should use its own limits, got %+v", got)
+	}
+
+	cfg.Pipeline.Checks["test"] = Check{Command: "go test ./...", Limits: &Limits{Memory: "2 gigs", CPU: "0", Pids: -1}}
+	cfg.Pipeline.Stages[1].Limits = &Limits{Memory: "0g"}
+	found := validationFields(cfg)
+	for _, f := range []string{
+		"pipeline.checks.test.limits.memory", "pipeline.checks.test.limits.cpu",
+		"pipeline.checks.test.limits.pids", "pipeline.stages[1].limits.memory",
+	} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+}
+
+func TestValidateBrowserQA(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		Stages: []Stage{{ID: "browser", Type: "browser_qa", BrowserQA: &BrowserQAConfig{
+			Start:    []string{"npm start -- --port $FACTORY_PORT"},
+			ReadyURL: "http://localhost:${FACTORY_PORT}/health",
+			Routes:   []string{"/", "/settings"},
+		}}},
+	}}
+	for _, e := range Validate(cfg) {
+		if strings.Contains(e.Field, "browser_qa") {
+			t.Errorf("unexpected error: %v", e)
+		}
+	}
+	b := cfg.Pipeline.Stages[0].BrowserQA.ForPort(3100)
+	if b.ReadyURL != "http://localhost:3100/health" {
+		t.Errorf("ForPort: ready_url = %q", b.ReadyURL)
+	}
+	if got := b.Base(); got != "http://localhost:3100" {
+		t.Errorf("Base() = %q, want ready_url's origin", got)
+	}
+	if got := b.ReadyWait(); got != DefaultBrowserReadyTimeout {
+		t.Errorf("ReadyWait() = %s, want default", got)
+	}
+
+	if got := b.Visual.MaxChange(); got != DefaultVisualThreshold {
+		t.Errorf("MaxChange() = %v, want default when visual is unset", got)
+	}
+	if got := (&VisualConfig{BaseRef: "origin/release"}).VisualBaseRef("origin/main"); got != "origin/release" {
+		t.Errorf("VisualBaseRef() = %q, want base_ref", got)
+	}
+
+	cfg.Pipeline.Stages[0].BrowserQA = &BrowserQAConfig{ReadyURL: "localhost:3100", ReadyTimeout: "soon", Routes: []string{"settings"}, Visual: &VisualConfig{Threshold: 150}}
+	cfg.Pipeline.Stages = append(cfg.Pipeline.Stages,
+		Stage{ID: "qa", Type: "browser_qa"},
+		Stage{ID: "implement", BrowserQA: &BrowserQAConfig{}},
+	)
+	found := validationFields(cfg)
+	for _, f := range []string{
+		"pipeline.stages[0].browser_qa.start", "pipeline.stages[0].browser_qa.ready_url",
+		"pipeline.stages[0].browser_qa.ready_timeout", "pipeline.stages[0].browser_qa.routes[0]",
+		"pipeline.stages[0].browser_qa.visual.threshold",
+		"pipeline.stages[1].browser_qa", "pipeline.stages[2].browser_qa",
+	} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+}
+
+func TestValidateCheckImpact(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		Checks: map[string]Check{
+			"test": {Command: "go test ./...", Paths: []string{"*.go", "go.mod", "internal/**"}, Impact: "go"},
+			"web":  {Command: "npm test", Paths: []string{"web/[a-", ""}, Impact: "rust"},
+		},
+		Stages: []Stage{{ID: "implement"}},
+	}}
+	found := validationFields(cfg)
+	for _, f := range []string{"pipeline.checks.web.paths[0]", "pipeline.checks.web.paths[1]", "pipeline.checks.web.impact"} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+	for f := range found {
+		if strings.HasPrefix(f, "pipeline.checks.test.") {
+			t.Errorf("unexpected error for %s", f)
+		}
+	}
+}
+
+func TestValidateCoverage(t *testing.T) {
+	cfg := &PipelineConfig{Pipeline: Pipeline{
+		Name: "test", Repo: "github.com/test/test",
+		Checks: map[string]Check{
+			"coverage": {Command: "go test -coverprofile=c.out ./...", Parser: "coverage", Coverage: &CoverageConfig{Report: "c.out", Format: "go", Threshold: 75}},
+			"bad":      {Command: "npm test", Parser: "coverage", Coverage: &CoverageConfig{Report: "../lcov.info", Format: "jacoco", Threshold: 120}},
+			"lint":     {Command: "npm run lint", Parser: "eslint", Coverage: &CoverageConfig{}},
+		},
+		Stages: []Stage{{ID: "implement"}},
+	}}
+	found := validationFields(cfg)
+	for _, f := range []string{"pipeline.checks.bad.coverage.report", "pipeline.checks.bad.coverage.format", "pipeline.checks.bad.coverage.threshold", "pipeline.checks.lint.coverage"} {
+		if !found[f] {
+			t.Errorf("expected validation error for %s", f)
+		}
+	}
+	for f := range found {
+		if strings.HasPrefix(f, "pipeline.checks.coverage.") {
+			t.Errorf("unexpected error for %s", f)
+		}
+	}
+	if got := (*CoverageConfig)(nil).MinChanged(); got != DefaultCoverageThreshold {
+		t.Errorf("MinChanged() = %v, want the default", got)
+	}
+}
+
+func TestRepoNamespace(t *testing.T) {
+	cases := []struct {
+		repo string
+		want string
+	}{
+		{"github.com/myorg/myapp", "myorg/myapp"},
+		{"https://github.com/myorg/myapp", "myorg/myapp"},
+		{"http://github.com/myorg/myapp", "myorg/myapp"},
+		{"myorg/myapp", "myorg/myapp"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := RepoNamespace(c.repo); got != c.want {
+			t.Errorf("RepoNamespace(%q) = %q, want %q", c.repo, got, c.want)
+		}
+	}
+}
+
+// validationFields returns the fields Validate reports errors for.
+func validationFields(cfg *PipelineConfig) map[string]bool {
+	found := make(map[string]bool)
+	for _, e := range Validate(cfg) {
+		found[e.Field] = true
+	}
+	return found
+}
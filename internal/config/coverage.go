@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// CoverageConfig configures a check with `parser: coverage`. The check's
+// command runs the tests with coverage on; the parser reads the report and
+// fails the check when too few of the lines changed on the branch are
+// covered.
+//
+//	checks:
+//	  coverage:
+//	    command: go test -coverprofile=coverage.out ./...
+//	    parser: coverage
+//	    coverage:
+//	      report: coverage.out
+//	      threshold: 80
+type CoverageConfig struct {
+	Report    string  `yaml:"report"`    // file the command writes, relative to the worktree; default the command's stdout
+	Format    string  `yaml:"format"`    // go, lcov or cobertura; detected from the report when unset
+	Threshold float64 `yaml:"threshold"` // percent of changed lines that must be covered; default 80
+}
+
+// DefaultCoverageThreshold is the percent of changed lines a coverage check
+// requires to be covered.
+const DefaultCoverageThreshold = 80
+
+// recognizedCoverageFormats are the report formats the coverage parser reads.
+var recognizedCoverageFormats = map[string]bool{"go": true, "lcov": true, "cobertura": true}
+
+// MinChanged returns the percent of changed lines that must be covered.
+func (c *CoverageConfig) MinChanged() float64 {
+	if c == nil || c.Threshold == 0 {
+		return DefaultCoverageThreshold
+	}
+	return c.Threshold
+}
+
+func validateCoverage(name string, c Check, errs *[]ValidationError) {
+	field := fmt.Sprintf("pipeline.checks.%s.coverage", name)
+	cov := c.Coverage
+	if cov == nil {
+		return
+	}
+	if c.Parser != "coverage" {
+		*errs = append(*errs, ValidationError{Field: field, Message: "is only used by checks with parser: coverage"})
+	}
+	if cov.Report != "" && !filepath.IsLocal(cov.Report) {
+		*errs = append(*errs, ValidationError{Field: field + ".report", Message: fmt.Sprintf("must be a path inside the worktree, got %q", cov.Report)})
+	}
+	if cov.Format != "" && !recognizedCoverageFormats[cov.Format] {
+		*errs = append(*errs, ValidationError{Field: field + ".format", Message: fmt.Sprintf("unrecognized format %q (want go, lcov or cobertura)", cov.Format)})
+	}
+	if cov.Threshold < 0 || cov.Threshold > 100 {
+		*errs = append(*errs, ValidationError{Field: field + ".threshold", Message: fmt.Sprintf("must be a percentage between 0 and 100, got %v", cov.Threshold)})
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Check impact settings let a gate skip checks the change cannot affect.
+// Patterns without a slash match a base name anywhere; "**" matches any
+// number of directories.
+//
+//	checks:
+//	  test:
+//	    command: go test ./...
+//	    paths: ["*.go", "go.mod", "go.sum"]
+//	    impact: go
+//	  web-lint:
+//	    command: npm run lint
+//	    paths: ["web/**"]
+
+// recognizedImpacts are the languages a check's impact analysis understands.
+var recognizedImpacts = map[string]bool{"go": true}
+
+func validateCheckImpact(name string, c Check, errs *[]ValidationError) {
+	field := fmt.Sprintf("pipeline.checks.%s", name)
+	for i, p := range c.Paths {
+		if !validPathPattern(p) {
+			*errs = append(*errs, ValidationError{Field: fmt.Sprintf("%s.paths[%d]", field, i), Message: fmt.Sprintf("invalid glob %q", p)})
+		}
+	}
+	if c.Impact != "" && !recognizedImpacts[c.Impact] {
+		*errs = append(*errs, ValidationError{Field: field + ".impact", Message: fmt.Sprintf("unrecognized impact %q (want go)", c.Impact)})
+	}
+}
+
+func validPathPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Limits caps the resources of a check command or an agent session. They are
+// enforced with cgroups v2 on Linux; elsewhere they are ignored. For sandboxed
+// pipelines they become the container's limits instead.
+//
+//	checks:
+//	  test:
+//	    command: go test ./...
+//	    limits: {memory: 2g, cpu: "2", pids: 512}
+//	defaults:
+//	  limits: {memory: 8g}
+type Limits struct {
+	Memory string `yaml:"memory"` // e.g. 512m or 4g; default unlimited
+	CPU    string `yaml:"cpu"`    // cores, e.g. "2" or "0.5"; default unlimited
+	Pids   int    `yaml:"pids"`   // default unlimited
+}
+
+// IsZero reports whether l limits nothing.
+func (l *Limits) IsZero() bool {
+	return l == nil || *l == Limits{}
+}
+
+var limitsMemoryRe = regexp.MustCompile(`^([0-9]+)([bkmgBKMG]?)$`)
+
+// MemoryBytes returns the memory limit in bytes, or 0 when unset.
+func (l *Limits) MemoryBytes() (int64, error) {
+	if l == nil || l.Memory == "" {
+		return 0, nil
+	}
+	m := limitsMemoryRe.FindStringSubmatch(l.Memory)
+	if m == nil {
+		return 0, fmt.Errorf("must be a size like 512m or 4g, got %q", l.Memory)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("size %q out of range", l.Memory)
+	}
+	switch strings.ToLower(m[2]) {
+	case "k":
+		n <<= 10
+	case "m":
+		n <<= 20
+	case "g":
+		n <<= 30
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("must be positive, got %q", l.Memory)
+	}
+	return n, nil
+}
+
+// CPUCores returns the CPU limit in cores, or 0 when unset.
+func (l *Limits) CPUCores() (float64, error) {
+	if l == nil || l.CPU == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(l.CPU, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("must be a positive number of cores, got %q", l.CPU)
+	}
+	return n, nil
+}
+
+// SessionLimits returns the limits of stage's agent session: its own, else
+// defaults.limits.
+func (p *Pipeline) SessionLimits(stage *Stage) *Limits {
+	if stage != nil && stage.Limits != nil {
+		return stage.Limits
+	}
+	return p.Defaults.Limits
+}
+
+func validateLimits(l *Limits, field string, errs *[]ValidationError) {
+	if l == nil {
+		return
+	}
+	if _, err := l.MemoryBytes(); err != nil {
+		*errs = append(*errs, ValidationError{Field: field + ".memory", Message: err.Error()})
+	}
+	if _, err := l.CPUCores(); err != nil {
+		*errs = append(*errs, ValidationError{Field: field + ".cpu", Message: err.Error()})
+	}
+	if l.Pids < 0 {
+		*errs = append(*errs, ValidationError{Field: field + ".pids", Message: "must not be negative"})
+	}
+}
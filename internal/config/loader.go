@@ -4,31 +4,46 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
-	"gopkg.in/yaml.v3"
 )
 
-// Load reads and parses a pipeline configuration from the given YAML file path.
+// Load reads and parses a pipeline configuration from the given YAML file path,
+// merging in any files it extends or includes (see merge.go). Unknown keys are
+// rejected with their file, line, and column (see schema.go).
 // After parsing, it applies defaults to stages that don't specify their own values.
 func Load(path string) (*PipelineConfig, error) {
-	data, err := os.ReadFile(path)
+	tree, err := loadTree(path, nil)
 	if err != nil {
-		return nil, fmt.Errorf("reading config file: %w", err)
+		return nil, err
 	}
 
 	var cfg PipelineConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := tree.root.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("parsing config YAML: %w", err)
 	}
+	if abs, err := filepath.Abs(path); err == nil {
+		cfg.Dir = filepath.Dir(abs)
+	}
 
 	applyDefaults(&cfg)
 	return &cfg, nil
 }
 
 // LoadFromBytes parses a pipeline configuration from raw YAML bytes.
+// extends and include need a file location to resolve against, so they are
+// rejected here; use Load instead.
 func LoadFromBytes(data []byte) (*PipelineConfig, error) {
+	root, err := parseMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config YAML: %w", err)
+	}
+	if mappingIndex(root, extendsKey) >= 0 || mappingIndex(root, includeKey) >= 0 {
+		return nil, fmt.Errorf("extends/include are only supported when loading from a file")
+	}
+	if err := CheckKnownKeys("", root, PipelineConfig{}); err != nil {
+		return nil, err
+	}
 	var cfg PipelineConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := root.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("parsing config YAML: %w", err)
 	}
 	applyDefaults(&cfg)
@@ -94,9 +109,16 @@ func applyDefaults(cfg *PipelineConfig) {
 			s.Flags = p.Defaults.Flags
 		}
 
+		if s.ContextBudget == 0 {
+			s.ContextBudget = p.Defaults.ContextBudget
+		}
+		if s.RelevantFiles == 0 {
+			s.RelevantFiles = p.Defaults.RelevantFiles
+		}
+
 		// Resolve default_checks: stages without explicit checks_after and without skip_checks
 		// get the pipeline's default_checks.
-		if len(s.ChecksAfter) == 0 && !s.SkipChecks && s.Type != "checks_only" {
+		if len(s.ChecksAfter) == 0 && !s.SkipChecks && s.Type != "checks_only" && s.Type != "migration_check" && s.Type != "browser_qa" {
 			s.ChecksAfter = p.DefaultChecks
 		}
 	}
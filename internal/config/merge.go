@@ -0,0 +1,283 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Top-level keys that pull other pipeline files into this one. Paths are
+// relative to the file that declares them.
+//
+//	extends: ../org/base-pipeline.yaml   # one base, or a list applied in order
+//	include:
+//	  - ../org/checks-go.yaml            # fragments, e.g. shared check libraries
+//
+// Merge order is: each extends base, then each include, then the file itself;
+// later sources win. Merge rules:
+//   - mappings merge key by key, recursively (checks merge by name, vars overlay)
+//   - pipeline.stages and deploy.stages merge by stage id; new stages are appended
+//   - any other value (scalars, other lists) is replaced
+const (
+	extendsKey = "extends"
+	includeKey = "include"
+)
+
+// resolvedTree is a merged pipeline document. Every scalar value carries its
+// origin file (absolute path) in LineComment.
+type resolvedTree struct {
+	root    *yaml.Node // mapping node
+	sources []string   // absolute paths in merge order, bases first
+}
+
+// LoadResolved returns the merged YAML document for the pipeline config at
+// path, with each scalar value annotated with its origin file (relative to
+// path's directory), and the list of files that were merged.
+func LoadResolved(path string) (*yaml.Node, []string, error) {
+	tree, err := loadTree(path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	absTop, _ := filepath.Abs(path)
+	topDir := filepath.Dir(absTop)
+
+	rel := func(p string) string {
+		if r, err := filepath.Rel(topDir, p); err == nil {
+			return r
+		}
+		return p
+	}
+	walkScalars(tree.root, func(n *yaml.Node) {
+		if n.LineComment != "" {
+			n.LineComment = rel(n.LineComment)
+		}
+	})
+
+	sources := make([]string, len(tree.sources))
+	for i, s := range tree.sources {
+		sources[i] = rel(s)
+	}
+	return tree.root, sources, nil
+}
+
+// loadTree reads path and everything it extends or includes, merged.
+// stack holds the files currently being loaded, for cycle detection.
+func loadTree(path string, stack []string) (*resolvedTree, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", path, err)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("config %s extends or includes itself", abs)
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	root, err := parseMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config YAML %s: %w", abs, err)
+	}
+	annotateOrigin(root, abs)
+
+	extends, err := takePathList(root, extendsKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+	includes, err := takePathList(root, includeKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+	if err := CheckKnownKeys(abs, root, PipelineConfig{}); err != nil {
+		return nil, err
+	}
+
+	out := &resolvedTree{}
+	seen := make(map[string]bool)
+	dir := filepath.Dir(abs)
+	for _, ref := range append(extends, includes...) {
+		if !filepath.IsAbs(ref) {
+			ref = filepath.Join(dir, ref)
+		}
+		sub, err := loadTree(ref, stack)
+		if err != nil {
+			return nil, err
+		}
+		out.root = mergeNodes(out.root, sub.root, "")
+		for _, s := range sub.sources {
+			if !seen[s] {
+				seen[s] = true
+				out.sources = append(out.sources, s)
+			}
+		}
+	}
+	out.root = mergeNodes(out.root, root, "")
+	out.sources = append(out.sources, abs)
+	return out, nil
+}
+
+// parseMapping parses a YAML document whose root must be a mapping. An empty
+// document yields an empty mapping.
+func parseMapping(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: top level must be a mapping", root.Line)
+	}
+	return root, nil
+}
+
+// takePathList removes key from the mapping and returns its value as a list
+// of paths. The value may be a single string or a list of strings.
+func takePathList(m *yaml.Node, key string) ([]string, error) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != key {
+			continue
+		}
+		v := m.Content[i+1]
+		m.Content = append(m.Content[:i], m.Content[i+2:]...)
+
+		switch v.Kind {
+		case yaml.ScalarNode:
+			if v.Value == "" {
+				return nil, nil
+			}
+			return []string{v.Value}, nil
+		case yaml.SequenceNode:
+			paths := make([]string, 0, len(v.Content))
+			for _, item := range v.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, fmt.Errorf("line %d: %s entries must be file paths", item.Line, key)
+				}
+				paths = append(paths, item.Value)
+			}
+			return paths, nil
+		default:
+			return nil, fmt.Errorf("line %d: %s must be a path or a list of paths", v.Line, key)
+		}
+	}
+	return nil, nil
+}
+
+// mergeNodes overlays over onto base following the rules documented above.
+// path is the dotted location of the nodes, used to spot stage lists.
+func mergeNodes(base, over *yaml.Node, path string) *yaml.Node {
+	if base == nil {
+		return over
+	}
+	if base.Kind == yaml.MappingNode && over.Kind == yaml.MappingNode {
+		out := *base
+		out.Content = append([]*yaml.Node(nil), base.Content...)
+		for i := 0; i+1 < len(over.Content); i += 2 {
+			key, val := over.Content[i], over.Content[i+1]
+			childPath := key.Value
+			if path != "" {
+				childPath = path + "." + key.Value
+			}
+			if j := mappingIndex(&out, key.Value); j >= 0 {
+				out.Content[j+1] = mergeNodes(out.Content[j+1], val, childPath)
+			} else {
+				out.Content = append(out.Content, key, val)
+			}
+		}
+		return &out
+	}
+	if base.Kind == yaml.SequenceNode && over.Kind == yaml.SequenceNode &&
+		(path == "pipeline.stages" || path == "deploy.stages") {
+		return mergeStages(base, over, path)
+	}
+	return over
+}
+
+// mergeStages merges two stage lists by id. Stages in over that match a base
+// stage are merged into it in place; the rest are appended in order.
+func mergeStages(base, over *yaml.Node, path string) *yaml.Node {
+	out := *base
+	out.Content = append([]*yaml.Node(nil), base.Content...)
+	for _, stage := range over.Content {
+		id := stageID(stage)
+		merged := false
+		if id != "" {
+			for j, existing := range out.Content {
+				if stageID(existing) == id {
+					out.Content[j] = mergeNodes(existing, stage, path+"["+id+"]")
+					merged = true
+					break
+				}
+			}
+		}
+		if !merged {
+			out.Content = append(out.Content, stage)
+		}
+	}
+	return &out
+}
+
+func stageID(n *yaml.Node) string {
+	if n.Kind != yaml.MappingNode {
+		return ""
+	}
+	if i := mappingIndex(n, "id"); i >= 0 {
+		return n.Content[i+1].Value
+	}
+	return ""
+}
+
+// mappingIndex returns the index of key in a mapping node's Content, or -1.
+func mappingIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// annotateOrigin drops the file's own comments and records origin on every
+// scalar value so the merged tree can report where each value came from.
+func annotateOrigin(n *yaml.Node, origin string) {
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	n.Style &^= yaml.FlowStyle // flow collections can't carry per-item comments
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			k.HeadComment, k.LineComment, k.FootComment = "", "", ""
+			annotateOrigin(n.Content[i+1], origin)
+		}
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			annotateOrigin(c, origin)
+		}
+	case yaml.ScalarNode:
+		n.LineComment = origin
+	}
+}
+
+// walkScalars calls fn for every scalar value (not mapping keys) under n.
+func walkScalars(n *yaml.Node, fn func(*yaml.Node)) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			walkScalars(n.Content[i+1], fn)
+		}
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			walkScalars(c, fn)
+		}
+	case yaml.ScalarNode:
+		fn(n)
+	}
+}
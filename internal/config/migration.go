@@ -0,0 +1,25 @@
+package config
+
+// MigrationCheckConfig configures a `type: migration_check` stage. The stage
+// needs pipeline.database with migrate and rollback commands; it runs them
+// against scratch databases rather than the pipeline's own.
+//
+//	stages:
+//	  - id: migrations
+//	    type: migration_check
+//	    on_fail: implement
+//	    migration_check:
+//	      allow_destructive: false
+type MigrationCheckConfig struct {
+	BaseRef          string `yaml:"base_ref"`          // schema baseline; default the pipeline's base branch, e.g. origin/main
+	AllowDestructive bool   `yaml:"allow_destructive"` // report dropped columns and narrowed types without failing
+}
+
+// MigrationBaseRef returns the baseline ref, defaulting to def (the
+// pipeline's remote-tracking base branch).
+func (m *MigrationCheckConfig) MigrationBaseRef(def string) string {
+	if m == nil || m.BaseRef == "" {
+		return def
+	}
+	return m.BaseRef
+}
@@ -0,0 +1,18 @@
+package config
+
+import "strings"
+
+// RepoNamespace converts a repo URL like "github.com/org/repo",
+// "https://github.com/org/repo", or plain "org/repo" to the namespace
+// "org/repo" that pipelines, queue items and the web UI are grouped by.
+func RepoNamespace(repo string) string {
+	repo = strings.TrimPrefix(repo, "https://")
+	repo = strings.TrimPrefix(repo, "http://")
+	parts := strings.SplitN(repo, "/", 3)
+	if len(parts) == 3 {
+		// domain/owner/repo → owner/repo
+		return parts[1] + "/" + parts[2]
+	}
+	// Already owner/repo
+	return repo
+}
@@ -0,0 +1,63 @@
+package config
+
+// Panel aggregation rules.
+const (
+	PanelAnyBlocker = "any_blocker"
+	PanelMajority   = "majority"
+	PanelWeighted   = "weighted"
+)
+
+// PanelConfig configures a `type: panel` stage: several reviewers run in
+// parallel against the same worktree without changing it, and their reports
+// are combined into one verdict.
+//
+//	stages:
+//	  - id: review
+//	    type: panel
+//	    on_fail: implement
+//	    panel:
+//	      rule: weighted
+//	      reviewers:
+//	        - name: security
+//	          focus: authentication, injection, secrets handling
+//	          weight: 2
+//	        - name: correctness
+//	          model: claude-sonnet-4-5
+type PanelConfig struct {
+	Rule      string          `yaml:"rule"`      // any_blocker (default), majority, weighted
+	Threshold float64         `yaml:"threshold"` // weighted: share of weight objecting that fails the panel; default 0.5
+	Reviewers []PanelReviewer `yaml:"reviewers"`
+}
+
+// PanelReviewer is one member of a review panel.
+type PanelReviewer struct {
+	Name           string  `yaml:"name"`
+	PromptTemplate string  `yaml:"prompt_template"` // defaults to the stage's prompt_template, then panel-review.md
+	Model          string  `yaml:"model"`           // defaults to the stage's model
+	Focus          string  `yaml:"focus"`           // rendered as {{review_focus}}
+	Weight         float64 `yaml:"weight"`          // weighted rule only; default 1
+}
+
+// PanelRule returns the aggregation rule, defaulting to any_blocker.
+func (p *PanelConfig) PanelRule() string {
+	if p == nil || p.Rule == "" {
+		return PanelAnyBlocker
+	}
+	return p.Rule
+}
+
+// PanelThreshold returns the weighted rule's threshold, defaulting to 0.5.
+func (p *PanelConfig) PanelThreshold() float64 {
+	if p == nil || p.Threshold == 0 {
+		return 0.5
+	}
+	return p.Threshold
+}
+
+// ReviewerWeight returns the reviewer's weight, defaulting to 1.
+func (r PanelReviewer) ReviewerWeight() float64 {
+	if r.Weight == 0 {
+		return 1
+	}
+	return r.Weight
+}
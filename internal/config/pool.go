@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxWorktreePool bounds pipeline.worktree_pool.size; every slot is a full
+// checkout with its dependencies installed.
+const maxWorktreePool = 16
+
+// DefaultLockfiles are the files whose contents decide whether a pooled
+// worktree must rerun pipeline.setup after it is moved to a new commit.
+var DefaultLockfiles = []string{
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Cargo.lock",
+	"poetry.lock",
+	"requirements.txt",
+	"Gemfile.lock",
+}
+
+// WorktreePoolConfig keeps worktrees of the base branch checked out and set
+// up ahead of time, so a new pipeline starts from one instead of running
+// `git worktree add` and pipeline.setup from scratch.
+//
+//	worktree_pool:
+//	  size: 2
+//	  lockfiles: [go.sum, web/package-lock.json]
+type WorktreePoolConfig struct {
+	Size      int      `yaml:"size"`      // worktrees kept ready; 0 disables the pool
+	Lockfiles []string `yaml:"lockfiles"` // relative to the repo root; default DefaultLockfiles
+}
+
+// PoolSize returns the number of worktrees to keep ready, 0 when disabled.
+func (p *WorktreePoolConfig) PoolSize() int {
+	if p == nil || p.Size < 0 {
+		return 0
+	}
+	return p.Size
+}
+
+// LockfileList returns the lockfiles to hash, defaulting to DefaultLockfiles.
+func (p *WorktreePoolConfig) LockfileList() []string {
+	if p == nil || len(p.Lockfiles) == 0 {
+		return DefaultLockfiles
+	}
+	return p.Lockfiles
+}
+
+func validateWorktreePool(p *WorktreePoolConfig, errs *[]ValidationError) {
+	if p == nil {
+		return
+	}
+	if p.Size < 0 || p.Size > maxWorktreePool {
+		*errs = append(*errs, ValidationError{
+			Field:   "pipeline.worktree_pool.size",
+			Message: fmt.Sprintf("must be between 0 and %d, got %d", maxWorktreePool, p.Size),
+		})
+	}
+	for i, f := range p.Lockfiles {
+		clean := filepath.ToSlash(filepath.Clean(f))
+		if f == "" || filepath.IsAbs(f) || clean == ".." || strings.HasPrefix(clean, "../") {
+			*errs = append(*errs, ValidationError{
+				Field:   fmt.Sprintf("pipeline.worktree_pool.lockfiles[%d]", i),
+				Message: fmt.Sprintf("must be a path inside the repo, got %q", f),
+			})
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Sandbox container runtimes.
+const (
+	SandboxPodman = "podman"
+	SandboxDocker = "docker"
+)
+
+// SandboxConfig runs a pipeline's agent sessions and checks inside a rootless
+// container instead of directly on the host. Only the worktree and the repo's
+// git directory are mounted.
+//
+//	sandbox:
+//	  image: ghcr.io/acme/factory-agent:latest
+//	  network: agents
+//	  cpus: "2"
+//	  memory: 4g
+//	  pids_limit: 512
+type SandboxConfig struct {
+	Runtime   string `yaml:"runtime"`    // podman (default) or docker
+	Image     string `yaml:"image"`      // must provide claude, git, sh and the repo's toolchain
+	Network   string `yaml:"network"`    // none, or a runtime network; default the runtime's default network
+	CPUs      string `yaml:"cpus"`       // e.g. "2" or "1.5"; default unlimited
+	Memory    string `yaml:"memory"`     // e.g. 4g or 512m; default unlimited
+	PidsLimit int    `yaml:"pids_limit"` // default unlimited
+}
+
+// Enabled reports whether sessions and checks run in a container.
+func (s *SandboxConfig) Enabled() bool {
+	return s != nil && s.Image != ""
+}
+
+// RuntimeName returns the container runtime binary, defaulting to podman.
+func (s *SandboxConfig) RuntimeName() string {
+	if s == nil || s.Runtime == "" {
+		return SandboxPodman
+	}
+	return s.Runtime
+}
+
+var (
+	sandboxMemoryRe  = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+	sandboxNetworkRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+func validateSandbox(s *SandboxConfig, stages []Stage, errs *[]ValidationError) {
+	if s == nil {
+		return
+	}
+	if s.Enabled() {
+		for i, st := range stages {
+			if st.Type == "browser_qa" {
+				*errs = append(*errs, ValidationError{
+					Field:   fmt.Sprintf("pipeline.stages[%d].type", i),
+					Message: "browser_qa serves the app on the host and cannot run with pipeline.sandbox",
+				})
+			}
+		}
+	}
+	if s.Image == "" {
+		*errs = append(*errs, ValidationError{Field: "pipeline.sandbox.image", Message: "is required"})
+	}
+	if rt := s.RuntimeName(); rt != SandboxPodman && rt != SandboxDocker {
+		*errs = append(*errs, ValidationError{
+			Field:   "pipeline.sandbox.runtime",
+			Message: fmt.Sprintf("unknown runtime %q (want podman or docker)", s.Runtime),
+		})
+	}
+	switch {
+	case s.Network == "host":
+		*errs = append(*errs, ValidationError{Field: "pipeline.sandbox.network", Message: "host networking defeats the sandbox"})
+	case s.Network != "" && !sandboxNetworkRe.MatchString(s.Network):
+		*errs = append(*errs, ValidationError{Field: "pipeline.sandbox.network", Message: fmt.Sprintf("invalid network name %q", s.Network)})
+	}
+	if s.CPUs != "" {
+		if n, err := strconv.ParseFloat(s.CPUs, 64); err != nil || n <= 0 {
+			*errs = append(*errs, ValidationError{Field: "pipeline.sandbox.cpus", Message: fmt.Sprintf("must be a positive number, got %q", s.CPUs)})
+		}
+	}
+	if s.Memory != "" && !sandboxMemoryRe.MatchString(s.Memory) {
+		*errs = append(*errs, ValidationError{Field: "pipeline.sandbox.memory", Message: fmt.Sprintf("must be a size like 512m or 4g, got %q", s.Memory)})
+	}
+	if s.PidsLimit < 0 {
+		*errs = append(*errs, ValidationError{Field: "pipeline.sandbox.pids_limit", Message: "must not be negative"})
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyError reports a mapping key that matches no field of the target type.
+type KeyError struct {
+	File    string // empty when parsed from bytes
+	Line    int
+	Column  int
+	Key     string
+	Path    string // dotted location of the enclosing mapping, e.g. pipeline.stages[2]
+	Suggest string // closest known key, if any
+}
+
+func (e KeyError) Error() string {
+	pos := fmt.Sprintf("%d:%d", e.Line, e.Column)
+	if e.File != "" {
+		pos = e.File + ":" + pos
+	}
+	where := "at top level"
+	if e.Path != "" {
+		where = "in " + e.Path
+	}
+	msg := fmt.Sprintf("%s: unknown key %q %s", pos, e.Key, where)
+	if e.Suggest != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", e.Suggest)
+	}
+	return msg
+}
+
+// UnknownKeysError lists every unknown key found in a document.
+type UnknownKeysError []KeyError
+
+func (e UnknownKeysError) Error() string {
+	lines := make([]string, len(e))
+	for i, k := range e {
+		lines[i] = k.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CheckKnownKeys reports every key in n that v's yaml-tagged fields do not
+// declare, as an UnknownKeysError. Types that implement yaml.Unmarshaler
+// validate their own shape and are not descended into.
+func CheckKnownKeys(file string, n *yaml.Node, v interface{}) error {
+	var errs UnknownKeysError
+	walkKnownKeys(n, reflect.TypeOf(v), "", func(k KeyError) {
+		k.File = file
+		errs = append(errs, k)
+	})
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+func walkKnownKeys(n *yaml.Node, t reflect.Type, path string, report func(KeyError)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return // type mismatches are reported by Decode
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			f, ok := fields[key.Value]
+			if !ok {
+				report(KeyError{
+					Line: key.Line, Column: key.Column, Key: key.Value, Path: path,
+					Suggest: closestKey(key.Value, fields),
+				})
+				continue
+			}
+			walkKnownKeys(val, f.Type, joinPath(path, key.Value), report)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			walkKnownKeys(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value), report)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			walkKnownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), report)
+		}
+	}
+}
+
+// yamlFields maps the YAML keys of a struct to their fields, following
+// yaml.v3's rules: the tag name, else the lowercased field name; "-" skips
+// the field and ",inline" flattens an embedded struct.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// closestKey returns the known key within edit distance 2 of key, if any.
+func closestKey(key string, fields map[string]reflect.StructField) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// schemaProvider lets a type with a custom YAML form describe itself.
+type schemaProvider interface {
+	JSONSchema() map[string]interface{}
+}
+
+var schemaProviderType = reflect.TypeOf((*schemaProvider)(nil)).Elem()
+
+// JSONSchema generates a JSON Schema (draft 2020-12) for the YAML form of v
+// from its yaml struct tags. Objects reject unknown properties, matching the
+// loader. No property is marked required so that include fragments, which
+// hold only part of a config, validate too.
+func JSONSchema(v interface{}, title string) map[string]interface{} {
+	s := typeSchema(reflect.TypeOf(v))
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = title
+	return s
+}
+
+// PipelineSchema returns the JSON Schema for pipeline.yaml, including the
+// top-level extends and include keys.
+func PipelineSchema() map[string]interface{} {
+	s := JSONSchema(PipelineConfig{}, "factory pipeline.yaml")
+	props := s["properties"].(map[string]interface{})
+	paths := map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	props[extendsKey] = map[string]interface{}{
+		"description": "base config file(s) to merge under this one, relative to this file",
+		"oneOf":       []interface{}{map[string]interface{}{"type": "string"}, paths},
+	}
+	props[includeKey] = map[string]interface{}{
+		"description": "config fragments to merge under this one, relative to this file",
+		"oneOf":       []interface{}{map[string]interface{}{"type": "string"}, paths},
+	}
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Implements(schemaProviderType) {
+		return reflect.Zero(t).Interface().(schemaProvider).JSONSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for name, f := range yamlFields(t) {
+			props[name] = typeSchema(f.Type)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
@@ -4,12 +4,19 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
 )
 
 // PipelineConfig is the top-level configuration structure parsed from pipeline YAML.
 type PipelineConfig struct {
 	Pipeline Pipeline        `yaml:"pipeline"`
 	Deploy   *DeployPipeline `yaml:"deploy"`
+
+	// Dir is the directory of the loaded YAML file; relative paths such as
+	// pipeline.env_file resolve against it. Empty when loaded from bytes.
+	Dir string `yaml:"-"`
 }
 
 // DeployPipeline defines the deploy pipeline configuration.
@@ -18,12 +25,60 @@ type DeployPipeline struct {
 	Stages []Stage `yaml:"stages"`
 }
 
+// Database isolation modes.
+const (
+	DatabaseShared      = "shared"       // every pipeline uses the repo database
+	DatabasePerPipeline = "per_pipeline" // each pipeline gets a clone of the template
+)
+
 // DatabaseConfig declares per-repo PostgreSQL database needs.
 type DatabaseConfig struct {
-	Name     string `yaml:"name"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Migrate  string `yaml:"migrate"`
+	Name      string `yaml:"name"`
+	User      string `yaml:"user"`
+	Password  string `yaml:"password"`
+	Migrate   string `yaml:"migrate"`
+	Rollback  string `yaml:"rollback"`  // undoes migrate; used by migration_check stages
+	Isolation string `yaml:"isolation"` // shared (default) or per_pipeline
+	Template  string `yaml:"template"`  // per_pipeline: database to clone; defaults to name
+	Issue     int    `yaml:"-"`         // set by ForIssue when Name is that issue's per_pipeline clone
+}
+
+// PerPipeline reports whether each pipeline gets its own database.
+func (d *DatabaseConfig) PerPipeline() bool {
+	return d != nil && d.Isolation == DatabasePerPipeline
+}
+
+// TemplateName returns the database per-pipeline databases are cloned from.
+func (d *DatabaseConfig) TemplateName() string {
+	if d.Template != "" {
+		return d.Template
+	}
+	return d.Name
+}
+
+// PipelineName returns the name of the database cloned for issue.
+func (d *DatabaseConfig) PipelineName(issue int) string {
+	return fmt.Sprintf("%s_issue_%d", d.Name, issue)
+}
+
+// ForIssue returns the config a pipeline for issue runs with. When the
+// database is per_pipeline, the copy points at the issue's own database so
+// that setup, migrations and sessions get its DATABASE_URL; otherwise c is
+// returned unchanged. The copy's isolation is cleared, so ForIssue is
+// idempotent.
+func (c *PipelineConfig) ForIssue(issue int) *PipelineConfig {
+	d := c.Pipeline.Database
+	if !d.PerPipeline() {
+		return c
+	}
+	db := *d
+	db.Name = d.PipelineName(issue)
+	db.Template = d.TemplateName()
+	db.Isolation = ""
+	db.Issue = issue
+	cp := *c
+	cp.Pipeline.Database = &db
+	return &cp
 }
 
 // URL returns a PostgreSQL connection string for this database config.
@@ -45,64 +100,210 @@ func (d *DatabaseConfig) URL() string {
 type Pipeline struct {
 	Name              string              `yaml:"name"`
 	Repo              string              `yaml:"repo"`
+	BaseBranch        string              `yaml:"base_branch"` // branch pipelines start from and merge into; default main
+	Remote            string              `yaml:"remote"`      // git remote to fetch and push; default origin
 	MaxFixRounds      int                 `yaml:"max_fix_rounds"`
 	FreshSessionAfter int                 `yaml:"fresh_session_after"`
 	Setup             []string            `yaml:"setup"`
+	WorktreePool      *WorktreePoolConfig `yaml:"worktree_pool"`
+	Sandbox           *SandboxConfig      `yaml:"sandbox"`
 	Database          *DatabaseConfig     `yaml:"database"`
-	Env               map[string]string   `yaml:"env"`
+	Env               map[string]string   `yaml:"env"`      // values may be "secret://<name>" references
+	EnvFile           string              `yaml:"env_file"` // dotenv file of secret values, relative to the config file
 	Defaults          StageDefaults       `yaml:"defaults"`
 	DefaultChecks     []string            `yaml:"default_checks"`
 	Checks            map[string]Check    `yaml:"checks"`
 	Stages            []Stage             `yaml:"stages"`
 	Vars              map[string]string   `yaml:"vars"`
 	Notifications     NotificationsConfig `yaml:"notifications"`
+	Lessons           LessonsConfig       `yaml:"lessons"`
+}
+
+// LessonsConfig controls the lessons from earlier pipelines injected as
+// {{lessons}}. Zero values use the defaults in package lessons.
+type LessonsConfig struct {
+	Disabled       bool `yaml:"disabled"`
+	Max            int  `yaml:"max"`             // lessons per prompt (default 5)
+	MinOccurrences int  `yaml:"min_occurrences"` // distinct issues a harvested lesson must recur in (default 3)
 }
 
 // DiscordConfig holds Discord webhook notification settings.
 type DiscordConfig struct {
-	WebhookURL     string `yaml:"webhook_url"`
-	ThreadPerIssue bool   `yaml:"thread_per_issue"`
+	WebhookURL     string           `yaml:"webhook_url"`
+	ThreadPerIssue bool             `yaml:"thread_per_issue"`
+	Bot            DiscordBotConfig `yaml:"bot"`
+}
+
+// DiscordBotConfig holds settings for the interactive slash-command bot.
+// The bot token used to register commands is read from DISCORD_BOT_TOKEN.
+type DiscordBotConfig struct {
+	ApplicationID string   `yaml:"application_id"`
+	PublicKey     string   `yaml:"public_key"` // hex Ed25519 key from the developer portal
+	GuildID       string   `yaml:"guild_id"`   // register commands to one guild; empty = global
+	AllowedUsers  []string `yaml:"allowed_users"`
+	AllowedRoles  []string `yaml:"allowed_roles"`
+}
+
+// SlackConfig holds Slack incoming-webhook notification settings.
+type SlackConfig struct {
+	WebhookURL string   `yaml:"webhook_url"`
+	Events     []string `yaml:"events"` // empty = stage_advanced, completed, failed, escalated
+}
+
+// WebhookConfig is a generic JSON webhook. When Secret is set, each request
+// carries an X-Factory-Signature HMAC-SHA256 of the body.
+type WebhookConfig struct {
+	Name   string   `yaml:"name"`
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
+}
+
+// EmailConfig holds SMTP email notification settings.
+type EmailConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Events   []string `yaml:"events"`
+}
+
+// NotifyRetryConfig controls delivery retries for Slack, webhook, and email sinks.
+type NotifyRetryConfig struct {
+	MaxAttempts int    `yaml:"max_attempts"` // default 3
+	Backoff     string `yaml:"backoff"`      // initial delay, doubled each retry; default "1s"
 }
 
 // NotificationsConfig holds per-project notification settings.
 type NotificationsConfig struct {
-	Discord DiscordConfig `yaml:"discord"`
+	Discord  DiscordConfig     `yaml:"discord"`
+	Slack    SlackConfig       `yaml:"slack"`
+	Webhooks []WebhookConfig   `yaml:"webhooks"`
+	Email    EmailConfig       `yaml:"email"`
+	Retry    NotifyRetryConfig `yaml:"retry"`
 }
 
 // StageDefaults holds default values applied to stages that don't specify their own.
 type StageDefaults struct {
-	Model   string `yaml:"model"`
-	Timeout string `yaml:"timeout"`
-	Flags   string `yaml:"flags"`
+	Model         string  `yaml:"model"`
+	Timeout       string  `yaml:"timeout"`
+	Flags         string  `yaml:"flags"`
+	ContextBudget int     `yaml:"context_budget"`
+	RelevantFiles int     `yaml:"relevant_files"`
+	Limits        *Limits `yaml:"limits"` // agent sessions of stages without their own
 }
 
 // Check defines a deterministic check that can be run between or after stages.
 type Check struct {
-	Command           string `yaml:"command"`
-	Parser            string `yaml:"parser"`
-	Timeout           string `yaml:"timeout"`
-	FixCommand        string `yaml:"fix_command"`
-	AutoFix           bool   `yaml:"auto_fix"`
-	SeverityThreshold string `yaml:"severity_threshold"`
+	Command           string          `yaml:"command"`
+	Parser            string          `yaml:"parser"`
+	Timeout           string          `yaml:"timeout"`
+	FixCommand        string          `yaml:"fix_command"`
+	AutoFix           bool            `yaml:"auto_fix"`
+	SeverityThreshold string          `yaml:"severity_threshold"`
+	Limits            *Limits         `yaml:"limits"`
+	Paths             []string        `yaml:"paths"`    // run only when a changed file matches one of these globs
+	Impact            string          `yaml:"impact"`   // "go": run only for, and narrow ./... to, the affected packages
+	Coverage          *CoverageConfig `yaml:"coverage"` // report and threshold for parser: coverage
 }
 
 // Stage defines a single pipeline stage — either an agent invocation or a checks-only gate.
 type Stage struct {
-	ID             string            `yaml:"id"`
-	Type           string            `yaml:"type"`
-	PromptTemplate string            `yaml:"prompt_template"`
-	Model          string            `yaml:"model"`
-	ContextMode    string            `yaml:"context_mode"`
-	Flags          string            `yaml:"flags"`
-	GoalGate       bool              `yaml:"goal_gate"`
-	SessionMode    string            `yaml:"session_mode"`
-	OnFail         interface{}       `yaml:"on_fail"`
-	BrowserCheck   bool              `yaml:"browser_check"`
-	SkipChecks     bool              `yaml:"skip_checks"`
-	ChecksAfter    []string          `yaml:"checks_after"`
-	ChecksBefore   []string          `yaml:"checks_before"`
-	ExtraChecks    []string          `yaml:"extra_checks"`
-	Checks         []string          `yaml:"checks"`
-	MergeStrategy  string            `yaml:"merge_strategy"`
-	Vars           map[string]string `yaml:"vars"`
+	ID               string                `yaml:"id"`
+	Type             string                `yaml:"type"`
+	PromptTemplate   string                `yaml:"prompt_template"`
+	PromptVariants   []PromptVariant       `yaml:"prompt_variants"` // A/B variants; replaces prompt_template
+	Model            string                `yaml:"model"`
+	Timeout          string                `yaml:"timeout"` // overrides defaults.timeout for this stage
+	ContextMode      string                `yaml:"context_mode"`
+	ContextBudget    int                   `yaml:"context_budget"`    // approx. tokens for injected context; 0 = unlimited
+	ContextSummarize bool                  `yaml:"context_summarize"` // condense over-budget inputs with an LLM instead of cutting
+	RelevantFiles    int                   `yaml:"relevant_files"`    // files listed in {{relevant_context}}; 0 = off
+	Candidates       int                   `yaml:"candidates"`        // best-of-N: parallel attempts in separate worktrees; 0 or 1 = off
+	Flags            string                `yaml:"flags"`
+	GoalGate         bool                  `yaml:"goal_gate"`
+	SessionMode      string                `yaml:"session_mode"`
+	OnFail           OnFail                `yaml:"on_fail"`
+	BrowserCheck     bool                  `yaml:"browser_check"`
+	SkipChecks       bool                  `yaml:"skip_checks"`
+	ChecksAfter      []string              `yaml:"checks_after"`
+	ChecksBefore     []string              `yaml:"checks_before"`
+	ExtraChecks      []string              `yaml:"extra_checks"`
+	Checks           []string              `yaml:"checks"`
+	MergeStrategy    string                `yaml:"merge_strategy"`
+	Panel            *PanelConfig          `yaml:"panel"` // reviewers of a type: panel stage
+	MigrationCheck   *MigrationCheckConfig `yaml:"migration_check"`
+	BrowserQA        *BrowserQAConfig      `yaml:"browser_qa"`
+	Limits           *Limits               `yaml:"limits"` // caps the stage's agent session
+	Vars             map[string]string     `yaml:"vars"`
+}
+
+// OnFail routes a stage whose checks fail. In YAML it is either a single stage
+// ID (on_fail: implement) or a map from failure kind to stage ID
+// (on_fail: {lint_fail: implement, default: escalate}). A single stage ID is
+// stored under the "default" key.
+type OnFail map[string]string
+
+// Target returns the stage to route to when no more specific route applies,
+// or "" to retry the same stage.
+func (o OnFail) Target() string {
+	return o["default"]
+}
+
+// Keys returns the failure kinds in sorted order.
+func (o OnFail) Keys() []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// UnmarshalYAML accepts a stage ID or a map of failure kind to stage ID.
+func (o *OnFail) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" || n.Value == "" {
+			*o = nil
+			return nil
+		}
+		*o = OnFail{"default": n.Value}
+		return nil
+	case yaml.MappingNode:
+		var m map[string]string
+		if err := n.Decode(&m); err != nil {
+			return fmt.Errorf("line %d: on_fail values must be stage IDs", n.Line)
+		}
+		*o = m
+		return nil
+	}
+	return fmt.Errorf("line %d: on_fail must be a stage ID or a map of failure kind to stage ID", n.Line)
+}
+
+// MarshalYAML writes a default-only route back as a plain stage ID.
+func (o OnFail) MarshalYAML() (interface{}, error) {
+	if len(o) == 0 {
+		return nil, nil
+	}
+	if len(o) == 1 && o["default"] != "" {
+		return o["default"], nil
+	}
+	return map[string]string(o), nil
+}
+
+// JSONSchema describes both accepted YAML forms.
+func (OnFail) JSONSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string", "description": "stage ID to route to, or \"escalate\""},
+			map[string]interface{}{
+				"type":                 "object",
+				"description":          "failure kind to stage ID; \"default\" applies when no kind matches",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
 }
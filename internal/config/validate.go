@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"regexp"
+	"time"
 )
 
 // ValidationError represents a single validation issue with a config.
@@ -18,6 +19,10 @@ func (e ValidationError) Error() string {
 // identifierRe matches valid SQL identifiers and environment variable names.
 var identifierRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
+// maxPipelineDBPrefix is the longest database name that still fits
+// "_issue_<n>" for issue numbers up to 99999999 in 63 bytes.
+const maxPipelineDBPrefix = 48
+
 // ValidationWarning represents a non-fatal validation issue.
 type ValidationWarning struct {
 	Field   string
@@ -31,6 +36,7 @@ var recognizedParsers = map[string]bool{
 	"typescript": true,
 	"vitest":     true,
 	"npm-audit":  true,
+	"coverage":   true,
 	"generic":    true,
 }
 
@@ -136,6 +142,50 @@ func Validate(cfg *PipelineConfig) []ValidationError {
 		}
 	}
 
+	if p.Defaults.ContextBudget < 0 {
+		errs = append(errs, ValidationError{Field: "pipeline.defaults.context_budget", Message: "must not be negative"})
+	}
+	if p.Lessons.Max < 0 {
+		errs = append(errs, ValidationError{Field: "pipeline.lessons.max", Message: "must not be negative"})
+	}
+	if p.Lessons.MinOccurrences < 0 {
+		errs = append(errs, ValidationError{Field: "pipeline.lessons.min_occurrences", Message: "must not be negative"})
+	}
+	if p.Defaults.RelevantFiles < 0 {
+		errs = append(errs, ValidationError{Field: "pipeline.defaults.relevant_files", Message: "must not be negative"})
+	}
+	for i, s := range p.Stages {
+		if s.ContextBudget < 0 {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("pipeline.stages[%d].context_budget", i),
+				Message: "must not be negative",
+			})
+		}
+		if s.RelevantFiles < 0 {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("pipeline.stages[%d].relevant_files", i),
+				Message: "must not be negative",
+			})
+		}
+		if s.Candidates < 0 {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("pipeline.stages[%d].candidates", i),
+				Message: "must not be negative",
+			})
+		} else if s.Candidates > 1 && s.Type != "" && s.Type != "agent" {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("pipeline.stages[%d].candidates", i),
+				Message: fmt.Sprintf("only applies to agent stages, not %q", s.Type),
+			})
+		}
+		validatePromptVariants(s, i, &errs)
+		validatePanel(s, i, &errs)
+		validateMigrationCheck(s, i, p.Database, &errs)
+		validateBrowserQA(s, i, &errs)
+		validateLimits(s.Limits, fmt.Sprintf("pipeline.stages[%d].limits", i), &errs)
+	}
+	validateLimits(p.Defaults.Limits, "pipeline.defaults.limits", &errs)
+
 	// Validate parser names in checks
 	for name, check := range p.Checks {
 		if check.Parser != "" && !recognizedParsers[check.Parser] {
@@ -144,7 +194,20 @@ func Validate(cfg *PipelineConfig) []ValidationError {
 				Message: fmt.Sprintf("unrecognized parser %q", check.Parser),
 			})
 		}
+		validateLimits(check.Limits, fmt.Sprintf("pipeline.checks.%s.limits", name), &errs)
+		validateCheckImpact(name, check, &errs)
+		validateCoverage(name, check, &errs)
+	}
+
+	if p.BaseBranch != "" && !ValidGitName(p.BaseBranch) {
+		errs = append(errs, ValidationError{Field: "pipeline.base_branch", Message: fmt.Sprintf("invalid branch name %q", p.BaseBranch)})
 	}
+	if p.Remote != "" && !ValidGitName(p.Remote) {
+		errs = append(errs, ValidationError{Field: "pipeline.remote", Message: fmt.Sprintf("invalid remote name %q", p.Remote)})
+	}
+
+	validateWorktreePool(p.WorktreePool, &errs)
+	validateSandbox(p.Sandbox, p.Stages, &errs)
 
 	// Validate database config fields
 	if p.Database != nil {
@@ -168,6 +231,34 @@ func Validate(cfg *PipelineConfig) []ValidationError {
 				Message: fmt.Sprintf("invalid identifier %q (must match %s)", p.Database.User, identifierRe.String()),
 			})
 		}
+		switch p.Database.Isolation {
+		case "", DatabaseShared:
+		case DatabasePerPipeline:
+			// Leave room for the "_issue_<n>" suffix within PostgreSQL's 63-byte limit.
+			if len(p.Database.Name) > maxPipelineDBPrefix {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.database.name",
+					Message: fmt.Sprintf("must be at most %d characters with per_pipeline isolation", maxPipelineDBPrefix),
+				})
+			}
+		default:
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.database.isolation",
+				Message: fmt.Sprintf("must be %s or %s, got %q", DatabaseShared, DatabasePerPipeline, p.Database.Isolation),
+			})
+		}
+		if p.Database.Template != "" {
+			if !p.Database.PerPipeline() {
+				errs = append(errs, ValidationError{
+					Field: "pipeline.database.template", Message: "only applies with per_pipeline isolation",
+				})
+			} else if !identifierRe.MatchString(p.Database.Template) {
+				errs = append(errs, ValidationError{
+					Field:   "pipeline.database.template",
+					Message: fmt.Sprintf("invalid identifier %q (must match %s)", p.Database.Template, identifierRe.String()),
+				})
+			}
+		}
 	}
 
 	// Validate deploy section (if present)
@@ -175,19 +266,56 @@ func Validate(cfg *PipelineConfig) []ValidationError {
 		errs = append(errs, validateDeployStages(cfg.Deploy)...)
 	}
 
-	// Validate env key names
-	for key := range p.Env {
+	errs = append(errs, validateNotifications(p.Notifications)...)
+
+	// Validate env key names and secret references
+	for key, val := range p.Env {
 		if !identifierRe.MatchString(key) {
 			errs = append(errs, ValidationError{
 				Field:   fmt.Sprintf("pipeline.env.%s", key),
 				Message: fmt.Sprintf("invalid env var name %q (must match %s)", key, identifierRe.String()),
 			})
 		}
+		if val == "secret://" {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("pipeline.env.%s", key),
+				Message: "secret reference is missing a name (secret://<name>)",
+			})
+		}
 	}
 
 	return errs
 }
 
+// validateNotifications checks the Slack, webhook, and email sink settings.
+func validateNotifications(n NotificationsConfig) []ValidationError {
+	var errs []ValidationError
+	for i, w := range n.Webhooks {
+		if w.URL == "" {
+			errs = append(errs, ValidationError{
+				Field: fmt.Sprintf("pipeline.notifications.webhooks[%d].url", i), Message: "is required",
+			})
+		}
+	}
+	if n.Email.Host != "" {
+		if n.Email.From == "" {
+			errs = append(errs, ValidationError{Field: "pipeline.notifications.email.from", Message: "is required"})
+		}
+		if len(n.Email.To) == 0 {
+			errs = append(errs, ValidationError{Field: "pipeline.notifications.email.to", Message: "at least one recipient is required"})
+		}
+	}
+	if n.Retry.Backoff != "" {
+		if _, err := time.ParseDuration(n.Retry.Backoff); err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "pipeline.notifications.retry.backoff",
+				Message: fmt.Sprintf("invalid duration %q", n.Retry.Backoff),
+			})
+		}
+	}
+	return errs
+}
+
 // validateDeployStages checks deploy-specific stage configuration.
 func validateDeployStages(deploy *DeployPipeline) []ValidationError {
 	var errs []ValidationError
@@ -230,60 +358,124 @@ func validateDeployStages(deploy *DeployPipeline) []ValidationError {
 // validateDeployOnFail checks on_fail targets in deploy stages.
 func validateDeployOnFail(s Stage, index int, stageIDs map[string]bool, errs *[]ValidationError) {
 	prefix := fmt.Sprintf("deploy.stages[%d].on_fail", index)
-
-	switch v := s.OnFail.(type) {
-	case nil:
-		// No on_fail specified
-	case string:
-		if v != "" && !stageIDs[v] {
+	for _, key := range s.OnFail.Keys() {
+		if target := s.OnFail[key]; !stageIDs[target] {
 			*errs = append(*errs, ValidationError{
-				Field:   prefix,
-				Message: fmt.Sprintf("references undefined deploy stage %q", v),
+				Field:   onFailField(prefix, s.OnFail, key),
+				Message: fmt.Sprintf("references undefined deploy stage %q", target),
 			})
 		}
-	case map[string]interface{}:
-		for key, val := range v {
-			target, ok := val.(string)
-			if !ok {
-				continue
-			}
-			if !stageIDs[target] {
-				*errs = append(*errs, ValidationError{
-					Field:   fmt.Sprintf("%s.%s", prefix, key),
-					Message: fmt.Sprintf("references undefined deploy stage %q", target),
-				})
-			}
-		}
 	}
 }
 
 // validateOnFail checks that on_fail values reference existing stage IDs.
+// validatePromptVariants checks a stage's prompt experiment.
+func validatePromptVariants(s Stage, index int, errs *[]ValidationError) {
+	if len(s.PromptVariants) == 0 {
+		return
+	}
+	field := fmt.Sprintf("pipeline.stages[%d].prompt_variants", index)
+	if s.PromptTemplate != "" {
+		*errs = append(*errs, ValidationError{Field: field, Message: "set either prompt_template or prompt_variants, not both"})
+	}
+	names := make(map[string]bool)
+	for j, v := range s.PromptVariants {
+		vf := fmt.Sprintf("%s[%d]", field, j)
+		if v.Template == "" {
+			*errs = append(*errs, ValidationError{Field: vf + ".template", Message: "is required"})
+			continue
+		}
+		if v.Weight < 0 {
+			*errs = append(*errs, ValidationError{Field: vf + ".weight", Message: "must not be negative"})
+		}
+		if names[v.VariantName()] {
+			*errs = append(*errs, ValidationError{Field: vf + ".name", Message: fmt.Sprintf("duplicate variant name %q", v.VariantName())})
+		}
+		names[v.VariantName()] = true
+	}
+}
+
+// validateMigrationCheck checks that a migration_check stage has the
+// database commands it runs.
+func validateMigrationCheck(s Stage, index int, db *DatabaseConfig, errs *[]ValidationError) {
+	field := fmt.Sprintf("pipeline.stages[%d]", index)
+	if s.Type != "migration_check" {
+		if s.MigrationCheck != nil {
+			*errs = append(*errs, ValidationError{Field: field + ".migration_check", Message: "only applies to stages with type: migration_check"})
+		}
+		return
+	}
+	switch {
+	case db == nil:
+		*errs = append(*errs, ValidationError{Field: field + ".type", Message: "migration_check stage requires pipeline.database"})
+	case db.Migrate == "" || db.Rollback == "":
+		*errs = append(*errs, ValidationError{Field: field + ".type", Message: "migration_check stage requires pipeline.database.migrate and pipeline.database.rollback"})
+	}
+}
+
+// panelNameRe limits reviewer names to what fits in a session name.
+var panelNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validatePanel checks the reviewers and rule of a panel stage.
+func validatePanel(s Stage, index int, errs *[]ValidationError) {
+	field := fmt.Sprintf("pipeline.stages[%d].panel", index)
+	if s.Type != "panel" {
+		if s.Panel != nil {
+			*errs = append(*errs, ValidationError{Field: field, Message: "only applies to stages with type: panel"})
+		}
+		return
+	}
+	if s.Panel == nil || len(s.Panel.Reviewers) == 0 {
+		*errs = append(*errs, ValidationError{Field: field + ".reviewers", Message: "panel stage must list at least one reviewer"})
+		return
+	}
+	switch s.Panel.PanelRule() {
+	case PanelAnyBlocker, PanelMajority, PanelWeighted:
+	default:
+		*errs = append(*errs, ValidationError{
+			Field:   field + ".rule",
+			Message: fmt.Sprintf("unknown rule %q (want %s, %s or %s)", s.Panel.Rule, PanelAnyBlocker, PanelMajority, PanelWeighted),
+		})
+	}
+	if s.Panel.Threshold < 0 || s.Panel.Threshold > 1 {
+		*errs = append(*errs, ValidationError{Field: field + ".threshold", Message: "must be between 0 and 1"})
+	}
+	names := make(map[string]bool)
+	for j, r := range s.Panel.Reviewers {
+		rf := fmt.Sprintf("%s.reviewers[%d]", field, j)
+		switch {
+		case r.Name == "":
+			*errs = append(*errs, ValidationError{Field: rf + ".name", Message: "is required"})
+		case !panelNameRe.MatchString(r.Name):
+			*errs = append(*errs, ValidationError{Field: rf + ".name", Message: fmt.Sprintf("invalid name %q (must match %s)", r.Name, panelNameRe.String())})
+		case names[r.Name]:
+			*errs = append(*errs, ValidationError{Field: rf + ".name", Message: fmt.Sprintf("duplicate reviewer name %q", r.Name)})
+		}
+		names[r.Name] = true
+		if r.Weight < 0 {
+			*errs = append(*errs, ValidationError{Field: rf + ".weight", Message: "must not be negative"})
+		}
+	}
+}
+
 func validateOnFail(s Stage, index int, stageIDs map[string]bool, errs *[]ValidationError) {
 	prefix := fmt.Sprintf("pipeline.stages[%d].on_fail", index)
-
-	switch v := s.OnFail.(type) {
-	case nil:
-		// No on_fail specified, that's fine
-	case string:
+	for _, key := range s.OnFail.Keys() {
 		// "escalate" is a reserved keyword handled by the orchestrator.
-		if v != "" && v != "escalate" && !stageIDs[v] {
+		if target := s.OnFail[key]; target != "escalate" && !stageIDs[target] {
 			*errs = append(*errs, ValidationError{
-				Field:   prefix,
-				Message: fmt.Sprintf("references undefined stage %q", v),
+				Field:   onFailField(prefix, s.OnFail, key),
+				Message: fmt.Sprintf("references undefined stage %q", target),
 			})
 		}
-	case map[string]interface{}:
-		for key, val := range v {
-			target, ok := val.(string)
-			if !ok {
-				continue
-			}
-			if target != "escalate" && !stageIDs[target] {
-				*errs = append(*errs, ValidationError{
-					Field:   fmt.Sprintf("%s.%s", prefix, key),
-					Message: fmt.Sprintf("references undefined stage %q", target),
-				})
-			}
-		}
 	}
 }
+
+// onFailField names the on_fail entry for key; a plain stage ID is reported
+// as on_fail itself rather than on_fail.default.
+func onFailField(prefix string, o OnFail, key string) string {
+	if key == "default" && len(o) == 1 {
+		return prefix
+	}
+	return prefix + "." + key
+}
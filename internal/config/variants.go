@@ -0,0 +1,63 @@
+package config
+
+import (
+	"hash/fnv"
+	"path"
+	"strings"
+)
+
+// PromptVariant is one arm of a prompt experiment: a stage that lists
+// several variants renders one of them per issue, chosen by weight.
+//
+//	prompt_variants:
+//	  - template: implement.md
+//	    weight: 3
+//	  - name: terse
+//	    template: implement-terse.md
+//	    weight: 1
+type PromptVariant struct {
+	Name     string `yaml:"name"` // defaults to the template file name without extension
+	Template string `yaml:"template"`
+	Weight   int    `yaml:"weight"` // relative share of issues; default 1
+}
+
+// VariantName returns the variant's name, defaulting to its template's base name.
+func (v PromptVariant) VariantName() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return strings.TrimSuffix(path.Base(v.Template), path.Ext(v.Template))
+}
+
+func (v PromptVariant) weight() int {
+	if v.Weight == 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// PickVariant chooses the stage's prompt variant for key (typically the
+// namespace, issue and stage) by weight. The choice is a hash of key, so
+// retries and fix rounds of the same issue keep their variant while issues
+// spread across variants in proportion to their weights. ok is false when
+// the stage declares no variants.
+func (s *Stage) PickVariant(key string) (v PromptVariant, ok bool) {
+	total := 0
+	for _, pv := range s.PromptVariants {
+		total += max(pv.weight(), 0)
+	}
+	if total == 0 {
+		return PromptVariant{}, false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	n := int(h.Sum32() % uint32(total))
+	for _, pv := range s.PromptVariants {
+		w := max(pv.weight(), 0)
+		if n < w {
+			return pv, true
+		}
+		n -= w
+	}
+	return PromptVariant{}, false
+}
@@ -0,0 +1,270 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/prompt"
+)
+
+// budgetWeights ranks the prompt inputs a context budget may shrink. Inputs
+// that fit their weighted share of the budget are kept whole; the rest split
+// what remains in proportion to their weight. Listed in processing order.
+var budgetWeights = []struct {
+	name   string
+	weight int
+}{
+	{"issue_body", 3},
+	{"check_failures", 3},
+	{"review_findings", 3},
+	{"git_diff", 3},
+	{"prior_stage_summary", 2},
+	{"relevant_context", 1},
+	{"lessons", 1},
+	{"git_diff_summary", 1},
+	{"files_changed", 1},
+	{"git_commits", 1},
+}
+
+// Truncation records how one prompt input was shrunk to fit the budget.
+type Truncation struct {
+	Section    string `json:"section"`
+	FromTokens int    `json:"from_tokens"`
+	ToTokens   int    `json:"to_tokens"`
+	Method     string `json:"method"` // "hunks trimmed", "head/tail", "summarised", "dropped"
+}
+
+// EstimateTokens approximates the token count of s (about four bytes per token).
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// applyBudget shrinks vars until their estimated total fits within budget
+// tokens. Diff hunks of files named in focus are kept longest. When summarise
+// is set and a summarizer is configured, text sections are condensed by the
+// LLM instead of cut, falling back to head/tail on error.
+func (b *Builder) applyBudget(budget int, vars prompt.Vars, focus map[string]bool, summarise bool) []Truncation {
+	available := budget
+	sizes := make(map[string]int)
+	weights := make(map[string]int)
+	for _, w := range budgetWeights {
+		weights[w.name] = w.weight
+	}
+	for name, v := range vars {
+		if _, ok := weights[name]; ok && v != "" {
+			sizes[name] = EstimateTokens(v)
+		} else {
+			available -= EstimateTokens(v)
+		}
+	}
+
+	// Water-fill: sections within their share keep everything and free the
+	// rest of their share for the others. Every share in a pass is measured
+	// against the budget left at the start of that pass, so the result does
+	// not depend on the order sections are visited.
+	shares := make(map[string]int)
+	for {
+		totalWeight := 0
+		for name := range sizes {
+			if _, done := shares[name]; !done {
+				totalWeight += weights[name]
+			}
+		}
+		if totalWeight == 0 {
+			break
+		}
+		left := max(available, 0)
+		settled := false
+		for _, w := range budgetWeights {
+			size, ok := sizes[w.name]
+			if _, done := shares[w.name]; !ok || done {
+				continue
+			}
+			if size <= left*w.weight/totalWeight {
+				shares[w.name] = size
+				available -= size
+				settled = true
+			}
+		}
+		if !settled {
+			for name := range sizes {
+				if _, done := shares[name]; !done {
+					shares[name] = left * weights[name] / totalWeight
+				}
+			}
+			break
+		}
+	}
+
+	var truncs []Truncation
+	for _, w := range budgetWeights {
+		name := w.name
+		from, ok := sizes[name]
+		if !ok || shares[name] >= from {
+			continue
+		}
+		text, target := vars[name], shares[name]
+
+		var out, method string
+		switch {
+		case target == 0:
+			out, method = "", "dropped"
+		case name == "git_diff":
+			out, method = trimDiff(text, focus, target)
+		case summarise && b.summarize != nil:
+			if s, err := b.summarize(summaryPrompt(name, text, target)); err == nil && s != "" && EstimateTokens(s) <= target {
+				out, method = s, "summarised"
+				break
+			}
+			fallthrough
+		default:
+			out, method = headTail(text, target), "head/tail"
+		}
+
+		vars[name] = out
+		truncs = append(truncs, Truncation{Section: name, FromTokens: from, ToTokens: EstimateTokens(out), Method: method})
+	}
+	return truncs
+}
+
+// headTail keeps the first and last lines of text within about target
+// tokens, replacing the middle with a marker.
+func headTail(text string, target int) string {
+	if EstimateTokens(text) <= target {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	budget := target * 4
+	var head, tail []string
+	used := 0
+	for i, j := 0, len(lines)-1; i <= j; {
+		// Alternate so that both the start (context) and end (final errors) survive.
+		line := lines[i]
+		fromHead := len(head) <= len(tail)
+		if !fromHead {
+			line = lines[j]
+		}
+		if used+len(line)+1 > budget {
+			break
+		}
+		used += len(line) + 1
+		if fromHead {
+			head = append(head, line)
+			i++
+		} else {
+			tail = append([]string{line}, tail...)
+			j--
+		}
+	}
+	cut := len(lines) - len(head) - len(tail)
+	parts := append(head, fmt.Sprintf("... [%d lines trimmed to fit the context budget] ...", cut))
+	return strings.Join(append(parts, tail...), "\n")
+}
+
+// trimDiff shrinks a unified diff: first the hunks of files outside focus are
+// replaced by a one-line note, then those files are dropped entirely, and as
+// a last resort the remaining diff is cut head/tail.
+func trimDiff(diff string, focus map[string]bool, target int) (string, string) {
+	files := splitDiff(diff)
+
+	var sb strings.Builder
+	for _, f := range files {
+		if focus[f.path] {
+			sb.WriteString(f.text)
+			continue
+		}
+		sb.WriteString(f.header)
+		if f.hunks > 0 {
+			fmt.Fprintf(&sb, "@@ %d hunk(s) trimmed: +%d -%d lines @@\n", f.hunks, f.added, f.removed)
+		}
+	}
+	if out := sb.String(); EstimateTokens(out) <= target {
+		return out, "hunks trimmed"
+	}
+
+	sb.Reset()
+	var omitted []string
+	for _, f := range files {
+		if focus[f.path] {
+			sb.WriteString(f.text)
+		} else {
+			omitted = append(omitted, f.path)
+		}
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&sb, "[diff of %d other file(s) omitted: %s]\n", len(omitted), strings.Join(omitted, ", "))
+	}
+	if out := sb.String(); EstimateTokens(out) <= target {
+		return out, "hunks trimmed"
+	}
+	return headTail(sb.String(), target), "head/tail"
+}
+
+type diffFile struct {
+	path           string
+	header         string // "diff --git" line through the +++ line
+	text           string // header and hunks
+	hunks          int
+	added, removed int
+}
+
+// splitDiff splits a unified diff into per-file sections.
+func splitDiff(diff string) []diffFile {
+	var files []diffFile
+	var cur *diffFile
+	inHunks := false
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			files = append(files, diffFile{path: diffPath(line)})
+			cur = &files[len(files)-1]
+			inHunks = false
+		}
+		if cur == nil {
+			continue
+		}
+		cur.text += line
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			cur.hunks++
+			inHunks = true
+		case !inHunks:
+			cur.header += line
+		case strings.HasPrefix(line, "+"):
+			cur.added++
+		case strings.HasPrefix(line, "-"):
+			cur.removed++
+		}
+	}
+	return files
+}
+
+// diffPath extracts the b/ path from a "diff --git a/x b/x" line.
+func diffPath(line string) string {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "diff --git "))
+	if i := strings.LastIndex(line, " b/"); i >= 0 {
+		return line[i+3:]
+	}
+	return line
+}
+
+func summaryPrompt(section, text string, target int) string {
+	return fmt.Sprintf(`Condense the following %s for a coding agent's prompt to at most about %d words.
+Keep file paths, function names, error messages, and test names verbatim. Output only the condensed text.
+
+%s`, strings.ReplaceAll(section, "_", " "), target*3/4, text)
+}
+
+// BudgetNote renders the truncations as an HTML comment appended to the saved
+// prompt, so a reader can tell which inputs were cut. Empty when none were.
+func BudgetNote(budget int, truncs []Truncation) string {
+	if len(truncs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\n<!-- context budget: %d tokens; truncated:", budget)
+	for _, t := range truncs {
+		fmt.Fprintf(&sb, "\n  %s: %d -> %d tokens (%s)", t.Section, t.FromTokens, t.ToTokens, t.Method)
+	}
+	sb.WriteString("\n-->\n")
+	return sb.String()
+}
@@ -0,0 +1,146 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+func bigDiff(files ...string) string {
+	var sb strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&sb, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", f, f, f, f)
+		for h := 0; h < 3; h++ {
+			fmt.Fprintf(&sb, "@@ -%d,4 +%d,4 @@\n", h*10, h*10)
+			for i := 0; i < 20; i++ {
+				fmt.Fprintf(&sb, "-old line %d in %s\n+new line %d in %s\n", i, f, i, f)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestTrimDiff_KeepsFocusFiles(t *testing.T) {
+	diff := bigDiff("src/auth.go", "docs/readme.md", "src/util.go")
+	out, method := trimDiff(diff, map[string]bool{"src/auth.go": true}, EstimateTokens(diff)/2)
+
+	if method != "hunks trimmed" {
+		t.Errorf("method = %q", method)
+	}
+	if !strings.Contains(out, "+new line 19 in src/auth.go") {
+		t.Error("focus file hunks should be kept")
+	}
+	if strings.Contains(out, "new line 0 in src/util.go") {
+		t.Error("unrelated hunks should be trimmed")
+	}
+	if !strings.Contains(out, "diff --git a/src/util.go b/src/util.go\n--- a/src/util.go\n+++ b/src/util.go\n@@ 3 hunk(s) trimmed: +60 -60 lines @@") {
+		t.Errorf("unrelated file should keep its header and a trim note:\n%s", out)
+	}
+
+	out, _ = trimDiff(diff, map[string]bool{"src/auth.go": true}, EstimateTokens(diff)/3+20)
+	if !strings.Contains(out, "[diff of 2 other file(s) omitted: docs/readme.md, src/util.go]") {
+		t.Errorf("expected omitted-files note:\n%s", out[len(out)-200:])
+	}
+}
+
+func TestHeadTail(t *testing.T) {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("log line %03d", i))
+	}
+	out := headTail(strings.Join(lines, "\n"), 50)
+
+	if !strings.HasPrefix(out, "log line 000\n") || !strings.HasSuffix(out, "log line 199") {
+		t.Errorf("head and tail should survive:\n%s", out)
+	}
+	if !strings.Contains(out, "lines trimmed to fit the context budget") {
+		t.Error("missing trim marker")
+	}
+	if EstimateTokens(out) > 70 {
+		t.Errorf("output too large: %d tokens", EstimateTokens(out))
+	}
+}
+
+func TestBuild_ContextBudget(t *testing.T) {
+	store := newTestStore(t)
+	ps := newTestPipeline(t, store)
+	ps.StageHistory = []pipeline.StageHistoryEntry{{Stage: "implement", Attempt: 1, Outcome: "fail"}}
+	_ = store.SaveStageOutcome(42, "implement", 1, &pipeline.StageOutcome{
+		Status:  "fail",
+		Summary: "FAIL src/auth.go:12 expected token" + strings.Repeat("\nnoise", 2000),
+	})
+
+	diff := bigDiff("src/auth.go", "src/other.go", "src/more.go")
+	git := &mockGit{diff: diff, filesChanged: "src/auth.go\nsrc/other.go\nsrc/more.go", log: "abc123 wip"}
+	builder := NewBuilder(store, git)
+
+	unlimited, err := builder.Build(ps, BuildOpts{Issue: 42, Stage: "implement", StageCfg: &config.Stage{ID: "implement"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := unlimited.Vars["git_diff"]; ok || len(unlimited.Truncations) != 0 {
+		t.Error("without a budget the full diff is not included and nothing is truncated")
+	}
+
+	var summarised []string
+	builder.SetSummarizer(func(p string) (string, error) {
+		summarised = append(summarised, p)
+		return "condensed", nil
+	})
+	result, err := builder.Build(ps, BuildOpts{
+		Issue: 42, Stage: "implement",
+		StageCfg: &config.Stage{ID: "implement", ContextBudget: 2500, ContextSummarize: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, v := range result.Vars {
+		total += EstimateTokens(v)
+	}
+	if total > 2500 {
+		t.Errorf("vars total %d tokens, budget 2500", total)
+	}
+	if !strings.Contains(result.Vars["git_diff"], "+new line 0 in src/auth.go") {
+		t.Error("diff of the failing file should be kept")
+	}
+	sections := map[string]string{}
+	for _, tr := range result.Truncations {
+		sections[tr.Section] = tr.Method
+	}
+	if sections["git_diff"] != "hunks trimmed" {
+		t.Errorf("truncations = %+v", result.Truncations)
+	}
+	if sections["check_failures"] != "summarised" || result.Vars["check_failures"] != "condensed" || len(summarised) != 2 {
+		t.Errorf("check_failures should be summarised: %+v", result.Truncations)
+	}
+
+	note := BudgetNote(result.Budget, result.Truncations)
+	if !strings.Contains(note, "context budget: 2500 tokens") || !strings.Contains(note, "git_diff:") {
+		t.Errorf("note = %q", note)
+	}
+}
+
+func TestApplyBudget_OrderIndependent(t *testing.T) {
+	// issue_body and git_diff each fit their 3/7 share of the budget but
+	// not the share left once the other has settled. Both are kept whole
+	// on every run and lessons gets what remains.
+	for i := 0; i < 50; i++ {
+		vars := map[string]string{
+			"issue_body": strings.Repeat("a", 40*4),
+			"git_diff":   strings.Repeat("b", 40*4),
+			"lessons":    strings.Repeat("c", 30*4),
+		}
+		truncs := (&Builder{}).applyBudget(100, vars, nil, false)
+		if len(truncs) != 1 || truncs[0].Section != "lessons" || truncs[0].FromTokens != 30 {
+			t.Fatalf("run %d: truncs = %+v, want only lessons cut", i, truncs)
+		}
+		if EstimateTokens(vars["lessons"]) > 20 {
+			t.Fatalf("run %d: lessons = %d tokens, want at most 20", i, EstimateTokens(vars["lessons"]))
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/github"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
 	"github.com/lucasnoah/taintfactory/internal/prompt"
 )
@@ -34,18 +35,34 @@ func IsValidMode(s string) bool {
 	return false
 }
 
-// GitRunner provides git operations for context building.
+// GitRunner provides git operations for context building. base is the ref
+// the branch is compared against (PipelineState.BaseRef).
 type GitRunner interface {
-	Diff(dir string) (string, error)
-	DiffSummary(dir string) (string, error)
-	FilesChanged(dir string) (string, error)
-	Log(dir string) (string, error)
+	Diff(dir, base string) (string, error)
+	DiffSummary(dir, base string) (string, error)
+	FilesChanged(dir, base string) (string, error)
+	Log(dir, base string) (string, error)
+}
+
+// Retriever finds the repository files and past changes most relevant to an
+// issue, formatted for a prompt. See repoindex.Provider.
+type Retriever interface {
+	Relevant(dir, query string, changed []string, n int) (string, error)
+}
+
+// LessonSource returns the lessons from earlier pipelines of a namespace
+// that apply to a stage, formatted for a prompt. See lessons.Provider.
+type LessonSource interface {
+	Lessons(namespace, query, stage string, checks []string) (string, error)
 }
 
 // Builder assembles context/prompt for a pipeline stage.
 type Builder struct {
-	store *pipeline.Store
-	git   GitRunner
+	store     *pipeline.Store
+	git       GitRunner
+	summarize github.LLMFunc // optional; condenses inputs for stages with context_summarize
+	retriever Retriever      // optional; fills relevant_context for stages with relevant_files
+	lessons   LessonSource   // optional; fills lessons
 }
 
 // NewBuilder creates a Builder.
@@ -53,6 +70,21 @@ func NewBuilder(store *pipeline.Store, git GitRunner) *Builder {
 	return &Builder{store: store, git: git}
 }
 
+// SetSummarizer configures the LLM used to condense over-budget inputs.
+func (b *Builder) SetSummarizer(fn github.LLMFunc) {
+	b.summarize = fn
+}
+
+// SetLessons configures the source of {{lessons}}.
+func (b *Builder) SetLessons(l LessonSource) {
+	b.lessons = l
+}
+
+// SetRetriever configures the repository index used for {{relevant_context}}.
+func (b *Builder) SetRetriever(r Retriever) {
+	b.retriever = r
+}
+
 // BuildOpts configures what context to build.
 type BuildOpts struct {
 	Issue        int
@@ -65,8 +97,13 @@ type BuildOpts struct {
 // BuildResult holds the assembled context.
 type BuildResult struct {
 	Vars     prompt.Vars
+	Data     prompt.Data // structured lists for {{#range}}: findings, changed_files, prior_stages, dependents
 	Mode     FidelityMode
 	Template string
+	Variant  string // prompt_variants name chosen for this issue; empty without an experiment
+
+	Budget      int          // stage context_budget in tokens; 0 = unlimited
+	Truncations []Truncation // inputs shrunk to fit Budget
 }
 
 // Build assembles template variables for a stage based on its fidelity mode.
@@ -84,6 +121,9 @@ func (b *Builder) Build(ps *pipeline.PipelineState, opts BuildOpts) (*BuildResul
 		"worktree_path":  ps.Worktree,
 		"repo_root":      filepath.Dir(filepath.Dir(ps.Worktree)),
 		"branch":         ps.Branch,
+		"base_branch":    ps.BaseBranchName(),
+		"remote":         ps.RemoteName(),
+		"base_ref":       ps.BaseRef(),
 		"stage_id":       opts.Stage,
 		"attempt":        strconv.Itoa(ps.CurrentAttempt),
 		"goal":           buildGoal(ps),
@@ -105,13 +145,24 @@ func (b *Builder) Build(ps *pipeline.PipelineState, opts BuildOpts) (*BuildResul
 		}
 	}
 
+	data := prompt.Data{}
+	if len(ps.DependentIssues) > 0 {
+		deps := make([]map[string]interface{}, len(ps.DependentIssues))
+		for i, d := range ps.DependentIssues {
+			deps[i] = map[string]interface{}{"number": d.Issue, "feature_intent": d.FeatureIntent}
+		}
+		data["dependents"] = deps
+	}
+
 	switch mode {
 	case ModeFull:
-		b.addFullContext(ps, opts, vars)
+		b.addFullContext(ps, opts, vars, data)
+		b.addBudgetedDiff(ps, opts, vars)
 	case ModeCodeOnly:
-		b.addCodeOnlyContext(ps, opts, vars)
+		b.addCodeOnlyContext(ps, opts, vars, data)
+		b.addBudgetedDiff(ps, opts, vars)
 	case ModeFindingsOnly:
-		b.addFindingsOnlyContext(ps, opts, vars)
+		b.addFindingsOnlyContext(ps, opts, vars, data)
 	case ModeMinimal:
 		// minimal: just the base vars above
 	}
@@ -119,38 +170,53 @@ func (b *Builder) Build(ps *pipeline.PipelineState, opts BuildOpts) (*BuildResul
 	// Check failures are always included regardless of mode
 	b.addCheckFailures(ps, opts, vars)
 
-	// Resolve template path
+	if mode != ModeMinimal {
+		b.addReviewFindings(ps, vars)
+		b.addRelevantContext(ps, opts, vars)
+		b.addLessons(ps, opts, vars)
+	}
+
+	var budget int
+	var truncs []Truncation
+	if opts.StageCfg != nil && opts.StageCfg.ContextBudget > 0 {
+		budget = opts.StageCfg.ContextBudget
+		truncs = b.applyBudget(budget, vars, focusFiles(vars, data), opts.StageCfg.ContextSummarize)
+	}
+
+	// Resolve template path; a prompt experiment picks one of its variants
 	tmplPath := opts.StageCfg.PromptTemplate
+	var variant string
+	if v, ok := opts.StageCfg.PickVariant(fmt.Sprintf("%s#%d/%s", ps.Namespace, ps.Issue, opts.Stage)); ok {
+		tmplPath, variant = v.Template, v.VariantName()
+	}
 	if tmplPath == "" {
 		tmplPath = opts.Stage + ".md"
 	}
 
 	return &BuildResult{
 		Vars:     vars,
+		Data:     data,
 		Mode:     mode,
 		Template: tmplPath,
+		Variant:  variant,
+
+		Budget:      budget,
+		Truncations: truncs,
 	}, nil
 }
 
 // addFullContext includes everything from prior stages.
-func (b *Builder) addFullContext(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars) {
-	// Git context — commits, summary, and file list (no full diff)
-	if b.git != nil {
-		if log, err := b.git.Log(ps.Worktree); err == nil && log != "" {
-			vars["git_commits"] = log
-		}
-		if summary, err := b.git.DiffSummary(ps.Worktree); err == nil && summary != "" {
-			vars["git_diff_summary"] = summary
-		}
-		if files, err := b.git.FilesChanged(ps.Worktree); err == nil && files != "" {
-			vars["files_changed"] = files
-		}
-	}
+func (b *Builder) addFullContext(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars, data prompt.Data) {
+	b.addGitContext(ps, vars, data)
 
 	// Prior stage summaries
-	priorSummary := b.collectPriorStageSummaries(ps)
+	priorSummary, priorStages := b.collectPriorStageSummaries(ps)
 	if priorSummary != "" {
 		vars["prior_stage_summary"] = priorSummary
+		data["prior_stages"] = priorStages
+	}
+	if findings := b.lastStageFindings(ps); len(findings) > 0 {
+		data["findings"] = findings
 	}
 
 	// Acceptance criteria from stage config goals
@@ -160,19 +226,8 @@ func (b *Builder) addFullContext(ps *pipeline.PipelineState, opts BuildOpts, var
 }
 
 // addCodeOnlyContext includes commits and file list but strips reasoning.
-func (b *Builder) addCodeOnlyContext(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars) {
-	// Git context — commits, summary, and file list (no full diff)
-	if b.git != nil {
-		if log, err := b.git.Log(ps.Worktree); err == nil && log != "" {
-			vars["git_commits"] = log
-		}
-		if summary, err := b.git.DiffSummary(ps.Worktree); err == nil && summary != "" {
-			vars["git_diff_summary"] = summary
-		}
-		if files, err := b.git.FilesChanged(ps.Worktree); err == nil && files != "" {
-			vars["files_changed"] = files
-		}
-	}
+func (b *Builder) addCodeOnlyContext(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars, data prompt.Data) {
+	b.addGitContext(ps, vars, data)
 
 	// Acceptance criteria (not reasoning)
 	if ac, ok := ps.GoalGates[opts.Stage]; ok && ac != "" {
@@ -182,8 +237,94 @@ func (b *Builder) addCodeOnlyContext(ps *pipeline.PipelineState, opts BuildOpts,
 	// No prior_stage_summary — fresh eyes on the code
 }
 
+// addGitContext adds commits, diff summary, and the changed file list (no full diff).
+func (b *Builder) addGitContext(ps *pipeline.PipelineState, vars prompt.Vars, data prompt.Data) {
+	if b.git == nil {
+		return
+	}
+	if log, err := b.git.Log(ps.Worktree, ps.BaseRef()); err == nil && log != "" {
+		vars["git_commits"] = log
+	}
+	if summary, err := b.git.DiffSummary(ps.Worktree, ps.BaseRef()); err == nil && summary != "" {
+		vars["git_diff_summary"] = summary
+	}
+	if files, err := b.git.FilesChanged(ps.Worktree, ps.BaseRef()); err == nil && files != "" {
+		vars["files_changed"] = files
+		data["changed_files"] = strings.Split(strings.TrimSpace(files), "\n")
+	}
+}
+
+// addBudgetedDiff includes the full branch diff for stages with a
+// context_budget; without a budget it could grow without bound.
+func (b *Builder) addBudgetedDiff(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars) {
+	if b.git == nil || opts.StageCfg == nil || opts.StageCfg.ContextBudget <= 0 {
+		return
+	}
+	if diff, err := b.git.Diff(ps.Worktree, ps.BaseRef()); err == nil && diff != "" {
+		vars["git_diff"] = diff
+	}
+}
+
+// addRelevantContext ranks repository files and past changes against the
+// issue and the branch's changed files for stages with relevant_files set.
+// Indexing errors are not fatal; the variable is simply left out.
+func (b *Builder) addRelevantContext(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars) {
+	if b.retriever == nil || opts.StageCfg == nil || opts.StageCfg.RelevantFiles <= 0 {
+		return
+	}
+	query := strings.Join([]string{ps.Title, opts.IssueBody, ps.FeatureIntent, vars["acceptance_criteria"]}, "\n")
+	files := vars["files_changed"]
+	if files == "" && b.git != nil {
+		files, _ = b.git.FilesChanged(ps.Worktree, ps.BaseRef())
+	}
+	var changed []string
+	for _, f := range strings.Split(strings.TrimSpace(files), "\n") {
+		if f != "" {
+			changed = append(changed, f)
+		}
+	}
+	if rc, err := b.retriever.Relevant(ps.Worktree, query, changed, opts.StageCfg.RelevantFiles); err == nil && rc != "" {
+		vars["relevant_context"] = rc
+	}
+}
+
+// addLessons adds the lessons learned in earlier pipelines of the same
+// namespace that apply to this stage.
+func (b *Builder) addLessons(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars) {
+	if b.lessons == nil || opts.StageCfg == nil {
+		return
+	}
+	var checks []string
+	checks = append(checks, opts.StageCfg.ChecksBefore...)
+	checks = append(checks, opts.StageCfg.ChecksAfter...)
+	checks = append(checks, opts.StageCfg.Checks...)
+	checks = append(checks, opts.StageCfg.ExtraChecks...)
+	query := ps.Title + "\n" + opts.IssueBody
+	if l, err := b.lessons.Lessons(ps.Namespace, query, opts.Stage, checks); err == nil && l != "" {
+		vars["lessons"] = l
+	}
+}
+
+// focusFiles returns the changed files that check failures or findings
+// mention; their diffs are kept longest when trimming to a budget.
+func focusFiles(vars prompt.Vars, data prompt.Data) map[string]bool {
+	focus := make(map[string]bool)
+	findings, _ := data["findings"].([]map[string]interface{})
+	for _, f := range findings {
+		if file, ok := f["file"].(string); ok && file != "" {
+			focus[file] = true
+		}
+	}
+	for _, file := range strings.Split(vars["files_changed"], "\n") {
+		if file != "" && strings.Contains(vars["check_failures"], file) {
+			focus[file] = true
+		}
+	}
+	return focus
+}
+
 // addFindingsOnlyContext includes only structured findings from prior stage.
-func (b *Builder) addFindingsOnlyContext(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars) {
+func (b *Builder) addFindingsOnlyContext(ps *pipeline.PipelineState, opts BuildOpts, vars prompt.Vars, data prompt.Data) {
 	// Acceptance criteria
 	if ac, ok := ps.GoalGates[opts.Stage]; ok && ac != "" {
 		vars["acceptance_criteria"] = ac
@@ -195,15 +336,8 @@ func (b *Builder) addFindingsOnlyContext(ps *pipeline.PipelineState, opts BuildO
 		outcome, err := b.store.GetStageOutcome(ps.Issue, lastEntry.Stage, lastEntry.Attempt)
 		if err == nil && outcome != nil {
 			if len(outcome.Findings) > 0 {
-				var findingsText strings.Builder
-				for _, f := range outcome.Findings {
-					fmt.Fprintf(&findingsText, "- %s:%d [%s] %s", f.File, f.Line, f.Severity, f.Message)
-					if f.Rule != "" {
-						fmt.Fprintf(&findingsText, " (%s)", f.Rule)
-					}
-					findingsText.WriteString("\n")
-				}
-				vars["prior_stage_summary"] = findingsText.String()
+				data["findings"] = findingsData(outcome.Findings)
+				vars["prior_stage_summary"] = formatFindings(outcome.Findings)
 			}
 			if outcome.Summary != "" && len(outcome.Findings) == 0 {
 				vars["prior_stage_summary"] = outcome.Summary
@@ -244,18 +378,94 @@ func (b *Builder) addCheckFailures(ps *pipeline.PipelineState, opts BuildOpts, v
 	}
 }
 
-// collectPriorStageSummaries builds a summary of all prior stage outcomes.
-func (b *Builder) collectPriorStageSummaries(ps *pipeline.PipelineState) string {
+// lastStageFindings returns the findings of the most recent completed stage.
+func (b *Builder) lastStageFindings(ps *pipeline.PipelineState) []map[string]interface{} {
+	if len(ps.StageHistory) == 0 {
+		return nil
+	}
+	last := ps.StageHistory[len(ps.StageHistory)-1]
+	outcome, err := b.store.GetStageOutcome(ps.Issue, last.Stage, last.Attempt)
+	if err != nil || outcome == nil {
+		return nil
+	}
+	return findingsData(outcome.Findings)
+}
+
+// addReviewFindings includes the findings of the most recent stage when it
+// failed, so a stage routed to by on_fail (e.g. implement after a review
+// panel) sees what it must address.
+func (b *Builder) addReviewFindings(ps *pipeline.PipelineState, vars prompt.Vars) {
 	if len(ps.StageHistory) == 0 {
-		return ""
+		return
+	}
+	last := ps.StageHistory[len(ps.StageHistory)-1]
+	if last.Outcome != "fail" {
+		return
+	}
+	outcome, err := b.store.GetStageOutcome(ps.Issue, last.Stage, last.Attempt)
+	if err != nil || outcome == nil || len(outcome.Findings) == 0 {
+		return
+	}
+	vars["review_findings"] = strings.TrimRight(formatFindings(outcome.Findings), "\n")
+}
+
+// formatFindings renders findings one per line, prefixed with the panel
+// reviewer that reported them, if any.
+func formatFindings(findings []pipeline.Finding) string {
+	var sb strings.Builder
+	for _, f := range findings {
+		sb.WriteString("- ")
+		if f.Reviewer != "" {
+			fmt.Fprintf(&sb, "[%s] ", f.Reviewer)
+		}
+		if f.File != "" {
+			fmt.Fprintf(&sb, "%s:%d ", f.File, f.Line)
+		}
+		fmt.Fprintf(&sb, "[%s] %s", f.Severity, f.Message)
+		if f.Rule != "" {
+			fmt.Fprintf(&sb, " (%s)", f.Rule)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// findingsData converts findings to template records.
+func findingsData(findings []pipeline.Finding) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(findings))
+	for i, f := range findings {
+		out[i] = map[string]interface{}{
+			"file":     f.File,
+			"line":     f.Line,
+			"severity": f.Severity,
+			"message":  f.Message,
+			"rule":     f.Rule,
+		}
+	}
+	return out
+}
+
+// collectPriorStageSummaries builds a summary of all prior stage outcomes,
+// as text and as template records.
+func (b *Builder) collectPriorStageSummaries(ps *pipeline.PipelineState) (string, []map[string]interface{}) {
+	if len(ps.StageHistory) == 0 {
+		return "", nil
 	}
 
 	var sb strings.Builder
+	var stages []map[string]interface{}
 	for _, entry := range ps.StageHistory {
 		outcome, err := b.store.GetStageOutcome(ps.Issue, entry.Stage, entry.Attempt)
 		if err != nil {
 			continue
 		}
+		stages = append(stages, map[string]interface{}{
+			"stage":         entry.Stage,
+			"attempt":       entry.Attempt,
+			"outcome":       entry.Outcome,
+			"summary":       outcome.Summary,
+			"files_changed": outcome.FilesChanged,
+		})
 		fmt.Fprintf(&sb, "### %s (attempt %d): %s\n", entry.Stage, entry.Attempt, entry.Outcome)
 		if outcome.Summary != "" {
 			fmt.Fprintf(&sb, "%s\n", outcome.Summary)
@@ -265,32 +475,38 @@ func (b *Builder) collectPriorStageSummaries(ps *pipeline.PipelineState) string
 		}
 		sb.WriteString("\n")
 	}
-	return strings.TrimSpace(sb.String())
+	return strings.TrimSpace(sb.String()), stages
 }
 
 // CheckpointOpts configures what to save in a checkpoint.
 type CheckpointOpts struct {
-	Status  string // "success", "fail", "escalate"
-	Summary string
+	Status   string // "success", "fail", "escalate"
+	Summary  string
+	Findings []pipeline.Finding
 }
 
 // Checkpoint saves a stage outcome for consumption by subsequent stages.
 func (b *Builder) Checkpoint(issue int, stage string, attempt int, opts CheckpointOpts) error {
 	outcome := &pipeline.StageOutcome{
-		Status:  opts.Status,
-		Summary: opts.Summary,
+		Status:   opts.Status,
+		Summary:  opts.Summary,
+		Findings: opts.Findings,
 	}
 
 	// Capture git state if available
 	if b.git != nil {
 		ps, err := b.store.Get(issue)
 		if err == nil {
-			if summary, err := b.git.DiffSummary(ps.Worktree); err == nil {
+			if summary, err := b.git.DiffSummary(ps.Worktree, ps.BaseRef()); err == nil {
 				outcome.DiffSummary = summary
 			}
-			if files, err := b.git.FilesChanged(ps.Worktree); err == nil && files != "" {
+			if files, err := b.git.FilesChanged(ps.Worktree, ps.BaseRef()); err == nil && files != "" {
 				outcome.FilesChanged = strings.Split(strings.TrimSpace(files), "\n")
 			}
+			// Keep the full patch alongside the outcome so attempts can be diffed later.
+			if diff, err := b.git.Diff(ps.Worktree, ps.BaseRef()); err == nil && diff != "" {
+				_ = b.store.SaveAttemptDiff(issue, stage, attempt, diff)
+			}
 		}
 	}
 
@@ -8,6 +8,7 @@ import (
 
 	"github.com/lucasnoah/taintfactory/internal/config"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/prompt"
 )
 
 // mockGit implements GitRunner for testing.
@@ -21,25 +22,25 @@ type mockGit struct {
 	filesChangedErr error
 }
 
-func (m *mockGit) Diff(dir string) (string, error) {
+func (m *mockGit) Diff(dir, base string) (string, error) {
 	return m.diff, m.diffErr
 }
 
-func (m *mockGit) DiffSummary(dir string) (string, error) {
+func (m *mockGit) DiffSummary(dir, base string) (string, error) {
 	if m.diffSummaryErr != nil {
 		return "", m.diffSummaryErr
 	}
 	return m.diffSummary, m.diffErr
 }
 
-func (m *mockGit) FilesChanged(dir string) (string, error) {
+func (m *mockGit) FilesChanged(dir, base string) (string, error) {
 	if m.filesChangedErr != nil {
 		return "", m.filesChangedErr
 	}
 	return m.filesChanged, m.diffErr
 }
 
-func (m *mockGit) Log(dir string) (string, error) {
+func (m *mockGit) Log(dir, base string) (string, error) {
 	return m.log, nil
 }
 
@@ -463,6 +464,14 @@ func TestCheckpoint(t *testing.T) {
 	if len(outcome.FilesChanged) != 3 {
 		t.Errorf("expected 3 files changed, got %d", len(outcome.FilesChanged))
 	}
+
+	patch, err := store.GetAttemptDiff(42, "implement", 1)
+	if err != nil {
+		t.Fatalf("get attempt diff: %v", err)
+	}
+	if patch != "+new code" {
+		t.Errorf("expected saved patch, got %q", patch)
+	}
 }
 
 func TestCheckpoint_NilGit(t *testing.T) {
@@ -592,3 +601,194 @@ func TestBuild_CustomVars(t *testing.T) {
 func containsStr(s, substr string) bool {
 	return strings.Contains(s, substr)
 }
+
+func TestBuild_StructuredData(t *testing.T) {
+	store := newTestStore(t)
+	ps := newTestPipeline(t, store)
+	ps.StageHistory = []pipeline.StageHistoryEntry{
+		{Stage: "review", Attempt: 1, Outcome: "fail"},
+	}
+	ps.DependentIssues = []pipeline.DependentIssue{{Issue: 7, FeatureIntent: "Reuse the login form"}}
+	_ = store.SaveStageOutcome(42, "review", 1, &pipeline.StageOutcome{
+		Status:   "fail",
+		Summary:  "Found issues",
+		Findings: []pipeline.Finding{{File: "src/auth.ts", Line: 42, Severity: "error", Message: "SQL injection"}},
+	})
+
+	builder := NewBuilder(store, &mockGit{filesChanged: "src/auth.ts\nsrc/login.ts\n"})
+	result, err := builder.Build(ps, BuildOpts{
+		Issue:    42,
+		Stage:    "implement",
+		StageCfg: &config.Stage{ID: "implement"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rendered, err := prompt.RenderWith(
+		"{{#range changed_files}}{{@number}}. {{.}}\n{{/range}}"+
+			"{{#range findings}}{{.file}}:{{.line}} {{.message}}\n{{/range}}"+
+			"{{#range prior_stages}}{{.stage}}={{.outcome}}\n{{/range}}"+
+			"{{#range dependents}}#{{.number}} {{.feature_intent}}{{/range}}",
+		result.Vars, prompt.RenderOpts{Data: result.Data})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "1. src/auth.ts\n2. src/login.ts\nsrc/auth.ts:42 SQL injection\nreview=fail\n#7 Reuse the login form"
+	if rendered != want {
+		t.Errorf("rendered = %q, want %q", rendered, want)
+	}
+}
+
+type fakeRetriever struct {
+	dir, query string
+	changed    []string
+	n          int
+}
+
+func (f *fakeRetriever) Relevant(dir, query string, changed []string, n int) (string, error) {
+	f.dir, f.query, f.changed, f.n = dir, query, changed, n
+	return "- `auth/session.go`", nil
+}
+
+func TestBuild_RelevantContext(t *testing.T) {
+	store := newTestStore(t)
+	ps := newTestPipeline(t, store)
+	r := &fakeRetriever{}
+	builder := NewBuilder(store, &mockGit{filesChanged: "auth/login.go\n"})
+	builder.SetRetriever(r)
+
+	result, err := builder.Build(ps, BuildOpts{
+		Issue: 42, Stage: "implement", IssueBody: "Login fails",
+		StageCfg: &config.Stage{ID: "implement", RelevantFiles: 5},
+	})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if result.Vars["relevant_context"] != "- `auth/session.go`" {
+		t.Errorf("relevant_context = %q", result.Vars["relevant_context"])
+	}
+	if r.dir != "/tmp/worktree" || r.n != 5 || !strings.Contains(r.query, "Add auth") || !strings.Contains(r.query, "Login fails") {
+		t.Errorf("retriever called with dir=%q n=%d query=%q", r.dir, r.n, r.query)
+	}
+	if len(r.changed) != 1 || r.changed[0] != "auth/login.go" {
+		t.Errorf("changed = %v", r.changed)
+	}
+
+	// Off unless relevant_files is set, and never in minimal mode.
+	for _, cfg := range []*config.Stage{
+		{ID: "implement"},
+		{ID: "implement", RelevantFiles: 5, ContextMode: "minimal"},
+	} {
+		result, err := builder.Build(ps, BuildOpts{Issue: 42, Stage: "implement", StageCfg: cfg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := result.Vars["relevant_context"]; ok {
+			t.Errorf("relevant_context set for %+v", cfg)
+		}
+	}
+}
+
+type fakeLessons struct {
+	namespace, stage string
+	checks           []string
+}
+
+func (f *fakeLessons) Lessons(namespace, query, stage string, checks []string) (string, error) {
+	f.namespace, f.stage, f.checks = namespace, stage, checks
+	return "- Run the linter before committing", nil
+}
+
+func TestBuild_Lessons(t *testing.T) {
+	store := newTestStore(t)
+	ps := newTestPipeline(t, store)
+	l := &fakeLessons{}
+	builder := NewBuilder(store, nil)
+	builder.SetLessons(l)
+
+	result, err := builder.Build(ps, BuildOpts{
+		Issue: 42, Stage: "implement",
+		StageCfg: &config.Stage{ID: "implement", ChecksAfter: []string{"lint", "test"}},
+	})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if result.Vars["lessons"] != "- Run the linter before committing" {
+		t.Errorf("lessons = %q", result.Vars["lessons"])
+	}
+	if l.stage != "implement" || strings.Join(l.checks, ",") != "lint,test" {
+		t.Errorf("lesson source called with stage=%q checks=%v", l.stage, l.checks)
+	}
+}
+
+func TestBuild_PromptVariant(t *testing.T) {
+	store := newTestStore(t)
+	ps := newTestPipeline(t, store)
+	builder := NewBuilder(store, nil)
+
+	stageCfg := &config.Stage{ID: "implement", PromptVariants: []config.PromptVariant{
+		{Template: "implement-a.md"},
+		{Name: "b", Template: "implement-b.md"},
+	}}
+	result, err := builder.Build(ps, BuildOpts{Issue: 42, Stage: "implement", StageCfg: stageCfg})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	switch {
+	case result.Variant == "implement-a" && result.Template == "implement-a.md":
+	case result.Variant == "b" && result.Template == "implement-b.md":
+	default:
+		t.Fatalf("variant = %q, template = %q", result.Variant, result.Template)
+	}
+
+	again, err := builder.Build(ps, BuildOpts{Issue: 42, Stage: "implement", StageCfg: stageCfg})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if again.Variant != result.Variant {
+		t.Errorf("variant changed between builds: %q then %q", result.Variant, again.Variant)
+	}
+}
+
+func TestBuild_ReviewFindings(t *testing.T) {
+	store := newTestStore(t)
+	ps := newTestPipeline(t, store)
+
+	ps.StageHistory = []pipeline.StageHistoryEntry{
+		{Stage: "implement", Attempt: 1, Outcome: "success"},
+		{Stage: "review", Attempt: 1, Outcome: "fail"},
+	}
+	_ = store.SaveStageOutcome(42, "review", 1, &pipeline.StageOutcome{
+		Status:  "fail",
+		Summary: "Review panel (any_blocker): changes requested",
+		Findings: []pipeline.Finding{
+			{File: "src/auth.ts", Line: 42, Severity: "blocker", Message: "Token is logged", Reviewer: "security"},
+		},
+	})
+
+	builder := NewBuilder(store, nil)
+	result, err := builder.Build(ps, BuildOpts{
+		Issue: 42, Stage: "implement",
+		StageCfg: &config.Stage{ID: "implement"},
+	})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if want := "- [security] src/auth.ts:42 [blocker] Token is logged"; result.Vars["review_findings"] != want {
+		t.Errorf("review_findings = %q, want %q", result.Vars["review_findings"], want)
+	}
+
+	// A passing stage's findings are not fed forward.
+	ps.StageHistory[1].Outcome = "success"
+	result, err = builder.Build(ps, BuildOpts{
+		Issue: 42, Stage: "merge",
+		StageCfg: &config.Stage{ID: "merge"},
+	})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, ok := result.Vars["review_findings"]; ok {
+		t.Error("review_findings set after a passing stage")
+	}
+}
@@ -6,51 +6,60 @@ import (
 )
 
 // ExecGit implements GitRunner by calling git commands.
-// Diffs are computed against the merge-base with main, so they show
-// what the feature branch changed rather than uncommitted edits.
+// Diffs are computed against the merge-base with the pipeline's base ref
+// (e.g. origin/develop), so they show what the feature branch changed rather
+// than uncommitted edits. When base is empty or unknown, local main and then
+// master are tried.
 type ExecGit struct{}
 
-func (g *ExecGit) Diff(dir string) (string, error) {
-	base, err := mergeBase(dir)
+func (g *ExecGit) Diff(dir, base string) (string, error) {
+	mb, err := mergeBase(dir, base)
 	if err != nil {
-		// Fall back to HEAD diff if merge-base fails (e.g. no main branch)
+		// Fall back to HEAD diff if merge-base fails (e.g. no base branch)
 		return runGit(dir, "diff", "HEAD")
 	}
-	return runGit(dir, "diff", base+"...HEAD")
+	return runGit(dir, "diff", mb+"...HEAD")
 }
 
-func (g *ExecGit) DiffSummary(dir string) (string, error) {
-	base, err := mergeBase(dir)
+func (g *ExecGit) DiffSummary(dir, base string) (string, error) {
+	mb, err := mergeBase(dir, base)
 	if err != nil {
 		return runGit(dir, "diff", "--stat", "HEAD")
 	}
-	return runGit(dir, "diff", "--stat", base+"...HEAD")
+	return runGit(dir, "diff", "--stat", mb+"...HEAD")
 }
 
-func (g *ExecGit) FilesChanged(dir string) (string, error) {
-	base, err := mergeBase(dir)
+func (g *ExecGit) FilesChanged(dir, base string) (string, error) {
+	mb, err := mergeBase(dir, base)
 	if err != nil {
 		return runGit(dir, "diff", "--name-only", "HEAD")
 	}
-	return runGit(dir, "diff", "--name-only", base+"...HEAD")
+	return runGit(dir, "diff", "--name-only", mb+"...HEAD")
 }
 
-func (g *ExecGit) Log(dir string) (string, error) {
-	base, err := mergeBase(dir)
+func (g *ExecGit) Log(dir, base string) (string, error) {
+	mb, err := mergeBase(dir, base)
 	if err != nil {
 		return runGit(dir, "log", "--oneline", "-20")
 	}
-	return runGit(dir, "log", "--oneline", base+"..HEAD")
+	return runGit(dir, "log", "--oneline", mb+"..HEAD")
 }
 
-// mergeBase finds the common ancestor between HEAD and main/master.
-func mergeBase(dir string) (string, error) {
-	// Try main first, then master
-	base, err := runGit(dir, "merge-base", "main", "HEAD")
-	if err != nil {
-		base, err = runGit(dir, "merge-base", "master", "HEAD")
+// mergeBase finds the common ancestor between HEAD and base, falling back
+// to main/master.
+func mergeBase(dir, base string) (string, error) {
+	var refs []string
+	if base != "" {
+		refs = append(refs, base)
+	}
+	var mb string
+	var err error
+	for _, ref := range append(refs, "main", "master") {
+		if mb, err = runGit(dir, "merge-base", ref, "HEAD"); err == nil {
+			return mb, nil
+		}
 	}
-	return base, err
+	return mb, err
 }
 
 func runGit(dir string, args ...string) (string, error) {
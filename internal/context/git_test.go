@@ -0,0 +1,47 @@
+package context
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecGit_UsesBaseRef(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q", "-b", "develop")
+	write("base.txt")
+	git("add", ".")
+	git("commit", "-qm", "base")
+	git("checkout", "-qb", "feature")
+	write("feature.txt")
+	git("add", ".")
+	git("commit", "-qm", "feature")
+
+	g := &ExecGit{}
+	files, err := g.FilesChanged(dir, "develop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != "feature.txt" {
+		t.Errorf("FilesChanged against develop = %q, want feature.txt", files)
+	}
+	// Without a main or master branch and no base, only uncommitted edits show.
+	if files, _ := g.FilesChanged(dir, ""); files != "" {
+		t.Errorf("FilesChanged without a base = %q, want empty", files)
+	}
+}
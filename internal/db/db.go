@@ -81,6 +81,8 @@ CREATE TABLE IF NOT EXISTS check_runs (
     findings    TEXT,
     timestamp   TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
+ALTER TABLE check_runs ADD COLUMN IF NOT EXISTS peak_memory_bytes BIGINT;
+ALTER TABLE check_runs ADD COLUMN IF NOT EXISTS cpu_ms INTEGER;
 CREATE INDEX IF NOT EXISTS idx_check_issue_stage ON check_runs(issue, stage, fix_round);
 CREATE INDEX IF NOT EXISTS idx_check_ns_issue ON check_runs(namespace, issue, stage, fix_round);
 
@@ -107,11 +109,13 @@ CREATE TABLE IF NOT EXISTS issue_queue (
     feature_intent TEXT NOT NULL DEFAULT '',
     depends_on     JSONB NOT NULL DEFAULT '[]',
     config_path    TEXT NOT NULL DEFAULT '',
+    priority       INTEGER NOT NULL DEFAULT 0,
     added_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     started_at     TIMESTAMPTZ,
     finished_at    TIMESTAMPTZ,
     UNIQUE(namespace, issue)
 );
+ALTER TABLE issue_queue ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
 CREATE INDEX IF NOT EXISTS idx_queue_status_position ON issue_queue(status, position);
 CREATE INDEX IF NOT EXISTS idx_queue_ns_issue ON issue_queue(namespace, issue);
 
@@ -127,6 +131,8 @@ CREATE TABLE IF NOT EXISTS repos (
     added_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 CREATE INDEX IF NOT EXISTS idx_repos_active ON repos(active);
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS base_branch TEXT;
+ALTER TABLE repos ADD COLUMN IF NOT EXISTS remote TEXT;
 
 CREATE TABLE IF NOT EXISTS deploys (
     id             SERIAL PRIMARY KEY,
@@ -153,6 +159,43 @@ CREATE TABLE IF NOT EXISTS deploy_events (
     timestamp   TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 CREATE INDEX IF NOT EXISTS idx_deploy_events_sha ON deploy_events(commit_sha, timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS notification_dead_letters (
+    id          SERIAL PRIMARY KEY,
+    sink        TEXT NOT NULL,
+    event_id    INTEGER NOT NULL,
+    namespace   TEXT NOT NULL DEFAULT '',
+    issue       INTEGER NOT NULL,
+    event       TEXT NOT NULL,
+    payload     TEXT NOT NULL,
+    error       TEXT NOT NULL,
+    attempts    INTEGER NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_notification_dead_letters_created ON notification_dead_letters(created_at DESC);
+
+CREATE TABLE IF NOT EXISTS lessons (
+    id          SERIAL PRIMARY KEY,
+    namespace   TEXT NOT NULL DEFAULT '',
+    source      TEXT NOT NULL CHECK(source IN ('manual','check','review','steer')),
+    key         TEXT NOT NULL,
+    check_name  TEXT NOT NULL DEFAULT '',
+    stage       TEXT NOT NULL DEFAULT '',
+    text        TEXT NOT NULL,
+    hits        INTEGER NOT NULL DEFAULT 1,
+    last_issue  INTEGER NOT NULL DEFAULT 0,
+    pinned      BOOLEAN NOT NULL DEFAULT false,
+    dismissed   BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(namespace, key)
+);
+
+CREATE TABLE IF NOT EXISTS lesson_issues (
+    lesson_id   INTEGER NOT NULL REFERENCES lessons(id) ON DELETE CASCADE,
+    issue       INTEGER NOT NULL,
+    PRIMARY KEY (lesson_id, issue)
+);
 `
 
 // Migrate applies the database schema.
@@ -168,7 +211,7 @@ func (d *DB) Migrate() error {
 
 // Reset drops all tables and re-applies the schema.
 func (d *DB) Reset() error {
-	tables := []string{"deploy_events", "deploys", "issue_queue", "pipeline_events", "check_runs", "session_events", "repos", "schema_version"}
+	tables := []string{"lesson_issues", "lessons", "notification_dead_letters", "deploy_events", "deploys", "issue_queue", "pipeline_events", "check_runs", "session_events", "repos", "schema_version"}
 	for _, t := range tables {
 		if _, err := d.conn.Exec("DROP TABLE IF EXISTS " + t + " CASCADE"); err != nil {
 			return fmt.Errorf("drop table %s: %w", t, err)
@@ -192,10 +192,30 @@ func (d *DB) DetectHumanIntervention(sessionID string) (bool, error) {
 
 // LogCheckRun inserts a check run record.
 func (d *DB) LogCheckRun(namespace string, issue int, stage string, attempt int, fixRound int, checkName string, passed bool, autoFixed bool, exitCode int, durationMs int, summary string, findings string) error {
+	return d.LogCheckRunUsage(namespace, issue, stage, attempt, fixRound, checkName, passed, autoFixed, exitCode, durationMs, summary, findings, CheckUsage{})
+}
+
+// CheckUsage is the memory and CPU a check run consumed. Zero values are
+// stored as NULL: the runner did not report them.
+type CheckUsage struct {
+	PeakMemoryBytes int64
+	CPUMs           int
+}
+
+// LogCheckRunUsage is LogCheckRun with the resources the check consumed.
+func (d *DB) LogCheckRunUsage(namespace string, issue int, stage string, attempt int, fixRound int, checkName string, passed bool, autoFixed bool, exitCode int, durationMs int, summary string, findings string, usage CheckUsage) error {
+	var peak, cpu *int64
+	if usage.PeakMemoryBytes > 0 {
+		peak = &usage.PeakMemoryBytes
+	}
+	if usage.CPUMs > 0 {
+		ms := int64(usage.CPUMs)
+		cpu = &ms
+	}
 	_, err := d.conn.Exec(
-		`INSERT INTO check_runs (namespace, issue, stage, attempt, fix_round, check_name, passed, auto_fixed, exit_code, duration_ms, summary, findings)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
-		namespace, issue, stage, attempt, fixRound, checkName, passed, autoFixed, exitCode, durationMs, summary, findings,
+		`INSERT INTO check_runs (namespace, issue, stage, attempt, fix_round, check_name, passed, auto_fixed, exit_code, duration_ms, summary, findings, peak_memory_bytes, cpu_ms)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		namespace, issue, stage, attempt, fixRound, checkName, passed, autoFixed, exitCode, durationMs, summary, findings, peak, cpu,
 	)
 	if err != nil {
 		return fmt.Errorf("log check run: %w", err)
@@ -240,6 +260,44 @@ func (d *DB) GetCheckRuns(namespace string, issue int, stage string, fixRound in
 	return runs, rows.Err()
 }
 
+// GetAttemptCheckRuns returns every check run recorded for one stage attempt,
+// across all fix rounds, ordered by fix round.
+func (d *DB) GetAttemptCheckRuns(namespace string, issue int, stage string, attempt int) ([]CheckRun, error) {
+	rows, err := d.conn.Query(
+		`SELECT id, namespace, issue, stage, attempt, fix_round, check_name, passed, auto_fixed, exit_code, duration_ms, summary, findings, timestamp
+		 FROM check_runs WHERE namespace = $1 AND issue = $2 AND stage = $3 AND attempt = $4 ORDER BY fix_round, id`,
+		namespace, issue, stage, attempt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get attempt check runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []CheckRun
+	for rows.Next() {
+		var r CheckRun
+		var exitCode, durationMs sql.NullInt64
+		var summary, findings sql.NullString
+		if err := rows.Scan(&r.ID, &r.Namespace, &r.Issue, &r.Stage, &r.Attempt, &r.FixRound, &r.CheckName, &r.Passed, &r.AutoFixed, &exitCode, &durationMs, &summary, &findings, &r.Timestamp); err != nil {
+			return nil, fmt.Errorf("scan check run: %w", err)
+		}
+		if exitCode.Valid {
+			r.ExitCode = int(exitCode.Int64)
+		}
+		if durationMs.Valid {
+			r.DurationMs = int(durationMs.Int64)
+		}
+		if summary.Valid {
+			r.Summary = summary.String
+		}
+		if findings.Valid {
+			r.Findings = findings.String
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
 // GetLatestCheckRun returns the most recent check run for a namespace, issue, and check name.
 func (d *DB) GetLatestCheckRun(namespace string, issue int, checkName string) (*CheckRun, error) {
 	row := d.conn.QueryRow(
@@ -374,6 +432,7 @@ type QueueItem struct {
 	FeatureIntent string
 	ConfigPath    string // abs path to pipeline.yaml; empty for legacy items
 	DependsOn     []int  // issue numbers that must be completed first
+	Priority      int    // higher priorities are dequeued first; ties fall back to position
 	AddedAt       string
 	StartedAt     string
 	FinishedAt    string
@@ -386,6 +445,7 @@ type QueueAddItem struct {
 	FeatureIntent string
 	ConfigPath    string // abs path to pipeline.yaml; empty if not specified
 	DependsOn     []int  // issue numbers that must be completed first
+	Priority      int    // higher priorities are dequeued first; 0 is the default
 }
 
 // QueueAdd inserts issues into the queue with sequential positions.
@@ -415,8 +475,8 @@ func (d *DB) QueueAdd(items []QueueAddItem) error {
 			depsJSON = string(b)
 		}
 		if _, err := tx.Exec(
-			`INSERT INTO issue_queue (namespace, issue, position, feature_intent, depends_on, config_path) VALUES ($1, $2, $3, $4, $5::jsonb, $6)`,
-			item.Namespace, item.Issue, nextPos, item.FeatureIntent, depsJSON, item.ConfigPath,
+			`INSERT INTO issue_queue (namespace, issue, position, feature_intent, depends_on, config_path, priority) VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7)`,
+			item.Namespace, item.Issue, nextPos, item.FeatureIntent, depsJSON, item.ConfigPath, item.Priority,
 		); err != nil {
 			if strings.Contains(err.Error(), "duplicate key") {
 				return fmt.Errorf("issue %d is already in the queue", item.Issue)
@@ -445,11 +505,11 @@ func (d *DB) QueueSetIntent(namespace string, issue int, intent string) error {
 	return nil
 }
 
-// QueueList returns all queue items ordered by position.
+// QueueList returns all queue items ordered by priority (highest first), then position.
 func (d *DB) QueueList() ([]QueueItem, error) {
 	rows, err := d.conn.Query(
-		`SELECT id, namespace, issue, status, position, feature_intent, depends_on, config_path, added_at, started_at, finished_at
-		 FROM issue_queue ORDER BY position`)
+		`SELECT id, namespace, issue, status, position, feature_intent, depends_on, config_path, priority, added_at, started_at, finished_at
+		 FROM issue_queue ORDER BY priority DESC, position`)
 	if err != nil {
 		return nil, fmt.Errorf("list queue: %w", err)
 	}
@@ -460,7 +520,7 @@ func (d *DB) QueueList() ([]QueueItem, error) {
 		var item QueueItem
 		var startedAt, finishedAt sql.NullString
 		var dependsOnJSON string
-		if err := rows.Scan(&item.ID, &item.Namespace, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.AddedAt, &startedAt, &finishedAt); err != nil {
+		if err := rows.Scan(&item.ID, &item.Namespace, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.Priority, &item.AddedAt, &startedAt, &finishedAt); err != nil {
 			return nil, fmt.Errorf("scan queue item: %w", err)
 		}
 		if startedAt.Valid {
@@ -479,13 +539,13 @@ func (d *DB) QueueList() ([]QueueItem, error) {
 	return items, rows.Err()
 }
 
-// QueueNext returns the next pending item (lowest position) whose dependencies are
+// QueueNext returns the next pending item (highest priority, then lowest position) whose dependencies are
 // all completed, or nil if none. A dependency issue missing from the queue entirely
 // is treated as satisfied.
 func (d *DB) QueueNext() (*QueueItem, error) {
 	row := d.conn.QueryRow(`
 		SELECT q.id, q.namespace, q.issue, q.status, q.position, q.feature_intent, q.depends_on,
-		       q.config_path, q.added_at, q.started_at, q.finished_at
+		       q.config_path, q.priority, q.added_at, q.started_at, q.finished_at
 		FROM issue_queue q
 		WHERE q.status = 'pending'
 		AND NOT EXISTS (
@@ -494,12 +554,12 @@ func (d *DB) QueueNext() (*QueueItem, error) {
 		    WHERE dep.namespace = q.namespace
 		      AND dep.status != 'completed'
 		)
-		ORDER BY q.position ASC LIMIT 1`)
+		ORDER BY q.priority DESC, q.position ASC LIMIT 1`)
 
 	var item QueueItem
 	var startedAt, finishedAt sql.NullString
 	var dependsOnJSON string
-	err := row.Scan(&item.ID, &item.Namespace, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.AddedAt, &startedAt, &finishedAt)
+	err := row.Scan(&item.ID, &item.Namespace, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.Priority, &item.AddedAt, &startedAt, &finishedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -576,7 +636,7 @@ func (d *DB) QueueRemove(namespace string, issue int) error {
 func (d *DB) QueueDependents(namespace string, issue int) ([]QueueItem, error) {
 	rows, err := d.conn.Query(`
 		SELECT id, namespace, issue, status, position, feature_intent, depends_on,
-		       config_path, added_at, started_at, finished_at
+		       config_path, priority, added_at, started_at, finished_at
 		FROM issue_queue
 		WHERE namespace = $1
 		AND status IN ('pending', 'active')
@@ -597,7 +657,7 @@ func (d *DB) QueueDependents(namespace string, issue int) ([]QueueItem, error) {
 		var item QueueItem
 		var startedAt, finishedAt sql.NullString
 		var dependsOnJSON string
-		if err := rows.Scan(&item.ID, &item.Namespace, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.AddedAt, &startedAt, &finishedAt); err != nil {
+		if err := rows.Scan(&item.ID, &item.Namespace, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.Priority, &item.AddedAt, &startedAt, &finishedAt); err != nil {
 			return nil, fmt.Errorf("scan queue item: %w", err)
 		}
 		if startedAt.Valid {
@@ -634,7 +694,7 @@ func (d *DB) QueueClear() (int, error) {
 // cursor-based incremental reads.
 func (d *DB) GetPipelineEventsSince(lastID int, limit int) ([]PipelineEvent, error) {
 	rows, err := d.conn.Query(
-		`SELECT id, issue, event, stage, attempt, detail, timestamp
+		`SELECT id, namespace, issue, event, stage, attempt, detail, timestamp
 		 FROM pipeline_events WHERE id > $1 ORDER BY id ASC LIMIT $2`,
 		lastID, limit,
 	)
@@ -648,7 +708,7 @@ func (d *DB) GetPipelineEventsSince(lastID int, limit int) ([]PipelineEvent, err
 		var e PipelineEvent
 		var stage, detail sql.NullString
 		var attempt sql.NullInt64
-		if err := rows.Scan(&e.ID, &e.Issue, &e.Event, &stage, &attempt, &detail, &e.Timestamp); err != nil {
+		if err := rows.Scan(&e.ID, &e.Namespace, &e.Issue, &e.Event, &stage, &attempt, &detail, &e.Timestamp); err != nil {
 			return nil, fmt.Errorf("scan pipeline event: %w", err)
 		}
 		if stage.Valid {
@@ -668,14 +728,14 @@ func (d *DB) GetPipelineEventsSince(lastID int, limit int) ([]PipelineEvent, err
 // GetQueueItem returns the queue item for the given issue number, or nil if not found.
 func (d *DB) GetQueueItem(issue int) (*QueueItem, error) {
 	row := d.conn.QueryRow(
-		`SELECT id, issue, status, position, feature_intent, depends_on, config_path, added_at, started_at, finished_at
+		`SELECT id, namespace, issue, status, position, feature_intent, depends_on, config_path, priority, added_at, started_at, finished_at
 		 FROM issue_queue WHERE issue = $1`,
 		issue,
 	)
 	var item QueueItem
 	var startedAt, finishedAt sql.NullString
 	var dependsOnJSON string
-	err := row.Scan(&item.ID, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.AddedAt, &startedAt, &finishedAt)
+	err := row.Scan(&item.ID, &item.Namespace, &item.Issue, &item.Status, &item.Position, &item.FeatureIntent, &dependsOnJSON, &item.ConfigPath, &item.Priority, &item.AddedAt, &startedAt, &finishedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -748,6 +808,8 @@ type RepoRecord struct {
 	PollInterval int
 	Active       bool
 	AddedAt      string
+	BaseBranch   string // overrides pipeline.base_branch; "" = use the config
+	Remote       string // overrides pipeline.remote; "" = use the config
 }
 
 // RepoUpdateOpts holds optional fields for updating a repo. Nil means "don't change".
@@ -755,14 +817,16 @@ type RepoUpdateOpts struct {
 	PollLabel    *string
 	PollInterval *int
 	Active       *bool
+	BaseBranch   *string // "" clears the override
+	Remote       *string // "" clears the override
 }
 
 // RepoAdd inserts a new repo into the registry.
 func (d *DB) RepoAdd(r RepoRecord) error {
 	_, err := d.conn.Exec(
-		`INSERT INTO repos (namespace, repo_url, local_path, config_path, poll_label, poll_interval, active)
-		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`,
-		r.Namespace, r.RepoURL, r.LocalPath, r.ConfigPath, r.PollLabel, r.PollInterval, r.Active,
+		`INSERT INTO repos (namespace, repo_url, local_path, config_path, poll_label, poll_interval, active, base_branch, remote)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, NULLIF($8, ''), NULLIF($9, ''))`,
+		r.Namespace, r.RepoURL, r.LocalPath, r.ConfigPath, r.PollLabel, r.PollInterval, r.Active, r.BaseBranch, r.Remote,
 	)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint") {
@@ -776,7 +840,8 @@ func (d *DB) RepoAdd(r RepoRecord) error {
 // RepoList returns all registered repos.
 func (d *DB) RepoList() ([]RepoRecord, error) {
 	rows, err := d.conn.Query(
-		`SELECT id, namespace, repo_url, local_path, config_path, COALESCE(poll_label, ''), poll_interval, active, added_at
+		`SELECT id, namespace, repo_url, local_path, config_path, COALESCE(poll_label, ''), poll_interval, active, added_at,
+		        COALESCE(base_branch, ''), COALESCE(remote, '')
 		 FROM repos ORDER BY namespace`)
 	if err != nil {
 		return nil, fmt.Errorf("list repos: %w", err)
@@ -786,7 +851,7 @@ func (d *DB) RepoList() ([]RepoRecord, error) {
 	var repos []RepoRecord
 	for rows.Next() {
 		var r RepoRecord
-		if err := rows.Scan(&r.ID, &r.Namespace, &r.RepoURL, &r.LocalPath, &r.ConfigPath, &r.PollLabel, &r.PollInterval, &r.Active, &r.AddedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.Namespace, &r.RepoURL, &r.LocalPath, &r.ConfigPath, &r.PollLabel, &r.PollInterval, &r.Active, &r.AddedAt, &r.BaseBranch, &r.Remote); err != nil {
 			return nil, fmt.Errorf("scan repo: %w", err)
 		}
 		repos = append(repos, r)
@@ -828,6 +893,16 @@ func (d *DB) RepoUpdate(namespace string, opts RepoUpdateOpts) error {
 		args = append(args, *opts.Active)
 		i++
 	}
+	if opts.BaseBranch != nil {
+		sets = append(sets, fmt.Sprintf("base_branch = NULLIF($%d, '')", i))
+		args = append(args, *opts.BaseBranch)
+		i++
+	}
+	if opts.Remote != nil {
+		sets = append(sets, fmt.Sprintf("remote = NULLIF($%d, '')", i))
+		args = append(args, *opts.Remote)
+		i++
+	}
 
 	if len(sets) == 0 {
 		return nil
@@ -850,7 +925,8 @@ func (d *DB) RepoUpdate(namespace string, opts RepoUpdateOpts) error {
 // RepoGetPollable returns repos that are active and have a poll_label set.
 func (d *DB) RepoGetPollable() ([]RepoRecord, error) {
 	rows, err := d.conn.Query(
-		`SELECT id, namespace, repo_url, local_path, config_path, poll_label, poll_interval, active, added_at
+		`SELECT id, namespace, repo_url, local_path, config_path, poll_label, poll_interval, active, added_at,
+		        COALESCE(base_branch, ''), COALESCE(remote, '')
 		 FROM repos WHERE active = true AND poll_label IS NOT NULL ORDER BY namespace`)
 	if err != nil {
 		return nil, fmt.Errorf("get pollable repos: %w", err)
@@ -860,7 +936,7 @@ func (d *DB) RepoGetPollable() ([]RepoRecord, error) {
 	var repos []RepoRecord
 	for rows.Next() {
 		var r RepoRecord
-		if err := rows.Scan(&r.ID, &r.Namespace, &r.RepoURL, &r.LocalPath, &r.ConfigPath, &r.PollLabel, &r.PollInterval, &r.Active, &r.AddedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.Namespace, &r.RepoURL, &r.LocalPath, &r.ConfigPath, &r.PollLabel, &r.PollInterval, &r.Active, &r.AddedAt, &r.BaseBranch, &r.Remote); err != nil {
 			return nil, fmt.Errorf("scan repo: %w", err)
 		}
 		repos = append(repos, r)
@@ -873,9 +949,10 @@ func (d *DB) RepoGetByNamespace(namespace string) (*RepoRecord, error) {
 	var r RepoRecord
 	err := d.conn.QueryRow(
 		`SELECT id, namespace, repo_url, local_path, config_path,
-		        COALESCE(poll_label, ''), poll_interval, active, added_at
+		        COALESCE(poll_label, ''), poll_interval, active, added_at,
+		        COALESCE(base_branch, ''), COALESCE(remote, '')
 		 FROM repos WHERE namespace = $1`, namespace,
-	).Scan(&r.ID, &r.Namespace, &r.RepoURL, &r.LocalPath, &r.ConfigPath, &r.PollLabel, &r.PollInterval, &r.Active, &r.AddedAt)
+	).Scan(&r.ID, &r.Namespace, &r.RepoURL, &r.LocalPath, &r.ConfigPath, &r.PollLabel, &r.PollInterval, &r.Active, &r.AddedAt, &r.BaseBranch, &r.Remote)
 	if err != nil {
 		return nil, fmt.Errorf("get repo %s: %w", namespace, err)
 	}
@@ -973,3 +1050,205 @@ func (d *DB) LogDeployEvent(commitSHA, namespace, event, stage string, attempt i
 	}
 	return nil
 }
+
+// ---------------------------------------------------------------------------
+// Notification dead letters
+// ---------------------------------------------------------------------------
+
+// NotificationDeadLetter is a pipeline event that a notification sink failed
+// to deliver after exhausting its retries.
+type NotificationDeadLetter struct {
+	ID        int
+	Sink      string
+	EventID   int
+	Namespace string
+	Issue     int
+	Event     string
+	Payload   string
+	Error     string
+	Attempts  int
+	CreatedAt string
+}
+
+// NotificationDeadLetterAdd records an undeliverable notification.
+func (d *DB) NotificationDeadLetterAdd(sink string, eventID int, namespace string, issue int, event string, payload string, lastError string, attempts int) error {
+	_, err := d.conn.Exec(
+		`INSERT INTO notification_dead_letters (sink, event_id, namespace, issue, event, payload, error, attempts)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		sink, eventID, namespace, issue, event, payload, lastError, attempts,
+	)
+	if err != nil {
+		return fmt.Errorf("add notification dead letter: %w", err)
+	}
+	return nil
+}
+
+// NotificationDeadLetterList returns recent dead letters, newest first.
+func (d *DB) NotificationDeadLetterList(limit int) ([]NotificationDeadLetter, error) {
+	rows, err := d.conn.Query(
+		`SELECT id, sink, event_id, namespace, issue, event, payload, error, attempts, created_at
+		 FROM notification_dead_letters ORDER BY id DESC LIMIT $1`, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list notification dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []NotificationDeadLetter
+	for rows.Next() {
+		var l NotificationDeadLetter
+		if err := rows.Scan(&l.ID, &l.Sink, &l.EventID, &l.Namespace, &l.Issue, &l.Event, &l.Payload, &l.Error, &l.Attempts, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan notification dead letter: %w", err)
+		}
+		letters = append(letters, l)
+	}
+	return letters, rows.Err()
+}
+
+// ---------------------------------------------------------------------------
+// Lessons
+// ---------------------------------------------------------------------------
+
+// LessonRecord represents a row in the lessons table. Harvested lessons
+// (source check, review, steer) start as candidates and count the distinct
+// issues they were seen in; manual and pinned lessons are always in effect.
+type LessonRecord struct {
+	ID        int
+	Namespace string
+	Source    string // manual, check, review, steer
+	Key       string // dedupe key, e.g. check:lint:no-unused-vars
+	CheckName string // scopes the lesson to stages running this check
+	Stage     string // scopes the lesson to this stage
+	Text      string
+	Hits      int
+	LastIssue int
+	Pinned    bool
+	Dismissed bool
+	CreatedAt string
+	UpdatedAt string
+}
+
+// LessonObserve records that a harvested lesson was seen in issue. Each
+// issue is recorded once in lesson_issues and hits is the number of distinct
+// issues there, so repeat or interleaved observations from parallel pipelines
+// do not inflate it. The text of an existing lesson is left as first recorded.
+func (d *DB) LessonObserve(l LessonRecord, issue int) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The upsert locks the lesson row, so concurrent observations of the
+	// same lesson count after one another.
+	var id int
+	err = tx.QueryRow(
+		`INSERT INTO lessons (namespace, source, key, check_name, stage, text, hits, last_issue)
+		 VALUES ($1, $2, $3, $4, $5, $6, 0, $7)
+		 ON CONFLICT (namespace, key) DO UPDATE SET
+		     last_issue = EXCLUDED.last_issue,
+		     updated_at = NOW()
+		 RETURNING id`,
+		l.Namespace, l.Source, l.Key, l.CheckName, l.Stage, l.Text, issue,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("observe lesson: %w", err)
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO lesson_issues (lesson_id, issue) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		id, issue,
+	); err != nil {
+		return fmt.Errorf("record lesson issue: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE lessons SET hits = (SELECT COUNT(DISTINCT issue) FROM lesson_issues WHERE lesson_id = $1)
+		 WHERE id = $1`,
+		id,
+	); err != nil {
+		return fmt.Errorf("count lesson issues: %w", err)
+	}
+	return tx.Commit()
+}
+
+// LessonAdd inserts a manual lesson and returns its ID.
+func (d *DB) LessonAdd(l LessonRecord) (int, error) {
+	var id int
+	err := d.conn.QueryRow(
+		`INSERT INTO lessons (namespace, source, key, check_name, stage, text, pinned)
+		 VALUES ($1, 'manual', 'manual:' || md5($2 || clock_timestamp()::text), $3, $4, $2, true)
+		 RETURNING id`,
+		l.Namespace, l.Text, l.CheckName, l.Stage,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("add lesson: %w", err)
+	}
+	return id, nil
+}
+
+// LessonList returns the lessons of a namespace, most observed first.
+// Dismissed lessons are included only when includeDismissed is set.
+func (d *DB) LessonList(namespace string, includeDismissed bool) ([]LessonRecord, error) {
+	rows, err := d.conn.Query(
+		`SELECT id, namespace, source, key, check_name, stage, text, hits, last_issue, pinned, dismissed, created_at, updated_at
+		 FROM lessons WHERE namespace = $1 AND (dismissed = false OR $2)
+		 ORDER BY pinned DESC, hits DESC, id`,
+		namespace, includeDismissed,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list lessons: %w", err)
+	}
+	defer rows.Close()
+
+	var lessons []LessonRecord
+	for rows.Next() {
+		var l LessonRecord
+		if err := rows.Scan(&l.ID, &l.Namespace, &l.Source, &l.Key, &l.CheckName, &l.Stage, &l.Text, &l.Hits, &l.LastIssue, &l.Pinned, &l.Dismissed, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan lesson: %w", err)
+		}
+		lessons = append(lessons, l)
+	}
+	return lessons, rows.Err()
+}
+
+// LessonSetFlags pins or dismisses a lesson. Dismissed lessons keep their
+// row so that harvesting does not bring them back. Nil means "don't change".
+func (d *DB) LessonSetFlags(namespace string, id int, pinned, dismissed *bool) error {
+	result, err := d.conn.Exec(
+		`UPDATE lessons SET pinned = COALESCE($3, pinned), dismissed = COALESCE($4, dismissed), updated_at = NOW()
+		 WHERE namespace = $1 AND id = $2`,
+		namespace, id, pinned, dismissed,
+	)
+	if err != nil {
+		return fmt.Errorf("update lesson: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("lesson %d not found", id)
+	}
+	return nil
+}
+
+// GetSteerMessages returns the messages humans sent to a session with
+// `factory session steer`, oldest first.
+func (d *DB) GetSteerMessages(sessionID string) ([]string, error) {
+	rows, err := d.conn.Query(
+		`SELECT metadata FROM session_events
+		 WHERE session_id = $1 AND event = 'steer' AND COALESCE(metadata, '') <> ''
+		 ORDER BY id`,
+		sessionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get steer messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []string
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return nil, fmt.Errorf("scan steer message: %w", err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
@@ -0,0 +1,171 @@
+package dbprov
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+// buildCloneSQL returns the statement that creates issue's database from the template.
+func buildCloneSQL(cfg *config.DatabaseConfig, issue int) string {
+	return buildCloneAsSQL(cfg, cfg.PipelineName(issue))
+}
+
+// buildCloneAsSQL returns the statement that creates name from the template.
+func buildCloneAsSQL(cfg *config.DatabaseConfig, name string) string {
+	return fmt.Sprintf(`CREATE DATABASE "%s" TEMPLATE "%s" OWNER "%s"`,
+		name, cfg.TemplateName(), cfg.User)
+}
+
+// buildDropSQL returns the statement that drops name, closing open connections.
+func buildDropSQL(name string) string {
+	return fmt.Sprintf(`DROP DATABASE IF EXISTS "%s" WITH (FORCE)`, name)
+}
+
+// clone creates the per-pipeline database. Treats duplicate_database (42P04) as success.
+func clone(adminConn *sql.DB, cfg *config.DatabaseConfig, issue int) error {
+	if _, err := adminConn.Exec(buildCloneSQL(cfg, issue)); err != nil {
+		if isAlreadyExists(err) {
+			return nil
+		}
+		return cloneError(cfg, err)
+	}
+	return nil
+}
+
+// cloneScratch drops any leftover database called name and clones the
+// template into it.
+func cloneScratch(adminConn *sql.DB, cfg *config.DatabaseConfig, name string) error {
+	if err := drop(adminConn, name); err != nil {
+		return err
+	}
+	if _, err := adminConn.Exec(buildCloneAsSQL(cfg, name)); err != nil {
+		return cloneError(cfg, err)
+	}
+	return nil
+}
+
+func cloneError(cfg *config.DatabaseConfig, err error) error {
+	if strings.Contains(err.Error(), "55006") {
+		return fmt.Errorf("dbprov: template database %q has open connections: %w", cfg.TemplateName(), err)
+	}
+	return fmt.Errorf("dbprov: %w", err)
+}
+
+// buildScratchSQL returns the statement that creates an empty database.
+func buildScratchSQL(cfg *config.DatabaseConfig, name string) string {
+	return fmt.Sprintf(`CREATE DATABASE "%s" TEMPLATE template0 OWNER "%s"`, name, cfg.User)
+}
+
+// createScratch drops any leftover database called name and creates it empty.
+func createScratch(adminConn *sql.DB, cfg *config.DatabaseConfig, name string) error {
+	if err := drop(adminConn, name); err != nil {
+		return err
+	}
+	if _, err := adminConn.Exec(buildScratchSQL(cfg, name)); err != nil {
+		return fmt.Errorf("dbprov: %w", err)
+	}
+	return nil
+}
+
+// drop removes the named database.
+func drop(adminConn *sql.DB, name string) error {
+	if _, err := adminConn.Exec(buildDropSQL(name)); err != nil {
+		return fmt.Errorf("dbprov: %w", err)
+	}
+	return nil
+}
+
+// pipelineIssue returns the issue a database name was cloned for, or false
+// when name is not one of cfg's per-pipeline databases.
+func pipelineIssue(cfg *config.DatabaseConfig, name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, cfg.Name+"_issue_")
+	if !ok {
+		return 0, false
+	}
+	issue, err := strconv.Atoi(rest)
+	if err != nil || issue <= 0 || strconv.Itoa(issue) != rest {
+		return 0, false
+	}
+	return issue, true
+}
+
+// pipelineDB is a per-pipeline database and when it was created. created is
+// zero when PostgreSQL cannot tell.
+type pipelineDB struct {
+	name    string
+	created time.Time
+}
+
+// listPipelineDBs reads pg_database for cfg's per-pipeline databases. The
+// creation time is the modification time of the database's PG_VERSION file,
+// which CREATE DATABASE writes once.
+func listPipelineDBs(adminConn *sql.DB, cfg *config.DatabaseConfig) (map[int]pipelineDB, error) {
+	rows, err := adminConn.Query(`SELECT datname, (pg_stat_file('base/' || oid || '/PG_VERSION', true)).modification
+		FROM pg_database WHERE starts_with(datname, $1)`, cfg.Name+"_issue_")
+	if err != nil {
+		return nil, fmt.Errorf("dbprov: list databases: %w", err)
+	}
+	defer rows.Close()
+
+	dbs := make(map[int]pipelineDB)
+	for rows.Next() {
+		var name string
+		var created sql.NullTime
+		if err := rows.Scan(&name, &created); err != nil {
+			return nil, fmt.Errorf("dbprov: list databases: %w", err)
+		}
+		if issue, ok := pipelineIssue(cfg, name); ok {
+			dbs[issue] = pipelineDB{name: name, created: created.Time}
+		}
+	}
+	return dbs, rows.Err()
+}
+
+// orphans returns the names in dbs whose issue keep rejects, sorted. Databases
+// created after cutoff, or whose creation time is unknown, are never orphans:
+// a pipeline's database is cloned before its pipeline.json is written.
+func orphans(dbs map[int]pipelineDB, cutoff time.Time, keep func(issue int) bool) []string {
+	var names []string
+	for issue, db := range dbs {
+		if db.created.IsZero() || db.created.After(cutoff) {
+			continue
+		}
+		if !keep(issue) {
+			names = append(names, db.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listOrphans returns cfg's per-pipeline databases older than minAge whose
+// issue keep rejects.
+func listOrphans(adminConn *sql.DB, cfg *config.DatabaseConfig, minAge time.Duration, keep func(issue int) bool) ([]string, error) {
+	dbs, err := listPipelineDBs(adminConn, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return orphans(dbs, time.Now().Add(-minAge), keep), nil
+}
+
+// gc drops cfg's per-pipeline databases older than minAge that keep rejects.
+func gc(adminConn *sql.DB, cfg *config.DatabaseConfig, minAge time.Duration, keep func(issue int) bool) ([]string, error) {
+	names, err := listOrphans(adminConn, cfg, minAge, keep)
+	if err != nil {
+		return nil, err
+	}
+	var dropped []string
+	for _, name := range names {
+		if err := drop(adminConn, name); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,90 @@
+package dbprov
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+func TestBuildCloneSQL(t *testing.T) {
+	cfg := &config.DatabaseConfig{Name: "mydb", User: "myuser", Isolation: config.DatabasePerPipeline}
+	got := buildCloneSQL(cfg, 42)
+	want := `CREATE DATABASE "mydb_issue_42" TEMPLATE "mydb" OWNER "myuser"`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	cfg.Template = "mydb_template"
+	got = buildCloneSQL(cfg, 42)
+	want = `CREATE DATABASE "mydb_issue_42" TEMPLATE "mydb_template" OWNER "myuser"`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got = buildCloneAsSQL(cfg, "mydb_cd42_c1")
+	want = `CREATE DATABASE "mydb_cd42_c1" TEMPLATE "mydb_template" OWNER "myuser"`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBuildDropSQL(t *testing.T) {
+	got := buildDropSQL("mydb_issue_42")
+	want := `DROP DATABASE IF EXISTS "mydb_issue_42" WITH (FORCE)`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBuildScratchSQL(t *testing.T) {
+	cfg := &config.DatabaseConfig{Name: "mydb", User: "myuser"}
+	got := buildScratchSQL(cfg, "mydb_mc42_head")
+	want := `CREATE DATABASE "mydb_mc42_head" TEMPLATE template0 OWNER "myuser"`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPipelineIssue(t *testing.T) {
+	cfg := &config.DatabaseConfig{Name: "mydb"}
+	tests := []struct {
+		name  string
+		issue int
+		ok    bool
+	}{
+		{"mydb_issue_42", 42, true},
+		{"mydb_issue_1", 1, true},
+		{"mydb", 0, false},
+		{"mydb_issue_", 0, false},
+		{"mydb_issue_042", 0, false},
+		{"mydb_issue_4x", 0, false},
+		{"mydb_issue_0", 0, false},
+		{"other_issue_42", 0, false},
+	}
+	for _, tt := range tests {
+		issue, ok := pipelineIssue(cfg, tt.name)
+		if issue != tt.issue || ok != tt.ok {
+			t.Errorf("pipelineIssue(%q) = %d, %v; want %d, %v", tt.name, issue, ok, tt.issue, tt.ok)
+		}
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	cutoff := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	old := cutoff.Add(-time.Hour)
+	dbs := map[int]pipelineDB{
+		3: {name: "mydb_issue_3", created: old},
+		1: {name: "mydb_issue_1", created: old},
+		2: {name: "mydb_issue_2", created: old},
+		4: {name: "mydb_issue_4", created: cutoff.Add(time.Minute)},
+		5: {name: "mydb_issue_5"},
+	}
+	active := map[int]bool{2: true}
+	got := orphans(dbs, cutoff, func(issue int) bool { return active[issue] })
+	want := []string{"mydb_issue_1", "mydb_issue_3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -4,6 +4,7 @@ package dbprov
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/lucasnoah/taintfactory/internal/config"
 )
@@ -26,3 +27,45 @@ func Provision(adminConn *sql.DB, cfg *config.DatabaseConfig) error {
 func AdminConnStr(databaseURL string) (string, error) {
 	return adminConnStr(databaseURL)
 }
+
+// Clone creates the per-pipeline database for issue from cfg's template
+// database. Idempotent: an existing database is left as is.
+func Clone(adminConn *sql.DB, cfg *config.DatabaseConfig, issue int) error {
+	return clone(adminConn, cfg, issue)
+}
+
+// Drop removes the per-pipeline database for issue, terminating any
+// connections to it. Dropping a database that does not exist is not an error.
+func Drop(adminConn *sql.DB, cfg *config.DatabaseConfig, issue int) error {
+	return drop(adminConn, cfg.PipelineName(issue))
+}
+
+// Orphans returns the per-pipeline databases cloned for cfg whose issue keep
+// rejects, without dropping them. Databases younger than minAge, or whose age
+// is unknown, are left out.
+func Orphans(adminConn *sql.DB, cfg *config.DatabaseConfig, minAge time.Duration, keep func(issue int) bool) ([]string, error) {
+	return listOrphans(adminConn, cfg, minAge, keep)
+}
+
+// GC drops the databases Orphans would return, and returns the names it
+// dropped.
+func GC(adminConn *sql.DB, cfg *config.DatabaseConfig, minAge time.Duration, keep func(issue int) bool) ([]string, error) {
+	return gc(adminConn, cfg, minAge, keep)
+}
+
+// CreateScratch creates an empty database named name, owned by cfg's user,
+// replacing any database left over under that name.
+func CreateScratch(adminConn *sql.DB, cfg *config.DatabaseConfig, name string) error {
+	return createScratch(adminConn, cfg, name)
+}
+
+// CloneScratch creates a database named name from cfg's template database,
+// replacing any database left over under that name.
+func CloneScratch(adminConn *sql.DB, cfg *config.DatabaseConfig, name string) error {
+	return cloneScratch(adminConn, cfg, name)
+}
+
+// DropScratch removes a database made by CreateScratch or CloneScratch.
+func DropScratch(adminConn *sql.DB, name string) error {
+	return drop(adminConn, name)
+}
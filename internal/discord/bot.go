@@ -0,0 +1,389 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAPIBase is the Discord REST API root used for follow-up messages and
+// command registration.
+const DefaultAPIBase = "https://discord.com/api/v10"
+
+// Interaction types and response types from the Discord interactions API.
+const (
+	interactionPing           = 1
+	interactionCommand        = 2
+	responsePong              = 1
+	responseMessage           = 4
+	responseDeferredMessage   = 5
+	messageFlagEphemeral      = 64
+	optionTypeSubCommand      = 1
+	optionTypeSubCommandGroup = 2
+	optionTypeString          = 3
+	optionTypeInteger         = 4
+)
+
+// Interaction is the subset of a Discord interaction payload the bot reads.
+type Interaction struct {
+	ID            string           `json:"id"`
+	ApplicationID string           `json:"application_id"`
+	Type          int              `json:"type"`
+	Token         string           `json:"token"`
+	Data          *InteractionData `json:"data,omitempty"`
+	Member        *Member          `json:"member,omitempty"` // set in guilds
+	User          *User            `json:"user,omitempty"`   // set in DMs
+}
+
+// InteractionData holds the invoked command and its options.
+type InteractionData struct {
+	Name    string              `json:"name"`
+	Options []InteractionOption `json:"options,omitempty"`
+}
+
+// InteractionOption is a (possibly nested) slash-command option.
+type InteractionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   json.RawMessage     `json:"value,omitempty"`
+	Options []InteractionOption `json:"options,omitempty"`
+}
+
+// Member is the guild member that invoked an interaction.
+type Member struct {
+	User  *User    `json:"user"`
+	Roles []string `json:"roles"`
+}
+
+// User is a Discord user.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// Command is a parsed /factory invocation, e.g. "/factory queue add issue:57"
+// becomes Name "queue add" with Options {"issue": "57"}.
+type Command struct {
+	Name     string
+	Options  map[string]string
+	UserID   string
+	Username string
+	Roles    []string
+}
+
+// Int returns an integer option, or an error naming the option.
+func (c Command) Int(name string) (int, error) {
+	v, ok := c.Options[name]
+	if !ok {
+		return 0, fmt.Errorf("missing option %q", name)
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(v, "#"))
+	if err != nil {
+		return 0, fmt.Errorf("option %q: %q is not a number", name, v)
+	}
+	return n, nil
+}
+
+// ParseCommand flattens an application-command interaction into a Command.
+// Subcommand groups and subcommands are joined into Name; leaf values become Options.
+func ParseCommand(i Interaction) Command {
+	cmd := Command{Options: make(map[string]string)}
+	if i.Member != nil {
+		cmd.Roles = i.Member.Roles
+		if i.Member.User != nil {
+			cmd.UserID, cmd.Username = i.Member.User.ID, i.Member.User.Username
+		}
+	} else if i.User != nil {
+		cmd.UserID, cmd.Username = i.User.ID, i.User.Username
+	}
+	if i.Data == nil {
+		return cmd
+	}
+
+	var path []string
+	opts := i.Data.Options
+	for len(opts) == 1 && (opts[0].Type == optionTypeSubCommand || opts[0].Type == optionTypeSubCommandGroup) {
+		path = append(path, opts[0].Name)
+		opts = opts[0].Options
+	}
+	cmd.Name = strings.Join(path, " ")
+	for _, o := range opts {
+		cmd.Options[o.Name] = optionValue(o.Value)
+	}
+	return cmd
+}
+
+// optionValue renders a raw option value (JSON string or number) as text.
+func optionValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// Authorizer restricts bot commands to specific Discord user and role IDs.
+// An empty Authorizer denies everyone.
+type Authorizer struct {
+	Users []string
+	Roles []string
+}
+
+// Allowed reports whether the command's invoker is authorised.
+func (a Authorizer) Allowed(cmd Command) bool {
+	for _, u := range a.Users {
+		if u == cmd.UserID {
+			return true
+		}
+	}
+	for _, r := range a.Roles {
+		for _, have := range cmd.Roles {
+			if r == have {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CommandHandler executes a parsed command and returns the reply text.
+type CommandHandler func(cmd Command) (string, error)
+
+// Bot serves the Discord interactions endpoint. Discord POSTs each slash
+// command to it; the bot verifies the request signature, checks
+// authorisation, acknowledges with a deferred response, runs the handler in
+// the background, and edits the original response with the result.
+type Bot struct {
+	PublicKey ed25519.PublicKey
+	Auth      Authorizer
+	Handle    CommandHandler
+	APIBase   string       // defaults to DefaultAPIBase
+	Client    *http.Client // defaults to a client with a 15s timeout
+
+	wg sync.WaitGroup
+}
+
+// ParsePublicKey decodes the hex-encoded application public key shown in the
+// Discord developer portal.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode public key: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// MaxRequestAge is how far an interaction's X-Signature-Timestamp may be
+// from now. Older requests are rejected so a captured one cannot be replayed.
+const MaxRequestAge = 5 * time.Minute
+
+var defaultClient = &http.Client{Timeout: 15 * time.Second}
+
+// VerifyRequest checks the X-Signature-Ed25519 header over timestamp+body,
+// and that timestamp (Unix seconds) is within MaxRequestAge of now.
+func VerifyRequest(key ed25519.PublicKey, signature, timestamp string, body []byte) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(secs, 0)); age > MaxRequestAge || age < -MaxRequestAge {
+		return false
+	}
+	msg := append([]byte(timestamp), body...)
+	return ed25519.Verify(key, msg, sig)
+}
+
+type interactionResponse struct {
+	Type int                      `json:"type"`
+	Data *interactionResponseData `json:"data,omitempty"`
+}
+
+type interactionResponseData struct {
+	Content string `json:"content"`
+	Flags   int    `json:"flags,omitempty"`
+}
+
+// ServeHTTP implements the interactions endpoint.
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	if !VerifyRequest(b.PublicKey, r.Header.Get("X-Signature-Ed25519"), r.Header.Get("X-Signature-Timestamp"), body) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var in Interaction
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, "invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	switch in.Type {
+	case interactionPing:
+		writeInteractionResponse(w, interactionResponse{Type: responsePong})
+	case interactionCommand:
+		cmd := ParseCommand(in)
+		if !b.Auth.Allowed(cmd) {
+			writeInteractionResponse(w, interactionResponse{
+				Type: responseMessage,
+				Data: &interactionResponseData{Content: "You are not authorised to run factory commands.", Flags: messageFlagEphemeral},
+			})
+			return
+		}
+		writeInteractionResponse(w, interactionResponse{Type: responseDeferredMessage})
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			reply := b.run(cmd)
+			_ = b.editOriginal(in.ApplicationID, in.Token, reply)
+		}()
+	default:
+		http.Error(w, "unsupported interaction type", http.StatusBadRequest)
+	}
+}
+
+// Wait blocks until all in-flight commands have replied. Used on shutdown and in tests.
+func (b *Bot) Wait() {
+	b.wg.Wait()
+}
+
+func (b *Bot) run(cmd Command) (reply string) {
+	defer func() {
+		if r := recover(); r != nil {
+			reply = fmt.Sprintf("`%s` failed: %v", cmd.Name, r)
+		}
+	}()
+	out, err := b.Handle(cmd)
+	if err != nil {
+		return fmt.Sprintf("`%s` failed: %v", cmd.Name, err)
+	}
+	return out
+}
+
+// maxMessageLen is Discord's message content limit.
+const maxMessageLen = 2000
+
+// editOriginal replaces the deferred "thinking…" response with content.
+func (b *Bot) editOriginal(appID, token, content string) error {
+	if len(content) > maxMessageLen {
+		content = content[:maxMessageLen-3] + "..."
+	}
+	data, err := json.Marshal(interactionResponseData{Content: content})
+	if err != nil {
+		return fmt.Errorf("marshal follow-up: %w", err)
+	}
+	url := fmt.Sprintf("%s/webhooks/%s/%s/messages/@original", b.apiBase(), appID, token)
+	return b.do(http.MethodPatch, url, "", data)
+}
+
+func (b *Bot) apiBase() string {
+	if b.APIBase == "" {
+		return DefaultAPIBase
+	}
+	return strings.TrimSuffix(b.APIBase, "/")
+}
+
+func (b *Bot) do(method, url, botToken string, data []byte) error {
+	client := b.Client
+	if client == nil {
+		client = defaultClient
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data)) //nolint:noctx
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if botToken != "" {
+		req.Header.Set("Authorization", "Bot "+botToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Discord returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func writeInteractionResponse(w http.ResponseWriter, resp interactionResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ApplicationCommand is a slash-command definition for registration.
+type ApplicationCommand struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Type        int                  `json:"type,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Options     []ApplicationCommand `json:"options,omitempty"`
+}
+
+// FactoryCommands returns the /factory slash-command tree.
+func FactoryCommands() []ApplicationCommand {
+	issue := ApplicationCommand{Name: "issue", Description: "Issue number", Type: optionTypeInteger, Required: true}
+	return []ApplicationCommand{{
+		Name:        "factory",
+		Description: "Inspect and control factory pipelines",
+		Options: []ApplicationCommand{
+			{Name: "status", Description: "Show pipeline status", Type: optionTypeSubCommand, Options: []ApplicationCommand{issue}},
+			{Name: "retry", Description: "Retry the current stage", Type: optionTypeSubCommand, Options: []ApplicationCommand{
+				issue,
+				{Name: "reason", Description: "Why the stage is being retried", Type: optionTypeString},
+			}},
+			{Name: "approve", Description: "Approve the current stage and advance", Type: optionTypeSubCommand, Options: []ApplicationCommand{
+				issue,
+				{Name: "stage", Description: "Stage being approved (must be the current stage)", Type: optionTypeString, Required: true},
+			}},
+			{Name: "queue", Description: "Manage the issue queue", Type: optionTypeSubCommandGroup, Options: []ApplicationCommand{
+				{Name: "add", Description: "Add an issue to the queue", Type: optionTypeSubCommand, Options: []ApplicationCommand{
+					issue,
+					{Name: "intent", Description: "Feature intent (derived from the issue if omitted)", Type: optionTypeString},
+				}},
+			}},
+		},
+	}}
+}
+
+// RegisterCommands overwrites the application's slash commands with
+// FactoryCommands. If guildID is set, commands are registered to that guild
+// only (they appear immediately); otherwise they are registered globally.
+func (b *Bot) RegisterCommands(appID, guildID, botToken string) error {
+	data, err := json.Marshal(FactoryCommands())
+	if err != nil {
+		return fmt.Errorf("marshal commands: %w", err)
+	}
+	url := fmt.Sprintf("%s/applications/%s/commands", b.apiBase(), appID)
+	if guildID != "" {
+		url = fmt.Sprintf("%s/applications/%s/guilds/%s/commands", b.apiBase(), appID, guildID)
+	}
+	if err := b.do(http.MethodPut, url, botToken, data); err != nil {
+		return fmt.Errorf("register commands: %w", err)
+	}
+	return nil
+}
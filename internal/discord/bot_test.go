@@ -0,0 +1,256 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDiscord stands in for the Discord REST API, recording follow-up edits
+// and command registrations.
+type fakeDiscord struct {
+	mu       sync.Mutex
+	requests []fakeRequest
+}
+
+type fakeRequest struct {
+	Method string
+	Path   string
+	Auth   string
+	Body   string
+}
+
+func (f *fakeDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{Method: r.Method, Path: r.URL.Path, Auth: r.Header.Get("Authorization"), Body: string(body)})
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func newTestBot(t *testing.T, handle CommandHandler) (*Bot, ed25519.PrivateKey, *fakeDiscord) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &fakeDiscord{}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return &Bot{
+		PublicKey: pub,
+		Auth:      Authorizer{Users: []string{"u-admin"}, Roles: []string{"r-ops"}},
+		Handle:    handle,
+		APIBase:   srv.URL,
+	}, priv, api
+}
+
+func signedRequest(t *testing.T, priv ed25519.PrivateKey, in Interaction) *http.Request {
+	t.Helper()
+	return signedRequestAt(t, priv, in, time.Now())
+}
+
+func signedRequestAt(t *testing.T, priv ed25519.PrivateKey, in Interaction, at time.Time) *http.Request {
+	t.Helper()
+	body, _ := json.Marshal(in)
+	ts := strconv.FormatInt(at.Unix(), 10)
+	sig := ed25519.Sign(priv, append([]byte(ts), body...))
+	req := httptest.NewRequest(http.MethodPost, "/interactions", bytes.NewReader(body))
+	req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(sig))
+	req.Header.Set("X-Signature-Timestamp", ts)
+	return req
+}
+
+func commandInteraction(userID string, roles []string, opts ...InteractionOption) Interaction {
+	return Interaction{
+		ApplicationID: "app1",
+		Type:          interactionCommand,
+		Token:         "tok",
+		Member:        &Member{User: &User{ID: userID, Username: "alice"}, Roles: roles},
+		Data:          &InteractionData{Name: "factory", Options: opts},
+	}
+}
+
+func intOpt(name string, v int) InteractionOption {
+	raw, _ := json.Marshal(v)
+	return InteractionOption{Name: name, Type: optionTypeInteger, Value: raw}
+}
+
+func strOpt(name, v string) InteractionOption {
+	raw, _ := json.Marshal(v)
+	return InteractionOption{Name: name, Type: optionTypeString, Value: raw}
+}
+
+func TestParseCommand_SubcommandGroup(t *testing.T) {
+	in := commandInteraction("u1", []string{"r1"}, InteractionOption{
+		Name: "queue", Type: optionTypeSubCommandGroup,
+		Options: []InteractionOption{{
+			Name: "add", Type: optionTypeSubCommand,
+			Options: []InteractionOption{intOpt("issue", 57), strOpt("intent", "ship it")},
+		}},
+	})
+	cmd := ParseCommand(in)
+	if cmd.Name != "queue add" {
+		t.Errorf("Name = %q, want %q", cmd.Name, "queue add")
+	}
+	if n, err := cmd.Int("issue"); err != nil || n != 57 {
+		t.Errorf("issue = %d, %v", n, err)
+	}
+	if cmd.Options["intent"] != "ship it" || cmd.UserID != "u1" || len(cmd.Roles) != 1 {
+		t.Errorf("unexpected command: %+v", cmd)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	a := Authorizer{Users: []string{"u1"}, Roles: []string{"r1"}}
+	if !a.Allowed(Command{UserID: "u1"}) {
+		t.Error("listed user should be allowed")
+	}
+	if !a.Allowed(Command{UserID: "u2", Roles: []string{"r0", "r1"}}) {
+		t.Error("user with listed role should be allowed")
+	}
+	if a.Allowed(Command{UserID: "u2", Roles: []string{"r2"}}) {
+		t.Error("unlisted user should be denied")
+	}
+	if (Authorizer{}).Allowed(Command{UserID: "u1"}) {
+		t.Error("empty authorizer should deny everyone")
+	}
+}
+
+func TestBot_Ping(t *testing.T) {
+	bot, priv, _ := newTestBot(t, nil)
+	rec := httptest.NewRecorder()
+	bot.ServeHTTP(rec, signedRequest(t, priv, Interaction{Type: interactionPing}))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"type":1`) {
+		t.Errorf("expected PONG, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBot_RejectsBadSignature(t *testing.T) {
+	bot, _, _ := newTestBot(t, nil)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	rec := httptest.NewRecorder()
+	bot.ServeHTTP(rec, signedRequest(t, otherKey, Interaction{Type: interactionPing}))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", rec.Code)
+	}
+}
+
+func TestBot_RejectsStaleTimestamp(t *testing.T) {
+	bot, priv, _ := newTestBot(t, nil)
+	rec := httptest.NewRecorder()
+	bot.ServeHTTP(rec, signedRequestAt(t, priv, Interaction{Type: interactionPing}, time.Now().Add(-10*time.Minute)))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a replayed request, got %d", rec.Code)
+	}
+}
+
+func TestBot_CommandDeferredThenEdited(t *testing.T) {
+	var got Command
+	bot, priv, api := newTestBot(t, func(c Command) (string, error) {
+		got = c
+		return "Pipeline #42: retry queued", nil
+	})
+
+	rec := httptest.NewRecorder()
+	in := commandInteraction("u-admin", nil, InteractionOption{
+		Name: "retry", Type: optionTypeSubCommand, Options: []InteractionOption{intOpt("issue", 42)},
+	})
+	bot.ServeHTTP(rec, signedRequest(t, priv, in))
+	bot.Wait()
+
+	if !strings.Contains(rec.Body.String(), `"type":5`) {
+		t.Errorf("expected deferred response, got %s", rec.Body.String())
+	}
+	if got.Name != "retry" {
+		t.Errorf("handler got %+v", got)
+	}
+	if len(api.requests) != 1 {
+		t.Fatalf("expected 1 follow-up request, got %d", len(api.requests))
+	}
+	r := api.requests[0]
+	if r.Method != http.MethodPatch || r.Path != "/webhooks/app1/tok/messages/@original" {
+		t.Errorf("unexpected follow-up: %s %s", r.Method, r.Path)
+	}
+	if !strings.Contains(r.Body, "retry queued") {
+		t.Errorf("follow-up body = %s", r.Body)
+	}
+}
+
+func TestBot_HandlerError(t *testing.T) {
+	bot, priv, api := newTestBot(t, func(c Command) (string, error) {
+		return "", errors.New("pipeline 42 is already completed")
+	})
+
+	in := commandInteraction("u-other", []string{"r-ops"}, InteractionOption{
+		Name: "approve", Type: optionTypeSubCommand, Options: []InteractionOption{intOpt("issue", 42), strOpt("stage", "review")},
+	})
+	bot.ServeHTTP(httptest.NewRecorder(), signedRequest(t, priv, in))
+	bot.Wait()
+
+	if len(api.requests) != 1 || !strings.Contains(api.requests[0].Body, "already completed") {
+		t.Errorf("expected error follow-up, got %+v", api.requests)
+	}
+}
+
+func TestBot_Unauthorised(t *testing.T) {
+	called := false
+	bot, priv, api := newTestBot(t, func(c Command) (string, error) {
+		called = true
+		return "", nil
+	})
+
+	rec := httptest.NewRecorder()
+	in := commandInteraction("u-stranger", []string{"r-guest"}, InteractionOption{
+		Name: "status", Type: optionTypeSubCommand, Options: []InteractionOption{intOpt("issue", 1)},
+	})
+	bot.ServeHTTP(rec, signedRequest(t, priv, in))
+	bot.Wait()
+
+	if called {
+		t.Error("handler should not run for unauthorised users")
+	}
+	if !strings.Contains(rec.Body.String(), "not authorised") || !strings.Contains(rec.Body.String(), `"flags":64`) {
+		t.Errorf("expected ephemeral rejection, got %s", rec.Body.String())
+	}
+	if len(api.requests) != 0 {
+		t.Errorf("expected no follow-up, got %+v", api.requests)
+	}
+}
+
+func TestBot_RegisterCommands(t *testing.T) {
+	bot, _, api := newTestBot(t, nil)
+	if err := bot.RegisterCommands("app1", "guild9", "secret-token"); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+	r := api.requests[0]
+	if r.Method != http.MethodPut || r.Path != "/applications/app1/guilds/guild9/commands" || r.Auth != "Bot secret-token" {
+		t.Errorf("unexpected request: %+v", r)
+	}
+	var cmds []ApplicationCommand
+	if err := json.Unmarshal([]byte(r.Body), &cmds); err != nil || len(cmds) != 1 || cmds[0].Name != "factory" {
+		t.Fatalf("unexpected body: %s", r.Body)
+	}
+	names := map[string]bool{}
+	for _, o := range cmds[0].Options {
+		names[o.Name] = true
+	}
+	for _, want := range []string{"status", "retry", "approve", "queue"} {
+		if !names[want] {
+			t.Errorf("missing subcommand %q", want)
+		}
+	}
+}
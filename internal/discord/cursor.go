@@ -7,7 +7,8 @@ import (
 	"path/filepath"
 )
 
-// Cursor tracks the last pipeline event ID sent to Discord.
+// Cursor tracks the last pipeline event ID a poller has handled. The Discord
+// poller and the notification sink poller each keep their own.
 type Cursor struct {
 	LastEventID int    `json:"last_event_id"`
 	path        string // file this cursor was loaded from
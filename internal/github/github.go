@@ -47,9 +47,10 @@ func (r *ExecRunner) RunGit(dir string, args ...string) (string, error) {
 
 // Client provides GitHub operations.
 type Client struct {
-	cmd  CmdRunner
-	git  GitRunner
-	repo string // optional "owner/repo" to scope all gh calls (--repo flag)
+	cmd    CmdRunner
+	git    GitRunner
+	repo   string // optional "owner/repo" to scope all gh calls (--repo flag)
+	remote string // git remote for push, fetch and rebase; "" = origin
 }
 
 // NewClient creates a GitHub client. If cmd also implements GitRunner,
@@ -75,6 +76,22 @@ func (c *Client) WithRepo(repo string) *Client {
 	return &clone
 }
 
+// WithRemote returns a copy of the client that pushes to and rebases from
+// remote instead of origin.
+func (c *Client) WithRemote(remote string) *Client {
+	clone := *c
+	clone.remote = remote
+	return &clone
+}
+
+// remoteName returns the git remote, defaulting to origin.
+func (c *Client) remoteName() string {
+	if c.remote == "" {
+		return "origin"
+	}
+	return c.remote
+}
+
 // repoArgs returns ["--repo", "owner/repo"] when a repo is set, else nil.
 func (c *Client) repoArgs() []string {
 	if c.repo == "" {
@@ -285,7 +302,7 @@ func (c *Client) PushBranch(dir string, branch string) error {
 	if strings.HasPrefix(branch, "-") {
 		return fmt.Errorf("invalid branch name %q: must not start with -", branch)
 	}
-	_, err := c.git.RunGit(dir, "push", "-u", "origin", branch)
+	_, err := c.git.RunGit(dir, "push", "-u", c.remoteName(), branch)
 	if err != nil {
 		return fmt.Errorf("push branch: %w", err)
 	}
@@ -301,25 +318,31 @@ func (c *Client) ForcePushBranch(dir string, branch string) error {
 	if strings.HasPrefix(branch, "-") {
 		return fmt.Errorf("invalid branch name %q: must not start with -", branch)
 	}
-	_, err := c.git.RunGit(dir, "push", "--force-with-lease", "-u", "origin", branch)
+	_, err := c.git.RunGit(dir, "push", "--force-with-lease", "-u", c.remoteName(), branch)
 	if err != nil {
 		return fmt.Errorf("force push branch: %w", err)
 	}
 	return nil
 }
 
-// RebaseOntoMain fetches origin/main and rebases the working tree onto it.
+// RebaseOnto fetches base from the client's remote and rebases the working
+// tree onto it.
 // Returns (conflicted=true, nil) when git detects merge conflicts and the
 // rebase has been aborted, leaving the worktree clean.
 // Returns (false, err) for fetch errors or unexpected rebase failures.
 // Returns (false, nil) when the rebase completes cleanly (including no-op).
-func (c *Client) RebaseOntoMain(dir string) (conflicted bool, err error) {
+func (c *Client) RebaseOnto(dir, base string) (conflicted bool, err error) {
 	if c.git == nil {
 		return false, fmt.Errorf("git runner not configured")
 	}
-	if _, err := c.git.RunGit(dir, "fetch", "origin", "main"); err != nil {
-		return false, fmt.Errorf("fetch origin main: %w", err)
+	if strings.HasPrefix(base, "-") {
+		return false, fmt.Errorf("invalid branch name %q: must not start with -", base)
+	}
+	remote := c.remoteName()
+	if _, err := c.git.RunGit(dir, "fetch", remote, base); err != nil {
+		return false, fmt.Errorf("fetch %s %s: %w", remote, base, err)
 	}
+	ref := remote + "/" + base
 
 	// Stash any uncommitted changes so the rebase doesn't refuse to start.
 	// Common cause: setup steps (npm install, go mod download) leave modified
@@ -327,7 +350,7 @@ func (c *Client) RebaseOntoMain(dir string) (conflicted bool, err error) {
 	stashOut, _ := c.git.RunGit(dir, "stash", "--include-untracked")
 	stashed := !strings.Contains(stashOut, "No local changes to save")
 
-	out, rebaseErr := c.git.RunGit(dir, "rebase", "origin/main")
+	out, rebaseErr := c.git.RunGit(dir, "rebase", ref)
 	if rebaseErr == nil {
 		if stashed {
 			_, _ = c.git.RunGit(dir, "stash", "pop")
@@ -342,7 +365,7 @@ func (c *Client) RebaseOntoMain(dir string) (conflicted bool, err error) {
 		}
 		return true, nil
 	}
-	return false, fmt.Errorf("rebase onto origin/main: %w", rebaseErr)
+	return false, fmt.Errorf("rebase onto %s: %w", ref, rebaseErr)
 }
 
 // LLMFunc sends a prompt to an LLM and returns the response text.
@@ -347,6 +347,47 @@ func TestPushBranch(t *testing.T) {
 	}
 }
 
+func TestPushBranch_WithRemote(t *testing.T) {
+	gitMock := &mockGitRunner{}
+	client := NewClientWithGit(&mockCmd{}, gitMock).WithRemote("upstream")
+	if err := client.PushBranch("/tmp/worktree", "feature/issue-42"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(gitMock.calls[0].Args, " "); got != "push -u upstream feature/issue-42" {
+		t.Errorf("unexpected push args: %q", got)
+	}
+}
+
+func TestRebaseOnto(t *testing.T) {
+	gitMock := &mockGitRunner{
+		results: []mockResult{
+			{output: ""},                         // fetch
+			{output: "No local changes to save"}, // stash
+			{output: ""},                         // rebase
+		},
+	}
+	client := NewClientWithGit(&mockCmd{}, gitMock).WithRemote("upstream")
+	conflicted, err := client.RebaseOnto("/tmp/worktree", "release/2.3")
+	if err != nil || conflicted {
+		t.Fatalf("expected clean rebase, got conflicted=%v err=%v", conflicted, err)
+	}
+	var got []string
+	for _, c := range gitMock.calls {
+		got = append(got, strings.Join(c.Args, " "))
+	}
+	want := []string{"fetch upstream release/2.3", "stash --include-untracked", "rebase upstream/release/2.3"}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("git calls = %v, want %v", got, want)
+	}
+}
+
+func TestRebaseOnto_RejectsDashPrefix(t *testing.T) {
+	client := NewClientWithGit(&mockCmd{}, &mockGitRunner{})
+	if _, err := client.RebaseOnto("/tmp", "--onto"); err == nil {
+		t.Fatal("expected error for base starting with -")
+	}
+}
+
 func TestPushBranch_RejectsDashPrefix(t *testing.T) {
 	client := NewClientWithGit(&mockCmd{}, &mockGitRunner{})
 	err := client.PushBranch("/tmp", "--delete")
@@ -0,0 +1,295 @@
+// Package lessons carries what went wrong in earlier pipelines of a namespace
+// into the prompts of new ones. Lessons are harvested after every stage from
+// failed check runs, review stage findings and human steer messages; a
+// harvested lesson takes effect once it has recurred in enough distinct
+// issues. Lessons added by hand (factory lessons add) apply immediately.
+package lessons
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/repoindex"
+)
+
+// Defaults for config.LessonsConfig fields left at zero.
+const (
+	DefaultMax            = 5
+	DefaultMinOccurrences = 3
+)
+
+// maxExample caps the example message quoted in a harvested lesson.
+const maxExample = 160
+
+// FromCheckRuns returns a lesson for each failing check run: one per rule or
+// error code reported by its parser, or one for the check as a whole when
+// the parser reports none.
+func FromCheckRuns(namespace string, runs []db.CheckRun) []db.LessonRecord {
+	var out []db.LessonRecord
+	seen := make(map[string]bool)
+	add := func(l db.LessonRecord) {
+		if !seen[l.Key] {
+			seen[l.Key] = true
+			out = append(out, l)
+		}
+	}
+	for _, r := range runs {
+		if r.Passed {
+			continue
+		}
+		rules := parseRules(r.Findings)
+		if len(rules) == 0 {
+			text := fmt.Sprintf("The `%s` check has failed in several past pipelines; run it before finishing.", r.CheckName)
+			if ex := exampleLine(r.Findings); ex != "" {
+				text += fmt.Sprintf(" A typical failure: %q", ex)
+			}
+			add(db.LessonRecord{
+				Namespace: namespace, Source: "check", Key: "check:" + r.CheckName,
+				CheckName: r.CheckName, Text: text,
+			})
+			continue
+		}
+		for _, rule := range rules {
+			text := fmt.Sprintf("The `%s` check keeps flagging `%s`", r.CheckName, rule.rule)
+			if rule.message != "" {
+				text += fmt.Sprintf(" (e.g. %q)", clip(rule.message))
+			}
+			add(db.LessonRecord{
+				Namespace: namespace, Source: "check", Key: "check:" + r.CheckName + ":" + rule.rule,
+				CheckName: r.CheckName, Text: text + "; avoid it up front.",
+			})
+		}
+	}
+	return out
+}
+
+type ruleHit struct {
+	rule, message string
+}
+
+// parseRules extracts the distinct rules from a parser's JSON findings
+// (eslint "rule", tsc "code"). Plain-text findings yield none.
+func parseRules(findings string) []ruleHit {
+	var parsed struct {
+		Findings []struct {
+			Rule    string `json:"rule"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"findings"`
+	}
+	if json.Unmarshal([]byte(findings), &parsed) != nil {
+		return nil
+	}
+	var out []ruleHit
+	seen := make(map[string]bool)
+	for _, f := range parsed.Findings {
+		rule := f.Rule
+		if rule == "" {
+			rule = f.Code
+		}
+		if rule == "" || seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		out = append(out, ruleHit{rule, f.Message})
+	}
+	return out
+}
+
+// exampleLine picks the first line of raw check output that looks like an
+// error.
+func exampleLine(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		l := strings.ToLower(line)
+		if strings.Contains(l, "error") || strings.Contains(l, "fail") || strings.Contains(l, "panic") {
+			return clip(strings.TrimSpace(line))
+		}
+	}
+	return ""
+}
+
+// FromFindings returns a lesson for each finding of a review stage, keyed by
+// rule when there is one and by the finding's wording otherwise.
+func FromFindings(namespace string, findings []pipeline.Finding) []db.LessonRecord {
+	var out []db.LessonRecord
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		if f.Message == "" {
+			continue
+		}
+		key := "review:" + f.Rule
+		if f.Rule == "" {
+			key = "review:" + termKey(f.Message)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		text := "Reviewers have flagged: " + clip(f.Message)
+		if f.Rule != "" {
+			text += fmt.Sprintf(" (`%s`)", f.Rule)
+		}
+		out = append(out, db.LessonRecord{Namespace: namespace, Source: "review", Key: key, Text: text})
+	}
+	return out
+}
+
+// FromSteers returns a lesson for each message a human sent to a stage's
+// session. Like other harvested lessons they apply once they recur; pin one
+// with `factory lessons pin` to apply it at once.
+func FromSteers(namespace, stage string, messages []string) []db.LessonRecord {
+	var out []db.LessonRecord
+	for _, m := range messages {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		out = append(out, db.LessonRecord{
+			Namespace: namespace, Source: "steer", Key: "steer:" + termKey(m),
+			Stage: stage, Text: "Human guidance given to an earlier agent: " + clip(m),
+		})
+	}
+	return out
+}
+
+// termKey identifies a message by its first distinctive terms, so that
+// rewordings which differ only in punctuation, case or numbers match.
+func termKey(msg string) string {
+	terms := repoindex.Terms(msg)
+	if len(terms) > 8 {
+		terms = terms[:8]
+	}
+	sum := sha1.Sum([]byte(strings.Join(terms, " ")))
+	return hex.EncodeToString(sum[:6])
+}
+
+func clip(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxExample {
+		return string(r[:maxExample]) + "..."
+	}
+	return s
+}
+
+// Query describes the stage a lesson selection is for.
+type Query struct {
+	Text   string   // issue title and body
+	Stage  string   // stage ID
+	Checks []string // checks the stage runs
+}
+
+// Active reports whether a lesson is in effect: pinned or manual lessons
+// always are, harvested ones once seen in minOccurrences distinct issues.
+func Active(l db.LessonRecord, minOccurrences int) bool {
+	if l.Dismissed {
+		return false
+	}
+	return l.Pinned || l.Source == "manual" || l.Hits >= minOccurrences
+}
+
+// Select picks the lessons that apply to q, most relevant first: lessons
+// scoped to another stage or to a check the stage does not run are skipped,
+// the rest rank by shared terms with the issue text, then by hits.
+func Select(all []db.LessonRecord, q Query, cfg config.LessonsConfig) []db.LessonRecord {
+	limit, minOcc := cfg.Max, cfg.MinOccurrences
+	if limit == 0 {
+		limit = DefaultMax
+	}
+	if minOcc == 0 {
+		minOcc = DefaultMinOccurrences
+	}
+	checks := make(map[string]bool)
+	for _, c := range q.Checks {
+		checks[c] = true
+	}
+	terms := make(map[string]bool)
+	for _, t := range repoindex.Terms(q.Text) {
+		terms[t] = true
+	}
+
+	type scored struct {
+		l     db.LessonRecord
+		score int
+	}
+	var picked []scored
+	for _, l := range all {
+		if !Active(l, minOcc) {
+			continue
+		}
+		if l.Stage != "" && l.Stage != q.Stage {
+			continue
+		}
+		if l.CheckName != "" && !checks[l.CheckName] {
+			continue
+		}
+		score := 0
+		for _, t := range repoindex.Terms(l.Text) {
+			if terms[t] {
+				score++
+			}
+		}
+		picked = append(picked, scored{l, score})
+	}
+	sort.SliceStable(picked, func(i, j int) bool {
+		a, b := picked[i], picked[j]
+		if a.l.Pinned != b.l.Pinned {
+			return a.l.Pinned
+		}
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.l.Hits > b.l.Hits
+	})
+
+	var out []db.LessonRecord
+	for i := 0; i < len(picked) && i < limit; i++ {
+		out = append(out, picked[i].l)
+	}
+	return out
+}
+
+// Format renders lessons as a Markdown list for the {{lessons}} variable.
+func Format(ls []db.LessonRecord) string {
+	var sb strings.Builder
+	for _, l := range ls {
+		fmt.Fprintf(&sb, "- %s\n", l.Text)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// lister is the part of *db.DB a Provider needs.
+type lister interface {
+	LessonList(namespace string, includeDismissed bool) ([]db.LessonRecord, error)
+}
+
+// Provider supplies the {{lessons}} variable from the lessons table.
+type Provider struct {
+	db  lister
+	cfg config.LessonsConfig
+}
+
+// NewProvider returns a Provider reading from d with the pipeline's lessons
+// settings.
+func NewProvider(d lister, cfg config.LessonsConfig) *Provider {
+	return &Provider{db: d, cfg: cfg}
+}
+
+// Lessons returns the formatted lessons for a stage, or "" when none apply
+// or lessons are disabled.
+func (p *Provider) Lessons(namespace, query, stage string, checks []string) (string, error) {
+	if p.cfg.Disabled {
+		return "", nil
+	}
+	all, err := p.db.LessonList(namespace, false)
+	if err != nil {
+		return "", err
+	}
+	return Format(Select(all, Query{Text: query, Stage: stage, Checks: checks}, p.cfg)), nil
+}
@@ -0,0 +1,110 @@
+package lessons
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+func TestFromCheckRuns(t *testing.T) {
+	runs := []db.CheckRun{
+		{CheckName: "lint", Passed: false, Findings: `{"errors":2,"findings":[
+			{"file":"a.ts","rule":"no-unused-vars","message":"'x' is defined but never used"},
+			{"file":"b.ts","rule":"no-unused-vars","message":"'y' is defined but never used"},
+			{"file":"b.ts","rule":"eqeqeq","message":"Expected '===' and instead saw '=='"}]}`},
+		{CheckName: "test", Passed: false, Findings: "ok  pkg/a\n--- FAIL: TestLogin (0.01s)\nFAIL"},
+		{CheckName: "typecheck", Passed: true},
+	}
+	got := FromCheckRuns("org/app", runs)
+
+	var keys []string
+	for _, l := range got {
+		keys = append(keys, l.Key)
+		if l.Namespace != "org/app" || l.Source != "check" {
+			t.Errorf("bad lesson %+v", l)
+		}
+	}
+	if strings.Join(keys, " ") != "check:lint:no-unused-vars check:lint:eqeqeq check:test" {
+		t.Errorf("keys = %v", keys)
+	}
+	if !strings.Contains(got[0].Text, "`no-unused-vars`") || got[0].CheckName != "lint" {
+		t.Errorf("rule lesson = %+v", got[0])
+	}
+	if !strings.Contains(got[2].Text, `"--- FAIL: TestLogin (0.01s)"`) {
+		t.Errorf("generic lesson should quote the failing line: %q", got[2].Text)
+	}
+}
+
+func TestFromFindingsAndSteers_KeysIgnoreWording(t *testing.T) {
+	a := FromFindings("", []pipeline.Finding{{Message: "Missing error handling in the HTTP handler"}})
+	b := FromFindings("", []pipeline.Finding{{Message: "missing error handling in the http handler!"}})
+	if a[0].Key != b[0].Key {
+		t.Errorf("rewordings should share a key: %q vs %q", a[0].Key, b[0].Key)
+	}
+	if r := FromFindings("", []pipeline.Finding{{Message: "x", Rule: "SEC-1"}}); r[0].Key != "review:SEC-1" {
+		t.Errorf("rule key = %q", r[0].Key)
+	}
+
+	s := FromSteers("", "implement", []string{"Use the existing retry helper", "  "})
+	if len(s) != 1 || s[0].Stage != "implement" || s[0].Source != "steer" {
+		t.Errorf("steer lessons = %+v", s)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	all := []db.LessonRecord{
+		{ID: 1, Source: "check", CheckName: "lint", Text: "lint flags eqeqeq", Hits: 5},
+		{ID: 2, Source: "check", CheckName: "e2e", Text: "e2e is flaky", Hits: 9},
+		{ID: 3, Source: "review", Text: "Reviewers flag missing session expiry tests", Hits: 3},
+		{ID: 4, Source: "review", Text: "candidate only", Hits: 2},
+		{ID: 5, Source: "manual", Stage: "review", Text: "check migrations", Hits: 1},
+		{ID: 6, Source: "steer", Text: "pinned steer", Hits: 1, Pinned: true},
+		{ID: 7, Source: "manual", Text: "dismissed", Dismissed: true},
+	}
+	got := Select(all, Query{Text: "Session expiry is wrong", Stage: "implement", Checks: []string{"lint"}}, config.LessonsConfig{})
+
+	var ids []int
+	for _, l := range got {
+		ids = append(ids, l.ID)
+	}
+	// pinned first, then by term overlap with the issue, then by hits
+	want := []int{6, 3, 1}
+	if len(ids) != len(want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("ids = %v, want %v", ids, want)
+		}
+	}
+
+	got = Select(all, Query{Stage: "implement", Checks: []string{"lint"}}, config.LessonsConfig{Max: 1, MinOccurrences: 2})
+	if len(got) != 1 || got[0].ID != 6 {
+		t.Errorf("max 1 should keep only the pinned lesson, got %+v", got)
+	}
+}
+
+type fakeLister []db.LessonRecord
+
+func (f fakeLister) LessonList(namespace string, includeDismissed bool) ([]db.LessonRecord, error) {
+	return f, nil
+}
+
+func TestProvider(t *testing.T) {
+	p := NewProvider(fakeLister{{Source: "manual", Text: "Run make generate after editing proto files"}}, config.LessonsConfig{})
+	out, err := p.Lessons("", "issue", "implement", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "- Run make generate after editing proto files" {
+		t.Errorf("Lessons = %q", out)
+	}
+
+	p = NewProvider(fakeLister{{Source: "manual", Text: "x"}}, config.LessonsConfig{Disabled: true})
+	if out, _ := p.Lessons("", "", "implement", nil); out != "" {
+		t.Errorf("disabled provider returned %q", out)
+	}
+}
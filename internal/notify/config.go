@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+// FromConfig builds a Dispatcher for the Slack, webhook, and email sinks
+// configured in a pipeline's notifications block. Discord is handled by its
+// own poller path and is not included. Returns nil if no sinks are configured.
+func FromConfig(n config.NotificationsConfig, dl DeadLetterStore) *Dispatcher {
+	var routes []Route
+	if n.Slack.WebhookURL != "" {
+		routes = append(routes, Route{
+			Sink:   &SlackSink{WebhookURL: n.Slack.WebhookURL},
+			Filter: Filter(n.Slack.Events),
+		})
+	}
+	for _, w := range n.Webhooks {
+		if w.URL == "" {
+			continue
+		}
+		routes = append(routes, Route{
+			Sink:   &WebhookSink{SinkName: w.Name, URL: w.URL, Secret: w.Secret},
+			Filter: Filter(w.Events),
+		})
+	}
+	if n.Email.Host != "" && len(n.Email.To) > 0 {
+		routes = append(routes, Route{
+			Sink: &EmailSink{
+				Host:     n.Email.Host,
+				Port:     n.Email.Port,
+				Username: n.Email.Username,
+				Password: n.Email.Password,
+				From:     n.Email.From,
+				To:       n.Email.To,
+			},
+			Filter: Filter(n.Email.Events),
+		})
+	}
+	if len(routes) == 0 {
+		return nil
+	}
+
+	policy := RetryPolicy{MaxAttempts: n.Retry.MaxAttempts}
+	if n.Retry.Backoff != "" {
+		if d, err := time.ParseDuration(n.Retry.Backoff); err == nil {
+			policy.InitialBackoff = d
+		}
+	}
+	return &Dispatcher{Routes: routes, Retry: policy, DeadLetter: dl}
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SendMailFunc matches smtp.SendMail so tests can substitute a fake.
+type SendMailFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// EmailSink sends each event as a plain-text email over SMTP.
+type EmailSink struct {
+	Host     string
+	Port     int // defaults to 587
+	Username string
+	Password string
+	From     string
+	To       []string
+	SendMail SendMailFunc // optional; defaults to sendMail, which gives up after DefaultTimeout
+}
+
+// Name returns "email".
+func (s *EmailSink) Name() string { return "email" }
+
+// Send delivers the event to every recipient in To.
+func (s *EmailSink) Send(e Event) error {
+	if len(s.To) == 0 {
+		return fmt.Errorf("email sink has no recipients")
+	}
+	port := s.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	send := s.SendMail
+	if send == nil {
+		send = sendMail
+	}
+	if err := send(addr, auth, s.From, s.To, s.message(e)); err != nil {
+		return fmt.Errorf("send mail via %s: %w", addr, err)
+	}
+	return nil
+}
+
+func (s *EmailSink) message(e Event) []byte {
+	subject := fmt.Sprintf("[factory] #%d %s", e.Issue, e.Event)
+	if e.Stage != "" {
+		subject += " (" + e.Stage + ")"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", s.From)
+	fmt.Fprintf(&sb, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&sb, "Subject: %s\r\n", subject)
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	sb.WriteString(e.Text())
+	sb.WriteString("\r\n")
+	return []byte(sb.String())
+}
+
+// sendMail is smtp.SendMail with the whole exchange bounded by DefaultTimeout.
+func sendMail(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", addr, DefaultTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(DefaultTimeout)); err != nil {
+		return err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if err := c.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
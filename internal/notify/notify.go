@@ -0,0 +1,211 @@
+// Package notify delivers pipeline event notifications to outbound sinks
+// (Slack incoming webhooks, generic signed JSON webhooks, and SMTP email).
+// Sinks are fed from pipeline_events by their own poller, with a cursor
+// separate from the Discord poller's.
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+// Event is a sink-agnostic notification built from a pipeline_events row.
+type Event struct {
+	ID        int    `json:"id"`
+	Namespace string `json:"namespace"`
+	Issue     int    `json:"issue"`
+	Title     string `json:"title,omitempty"`
+	Event     string `json:"event"`
+	Stage     string `json:"stage,omitempty"`
+	// FromStage is the stage that just finished for stage_advanced events
+	// (parsed from the "from=<stage>" detail); empty otherwise.
+	FromStage string `json:"from_stage,omitempty"`
+	Attempt   int    `json:"attempt,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// FromPipelineEvent builds an Event from a DB row, enriched with the pipeline
+// title when the state is available.
+func FromPipelineEvent(evt db.PipelineEvent, ps *pipeline.PipelineState) Event {
+	e := Event{
+		ID:        evt.ID,
+		Namespace: evt.Namespace,
+		Issue:     evt.Issue,
+		Event:     evt.Event,
+		Stage:     evt.Stage,
+		Attempt:   evt.Attempt,
+		Detail:    evt.Detail,
+		Timestamp: evt.Timestamp,
+	}
+	if after, ok := strings.CutPrefix(evt.Detail, "from="); ok && evt.Event == "stage_advanced" {
+		e.FromStage = after
+	}
+	if ps != nil {
+		e.Title = ps.Title
+		if e.Namespace == "" {
+			e.Namespace = ps.Namespace
+		}
+	}
+	return e
+}
+
+// Text renders the event as a single human-readable line.
+func (e Event) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#%d", e.Issue)
+	if e.Title != "" {
+		fmt.Fprintf(&sb, " %s", e.Title)
+	}
+	switch e.Event {
+	case "stage_advanced":
+		if e.FromStage != "" {
+			fmt.Fprintf(&sb, ": %s passed, now at %s", e.FromStage, e.Stage)
+		} else {
+			fmt.Fprintf(&sb, ": advanced to %s", e.Stage)
+		}
+	case "escalated":
+		fmt.Fprintf(&sb, ": blocked at %s", e.Stage)
+	default:
+		fmt.Fprintf(&sb, ": %s", e.Event)
+		if e.Stage != "" {
+			fmt.Fprintf(&sb, " at %s", e.Stage)
+		}
+	}
+	if e.Namespace != "" {
+		fmt.Fprintf(&sb, " (%s)", e.Namespace)
+	}
+	if e.Detail != "" && e.FromStage == "" {
+		fmt.Fprintf(&sb, " — %s", e.Detail)
+	}
+	return sb.String()
+}
+
+// Sink delivers a single event to an external system.
+type Sink interface {
+	// Name identifies the sink in logs and dead-letter records.
+	Name() string
+	// Send delivers the event. Returning an error triggers a retry.
+	Send(e Event) error
+}
+
+// Filter selects which events a sink receives. An empty filter accepts the
+// default set: stage_advanced, completed, failed, and escalated.
+//
+// Besides raw pipeline_events names, two aliases are understood:
+// "blocked" matches escalated events, and "merged" matches the
+// stage_advanced event leaving a stage named "merge".
+type Filter []string
+
+var defaultEvents = map[string]bool{
+	"stage_advanced": true,
+	"completed":      true,
+	"failed":         true,
+	"escalated":      true,
+}
+
+// Matches reports whether the filter accepts the event.
+func (f Filter) Matches(e Event) bool {
+	if len(f) == 0 {
+		return defaultEvents[e.Event]
+	}
+	for _, name := range f {
+		switch name {
+		case e.Event:
+			return true
+		case "blocked":
+			if e.Event == "escalated" {
+				return true
+			}
+		case "merged":
+			if e.Event == "stage_advanced" && e.FromStage == "merge" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Route pairs a sink with its event filter.
+type Route struct {
+	Sink   Sink
+	Filter Filter
+}
+
+// RetryPolicy controls delivery retries for a single event.
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts including the first; defaults to 3
+	InitialBackoff time.Duration // delay before the second attempt; doubles each retry; defaults to 1s
+	Sleep          func(time.Duration)
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = time.Second
+	}
+	if p.Sleep == nil {
+		p.Sleep = time.Sleep
+	}
+	return p
+}
+
+// Deliver sends an event through a sink, retrying with exponential backoff.
+// It returns the number of attempts made and the last error, if every attempt failed.
+func Deliver(s Sink, e Event, policy RetryPolicy) (int, error) {
+	policy = policy.withDefaults()
+	backoff := policy.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if err = s.Send(e); err == nil {
+			return attempt, nil
+		}
+		if attempt < policy.MaxAttempts {
+			policy.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return policy.MaxAttempts, err
+}
+
+// DeadLetterStore records events that could not be delivered after all retries.
+type DeadLetterStore interface {
+	NotificationDeadLetterAdd(sink string, eventID int, namespace string, issue int, event string, payload string, lastError string, attempts int) error
+}
+
+// Dispatcher fans events out to all routes whose filters match.
+type Dispatcher struct {
+	Routes     []Route
+	Retry      RetryPolicy
+	DeadLetter DeadLetterStore // optional
+}
+
+// Dispatch delivers an event to every matching route. Failed deliveries are
+// recorded as dead letters; the returned error summarises all failures.
+func (d *Dispatcher) Dispatch(e Event) error {
+	var failures []string
+	for _, r := range d.Routes {
+		if !r.Filter.Matches(e) {
+			continue
+		}
+		attempts, err := Deliver(r.Sink, e, d.Retry)
+		if err == nil {
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", r.Sink.Name(), err))
+		if d.DeadLetter != nil {
+			payload, _ := jsonPayload(e)
+			_ = d.DeadLetter.NotificationDeadLetterAdd(r.Sink.Name(), e.ID, e.Namespace, e.Issue, e.Event, string(payload), err.Error(), attempts)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("notification delivery failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
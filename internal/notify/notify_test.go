@@ -0,0 +1,265 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+func TestFromPipelineEvent(t *testing.T) {
+	evt := db.PipelineEvent{ID: 9, Issue: 42, Event: "stage_advanced", Stage: "review", Detail: "from=implement"}
+	ps := &pipeline.PipelineState{Issue: 42, Title: "Add login", Namespace: "org/app"}
+
+	e := FromPipelineEvent(evt, ps)
+	if e.FromStage != "implement" || e.Title != "Add login" || e.Namespace != "org/app" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if got := e.Text(); got != "#42 Add login: implement passed, now at review (org/app)" {
+		t.Errorf("Text() = %q", got)
+	}
+}
+
+func TestFilter_Matches(t *testing.T) {
+	merged := Event{Event: "stage_advanced", Stage: "done", FromStage: "merge"}
+	advanced := Event{Event: "stage_advanced", Stage: "review", FromStage: "implement"}
+	escalated := Event{Event: "escalated", Stage: "implement"}
+	created := Event{Event: "created"}
+
+	var empty Filter
+	if !empty.Matches(advanced) || !empty.Matches(escalated) || empty.Matches(created) {
+		t.Error("empty filter should accept the default event set only")
+	}
+
+	f := Filter{"blocked", "failed", "merged"}
+	if !f.Matches(escalated) {
+		t.Error("blocked should match escalated")
+	}
+	if !f.Matches(merged) {
+		t.Error("merged should match stage_advanced from merge")
+	}
+	if f.Matches(advanced) {
+		t.Error("merged should not match other stage advances")
+	}
+	if !f.Matches(Event{Event: "failed"}) {
+		t.Error("failed should match failed")
+	}
+}
+
+type fakeSink struct {
+	name  string
+	fails int
+	calls int
+}
+
+func (f *fakeSink) Name() string { return f.name }
+
+func (f *fakeSink) Send(Event) error {
+	f.calls++
+	if f.calls <= f.fails {
+		return errors.New("boom")
+	}
+	return nil
+}
+
+func TestDeliver_RetriesWithBackoff(t *testing.T) {
+	var sleeps []time.Duration
+	policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Second, Sleep: func(d time.Duration) { sleeps = append(sleeps, d) }}
+
+	s := &fakeSink{name: "x", fails: 2}
+	attempts, err := Deliver(s, Event{}, policy)
+	if err != nil || attempts != 3 {
+		t.Fatalf("Deliver = (%d, %v), want (3, nil)", attempts, err)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second}
+	if len(sleeps) != len(want) || sleeps[0] != want[0] || sleeps[1] != want[1] {
+		t.Errorf("sleeps = %v, want %v", sleeps, want)
+	}
+}
+
+type fakeDeadLetters struct {
+	sink     string
+	attempts int
+	payload  string
+}
+
+func (f *fakeDeadLetters) NotificationDeadLetterAdd(sink string, eventID int, namespace string, issue int, event string, payload string, lastError string, attempts int) error {
+	f.sink, f.attempts, f.payload = sink, attempts, payload
+	return nil
+}
+
+func TestDispatch_DeadLettersAfterRetries(t *testing.T) {
+	dl := &fakeDeadLetters{}
+	ok := &fakeSink{name: "ok"}
+	bad := &fakeSink{name: "bad", fails: 100}
+	filtered := &fakeSink{name: "filtered"}
+	d := &Dispatcher{
+		Routes: []Route{
+			{Sink: ok},
+			{Sink: bad},
+			{Sink: filtered, Filter: Filter{"completed"}},
+		},
+		Retry:      RetryPolicy{MaxAttempts: 3, Sleep: func(time.Duration) {}},
+		DeadLetter: dl,
+	}
+
+	err := d.Dispatch(Event{ID: 5, Issue: 1, Event: "failed"})
+	if err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Fatalf("expected error naming the failing sink, got %v", err)
+	}
+	if ok.calls != 1 || bad.calls != 3 || filtered.calls != 0 {
+		t.Errorf("calls ok=%d bad=%d filtered=%d", ok.calls, bad.calls, filtered.calls)
+	}
+	if dl.sink != "bad" || dl.attempts != 3 || !strings.Contains(dl.payload, `"event":"failed"`) {
+		t.Errorf("unexpected dead letter: %+v", dl)
+	}
+}
+
+func TestSlackSink_Send(t *testing.T) {
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	s := &SlackSink{WebhookURL: srv.URL}
+	if err := s.Send(Event{Issue: 3, Event: "failed", Stage: "implement"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if body["text"] != "#3: failed at implement" {
+		t.Errorf("text = %q", body["text"])
+	}
+}
+
+func TestSlackSink_Non2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := (&SlackSink{WebhookURL: srv.URL}).Send(Event{}); err == nil {
+		t.Error("expected error for 500 response")
+	}
+}
+
+func TestWebhookSink_Signed(t *testing.T) {
+	var gotSig, gotEvent string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(SignatureHeader)
+		gotEvent = r.Header.Get(EventHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := &WebhookSink{SinkName: "ops", URL: srv.URL, Secret: "s3cret"}
+	if s.Name() != "webhook:ops" {
+		t.Errorf("Name() = %q", s.Name())
+	}
+	if err := s.Send(Event{ID: 1, Issue: 7, Event: "completed"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if gotEvent != "completed" {
+		t.Errorf("event header = %q", gotEvent)
+	}
+	if want := Sign("s3cret", gotBody); gotSig != want || !strings.HasPrefix(gotSig, "sha256=") {
+		t.Errorf("signature = %q, want %q", gotSig, want)
+	}
+	var decoded Event
+	if err := json.Unmarshal(gotBody, &decoded); err != nil || decoded.Issue != 7 {
+		t.Errorf("body = %s (%v)", gotBody, err)
+	}
+}
+
+func TestWebhookSink_Unsigned(t *testing.T) {
+	var gotSig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(SignatureHeader)
+	}))
+	defer srv.Close()
+
+	if err := (&WebhookSink{URL: srv.URL}).Send(Event{}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if gotSig != "" {
+		t.Errorf("expected no signature without a secret, got %q", gotSig)
+	}
+}
+
+func TestEmailSink_Send(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg string
+	s := &EmailSink{
+		Host: "smtp.example.com",
+		From: "factory@example.com",
+		To:   []string{"a@example.com", "b@example.com"},
+		SendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, string(msg)
+			return nil
+		},
+	}
+	if err := s.Send(Event{Issue: 4, Event: "escalated", Stage: "review"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if gotAddr != "smtp.example.com:587" || gotFrom != "factory@example.com" || len(gotTo) != 2 {
+		t.Errorf("addr=%q from=%q to=%v", gotAddr, gotFrom, gotTo)
+	}
+	if !strings.Contains(gotMsg, "Subject: [factory] #4 escalated (review)\r\n") {
+		t.Errorf("missing subject:\n%s", gotMsg)
+	}
+	if !strings.Contains(gotMsg, "#4: blocked at review") {
+		t.Errorf("missing body:\n%s", gotMsg)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if FromConfig(config.NotificationsConfig{}, nil) != nil {
+		t.Error("expected nil dispatcher with no sinks configured")
+	}
+
+	d := FromConfig(config.NotificationsConfig{
+		Slack:    config.SlackConfig{WebhookURL: "https://hooks.slack.test/x", Events: []string{"failed"}},
+		Webhooks: []config.WebhookConfig{{Name: "ops", URL: "https://ops.test"}, {Name: "no-url"}},
+		Email:    config.EmailConfig{Host: "smtp.test", From: "f@test", To: []string{"t@test"}},
+		Retry:    config.NotifyRetryConfig{MaxAttempts: 5, Backoff: "250ms"},
+	}, nil)
+	if d == nil || len(d.Routes) != 3 {
+		t.Fatalf("expected 3 routes, got %+v", d)
+	}
+	if d.Routes[0].Sink.Name() != "slack" || len(d.Routes[0].Filter) != 1 {
+		t.Errorf("unexpected slack route: %+v", d.Routes[0])
+	}
+	if d.Retry.MaxAttempts != 5 || d.Retry.InitialBackoff != 250*time.Millisecond {
+		t.Errorf("unexpected retry policy: %+v", d.Retry)
+	}
+}
+
+func TestSlackSink_TimesOut(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s := &SlackSink{WebhookURL: srv.URL, Client: &http.Client{Timeout: 50 * time.Millisecond}}
+	if err := s.Send(Event{Issue: 1, Event: "failed"}); err == nil {
+		t.Fatal("expected a timeout error from an unresponsive endpoint")
+	}
+	if defaultClient.Timeout != DefaultTimeout {
+		t.Errorf("default client timeout = %v, want %v", defaultClient.Timeout, DefaultTimeout)
+	}
+}
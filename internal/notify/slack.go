@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SlackSink posts events to a Slack incoming webhook.
+type SlackSink struct {
+	WebhookURL string
+	Client     *http.Client // optional; defaults to a client with DefaultTimeout
+}
+
+// Name returns "slack".
+func (s *SlackSink) Name() string { return "slack" }
+
+// Send posts the event as a plain-text Slack message.
+func (s *SlackSink) Send(e Event) error {
+	data, err := json.Marshal(map[string]string{"text": e.Text()})
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	return postJSON(s.Client, s.WebhookURL, data, nil)
+}
+
+// DefaultTimeout bounds each delivery attempt, so an unresponsive endpoint
+// cannot stall the notification loop.
+const DefaultTimeout = 10 * time.Second
+
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// postJSON POSTs a JSON body and treats any non-2xx status as an error.
+func postJSON(client *http.Client, url string, body []byte, headers map[string]string) error {
+	if client == nil {
+		client = defaultClient
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body)) //nolint:noctx
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return nil
+}
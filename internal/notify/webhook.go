@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body, formatted as
+// "sha256=<hex>", when the webhook has a secret configured.
+const SignatureHeader = "X-Factory-Signature"
+
+// EventHeader carries the pipeline event name.
+const EventHeader = "X-Factory-Event"
+
+// WebhookSink posts the event as JSON to an arbitrary URL.
+type WebhookSink struct {
+	SinkName string // used in logs and dead letters; defaults to "webhook"
+	URL      string
+	Secret   string       // optional; when set, requests are signed
+	Client   *http.Client // optional; defaults to a client with DefaultTimeout
+}
+
+// Name returns the configured sink name.
+func (w *WebhookSink) Name() string {
+	if w.SinkName == "" {
+		return "webhook"
+	}
+	return "webhook:" + w.SinkName
+}
+
+// Send posts the event JSON, signed with the sink secret if present.
+func (w *WebhookSink) Send(e Event) error {
+	body, err := jsonPayload(e)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	headers := map[string]string{EventHeader: e.Event}
+	if w.Secret != "" {
+		headers[SignatureHeader] = Sign(w.Secret, body)
+	}
+	return postJSON(w.Client, w.URL, body, headers)
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by the
+// hex-encoded HMAC-SHA256 keyed with secret. Receivers should recompute it
+// over the raw request body and compare with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func jsonPayload(e Event) ([]byte, error) {
+	return json.Marshal(e)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/lucasnoah/taintfactory/internal/config"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
 	"github.com/lucasnoah/taintfactory/internal/prompt"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
 	"github.com/lucasnoah/taintfactory/internal/session"
 )
 
@@ -290,7 +291,7 @@ func (o *Orchestrator) handleDeployFailure(ds *pipeline.DeployState, stageCfg *c
 	})
 
 	// Check for on_fail routing
-	target := resolveOnFail(stageCfg.OnFail)
+	target := stageCfg.OnFail.Target()
 	if target == "" {
 		// No on_fail configured — mark deploy as failed
 		o.logf("deploy %s: stage %q failed, no on_fail configured", sha7, ds.CurrentStage)
@@ -461,14 +462,16 @@ func (o *Orchestrator) runDeployStage(ds *pipeline.DeployState, stageCfg *config
 		return "", fmt.Errorf("load template %q: %w", stageCfg.PromptTemplate, err)
 	}
 
-	rendered, err := prompt.Render(tmplContent, vars)
+	rendered, err := prompt.RenderWith(tmplContent, vars, prompt.RenderOpts{
+		Partials: prompt.TemplatePartials(stageCfg.PromptTemplate, workdir),
+	})
 	if err != nil {
 		return "", fmt.Errorf("render template: %w", err)
 	}
 
 	// Save rendered prompt to attempt directory
 	_ = o.deployStore.InitStageAttempt(ds.CommitSHA, ds.CurrentStage, ds.CurrentAttempt)
-	_ = o.deployStore.SavePrompt(ds.CommitSHA, ds.CurrentStage, ds.CurrentAttempt, rendered)
+	_ = o.deployStore.SavePrompt(ds.CommitSHA, ds.CurrentStage, ds.CurrentAttempt, o.deployRedactor(ds).Redact(rendered))
 
 	// Determine model and flags
 	model := stageCfg.Model
@@ -526,6 +529,17 @@ func (o *Orchestrator) deployConfig(ds *pipeline.DeployState) (*config.DeployPip
 	return cfg.Deploy, nil
 }
 
+// deployRedactor masks secrets from the pipeline config a deploy was started with.
+func (o *Orchestrator) deployRedactor(ds *pipeline.DeployState) *secrets.Redactor {
+	cfg := o.cfg
+	if ds.ConfigPath != "" {
+		if loaded, err := config.Load(ds.ConfigPath); err == nil {
+			cfg = loaded
+		}
+	}
+	return secrets.RedactorFor(cfg)
+}
+
 // findDeployStage finds a stage by ID in the deploy pipeline config.
 func findDeployStage(stageID string, cfg *config.DeployPipeline) *config.Stage {
 	for i := range cfg.Stages {
@@ -22,8 +22,8 @@ func deployConfigWithFailure() *config.DeployPipeline {
 	return &config.DeployPipeline{
 		Name: "deploy",
 		Stages: []config.Stage{
-			{ID: "deploy", Type: "agent", PromptTemplate: "deploy.md", OnFail: "rollback"},
-			{ID: "smoke-test", Type: "agent", PromptTemplate: "smoke-test.md", OnFail: "rollback"},
+			{ID: "deploy", Type: "agent", PromptTemplate: "deploy.md", OnFail: config.OnFail{"default": "rollback"}},
+			{ID: "smoke-test", Type: "agent", PromptTemplate: "smoke-test.md", OnFail: config.OnFail{"default": "rollback"}},
 			{ID: "rollback", Type: "agent", PromptTemplate: "rollback.md"},
 		},
 	}
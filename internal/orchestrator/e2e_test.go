@@ -378,7 +378,7 @@ func TestE2E_Escalation(t *testing.T) {
 				"lint": {Command: "lint cmd", Parser: "generic"},
 			},
 			Stages: []config.Stage{
-				{ID: "validate", Type: "checks_only", Checks: []string{"lint"}, OnFail: "escalate"},
+				{ID: "validate", Type: "checks_only", Checks: []string{"lint"}, OnFail: config.OnFail{"default": "escalate"}},
 			},
 		},
 	}
@@ -423,7 +423,7 @@ func TestE2E_OnFailRoute(t *testing.T) {
 			},
 			Stages: []config.Stage{
 				{ID: "implement", Type: "checks_only", Checks: []string{"lint"}},
-				{ID: "review", Type: "checks_only", Checks: []string{"lint"}, OnFail: "implement"},
+				{ID: "review", Type: "checks_only", Checks: []string{"lint"}, OnFail: config.OnFail{"default": "implement"}},
 			},
 		},
 	}
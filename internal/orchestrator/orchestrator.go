@@ -1,6 +1,7 @@
 package orchestrator
 
 import (
+	"database/sql"
 	"fmt"
 	"io"
 	"os"
@@ -10,11 +11,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lucasnoah/taintfactory/internal/analytics"
 	"github.com/lucasnoah/taintfactory/internal/config"
 	appctx "github.com/lucasnoah/taintfactory/internal/context"
 	"github.com/lucasnoah/taintfactory/internal/db"
+	"github.com/lucasnoah/taintfactory/internal/dbprov"
 	"github.com/lucasnoah/taintfactory/internal/github"
+	"github.com/lucasnoah/taintfactory/internal/lessons"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
 	"github.com/lucasnoah/taintfactory/internal/session"
 	"github.com/lucasnoah/taintfactory/internal/stage"
 	"github.com/lucasnoah/taintfactory/internal/triage"
@@ -41,6 +46,7 @@ type Orchestrator struct {
 	triageRunner *triage.Runner  // optional; nil if no triage.yaml
 	ghForRepo    func(repoURL string) labelPoller // factory for repo-scoped GitHub clients
 	deployStore  *pipeline.DeployStore            // deploy pipeline state store
+	dbAdmin      *sql.DB                          // postgres maintenance connection; nil = no per-pipeline databases
 	pollTick     int
 	pollInterval int // in number of check-ins; 0 = disabled
 }
@@ -95,6 +101,12 @@ func (o *Orchestrator) SetDeployStore(ds *pipeline.DeployStore) {
 	o.deployStore = ds
 }
 
+// SetDatabaseAdmin configures the admin connection used to clone and drop
+// per-pipeline databases.
+func (o *Orchestrator) SetDatabaseAdmin(conn *sql.DB) {
+	o.dbAdmin = conn
+}
+
 // SetTriageRunner configures an optional triage runner to advance alongside dev pipelines.
 func (o *Orchestrator) SetTriageRunner(r *triage.Runner) {
 	o.triageRunner = r
@@ -112,24 +124,41 @@ type CreateOpts struct {
 	Issue         int
 	FeatureIntent string
 	ConfigPath    string // optional: absolute path to pipeline.yaml for multi-project support
+	BaseBranch    string // optional: branch to start from and merge into; overrides labels and config
 }
 
 // configFor returns the effective config for a pipeline.
 // If ps.ConfigPath is set, loads and returns that config; otherwise returns o.cfg.
+// A per_pipeline database is resolved to the pipeline's own database.
 func (o *Orchestrator) configFor(ps *pipeline.PipelineState) (*config.PipelineConfig, error) {
+	cfg, err := o.repoConfigFor(ps)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.ForIssue(ps.Issue), nil
+}
+
+// repoConfigFor returns the pipeline's config as loaded, without resolving
+// a per_pipeline database to the pipeline's own.
+func (o *Orchestrator) repoConfigFor(ps *pipeline.PipelineState) (*config.PipelineConfig, error) {
 	if ps.ConfigPath == "" {
 		return o.cfg, nil
 	}
 	return config.Load(ps.ConfigPath)
 }
 
-// ghFor returns a GitHub client scoped to the pipeline's repo.
-// If ps.Namespace is set, returns a client with --repo; otherwise returns o.gh.
+// ghFor returns a GitHub client scoped to the pipeline's repo and remote.
+// If ps.Namespace is set, returns a client with --repo; if ps.Remote is set,
+// the client pushes to and rebases from it; otherwise returns o.gh.
 func (o *Orchestrator) ghFor(ps *pipeline.PipelineState) *github.Client {
-	if ps.Namespace == "" {
-		return o.gh
+	gh := o.gh
+	if ps.Namespace != "" {
+		gh = gh.WithRepo(ps.Namespace)
+	}
+	if ps.Remote != "" {
+		gh = gh.WithRemote(ps.Remote)
 	}
-	return o.gh.WithRepo(ps.Namespace)
+	return gh
 }
 
 // worktreeFor returns a worktree.Manager scoped to the pipeline's repo.
@@ -192,17 +221,34 @@ func (o *Orchestrator) Create(opts CreateOpts) (*pipeline.PipelineState, error)
 		return nil, fmt.Errorf("fetch issue: %w", err)
 	}
 
-	// Create worktree
+	// Create worktree from the resolved base branch
+	baseBranch, remote := o.baseFor(opts.BaseBranch, issue.Labels, namespace, cfg)
+	if baseBranch != config.DefaultBaseBranch || remote != config.DefaultRemote {
+		o.logf("pipeline #%d: base branch %s/%s", opts.Issue, remote, baseBranch)
+	}
 	wtResult, err := wt.Create(worktree.CreateOpts{
-		Issue: opts.Issue,
-		Title: issue.Title,
+		Issue:      opts.Issue,
+		Title:      issue.Title,
+		Remote:     remote,
+		BaseBranch: baseBranch,
+		Pool:       poolOpts(cfg),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create worktree: %w", err)
 	}
+	if wtResult.Pooled {
+		o.logf("pipeline #%d: using pooled worktree %s", opts.Issue, wtResult.Path)
+	}
+
+	// Clone the pipeline's own database before setup so migrations run against it
+	if err := o.createPipelineDB(opts.Issue, cfg); err != nil {
+		_ = wt.Remove(opts.Issue, true)
+		return nil, err
+	}
 
 	// Run setup commands in the worktree (e.g. install dependencies)
-	if err := o.runSetupWith(wtResult.Path, cfg); err != nil {
+	if err := o.runSetupWith(wtResult.Path, cfg.ForIssue(opts.Issue), wtResult.SetupDone); err != nil {
+		o.dropPipelineDB(opts.Issue, cfg)
 		_ = wt.Remove(opts.Issue, true)
 		return nil, fmt.Errorf("worktree setup: %w", err)
 	}
@@ -232,9 +278,12 @@ func (o *Orchestrator) Create(opts CreateOpts) (*pipeline.PipelineState, error)
 		ConfigPath: opts.ConfigPath,
 		RepoDir:    repoDir,
 		Namespace:  namespace,
+		BaseBranch: baseBranch,
+		Remote:     remote,
 	})
 	if err != nil {
-		// Clean up orphaned worktree on store failure
+		// Clean up orphaned worktree and database on store failure
+		o.dropPipelineDB(opts.Issue, cfg)
 		_ = wt.Remove(opts.Issue, true)
 		return nil, fmt.Errorf("create pipeline: %w", err)
 	}
@@ -254,6 +303,46 @@ func (o *Orchestrator) Create(opts CreateOpts) (*pipeline.PipelineState, error)
 	return ps, nil
 }
 
+// baseFor resolves the branch and remote a new pipeline starts from. The
+// base branch comes from, in order: the explicit override, a
+// target-branch:<name> label on the issue, the repo's registry entry, and
+// pipeline.base_branch. The remote comes from the registry entry, then
+// pipeline.remote.
+func (o *Orchestrator) baseFor(override string, labels []github.Label, namespace string, cfg *config.PipelineConfig) (string, string) {
+	branch, remote := cfg.Pipeline.BaseBranchName(), cfg.Pipeline.RemoteName()
+	if namespace != "" && o.db != nil {
+		if repo, err := o.db.RepoGetByNamespace(namespace); err == nil {
+			if repo.BaseBranch != "" {
+				branch = repo.BaseBranch
+			}
+			if repo.Remote != "" {
+				remote = repo.Remote
+			}
+		}
+	}
+	if label := targetBranchLabel(labels); label != "" {
+		branch = label
+	}
+	if override != "" {
+		branch = override
+	}
+	return branch, remote
+}
+
+// targetBranchLabel returns the branch named by a target-branch:<name> label,
+// or "" when there is none or the name is not a valid branch.
+func targetBranchLabel(labels []github.Label) string {
+	for _, l := range labels {
+		if name, ok := strings.CutPrefix(l.Name, config.TargetBranchLabel); ok {
+			name = strings.TrimSpace(name)
+			if config.ValidGitName(name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
 // AdvanceResult describes what happened during an advance.
 type AdvanceResult struct {
 	Issue       int    `json:"issue"`
@@ -303,12 +392,7 @@ func (o *Orchestrator) Advance(issue int) (*AdvanceResult, error) {
 	}
 
 	// Determine timeout from per-pipeline config
-	timeout := 30 * time.Minute
-	if cfg.Pipeline.Defaults.Timeout != "" {
-		if d, err := time.ParseDuration(cfg.Pipeline.Defaults.Timeout); err == nil {
-			timeout = d
-		}
-	}
+	timeout := stageTimeout(cfg, stageCfg)
 
 	// Run the stage lifecycle
 	var runResult *stage.RunResult
@@ -362,12 +446,50 @@ func (o *Orchestrator) Advance(issue int) (*AdvanceResult, error) {
 		return nil, fmt.Errorf("record stage history: %w", err)
 	}
 
-	// Checkpoint the stage outcome
+	_ = o.store.SaveStageSummary(issue, currentStage, currentAttempt, &pipeline.StageSummary{
+		Stage:           runResult.Stage,
+		Attempt:         runResult.Attempt,
+		Outcome:         runResult.Outcome,
+		AgentDuration:   runResult.AgentDuration.String(),
+		TotalDuration:   runResult.TotalDuration.String(),
+		FixRounds:       runResult.FixRounds,
+		ChecksFirstPass: runResult.ChecksFirstPass,
+		AutoFixes:       runResult.AutoFixes,
+		AgentFixes:      runResult.AgentFixes,
+		FinalCheckState: runResult.FinalCheckState,
+		Variant:         runResult.Variant,
+		Candidate:       runResult.Candidate,
+	})
+	if runResult.Variant != "" {
+		_ = o.db.LogPipelineEvent(ps.Namespace, issue, "prompt_variant", currentStage, currentAttempt, analytics.FormatVariantDetail(analytics.VariantRun{
+			Variant:   runResult.Variant,
+			Template:  variantTemplate(stageCfg, runResult.Variant),
+			Outcome:   runResult.Outcome,
+			FixRounds: runResult.FixRounds,
+			FirstPass: runResult.ChecksFirstPass,
+			Duration:  runResult.TotalDuration,
+		}))
+	}
+
+	// Harvest lessons before the checkpoint replaces the agent's outcome
+	o.harvestLessons(ps, currentStage, currentAttempt, runResult, cfg)
+
+	// Checkpoint the stage outcome; panel stages carry their verdict and findings
+	summary := runResult.Summary
+	if summary == "" {
+		summary = formatCheckStateSummary(runResult.FinalCheckState)
+	}
 	_ = o.builder.Checkpoint(issue, currentStage, currentAttempt, appctx.CheckpointOpts{
-		Status:  runResult.Outcome,
-		Summary: formatCheckStateSummary(runResult.FinalCheckState),
+		Status:   runResult.Outcome,
+		Summary:  summary,
+		Findings: runResult.Findings,
 	})
 
+	// A stage that passed but needs sign-off waits for `factory pipeline approve`
+	if runResult.Outcome == "success" && runResult.NeedsApproval != "" {
+		return o.awaitApproval(ps.Namespace, issue, currentStage, currentAttempt, runResult.NeedsApproval)
+	}
+
 	// Update goal gate if applicable
 	if stageCfg.GoalGate && runResult.Outcome == "success" {
 		if err := o.store.Update(issue, func(ps *pipeline.PipelineState) {
@@ -393,6 +515,44 @@ func (o *Orchestrator) Advance(issue int) (*AdvanceResult, error) {
 	return o.handleStageFailure(ps.Namespace, issue, currentStage, currentAttempt, stageCfg, runResult, cfg)
 }
 
+// variantTemplate returns the template of the named prompt variant.
+func variantTemplate(stageCfg *config.Stage, name string) string {
+	for _, v := range stageCfg.PromptVariants {
+		if v.VariantName() == name {
+			return v.Template
+		}
+	}
+	return ""
+}
+
+// harvestLessons records the check failures, review findings and human steer
+// messages of a finished stage attempt as lesson candidates for the
+// namespace. Failures are ignored; lessons are best effort.
+func (o *Orchestrator) harvestLessons(ps *pipeline.PipelineState, stageID string, attempt int, runResult *stage.RunResult, cfg *config.PipelineConfig) {
+	if o.db == nil || cfg.Pipeline.Lessons.Disabled {
+		return
+	}
+	var found []db.LessonRecord
+	if runs, err := o.db.GetAttemptCheckRuns(ps.Namespace, ps.Issue, stageID, attempt); err == nil {
+		found = append(found, lessons.FromCheckRuns(ps.Namespace, runs)...)
+	}
+	if len(runResult.Findings) > 0 {
+		found = append(found, lessons.FromFindings(ps.Namespace, runResult.Findings)...)
+	} else if strings.Contains(stageID, "review") {
+		if outcome, err := o.store.GetStageOutcome(ps.Issue, stageID, attempt); err == nil && outcome != nil {
+			found = append(found, lessons.FromFindings(ps.Namespace, outcome.Findings)...)
+		}
+	}
+	if runResult.Session != "" {
+		if msgs, err := o.db.GetSteerMessages(runResult.Session); err == nil {
+			found = append(found, lessons.FromSteers(ps.Namespace, stageID, msgs)...)
+		}
+	}
+	for _, l := range found {
+		_ = o.db.LessonObserve(l, ps.Issue)
+	}
+}
+
 // advanceToNextStage moves the pipeline to the next stage or completes it.
 // stageCfg is the config of the just-completed stage; it is used to skip
 // the on_fail fallback stage when a merge stage succeeds (the fallback is
@@ -404,7 +564,7 @@ func (o *Orchestrator) advanceToNextStage(namespace string, issue int, currentSt
 	// agent-merge) so the pipeline proceeds directly to the post-merge stage
 	// (e.g. contract-check) or completes if no further stages remain.
 	if stageCfg != nil && stageCfg.Type == "merge" && nextStage != "" {
-		if onFailTarget := resolveOnFail(stageCfg.OnFail); nextStage == onFailTarget {
+		if onFailTarget := stageCfg.OnFail.Target(); nextStage == onFailTarget {
 			nextStage = o.nextStageID(nextStage, cfg)
 		}
 	}
@@ -475,10 +635,30 @@ func (o *Orchestrator) advanceToNextStage(namespace string, issue int, currentSt
 	}, nil
 }
 
+// awaitApproval blocks a stage that passed but needs a human sign-off.
+// Approve moves the pipeline on once someone has looked.
+func (o *Orchestrator) awaitApproval(namespace string, issue int, currentStage string, currentAttempt int, reason string) (*AdvanceResult, error) {
+	if err := o.store.Update(issue, func(ps *pipeline.PipelineState) {
+		ps.Status = "blocked"
+	}); err != nil {
+		return nil, fmt.Errorf("update blocked status: %w", err)
+	}
+	o.logf("pipeline #%d: %s needs approval: %s", issue, currentStage, reason)
+	_ = o.db.LogPipelineEvent(namespace, issue, "escalated", currentStage, currentAttempt, "approval required: "+reason)
+
+	return &AdvanceResult{
+		Issue:   issue,
+		Action:  "escalated",
+		Stage:   currentStage,
+		Outcome: "success",
+		Message: fmt.Sprintf("approval required: %s; run `factory pipeline approve %d %s`", reason, issue, currentStage),
+	}, nil
+}
+
 // handleStageFailure routes the pipeline based on on_fail config.
 // Uses captured values from the start of Advance() to avoid stale-snapshot issues.
 func (o *Orchestrator) handleStageFailure(namespace string, issue int, currentStage string, currentAttempt int, stageCfg *config.Stage, runResult *stage.RunResult, cfg *config.PipelineConfig) (*AdvanceResult, error) {
-	target := resolveOnFail(stageCfg.OnFail)
+	target := stageCfg.OnFail.Target()
 
 	if target == "escalate" {
 		if err := o.store.Update(issue, func(ps *pipeline.PipelineState) {
@@ -608,6 +788,74 @@ func (o *Orchestrator) Retry(opts RetryOpts) error {
 	return nil
 }
 
+// ApproveOpts holds options for approving a stage.
+type ApproveOpts struct {
+	Issue  int
+	Stage  string
+	Reason string
+}
+
+// Approve records a human sign-off for the pipeline's current stage and
+// advances past it. It is the manual way out of an escalated (blocked) stage:
+// the stage is recorded as "approved" in history, satisfies its goal gate,
+// and the pipeline moves on exactly as if the stage had succeeded.
+func (o *Orchestrator) Approve(opts ApproveOpts) (*AdvanceResult, error) {
+	ps, err := o.store.Get(opts.Issue)
+	if err != nil {
+		return nil, fmt.Errorf("get pipeline: %w", err)
+	}
+
+	if ps.Status == "completed" {
+		return nil, fmt.Errorf("pipeline %d is already completed", opts.Issue)
+	}
+	if ps.Status == "failed" {
+		return nil, fmt.Errorf("pipeline %d has failed; retry it instead", opts.Issue)
+	}
+	if ps.CurrentStage != opts.Stage {
+		return nil, fmt.Errorf("pipeline %d is at stage %q, not %q", opts.Issue, ps.CurrentStage, opts.Stage)
+	}
+	if ps.Status == "in_progress" && ps.CurrentSession != "" {
+		return nil, fmt.Errorf("pipeline %d has an active session %s; wait for it to finish", opts.Issue, ps.CurrentSession)
+	}
+
+	cfg, err := o.configFor(ps)
+	if err != nil {
+		return nil, fmt.Errorf("load pipeline config: %w", err)
+	}
+	stageCfg := o.findStage(opts.Stage, cfg)
+	if stageCfg == nil {
+		return nil, fmt.Errorf("stage %q not found in config", opts.Stage)
+	}
+
+	if err := o.store.Update(opts.Issue, func(ps *pipeline.PipelineState) {
+		ps.StageHistory = append(ps.StageHistory, pipeline.StageHistoryEntry{
+			Stage:   opts.Stage,
+			Attempt: ps.CurrentAttempt,
+			Outcome: "approved",
+		})
+		if stageCfg.GoalGate {
+			if ps.GoalGates == nil {
+				ps.GoalGates = make(map[string]string)
+			}
+			ps.GoalGates[opts.Stage] = "success"
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("record approval: %w", err)
+	}
+
+	detail := "manual"
+	if opts.Reason != "" {
+		detail = fmt.Sprintf("manual: %s", opts.Reason)
+	}
+	_ = o.db.LogPipelineEvent(ps.Namespace, opts.Issue, "approved", opts.Stage, ps.CurrentAttempt, detail)
+
+	return o.advanceToNextStage(ps.Namespace, opts.Issue, opts.Stage, stageCfg, &stage.RunResult{
+		Stage:   opts.Stage,
+		Attempt: ps.CurrentAttempt,
+		Outcome: "success",
+	}, cfg)
+}
+
 // FailOpts holds options for failing a pipeline.
 type FailOpts struct {
 	Issue  int
@@ -940,10 +1188,8 @@ func (o *Orchestrator) checkInPipeline(ps *pipeline.PipelineState) CheckInAction
 // handleActiveSession decides what to do with a running session.
 func (o *Orchestrator) handleActiveSession(ps *pipeline.PipelineState) CheckInAction {
 	timeout := 30 * time.Minute
-	if cfg, err := o.configFor(ps); err == nil && cfg.Pipeline.Defaults.Timeout != "" {
-		if d, err := time.ParseDuration(cfg.Pipeline.Defaults.Timeout); err == nil {
-			timeout = d
-		}
+	if cfg, err := o.configFor(ps); err == nil {
+		timeout = stageTimeout(cfg, o.findStage(ps.CurrentStage, cfg))
 	}
 
 	// Use the session's original "started" timestamp for timeout comparison,
@@ -1138,16 +1384,15 @@ func (o *Orchestrator) processQueue() *CheckInAction {
 }
 
 // setupEnv builds the environment for setup and migrate commands.
-// It merges os.Environ() with pipeline env vars and auto DATABASE_URL.
-func (o *Orchestrator) setupEnv(cfg *config.PipelineConfig) []string {
+// It merges os.Environ() with the resolved pipeline env (env_file, pipeline.env
+// with secret:// references, auto DATABASE_URL).
+func (o *Orchestrator) setupEnv(cfg *config.PipelineConfig) ([]string, error) {
 	env := os.Environ()
-	extra := make(map[string]string)
-	for k, v := range cfg.Pipeline.Env {
-		extra[k] = v
-	}
-	if cfg.Pipeline.Database != nil {
-		extra["DATABASE_URL"] = cfg.Pipeline.Database.URL()
+	resolved, err := secrets.Resolve(cfg, secrets.DefaultStore())
+	if err != nil {
+		return nil, err
 	}
+	extra := resolved.Vars
 	// Append extra vars (sorted for determinism)
 	keys := make([]string, 0, len(extra))
 	for k := range extra {
@@ -1157,21 +1402,52 @@ func (o *Orchestrator) setupEnv(cfg *config.PipelineConfig) []string {
 	for _, k := range keys {
 		env = append(env, fmt.Sprintf("%s=%s", k, extra[k]))
 	}
-	return env
+	return env, nil
 }
 
-// runSetupWith runs the pipeline.setup commands from cfg inside the worktree directory.
-func (o *Orchestrator) runSetupWith(worktreePath string, cfg *config.PipelineConfig) error {
-	env := o.setupEnv(cfg)
-	for _, cmdStr := range cfg.Pipeline.Setup {
-		o.logf("setup: running %q in %s", cmdStr, worktreePath)
-		cmd := exec.Command("sh", "-c", cmdStr)
-		cmd.Dir = worktreePath
-		cmd.Env = env
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("command %q failed: %s: %w", cmdStr, strings.TrimSpace(string(out)), err)
-		}
+// createPipelineDB clones the issue's database from the repo template when
+// cfg uses per_pipeline database isolation.
+func (o *Orchestrator) createPipelineDB(issue int, cfg *config.PipelineConfig) error {
+	dbCfg := cfg.Pipeline.Database
+	if !dbCfg.PerPipeline() {
+		return nil
+	}
+	if o.dbAdmin == nil {
+		return fmt.Errorf("per_pipeline database isolation needs an admin connection (set DATABASE_URL)")
+	}
+	o.logf("setup: cloning database %s from %s", dbCfg.PipelineName(issue), dbCfg.TemplateName())
+	if err := dbprov.Clone(o.dbAdmin, dbCfg, issue); err != nil {
+		return fmt.Errorf("clone database: %w", err)
+	}
+	return nil
+}
+
+// dropPipelineDB drops the issue's database when cfg uses per_pipeline
+// isolation. Failures are logged; `factory repo db-gc` removes leftovers.
+func (o *Orchestrator) dropPipelineDB(issue int, cfg *config.PipelineConfig) {
+	dbCfg := cfg.Pipeline.Database
+	if !dbCfg.PerPipeline() || o.dbAdmin == nil {
+		return
+	}
+	if err := dbprov.Drop(o.dbAdmin, dbCfg, issue); err != nil {
+		o.logf("pipeline #%d: warning: drop database %s: %v", issue, dbCfg.PipelineName(issue), err)
+		return
+	}
+	o.logf("pipeline #%d: dropped database %s", issue, dbCfg.PipelineName(issue))
+}
+
+// runSetupWith runs the pipeline.setup commands from cfg inside the worktree
+// directory, then the database migration. warm skips the setup commands for a
+// pooled worktree that was already set up with the same lockfiles.
+func (o *Orchestrator) runSetupWith(worktreePath string, cfg *config.PipelineConfig, warm bool) error {
+	env, err := o.setupEnv(cfg)
+	if err != nil {
+		return fmt.Errorf("resolve env: %w", err)
+	}
+	if warm {
+		o.logf("setup: lockfiles unchanged in pooled worktree %s, skipping setup commands", worktreePath)
+	} else if err := o.runSetupCommands(worktreePath, cfg, env); err != nil {
+		return err
 	}
 	// Run database migration if configured
 	if cfg.Pipeline.Database != nil && cfg.Pipeline.Database.Migrate != "" {
@@ -1187,6 +1463,21 @@ func (o *Orchestrator) runSetupWith(worktreePath string, cfg *config.PipelineCon
 	return nil
 }
 
+// runSetupCommands runs the pipeline.setup commands from cfg in dir.
+func (o *Orchestrator) runSetupCommands(dir string, cfg *config.PipelineConfig, env []string) error {
+	for _, cmdStr := range cfg.Pipeline.Setup {
+		o.logf("setup: running %q in %s", cmdStr, dir)
+		cmd := exec.Command("sh", "-c", cmdStr)
+		cmd.Dir = dir
+		cmd.Env = env
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("command %q failed: %s: %w", cmdStr, strings.TrimSpace(string(out)), err)
+		}
+	}
+	return nil
+}
+
 // runMerge handles the merge stage: push branch, create PR, merge PR.
 func (o *Orchestrator) runMerge(issue int, ps *pipeline.PipelineState, stageCfg *config.Stage) (*stage.RunResult, error) {
 	start := time.Now()
@@ -1204,11 +1495,11 @@ func (o *Orchestrator) runMerge(issue int, ps *pipeline.PipelineState, stageCfg
 		AgentFixes:      make(map[string]int),
 	}
 
-	// Rebase onto main before pushing to surface divergence early.
+	// Rebase onto the base branch before pushing to surface divergence early.
 	// This handles the common case where other PRs merged after this branch
 	// was cut, causing a "both added" or content conflict at gh pr merge time.
-	o.logf("rebasing %s onto origin/main", ps.Branch)
-	conflicted, rebaseErr := gh.RebaseOntoMain(ps.Worktree)
+	o.logf("rebasing %s onto %s", ps.Branch, ps.BaseRef())
+	conflicted, rebaseErr := gh.RebaseOnto(ps.Worktree, ps.BaseBranchName())
 	if rebaseErr != nil {
 		o.logf("rebase failed: %v", rebaseErr)
 		result.Outcome = "fail"
@@ -1216,7 +1507,7 @@ func (o *Orchestrator) runMerge(issue int, ps *pipeline.PipelineState, stageCfg
 		return result, nil
 	}
 	if conflicted {
-		o.logf("merge conflicts detected during rebase onto origin/main; manual resolution required")
+		o.logf("merge conflicts detected during rebase onto %s; manual resolution required", ps.BaseRef())
 		result.Outcome = "fail"
 		result.TotalDuration = time.Since(start)
 		return result, nil
@@ -1251,6 +1542,7 @@ func (o *Orchestrator) runMerge(issue int, ps *pipeline.PipelineState, stageCfg
 			Title:  prTitle,
 			Body:   prBody,
 			Branch: ps.Branch,
+			Base:   ps.BaseBranchName(),
 		})
 		if err != nil {
 			o.logf("create PR failed: %v", err)
@@ -1321,6 +1613,11 @@ func (o *Orchestrator) Cleanup(issue int) (*CleanupResult, error) {
 		}
 	}
 
+	// Drop the pipeline's own database
+	if cfg, err := o.repoConfigFor(ps); err == nil {
+		o.dropPipelineDB(issue, cfg)
+	}
+
 	// Remove pipeline data from disk
 	if err := o.store.Delete(issue); err != nil {
 		return nil, fmt.Errorf("delete pipeline data: %w", err)
@@ -1373,9 +1670,11 @@ func (o *Orchestrator) preparePostMerge(issue int, ps *pipeline.PipelineState) {
 
 	dependents, err := o.db.QueueDependents(ps.Namespace, issue)
 	var depText string
+	var depList []pipeline.DependentIssue
 	if err == nil && len(dependents) > 0 {
 		var sb strings.Builder
 		for _, dep := range dependents {
+			depList = append(depList, pipeline.DependentIssue{Issue: dep.Issue, FeatureIntent: dep.FeatureIntent})
 			if dep.FeatureIntent != "" {
 				fmt.Fprintf(&sb, "- #%d: %s\n", dep.Issue, dep.FeatureIntent)
 			} else {
@@ -1390,6 +1689,7 @@ func (o *Orchestrator) preparePostMerge(issue int, ps *pipeline.PipelineState) {
 			p.RuntimeVars = make(map[string]string)
 		}
 		p.RuntimeVars["dependent_issues"] = depText
+		p.DependentIssues = depList
 		p.Worktree = repoRoot
 	})
 }
@@ -1399,7 +1699,7 @@ func (o *Orchestrator) preparePostMerge(issue int, ps *pipeline.PipelineState) {
 func (o *Orchestrator) findMergeOnFailTarget(cfg *config.PipelineConfig) string {
 	for _, s := range cfg.Pipeline.Stages {
 		if s.Type == "merge" {
-			return resolveOnFail(s.OnFail)
+			return s.OnFail.Target()
 		}
 	}
 	return ""
@@ -1415,6 +1715,20 @@ func (o *Orchestrator) findStage(stageID string, cfg *config.PipelineConfig) *co
 	return nil
 }
 
+// stageTimeout returns the stage's own timeout, else defaults.timeout, else 30m.
+func stageTimeout(cfg *config.PipelineConfig, stageCfg *config.Stage) time.Duration {
+	candidates := []string{cfg.Pipeline.Defaults.Timeout}
+	if stageCfg != nil {
+		candidates = append([]string{stageCfg.Timeout}, candidates...)
+	}
+	for _, v := range candidates {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return 30 * time.Minute
+}
+
 // nextStageID returns the stage ID after the given one in cfg, or "" if last.
 func (o *Orchestrator) nextStageID(currentID string, cfg *config.PipelineConfig) string {
 	for i, s := range cfg.Pipeline.Stages {
@@ -1443,33 +1757,6 @@ func (o *Orchestrator) checkGoalGates(issue int, cfg *config.PipelineConfig) err
 	return nil
 }
 
-// resolveOnFail determines the target stage from on_fail config.
-func resolveOnFail(onFail interface{}) string {
-	if onFail == nil {
-		return "" // retry same stage
-	}
-	if s, ok := onFail.(string); ok {
-		return s
-	}
-	if m, ok := onFail.(map[string]interface{}); ok {
-		// Check for "default" key
-		if def, ok := m["default"]; ok {
-			if s, ok := def.(string); ok {
-				return s
-			}
-		}
-	}
-	// YAML maps may parse as map[interface{}]interface{}
-	if m, ok := onFail.(map[interface{}]interface{}); ok {
-		if def, ok := m["default"]; ok {
-			if s, ok := def.(string); ok {
-				return s
-			}
-		}
-	}
-	return ""
-}
-
 // formatCheckStateSummary formats a check state map into a readable string.
 func formatCheckStateSummary(state map[string]string) string {
 	if len(state) == 0 {
@@ -102,10 +102,10 @@ func (m *mockCheckCmd) Run(ctx context.Context, dir string, command string) (str
 
 type mockContextGit struct{}
 
-func (m *mockContextGit) Diff(dir string) (string, error)        { return "", nil }
-func (m *mockContextGit) DiffSummary(dir string) (string, error)  { return "", nil }
-func (m *mockContextGit) FilesChanged(dir string) (string, error) { return "", nil }
-func (m *mockContextGit) Log(dir string) (string, error)          { return "", nil }
+func (m *mockContextGit) Diff(dir, base string) (string, error)         { return "", nil }
+func (m *mockContextGit) DiffSummary(dir, base string) (string, error)  { return "", nil }
+func (m *mockContextGit) FilesChanged(dir, base string) (string, error) { return "", nil }
+func (m *mockContextGit) Log(dir, base string) (string, error)          { return "", nil }
 
 // --- Test helpers ---
 
@@ -433,7 +433,7 @@ func TestAdvance_OnFail_Escalate(t *testing.T) {
 				"lint": {Command: "lint", Parser: "generic"},
 			},
 			Stages: []config.Stage{
-				{ID: "validate", Type: "checks_only", Checks: []string{"lint"}, OnFail: "escalate"},
+				{ID: "validate", Type: "checks_only", Checks: []string{"lint"}, OnFail: config.OnFail{"default": "escalate"}},
 			},
 		},
 	}
@@ -469,7 +469,7 @@ func TestAdvance_OnFail_RouteToStage(t *testing.T) {
 			},
 			Stages: []config.Stage{
 				{ID: "implement", Type: "checks_only", Checks: []string{"lint"}},
-				{ID: "review", Type: "checks_only", Checks: []string{"lint"}, OnFail: "implement"},
+				{ID: "review", Type: "checks_only", Checks: []string{"lint"}, OnFail: config.OnFail{"default": "implement"}},
 			},
 		},
 	}
@@ -589,6 +589,62 @@ func TestRetry(t *testing.T) {
 	}
 }
 
+func TestApprove_BlockedStage(t *testing.T) {
+	env := setupTest(t, defaultConfig())
+
+	worktreeDir := t.TempDir()
+	env.store.Create(pipeline.CreateOpts{Issue: 42, Title: "Test", Branch: "feature/test", Worktree: worktreeDir, FirstStage: "review", GoalGates: nil})
+	env.store.Update(42, func(ps *pipeline.PipelineState) {
+		ps.CurrentAttempt = 2
+		ps.Status = "blocked"
+	})
+
+	result, err := env.orch.Approve(ApproveOpts{Issue: 42, Stage: "review", Reason: "looks fine"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Action != "advanced" || result.NextStage != "qa" {
+		t.Errorf("expected advance to qa, got %+v", result)
+	}
+
+	ps, _ := env.store.Get(42)
+	if ps.CurrentStage != "qa" || ps.Status != "pending" {
+		t.Errorf("expected pending at qa, got %q at %q", ps.Status, ps.CurrentStage)
+	}
+	if ps.GoalGates["review"] != "success" {
+		t.Errorf("expected review goal gate satisfied, got %v", ps.GoalGates)
+	}
+	last := ps.StageHistory[len(ps.StageHistory)-1]
+	if last.Stage != "review" || last.Outcome != "approved" || last.Attempt != 2 {
+		t.Errorf("unexpected history entry: %+v", last)
+	}
+}
+
+func TestApprove_WrongStage(t *testing.T) {
+	env := setupTest(t, defaultConfig())
+
+	worktreeDir := t.TempDir()
+	env.store.Create(pipeline.CreateOpts{Issue: 42, Title: "Test", Branch: "feature/test", Worktree: worktreeDir, FirstStage: "implement", GoalGates: nil})
+
+	if _, err := env.orch.Approve(ApproveOpts{Issue: 42, Stage: "review"}); err == nil {
+		t.Fatal("expected error approving a stage the pipeline is not at")
+	}
+}
+
+func TestApprove_FailedPipeline(t *testing.T) {
+	env := setupTest(t, defaultConfig())
+
+	worktreeDir := t.TempDir()
+	env.store.Create(pipeline.CreateOpts{Issue: 42, Title: "Test", Branch: "feature/test", Worktree: worktreeDir, FirstStage: "review", GoalGates: nil})
+	env.store.Update(42, func(ps *pipeline.PipelineState) {
+		ps.Status = "failed"
+	})
+
+	if _, err := env.orch.Approve(ApproveOpts{Issue: 42, Stage: "review"}); err == nil {
+		t.Fatal("expected error approving a failed pipeline")
+	}
+}
+
 func TestRetry_CompletedPipeline(t *testing.T) {
 	env := setupTest(t, defaultConfig())
 
@@ -691,7 +747,7 @@ func TestAdvance_OnFail_InvalidTarget(t *testing.T) {
 				"lint": {Command: "lint", Parser: "generic"},
 			},
 			Stages: []config.Stage{
-				{ID: "validate", Type: "checks_only", Checks: []string{"lint"}, OnFail: "nonexistent_stage"},
+				{ID: "validate", Type: "checks_only", Checks: []string{"lint"}, OnFail: config.OnFail{"default": "nonexistent_stage"}},
 			},
 		},
 	}
@@ -735,29 +791,6 @@ func TestRetry_BlockedPipeline(t *testing.T) {
 	}
 }
 
-func TestResolveOnFail(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    interface{}
-		expected string
-	}{
-		{"nil", nil, ""},
-		{"string", "implement", "implement"},
-		{"escalate", "escalate", "escalate"},
-		{"map with default", map[string]interface{}{"default": "targeted_fix"}, "targeted_fix"},
-		{"empty map", map[string]interface{}{}, ""},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			got := resolveOnFail(tc.input)
-			if got != tc.expected {
-				t.Errorf("resolveOnFail(%v) = %q, want %q", tc.input, got, tc.expected)
-			}
-		})
-	}
-}
-
 func TestNextStageID(t *testing.T) {
 	cfg := defaultConfig()
 	env := setupTest(t, cfg)
@@ -1758,7 +1791,7 @@ func TestAdvance_MergeStage_CreatePRFails(t *testing.T) {
 	cfg := &config.PipelineConfig{
 		Pipeline: config.Pipeline{
 			Stages: []config.Stage{
-				{ID: "merge", Type: "merge", OnFail: "escalate"},
+				{ID: "merge", Type: "merge", OnFail: config.OnFail{"default": "escalate"}},
 			},
 		},
 	}
@@ -2205,3 +2238,42 @@ func TestProcessQueue_PassesConfigPath(t *testing.T) {
 		t.Errorf("pipeline.Namespace = %q, want 'myorg/myapp'", ps.Namespace)
 	}
 }
+
+func TestTargetBranchLabel(t *testing.T) {
+	cases := []struct {
+		labels []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{"bug", "factory"}, ""},
+		{[]string{"bug", "target-branch:release-2.3"}, "release-2.3"},
+		{[]string{"target-branch: develop"}, "develop"},
+		{[]string{"target-branch:--force"}, ""},
+		{[]string{"target-branch:"}, ""},
+	}
+	for _, tc := range cases {
+		var labels []github.Label
+		for _, name := range tc.labels {
+			labels = append(labels, github.Label{Name: name})
+		}
+		if got := targetBranchLabel(labels); got != tc.want {
+			t.Errorf("targetBranchLabel(%v) = %q, want %q", tc.labels, got, tc.want)
+		}
+	}
+}
+
+func TestBaseFor_Precedence(t *testing.T) {
+	o := &Orchestrator{}
+	cfg := &config.PipelineConfig{Pipeline: config.Pipeline{BaseBranch: "develop", Remote: "upstream"}}
+
+	if b, r := o.baseFor("", nil, "", cfg); b != "develop" || r != "upstream" {
+		t.Errorf("config: got %q %q", b, r)
+	}
+	labels := []github.Label{{Name: "target-branch:release-2.3"}}
+	if b, _ := o.baseFor("", labels, "", cfg); b != "release-2.3" {
+		t.Errorf("label: got %q", b)
+	}
+	if b, _ := o.baseFor("hotfix", labels, "", cfg); b != "hotfix" {
+		t.Errorf("override: got %q", b)
+	}
+}
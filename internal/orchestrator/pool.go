@@ -0,0 +1,179 @@
+package orchestrator
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/worktree"
+)
+
+// poolFetchEvery is the least time between fetches of a pool's base branch.
+const poolFetchEvery = time.Minute
+
+// poolTarget is one repo whose worktree pool the orchestrator maintains.
+type poolTarget struct {
+	namespace string
+	cfg       *config.PipelineConfig
+	wt        *worktree.Manager
+	opts      worktree.PoolOpts
+}
+
+// poolOpts returns cfg's worktree pool settings, or nil when it has no pool.
+// Remote and Branch are left for the caller to fill in.
+func poolOpts(cfg *config.PipelineConfig) *worktree.PoolOpts {
+	size := cfg.Pipeline.WorktreePool.PoolSize()
+	if size == 0 {
+		return nil
+	}
+	return &worktree.PoolOpts{Size: size, Lockfiles: cfg.Pipeline.WorktreePool.LockfileList()}
+}
+
+// poolTarget returns the pool of namespace's base branch, or of the default
+// pipeline config when namespace is "". Pipelines created with a different
+// base branch do not draw from it.
+func (o *Orchestrator) poolTarget(namespace string) (*poolTarget, error) {
+	if o.wt == nil {
+		return nil, fmt.Errorf("no worktree manager configured")
+	}
+	cfg, wt := o.cfg, o.wt
+	if namespace != "" {
+		if o.db == nil {
+			return nil, fmt.Errorf("no database configured")
+		}
+		repo, err := o.db.RepoGetByNamespace(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("lookup repo %s: %w", namespace, err)
+		}
+		if repo.ConfigPath == "" {
+			return nil, fmt.Errorf("repo %s has no config path", namespace)
+		}
+		if cfg, err = config.Load(repo.ConfigPath); err != nil {
+			return nil, fmt.Errorf("load pipeline config %s: %w", repo.ConfigPath, err)
+		}
+		wt = o.wt.WithRepoDir(filepath.Dir(repo.ConfigPath))
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("no pipeline config")
+	}
+	// A pool with size 0 is still returned so its leftover slots can be
+	// drained after it is disabled.
+	opts := worktree.PoolOpts{
+		Size:       cfg.Pipeline.WorktreePool.PoolSize(),
+		Lockfiles:  cfg.Pipeline.WorktreePool.LockfileList(),
+		FetchEvery: poolFetchEvery,
+	}
+	opts.Branch, opts.Remote = o.baseFor("", nil, namespace, cfg)
+	return &poolTarget{namespace: namespace, cfg: cfg, wt: wt, opts: opts}, nil
+}
+
+// poolTargets returns every enabled pool: the default config's and those of
+// the active registered repos.
+func (o *Orchestrator) poolTargets() []*poolTarget {
+	var targets []*poolTarget
+	defaultNS := ""
+	if t, err := o.poolTarget(""); err == nil && t.opts.Size > 0 {
+		targets = append(targets, t)
+		defaultNS = namespaceFromRepo(o.cfg.Pipeline.Repo)
+	}
+	if o.db == nil {
+		return targets
+	}
+	repos, err := o.db.RepoList()
+	if err != nil {
+		return targets
+	}
+	for _, r := range repos {
+		if !r.Active || r.ConfigPath == "" {
+			continue
+		}
+		if r.Namespace == defaultNS {
+			continue // already the default config's pool
+		}
+		if t, err := o.poolTarget(r.Namespace); err == nil && t.opts.Size > 0 {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// poolSetup runs pipeline.setup in a pooled worktree. Unlike setup for a
+// pipeline it does not migrate: the database is the pipeline's own.
+func (o *Orchestrator) poolSetup(cfg *config.PipelineConfig) worktree.SetupFunc {
+	return func(dir string) error {
+		env, err := o.setupEnv(cfg)
+		if err != nil {
+			return fmt.Errorf("resolve env: %w", err)
+		}
+		return o.runSetupCommands(dir, cfg, env)
+	}
+}
+
+// RefreshPools brings every worktree pool up to date, logging what it did.
+// Setup can take minutes, so it runs from its own loop (`factory serve
+// --with-orchestrator` or `factory worktree pool refresh --watch`) rather
+// than from a check-in.
+func (o *Orchestrator) RefreshPools() {
+	for _, t := range o.poolTargets() {
+		actions, err := o.refreshTarget(t)
+		for _, a := range actions {
+			o.logf("worktree pool %s: %s", poolLabel(t), a)
+		}
+		if err != nil {
+			o.logf("worktree pool %s: %v", poolLabel(t), err)
+		}
+	}
+}
+
+func poolLabel(t *poolTarget) string {
+	label := t.opts.Remote + "/" + t.opts.Branch
+	if t.namespace != "" {
+		label = t.namespace + " " + label
+	}
+	return label
+}
+
+// RefreshPool brings namespace's worktree pool fully up to date and returns
+// what it did.
+func (o *Orchestrator) RefreshPool(namespace string) ([]string, error) {
+	t, err := o.poolTarget(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return o.refreshTarget(t)
+}
+
+// refreshTarget runs pool maintenance on t until every slot is current.
+func (o *Orchestrator) refreshTarget(t *poolTarget) ([]string, error) {
+	var actions []string
+	for {
+		action, err := t.wt.RefreshPool(t.opts, o.poolSetup(t.cfg))
+		if err != nil {
+			return actions, err
+		}
+		if action == "" {
+			return actions, nil
+		}
+		actions = append(actions, action)
+	}
+}
+
+// PoolStatus returns the slots of namespace's worktree pool.
+func (o *Orchestrator) PoolStatus(namespace string) (worktree.PoolOpts, []worktree.Slot, error) {
+	t, err := o.poolTarget(namespace)
+	if err != nil {
+		return worktree.PoolOpts{}, nil, err
+	}
+	return t.opts, t.wt.PoolStatus(t.opts), nil
+}
+
+// DrainPool removes the idle worktrees of namespace's pool and returns how
+// many it removed.
+func (o *Orchestrator) DrainPool(namespace string) (int, error) {
+	t, err := o.poolTarget(namespace)
+	if err != nil {
+		return 0, err
+	}
+	return t.wt.DrainPool(t.opts), nil
+}
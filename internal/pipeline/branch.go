@@ -0,0 +1,24 @@
+package pipeline
+
+import "github.com/lucasnoah/taintfactory/internal/config"
+
+// BaseBranchName returns the branch the pipeline merges into.
+func (ps *PipelineState) BaseBranchName() string {
+	if ps.BaseBranch == "" {
+		return config.DefaultBaseBranch
+	}
+	return ps.BaseBranch
+}
+
+// RemoteName returns the git remote the pipeline fetches from and pushes to.
+func (ps *PipelineState) RemoteName() string {
+	if ps.Remote == "" {
+		return config.DefaultRemote
+	}
+	return ps.Remote
+}
+
+// BaseRef returns the remote-tracking ref of the base branch, e.g. origin/main.
+func (ps *PipelineState) BaseRef() string {
+	return ps.RemoteName() + "/" + ps.BaseBranchName()
+}
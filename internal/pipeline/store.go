@@ -70,6 +70,28 @@ func (s *Store) GateResultDir(issue int, stage string, attempt int, fixRound int
 	return filepath.Join(s.stageAttemptDir(issue, stage, attempt), "checks", fmt.Sprintf("post-gate-%d", fixRound))
 }
 
+// CandidateDir returns where a best-of-N candidate's log, diff and result
+// are archived.
+func (s *Store) CandidateDir(issue int, stage string, attempt int, candidate string) string {
+	return filepath.Join(s.stageAttemptDir(issue, stage, attempt), "candidates", candidate)
+}
+
+// PanelReportPath returns where a panel reviewer writes its report.
+func (s *Store) PanelReportPath(issue int, stage string, attempt int, reviewer string) string {
+	return filepath.Join(s.stageAttemptDir(issue, stage, attempt), "panel", reviewer+".json")
+}
+
+// MigrationDumpPath returns where a migration_check stage saves a schema dump.
+func (s *Store) MigrationDumpPath(issue int, stage string, attempt int, name string) string {
+	return filepath.Join(s.stageAttemptDir(issue, stage, attempt), "migration", name+".sql")
+}
+
+// BrowserQADir returns where a browser_qa stage saves its screenshots, app
+// logs and runner report.
+func (s *Store) BrowserQADir(issue int, stage string, attempt int) string {
+	return filepath.Join(s.stageAttemptDir(issue, stage, attempt), "browser")
+}
+
 // CreateOpts holds options for creating a new pipeline on disk.
 type CreateOpts struct {
 	Issue      int
@@ -82,6 +104,8 @@ type CreateOpts struct {
 	ConfigPath string
 	RepoDir    string
 	Namespace  string
+	BaseBranch string
+	Remote     string
 }
 
 // Create initialises a new pipeline on disk.
@@ -111,6 +135,8 @@ func (s *Store) Create(opts CreateOpts) (*PipelineState, error) {
 		ConfigPath:     opts.ConfigPath,
 		RepoDir:        opts.RepoDir,
 		Namespace:      opts.Namespace,
+		BaseBranch:     opts.BaseBranch,
+		Remote:         opts.Remote,
 	}
 
 	if err := WriteJSON(s.pipelinePathFor(ps), ps); err != nil {
@@ -128,7 +154,7 @@ func (s *Store) GetForNamespace(namespace string, issue int) (*PipelineState, er
 	path := filepath.Join(s.issueDir(namespace, issue), "pipeline.json")
 	var ps PipelineState
 	if err := ReadJSON(path, &ps); err != nil {
-		return nil, fmt.Errorf("pipeline %d not found in namespace %s", issue, namespace)
+		return nil, fmt.Errorf("pipeline %d not found in namespace %s: %w", issue, namespace, err)
 	}
 	return &ps, nil
 }
@@ -301,3 +327,22 @@ func (s *Store) GetSessionLog(issue int, stage string, attempt int) (string, err
 	}
 	return string(data), nil
 }
+
+// SaveAttemptDiff writes the code diff (git patch) a stage attempt left on the branch.
+func (s *Store) SaveAttemptDiff(issue int, stage string, attempt int, diff string) error {
+	dir := s.stageAttemptDir(issue, stage, attempt)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("mkdir attempt dir: %w", err)
+	}
+	return WriteAtomic(filepath.Join(dir, "diff.patch"), []byte(diff))
+}
+
+// GetAttemptDiff reads the code diff saved for a stage attempt.
+func (s *Store) GetAttemptDiff(issue int, stage string, attempt int) (string, error) {
+	path := filepath.Join(s.stageAttemptDir(issue, stage, attempt), "diff.patch")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
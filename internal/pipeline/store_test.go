@@ -1,6 +1,8 @@
 package pipeline
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
@@ -562,3 +564,24 @@ func TestNamespacedStorageIsolatesIssues(t *testing.T) {
 		t.Errorf("List returned %d pipelines, want 2", len(all))
 	}
 }
+
+func TestGetForNamespaceMissingVsUnreadable(t *testing.T) {
+	s := newTestStore(t)
+
+	_, err := s.GetForNamespace("org/repo", 1)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing pipeline: err = %v, want fs.ErrNotExist", err)
+	}
+
+	if _, err := s.Create(CreateOpts{Issue: 2, Title: "t", Branch: "b", Worktree: "w", FirstStage: "impl", Namespace: "org/repo"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	path := filepath.Join(s.issueDir("org/repo", 2), "pipeline.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.GetForNamespace("org/repo", 2)
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("half-written pipeline: err = %v, want a non-ErrNotExist error", err)
+	}
+}
@@ -20,11 +20,25 @@ type PipelineState struct {
 	// a merge, dependent_issues is populated for the contract-check stage). These are
 	// merged into template vars with lower priority than pipeline/stage vars.
 	RuntimeVars map[string]string `json:"runtime_vars,omitempty"`
+	// DependentIssues is the structured form of RuntimeVars["dependent_issues"],
+	// exposed to templates as the "dependents" list.
+	DependentIssues []DependentIssue `json:"dependent_issues,omitempty"`
 
 	// Multi-project fields (optional; empty for legacy single-project pipelines)
 	ConfigPath string `json:"config_path,omitempty"` // abs path to pipeline.yaml
 	RepoDir    string `json:"repo_dir,omitempty"`    // abs path to git repo root
 	Namespace  string `json:"namespace,omitempty"`   // "{org}/{repo}", e.g. "myorg/myapp"
+
+	// Branch and remote the pipeline started from and merges into; empty for
+	// pipelines created before they were recorded, which used origin/main.
+	BaseBranch string `json:"base_branch,omitempty"`
+	Remote     string `json:"remote,omitempty"`
+}
+
+// DependentIssue is a queued issue that depends on this one.
+type DependentIssue struct {
+	Issue         int    `json:"issue"`
+	FeatureIntent string `json:"feature_intent,omitempty"`
 }
 
 // StageHistoryEntry records the outcome of a completed stage attempt.
@@ -54,6 +68,7 @@ type Finding struct {
 	Severity string `json:"severity"`
 	Message  string `json:"message"`
 	Rule     string `json:"rule,omitempty"`
+	Reviewer string `json:"reviewer,omitempty"` // panel reviewer that reported it
 }
 
 // DeployState is the persisted state for a deploy pipeline.
@@ -95,4 +110,6 @@ type StageSummary struct {
 	AutoFixes       map[string]int    `json:"auto_fixes"`
 	AgentFixes      map[string]int    `json:"agent_fixes"`
 	FinalCheckState map[string]string `json:"final_check_state"`
+	Variant         string            `json:"variant,omitempty"` // prompt_variants arm that rendered the prompt
+	Candidate       string            `json:"candidate,omitempty"` // best-of-N candidate that was kept
 }
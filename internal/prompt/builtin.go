@@ -10,6 +10,7 @@ var builtinTemplates = map[string]string{
 	"agent-merge.md":     agentMergeTemplate,
 	"contract-check.md":  contractCheckTemplate,
 	"plan-review.md":     planReviewTemplate,
+	"panel-review.md":    panelReviewTemplate,
 }
 
 const implementTemplate = `# Implement: {{issue_title}}
@@ -33,6 +34,16 @@ const implementTemplate = `# Implement: {{issue_title}}
 Working in: {{worktree_path}}
 Branch: {{branch}}
 Stage: {{stage_id}} (attempt {{attempt}})
+{{#if relevant_context}}
+
+## Where to Look
+{{relevant_context}}
+{{/if}}
+{{#if lessons}}
+
+## Lessons From Earlier Pipelines
+{{lessons}}
+{{/if}}
 
 ## Goal
 {{goal}}
@@ -49,6 +60,12 @@ Stage: {{stage_id}} (attempt {{attempt}})
 The following checks failed and need to be addressed:
 {{check_failures}}
 {{/if}}
+{{#if review_findings}}
+
+## Review Findings
+Reviewers requested changes. Address each finding below:
+{{review_findings}}
+{{/if}}
 {{#if prior_stage_summary}}
 
 ## Prior Stage Context
@@ -93,11 +110,18 @@ Stage: {{stage_id}} (attempt {{attempt}})
 {{git_commits}}
 {{/if}}
 
+{{#if visual_diff_summary}}
+### Visual Changes
+{{visual_diff_summary}}
+
+Screenshots and diff images are on the browser QA stage's attempt page. Check that each change is one the issue asks for.
+{{/if}}
+
 ## Review Instructions
 
 Your job is adversarial review. Assume the implementation is wrong until proven otherwise. Do not give the author the benefit of the doubt — if something looks suspicious, dig in.
 
-1. Use git to explore the changes: ` + "`git log`" + `, ` + "`git show <commit>`" + `, ` + "`git diff main...HEAD`" + `. Read every changed file in full — do not skim.
+1. Use git to explore the changes: ` + "`git log`" + `, ` + "`git show <commit>`" + `, ` + "`git diff {{base_ref | default origin/main}}...HEAD`" + `. Read every changed file in full — do not skim.
 2. **Do not trust the tests.** Tests written by the implementer are the most likely place for blind spots. Ask: what cases are not tested? What inputs would break this? Write tests for those cases and run them.
 3. **Do not trust the happy path.** Actively look for what happens when things go wrong: nil inputs, empty slices, zero values, network failures, DB errors, concurrent access, clock edge cases (midnight, DST, leap day). If error paths are unhandled or silently swallowed, that is a bug.
 4. **Do not trust that the acceptance criteria are met.** Read each criterion and find the exact code path that satisfies it. If you cannot point to it, it may not exist.
@@ -108,6 +132,66 @@ Your job is adversarial review. Assume the implementation is wrong until proven
 9. Run the full test suite after your fixes. If anything fails that was passing before, fix it.
 `
 
+const panelReviewTemplate = `# Code Review ({{reviewer}}): {{issue_title}}
+
+> **Do not invoke any skills or slash commands** (e.g. /superpowers, /commit, or any /command). Use only built-in tools.
+
+## Issue #{{issue_number}}
+{{issue_body}}
+
+{{#if feature_intent}}
+## Feature Intent
+{{feature_intent}}
+{{/if}}
+
+{{#if acceptance_criteria}}
+## Acceptance Criteria
+{{acceptance_criteria}}
+{{/if}}
+
+## Repository Context
+Working in: {{worktree_path}}
+Branch: {{branch}}
+Stage: {{stage_id}} (attempt {{attempt}})
+
+{{#if git_diff_summary}}
+## Changes Summary
+{{git_diff_summary}}
+{{/if}}
+
+{{#if files_changed}}
+### Files Changed
+{{files_changed}}
+{{/if}}
+
+{{#if git_commits}}
+### Commits
+{{git_commits}}
+{{/if}}
+
+{{#if visual_diff_summary}}
+### Visual Changes
+{{visual_diff_summary}}
+
+Screenshots and diff images are on the browser QA stage's attempt page. Check that each change is one the issue asks for.
+{{/if}}
+
+## Review Instructions
+
+You are the **{{reviewer}}** reviewer on a panel of independent reviewers.
+{{#if review_focus}}
+Concentrate on: {{review_focus}}. Leave other concerns to the other reviewers unless they are severe.
+{{/if}}
+
+Your job is adversarial review. Assume the implementation is wrong until proven otherwise.
+
+1. Use git to explore the changes: ` + "`git log`" + `, ` + "`git show <commit>`" + `, ` + "`git diff {{base_ref | default origin/main}}...HEAD`" + `. Read every changed file in full.
+2. Check each acceptance criterion against the exact code path that satisfies it.
+3. Look for unhandled error paths, edge cases the tests miss, and behavior the change silently breaks.
+4. **Do not change anything.** Do not edit files, commit, or run commands that write to the worktree. Report problems as findings; the implementer fixes them.
+5. Give each finding the file and line it concerns and a message that says what is wrong and why.
+`
+
 const qaTemplate = `# QA Testing: {{issue_title}}
 
 > **Do not invoke any skills or slash commands** (e.g. /superpowers, /commit, or any /command). Use only built-in tools.
@@ -155,7 +239,7 @@ startup, migrations, test, build. Use what you find there — do not guess
 or invent commands.
 
 ### Step 1 — Explore the changes
-Use git to understand what was built: ` + "`git log`" + `, ` + "`git show <commit>`" + `, ` + "`git diff main...HEAD`" + `, and read the changed files directly.
+Use git to understand what was built: ` + "`git log`" + `, ` + "`git show <commit>`" + `, ` + "`git diff {{base_ref | default origin/main}}...HEAD`" + `, and read the changed files directly.
 
 ### Step 2 — Determine what runtime testing is required
 Based on the changes and acceptance criteria, decide which of these apply:
@@ -253,7 +337,7 @@ const agentMergeTemplate = `# Resolve and Merge: {{issue_title}}
 
 ## Context
 The automated merge stage failed on branch ` + "`{{branch}}`" + ` (issue #{{issue_number}}, attempt {{attempt}}).
-Your job is to get this PR merged. The most likely cause is that other PRs landed on main
+Your job is to get this PR merged. The most likely cause is that other PRs landed on {{base_branch}}
 after this branch was cut, creating "both added" conflicts on shared files.
 
 Worktree: {{worktree_path}}
@@ -266,17 +350,17 @@ Branch: {{branch}}
 cd {{worktree_path}}
 git status                   # is a rebase already in progress?
 git log --oneline -5         # what's on this branch?
-git log --oneline origin/main -5  # what landed on main?
+git log --oneline {{base_ref}} -5  # what landed on {{base_branch}}?
 ` + "```" + `
 
 If a rebase is in progress (` + "`git status`" + ` shows "rebase in progress"), abort it first:
 ` + "`git rebase --abort`" + `
 
-## Step 2 — Rebase onto main
+## Step 2 — Rebase onto {{base_branch}}
 
 ` + "```" + `bash
-git fetch origin
-git rebase origin/main
+git fetch {{remote}}
+git rebase {{base_ref}}
 ` + "```" + `
 
 If the rebase exits cleanly (no conflicts), skip to Step 4.
@@ -287,21 +371,21 @@ For each conflicting file shown by ` + "`git status`" + `, apply the rules below
 ` + "`git add <file>`" + ` and ` + "`git rebase --continue`" + `. Repeat until the rebase finishes.
 
 **Rule 1 — "Both added" (` + "`AA`" + `) conflicts** (most common):
-Both this branch and main added the same file. This happens when an earlier slice
-landed on main after this branch was cut.
-
-- Check if main already has an authoritative version: ` + "`git show origin/main:<path>`" + `
-  - If yes, and your version duplicates structs/types/migrations already in main:
-    take origin/main's version → ` + "`git checkout --ours <file>`" + `
-    *(in a rebase, ` + "`--ours`" + ` = origin/main = the target branch)*
-  - If the file is genuinely net-new on this branch (main has nothing at that path):
+Both this branch and {{base_branch}} added the same file. This happens when an earlier slice
+landed on {{base_branch}} after this branch was cut.
+
+- Check if {{base_branch}} already has an authoritative version: ` + "`git show {{base_ref}}:<path>`" + `
+  - If yes, and your version duplicates structs/types/migrations already in {{base_branch}}:
+    take {{base_ref}}'s version → ` + "`git checkout --ours <file>`" + `
+    *(in a rebase, ` + "`--ours`" + ` = {{base_ref}} = the target branch)*
+  - If the file is genuinely net-new on this branch ({{base_branch}} has nothing at that path):
     keep your version → ` + "`git checkout --theirs <file>`" + `
 
 **Rule 2 — "Both modified" conflicts:**
-- Generated files (` + "`*.sql.go`" + `, ` + "`models.go`" + `): take ` + "`--ours`" + ` (origin/main).
-- Migration files (` + "`migrations/*.sql`" + `): take ` + "`--ours`" + ` (origin/main).
+- Generated files (` + "`*.sql.go`" + `, ` + "`models.go`" + `): take ` + "`--ours`" + ` ({{base_ref}}).
+- Migration files (` + "`migrations/*.sql`" + `): take ` + "`--ours`" + ` ({{base_ref}}).
 - Application code: open the file, read the conflict markers, and merge manually —
-  keep origin/main's structure while preserving the new code this branch adds.
+  keep {{base_ref}}'s structure while preserving the new code this branch adds.
 
 **After each file:** ` + "`git add <file>`" + `
 
@@ -319,7 +403,7 @@ If the build fails, re-examine the conflicting files. Fix build errors before pr
 ## Step 5 — Push
 
 ` + "```" + `bash
-git push --force-with-lease -u origin {{branch}}
+git push --force-with-lease -u {{remote}} {{branch}}
 ` + "```" + `
 
 ## Step 6 — Create PR (if none exists)
@@ -327,7 +411,7 @@ git push --force-with-lease -u origin {{branch}}
 ` + "```" + `bash
 gh pr list --head {{branch}} --json url --limit 1
 # If the output is "[]", create the PR:
-gh pr create --title "#{{issue_number}}: {{issue_title}}" \
+gh pr create --base {{base_branch}} --title "#{{issue_number}}: {{issue_title}}" \
   --body "Closes #{{issue_number}}
 
 Automated merge via pipeline."
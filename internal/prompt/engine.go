@@ -0,0 +1,509 @@
+package prompt
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Template language
+//
+// The legacy syntax is a subset and renders exactly as before:
+//
+//	{{name}}                    substitute a variable (missing variables are an error)
+//	{{#if name}}...{{/if}}      include the body when name is non-empty
+//
+// On top of it:
+//
+//	{{#if name}}...{{else}}...{{/if}}
+//	{{#range findings}}...{{else}}...{{/range}}
+//	                            repeat the body per element; a string ranges
+//	                            over its non-empty lines. Inside the body {{.}}
+//	                            is the element, {{.file}} one of its fields,
+//	                            {{@index}} the 0-based and {{@number}} the
+//	                            1-based position.
+//	{{finding.file}}            dotted lookups into structured data
+//	{{issue_body | truncate 2000 | indent 2}}
+//	                            filters, applied left to right
+//	{{> partial}}               include another template (see TemplatePartials)
+//
+// Expression tags must start right after the braces, so text such as
+// "${{ secrets.TOKEN }}" in a prompt is left alone. Outside a range, a dotted
+// or leading-dot path whose root is not a known variable or data key is
+// also kept as written ({{secrets.TOKEN}}, {{.Values.image}}), so prompts
+// can quote other template languages; only a plain {{name}} must resolve.
+
+// Data holds structured template values (lists of findings, changed files,
+// ...). Lists are slices; records are map[string]interface{}.
+type Data map[string]interface{}
+
+// RenderOpts carries the optional inputs of the template language.
+type RenderOpts struct {
+	Data     Data
+	Partials func(name string) (string, error) // resolves {{> name}}; nil disables partials
+}
+
+// maxPartialDepth bounds partial nesting so a partial that includes itself fails cleanly.
+const maxPartialDepth = 10
+
+var (
+	pathRe    = regexp.MustCompile(`^(\.|\.?[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)*|@index|@number)$`)
+	partialRe = regexp.MustCompile(`^>\s*([a-zA-Z0-9_./-]+)\s*$`)
+)
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	exprNode
+	ifNode
+	rangeNode
+	partialNode
+)
+
+type node struct {
+	kind    nodeKind
+	text    string // text: literal; expr/if/range: path; partial: name
+	raw     string // expr: the tag as written
+	filters []filterCall
+	body    []node
+	orElse  []node
+}
+
+type filterCall struct {
+	name string
+	args []string
+}
+
+// RenderWith expands tmpl with vars, structured data, and partials.
+func RenderWith(tmpl string, vars Vars, opts RenderOpts) (string, error) {
+	r := &renderer{vars: vars, data: opts.Data, partials: opts.Partials}
+	var sb strings.Builder
+	if err := r.renderSource(&sb, tmpl, nil, 0); err != nil {
+		return "", err
+	}
+	if len(r.missing) > 0 {
+		return "", fmt.Errorf("missing template variables: %s", strings.Join(r.missing, ", "))
+	}
+	return sb.String(), nil
+}
+
+// CheckSyntax parses tmpl without rendering it, reporting unbalanced blocks
+// and unknown filters.
+func CheckSyntax(tmpl string) error {
+	p := &parser{src: tmpl}
+	_, _, err := p.parse("", "")
+	return err
+}
+
+// TemplatePartials resolves {{> name}} relative to the directory of the
+// template being rendered, through the same lookup as LoadTemplate. ".md" is
+// appended when name has no extension.
+func TemplatePartials(templatePath, workdir string) func(string) (string, error) {
+	dir := path.Dir(templatePath)
+	return func(name string) (string, error) {
+		if path.Ext(name) == "" {
+			name += ".md"
+		}
+		return LoadTemplate(path.Join(dir, name), workdir)
+	}
+}
+
+// --- parsing ---
+
+type parser struct {
+	src string
+	pos int
+}
+
+// parse returns the nodes up to the closing tag named in until ("/if" or
+// "/range"; "" at top level) and whether the block ended at {{else}}. opener
+// is the tag that started the block, for error messages.
+func (p *parser) parse(until, opener string) (nodes []node, sawElse bool, err error) {
+	for p.pos < len(p.src) {
+		open := strings.Index(p.src[p.pos:], "{{")
+		if open < 0 {
+			break
+		}
+		open += p.pos
+		end := strings.Index(p.src[open+2:], "}}")
+		if end < 0 {
+			break
+		}
+		end += open + 2
+		tag := p.src[open+2 : end]
+		if i := strings.LastIndex(tag, "{{"); i >= 0 {
+			// "{{ text {{name}}": only the innermost braces form the tag.
+			open += i + 2
+			tag = tag[i+2:]
+		}
+
+		if open > p.pos {
+			nodes = append(nodes, node{kind: textNode, text: p.src[p.pos:open]})
+		}
+		p.pos = end + 2
+
+		switch {
+		case tag == "/if" || tag == "/range":
+			if until == "" {
+				return nil, false, fmt.Errorf("dangling {{%s}} without matching {{#%s}}", tag, tag[1:])
+			}
+			if tag != until {
+				return nil, false, fmt.Errorf("{{%s}} closes a {{#%s}} block", tag, until[1:])
+			}
+			return nodes, false, nil
+		case tag == "else" && until != "":
+			return nodes, true, nil
+		}
+
+		if kw, arg, ok := blockTag(tag); ok {
+			n := node{kind: ifNode, text: arg}
+			closer := "/if"
+			if kw == "range" {
+				n.kind, closer = rangeNode, "/range"
+			}
+			var sawElse bool
+			if n.body, sawElse, err = p.parse(closer, "{{"+tag+"}}"); err != nil {
+				return nil, false, err
+			}
+			if sawElse {
+				if n.orElse, sawElse, err = p.parse(closer, "{{"+tag+"}}"); err != nil {
+					return nil, false, err
+				}
+				if sawElse {
+					return nil, false, fmt.Errorf("duplicate {{else}} in {{#%s %s}}", kw, arg)
+				}
+			}
+			nodes = append(nodes, n)
+			continue
+		}
+		if m := partialRe.FindStringSubmatch(tag); m != nil {
+			nodes = append(nodes, node{kind: partialNode, text: m[1]})
+			continue
+		}
+		if n, ok, err := exprTag(tag); err != nil {
+			return nil, false, err
+		} else if ok {
+			nodes = append(nodes, n)
+			continue
+		}
+		// Not template syntax; keep it verbatim.
+		nodes = append(nodes, node{kind: textNode, text: "{{" + tag + "}}"})
+	}
+	if p.pos < len(p.src) {
+		nodes = append(nodes, node{kind: textNode, text: p.src[p.pos:]})
+		p.pos = len(p.src)
+	}
+	if until != "" {
+		return nil, false, fmt.Errorf("unclosed conditional block: %s (missing {{%s}})", opener, until)
+	}
+	return nodes, false, nil
+}
+
+// blockTag recognises "#if path" and "#range path" (any whitespace around path).
+func blockTag(tag string) (kw, arg string, ok bool) {
+	for _, kw := range []string{"if", "range"} {
+		rest, found := strings.CutPrefix(tag, "#"+kw)
+		if !found || rest == "" || !isSpace(rest[0]) {
+			continue
+		}
+		arg = strings.TrimSpace(rest)
+		if pathRe.MatchString(arg) {
+			return kw, arg, true
+		}
+	}
+	return "", "", false
+}
+
+// exprTag parses "path | filter arg ... | filter". ok is false when tag is
+// not an expression at all; an unknown filter on a valid path is an error.
+func exprTag(tag string) (node, bool, error) {
+	if tag == "" || isSpace(tag[0]) {
+		return node{}, false, nil
+	}
+	parts := strings.Split(tag, "|")
+	p := strings.TrimSpace(parts[0])
+	if !pathRe.MatchString(p) || (len(parts) == 1 && p != tag) {
+		return node{}, false, nil
+	}
+	n := node{kind: exprNode, text: p, raw: tag}
+	for _, part := range parts[1:] {
+		fields, err := splitArgs(strings.TrimSpace(part))
+		if err != nil || len(fields) == 0 {
+			return node{}, false, nil
+		}
+		if _, ok := filters[fields[0]]; !ok {
+			return node{}, false, fmt.Errorf("unknown template filter %q in {{%s}}", fields[0], tag)
+		}
+		n.filters = append(n.filters, filterCall{name: fields[0], args: fields[1:]})
+	}
+	return n, true, nil
+}
+
+// splitArgs splits on whitespace, keeping "double quoted" arguments whole.
+func splitArgs(s string) ([]string, error) {
+	var out []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			v, _ := strconv.Unquote(q)
+			out = append(out, v)
+			s = s[len(q):]
+			continue
+		}
+		i := strings.IndexFunc(s, func(r rune) bool { return r < 128 && isSpace(byte(r)) })
+		if i < 0 {
+			i = len(s)
+		}
+		out = append(out, s[:i])
+		s = s[i:]
+	}
+	return out, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// --- evaluation ---
+
+type frame struct {
+	elem  interface{}
+	index int
+}
+
+type renderer struct {
+	vars     Vars
+	data     Data
+	partials func(string) (string, error)
+	missing  []string
+}
+
+func (r *renderer) renderSource(sb *strings.Builder, src string, stack []frame, depth int) error {
+	p := &parser{src: src}
+	nodes, _, err := p.parse("", "")
+	if err != nil {
+		return err
+	}
+	return r.render(sb, nodes, stack, depth)
+}
+
+func (r *renderer) render(sb *strings.Builder, nodes []node, stack []frame, depth int) error {
+	for _, n := range nodes {
+		switch n.kind {
+		case textNode:
+			sb.WriteString(n.text)
+
+		case exprNode:
+			v, found := r.lookup(n.text, stack)
+			if !found && !hasFilter(n.filters, "default") {
+				if len(stack) == 0 && strings.ContainsAny(n.text, ".@") {
+					// Not ours (e.g. {{secrets.TOKEN}}); keep it verbatim.
+					sb.WriteString("{{" + n.raw + "}}")
+					continue
+				}
+				r.missing = append(r.missing, n.text)
+				sb.WriteString("{{" + n.text + "}}")
+				continue
+			}
+			for _, f := range n.filters {
+				var err error
+				if v, err = filters[f.name](v, f.args); err != nil {
+					return fmt.Errorf("filter %s on %s: %w", f.name, n.text, err)
+				}
+			}
+			sb.WriteString(toString(v))
+
+		case ifNode:
+			v, _ := r.lookup(n.text, stack)
+			branch := n.orElse
+			if truthy(v) {
+				branch = n.body
+			}
+			if err := r.render(sb, branch, stack, depth); err != nil {
+				return err
+			}
+
+		case rangeNode:
+			v, _ := r.lookup(n.text, stack)
+			items := listItems(v)
+			if len(items) == 0 {
+				if err := r.render(sb, n.orElse, stack, depth); err != nil {
+					return err
+				}
+				continue
+			}
+			for i, item := range items {
+				if err := r.render(sb, n.body, append(stack, frame{elem: item, index: i}), depth); err != nil {
+					return err
+				}
+			}
+
+		case partialNode:
+			if r.partials == nil {
+				return fmt.Errorf("partial %q: partials are not available here", n.text)
+			}
+			if depth >= maxPartialDepth {
+				return fmt.Errorf("partial %q: nested more than %d deep", n.text, maxPartialDepth)
+			}
+			src, err := r.partials(n.text)
+			if err != nil {
+				return fmt.Errorf("partial %q: %w", n.text, err)
+			}
+			if err := r.renderSource(sb, src, stack, depth+1); err != nil {
+				return fmt.Errorf("partial %q: %w", n.text, err)
+			}
+		}
+	}
+	return nil
+}
+
+// lookup resolves a path. found is false only when the root name is unknown;
+// missing fields of a known value resolve to nil.
+func (r *renderer) lookup(p string, stack []frame) (interface{}, bool) {
+	var cur frame
+	if len(stack) > 0 {
+		cur = stack[len(stack)-1]
+	}
+	switch p {
+	case "@index":
+		return cur.index, len(stack) > 0
+	case "@number":
+		return cur.index + 1, len(stack) > 0
+	case ".":
+		return cur.elem, len(stack) > 0
+	}
+
+	var v interface{}
+	var fields []string
+	if strings.HasPrefix(p, ".") {
+		if len(stack) == 0 {
+			return nil, false
+		}
+		v, fields = cur.elem, strings.Split(p[1:], ".")
+	} else {
+		parts := strings.Split(p, ".")
+		root, ok := r.vars[parts[0]]
+		if ok {
+			v = root
+		} else if dv, ok := r.data[parts[0]]; ok {
+			v = dv
+		} else {
+			return nil, false
+		}
+		fields = parts[1:]
+	}
+	for _, f := range fields {
+		v = field(v, f)
+	}
+	return v, true
+}
+
+func field(v interface{}, name string) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	mv := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+	if !mv.IsValid() {
+		return nil
+	}
+	return mv.Interface()
+}
+
+// listItems returns the elements a {{#range}} iterates: slice elements, or
+// the non-empty lines of a string.
+func listItems(v interface{}) []interface{} {
+	if s, ok := v.(string); ok {
+		var out []interface{}
+		for _, line := range strings.Split(s, "\n") {
+			if strings.TrimSpace(line) != "" {
+				out = append(out, line)
+			}
+		}
+		return out
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
+
+func truthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() > 0
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() != 0
+	case reflect.Pointer, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return true
+}
+
+// toString renders a value: lists one element per line, records as sorted
+// key: value pairs.
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case fmt.Stringer:
+		return t.String()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		lines := make([]string, rv.Len())
+		for i := range lines {
+			lines[i] = toString(rv.Index(i).Interface())
+		}
+		return strings.Join(lines, "\n")
+	case reflect.Map:
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, fmt.Sprint(k.Interface()))
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = k + ": " + toString(field(v, k))
+		}
+		return strings.Join(pairs, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+func hasFilter(fs []filterCall, name string) bool {
+	for _, f := range fs {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderWith_RangeOverData(t *testing.T) {
+	tmpl := "Findings for #{{issue}}:\n{{#range findings}}{{@number}}. {{.file}}:{{.line}} [{{.severity}}] {{.message}}\n{{else}}none\n{{/range}}"
+	data := Data{"findings": []map[string]interface{}{
+		{"file": "a.go", "line": 3, "severity": "error", "message": "unused"},
+		{"file": "b.go", "line": 9, "severity": "warning", "message": "shadow"},
+	}}
+
+	got, err := RenderWith(tmpl, Vars{"issue": "5"}, RenderOpts{Data: data})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "Findings for #5:\n1. a.go:3 [error] unused\n2. b.go:9 [warning] shadow\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, err = RenderWith(tmpl, Vars{"issue": "5"}, RenderOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(got, "none\n") {
+		t.Errorf("empty range should render else branch, got %q", got)
+	}
+}
+
+func TestRenderWith_RangeOverStringLines(t *testing.T) {
+	got, err := Render("{{#range files_changed}}- {{.}}\n{{/range}}", Vars{"files_changed": "a.go\n\nb.go\n"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "- a.go\n- b.go\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRenderWith_IfElse(t *testing.T) {
+	got, err := Render("{{#if x}}yes{{else}}no{{/if}}", Vars{})
+	if err != nil || got != "no" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestRenderWith_Filters(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{{body | truncate 5}}", "hello..."},
+		{"{{body | truncate 50}}", "hello world"},
+		{"{{multi | indent 2}}", "  a\n\n  b"},
+		{"{{diff | code diff}}", "```diff\n-x\n+y\n```"},
+		{"{{fenced | code}}", "````\n```go\n```\n````"},
+		{"{{missing | default \"n/a\"}}", "n/a"},
+		{"{{empty | default none}}", "none"},
+		{"{{files | join \", \"}}", "a.go, b.go"},
+		{"{{files | count}}", "2"},
+		{"{{body | truncate 5 | code}}", "```\nhello...\n```"},
+	}
+	vars := Vars{
+		"body":   "hello world",
+		"multi":  "a\n\nb",
+		"diff":   "-x\n+y\n",
+		"fenced": "```go\n```",
+		"empty":  "",
+	}
+	data := Data{"files": []string{"a.go", "b.go"}}
+	for _, tc := range tests {
+		got, err := RenderWith(tc.tmpl, vars, RenderOpts{Data: data})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.tmpl, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.tmpl, got, tc.want)
+		}
+	}
+}
+
+func TestRenderWith_UnknownFilter(t *testing.T) {
+	_, err := Render("{{body | shout}}", Vars{"body": "x"})
+	if err == nil || !strings.Contains(err.Error(), `unknown template filter "shout"`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRenderWith_NonTemplateBracesKept(t *testing.T) {
+	tmpl := "token: ${{ secrets.TOKEN }} and {{name}}"
+	got, err := Render(tmpl, Vars{"name": "x"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "token: ${{ secrets.TOKEN }} and x" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRenderWith_UnknownDottedPathsKept(t *testing.T) {
+	for _, tmpl := range []string{"{{secrets.TOKEN}}", "{{.Values.image}}", "{{.}}", "{{@index}}"} {
+		got, err := Render(tmpl, Vars{})
+		if err != nil {
+			t.Errorf("Render(%q): unexpected error: %v", tmpl, err)
+			continue
+		}
+		if got != tmpl {
+			t.Errorf("Render(%q) = %q, want it unchanged", tmpl, got)
+		}
+	}
+}
+
+func TestRenderWith_UnknownPathsInRangeMissing(t *testing.T) {
+	_, err := RenderWith("{{#range items}}{{nope.file}}{{/range}}", Vars{}, RenderOpts{Data: Data{"items": []interface{}{"a"}}})
+	if err == nil || !strings.Contains(err.Error(), "missing template variables: nope.file") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRenderWith_Partials(t *testing.T) {
+	workdir := t.TempDir()
+	dir := filepath.Join(workdir, "templates")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"implement.md": "# {{title}}\n{{> rules}}",
+		"rules.md":     "{{#range rules}}- {{.}}\n{{/range}}",
+		"loop.md":      "{{> loop}}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tmpl, err := LoadTemplate("templates/implement.md", workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderWith(tmpl, Vars{"title": "Auth"}, RenderOpts{
+		Data:     Data{"rules": []string{"small commits", "tests first"}},
+		Partials: TemplatePartials("templates/implement.md", workdir),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "# Auth\n- small commits\n- tests first\n" {
+		t.Errorf("got %q", got)
+	}
+
+	_, err = RenderWith("{{> loop}}", Vars{}, RenderOpts{Partials: TemplatePartials("templates/x.md", workdir)})
+	if err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("self-including partial: err = %v", err)
+	}
+	if _, err := Render("{{> rules}}", Vars{}); err == nil {
+		t.Error("partials without a resolver should fail")
+	}
+}
+
+func TestRenderWith_MismatchedBlocks(t *testing.T) {
+	if _, err := Render("{{#range xs}}{{/if}}", Vars{}); err == nil {
+		t.Error("expected error for {{/if}} closing {{#range}}")
+	}
+	if _, err := Render("{{#range xs}}body", Vars{}); err == nil || !strings.Contains(err.Error(), "unclosed") {
+		t.Errorf("expected unclosed error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filterFunc transforms a value in a {{value | filter args}} expression.
+type filterFunc func(v interface{}, args []string) (interface{}, error)
+
+// filters is the set of template filters.
+//
+//	truncate N    keep the first N characters, marking the cut with "..."
+//	indent N      prefix every non-empty line with N spaces
+//	code [lang]   wrap in a Markdown code fence (longer fence if the text has one)
+//	join [sep]    join a list with sep (default ", ")
+//	default text  use text when the value is missing or empty
+//	count         number of list elements (or non-empty lines of a string)
+//	trim          strip leading and trailing whitespace
+var filters = map[string]filterFunc{
+	"truncate": filterTruncate,
+	"indent":   filterIndent,
+	"code":     filterCode,
+	"join":     filterJoin,
+	"default":  filterDefault,
+	"count":    filterCount,
+	"trim": func(v interface{}, _ []string) (interface{}, error) {
+		return strings.TrimSpace(toString(v)), nil
+	},
+}
+
+func intArg(args []string, name string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%s takes one number", name)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: invalid number %q", name, args[0])
+	}
+	return n, nil
+}
+
+func filterTruncate(v interface{}, args []string) (interface{}, error) {
+	n, err := intArg(args, "truncate")
+	if err != nil {
+		return nil, err
+	}
+	r := []rune(toString(v))
+	if len(r) <= n {
+		return string(r), nil
+	}
+	return string(r[:n]) + "...", nil
+}
+
+func filterIndent(v interface{}, args []string) (interface{}, error) {
+	n, err := intArg(args, "indent")
+	if err != nil {
+		return nil, err
+	}
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(toString(v), "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = pad + l
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func filterCode(v interface{}, args []string) (interface{}, error) {
+	lang := strings.Join(args, " ")
+	s := strings.TrimRight(toString(v), "\n")
+	fence := "```"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + s + "\n" + fence, nil
+}
+
+func filterJoin(v interface{}, args []string) (interface{}, error) {
+	sep := ", "
+	if len(args) > 0 {
+		sep = args[0]
+	}
+	items := listItems(v)
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = toString(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func filterDefault(v interface{}, args []string) (interface{}, error) {
+	if truthy(v) {
+		return v, nil
+	}
+	return strings.Join(args, " "), nil
+}
+
+func filterCount(v interface{}, _ []string) (interface{}, error) {
+	return len(listItems(v)), nil
+}
@@ -0,0 +1,105 @@
+package qa
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+// PlaywrightScript is the built-in browser_qa runner. It reads the
+// FACTORY_* variables described on BrowserReport and writes the report.
+//
+//go:embed playwright.js
+var PlaywrightScript []byte
+
+// BrowserReport is what a browser_qa runner writes to $FACTORY_BROWSER_REPORT.
+// The runner is given FACTORY_BASE_URL, FACTORY_ROUTES (one per line),
+// FACTORY_ARTIFACTS_DIR for screenshots, and FACTORY_NAV_TIMEOUT_MS.
+type BrowserReport struct {
+	Routes []RouteResult `json:"routes"`
+}
+
+// RouteResult is what happened when a route was loaded.
+type RouteResult struct {
+	Route         string   `json:"route"`
+	URL           string   `json:"url"`
+	Status        int      `json:"status"` // HTTP status of the document; 0 if it never loaded
+	Screenshot    string   `json:"screenshot,omitempty"`
+	ConsoleErrors []string `json:"console_errors,omitempty"`
+	PageErrors    []string `json:"page_errors,omitempty"` // uncaught exceptions
+	Error         string   `json:"error,omitempty"`       // navigation failure, e.g. a timeout
+}
+
+// ReadBrowserReport loads a runner's report.
+func ReadBrowserReport(path string) (*BrowserReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r BrowserReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse browser report: %w", err)
+	}
+	return &r, nil
+}
+
+// Findings returns one finding per problem on each route. Console errors
+// containing any of ignore are skipped.
+func (r *BrowserReport) Findings(ignore []string) []pipeline.Finding {
+	var findings []pipeline.Finding
+	add := func(route RouteResult, severity, rule, msg string) {
+		if route.Screenshot != "" {
+			msg += " (screenshot: " + route.Screenshot + ")"
+		}
+		findings = append(findings, pipeline.Finding{Severity: severity, Rule: rule, Message: msg})
+	}
+	for _, route := range r.Routes {
+		switch {
+		case route.Error != "":
+			add(route, "blocker", "browser_navigation", fmt.Sprintf("%s failed to load: %s", route.Route, firstLine(route.Error)))
+		case route.Status >= 400:
+			add(route, "blocker", "browser_http_status", fmt.Sprintf("%s returned HTTP %d", route.Route, route.Status))
+		}
+		for _, e := range route.PageErrors {
+			add(route, "blocker", "browser_page_error", fmt.Sprintf("uncaught error on %s: %s", route.Route, firstLine(e)))
+		}
+		for _, e := range route.ConsoleErrors {
+			if containsAny(e, ignore) {
+				continue
+			}
+			add(route, "major", "browser_console_error", fmt.Sprintf("console error on %s: %s", route.Route, firstLine(e)))
+		}
+	}
+	return findings
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if sub != "" && strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// VisitableRoutes splits routes into those that can be loaded as they are
+// and those with dynamic segments ([id], :id, *), which need real values.
+func VisitableRoutes(routes []string) (visit, skipped []string) {
+	for _, r := range dedupe(routes) {
+		if strings.ContainsAny(r, "[]:*") {
+			skipped = append(skipped, r)
+			continue
+		}
+		visit = append(visit, r)
+	}
+	return visit, skipped
+}
@@ -0,0 +1,69 @@
+package qa
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBrowserReportFindings(t *testing.T) {
+	r := &BrowserReport{Routes: []RouteResult{
+		{Route: "/", Status: 200, Screenshot: "/tmp/root.png"},
+		{Route: "/settings", Status: 500, PageErrors: []string{"TypeError: x is undefined\n    at app.js:1"}},
+		{Route: "/login", Error: "Timeout 30000ms exceeded"},
+		{Route: "/about", Status: 200, ConsoleErrors: []string{"Download the React DevTools", "Failed to load resource: 404"}},
+	}}
+	findings := r.Findings([]string{"React DevTools"})
+
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule)
+	}
+	want := []string{"browser_http_status", "browser_page_error", "browser_navigation", "browser_console_error"}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules = %v, want %v", rules, want)
+	}
+	if findings[1].Message != "uncaught error on /settings: TypeError: x is undefined" {
+		t.Errorf("page error message = %q", findings[1].Message)
+	}
+	if findings[3].Severity != "major" || !strings.Contains(findings[3].Message, "404") {
+		t.Errorf("console finding = %+v", findings[3])
+	}
+}
+
+func TestBrowserReportFindings_Screenshot(t *testing.T) {
+	r := &BrowserReport{Routes: []RouteResult{{Route: "/", Status: 404, Screenshot: "/tmp/root.png"}}}
+	findings := r.Findings(nil)
+	if len(findings) != 1 || !strings.HasSuffix(findings[0].Message, "(screenshot: /tmp/root.png)") {
+		t.Errorf("findings = %+v, want the screenshot path in the message", findings)
+	}
+}
+
+func TestReadBrowserReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	os.WriteFile(path, []byte(`{"routes":[{"route":"/","url":"http://localhost/","status":200,"console_errors":["boom"]}]}`), 0o644)
+	r, err := ReadBrowserReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Routes) != 1 || r.Routes[0].Status != 200 || r.Routes[0].ConsoleErrors[0] != "boom" {
+		t.Errorf("report = %+v", r)
+	}
+
+	os.WriteFile(path, []byte("not json"), 0o644)
+	if _, err := ReadBrowserReport(path); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestVisitableRoutes(t *testing.T) {
+	visit, skipped := VisitableRoutes([]string{"/", "/users/[id]", "/posts/:slug", "/", "/settings"})
+	if !reflect.DeepEqual(visit, []string{"/", "/settings"}) {
+		t.Errorf("visit = %v", visit)
+	}
+	if !reflect.DeepEqual(skipped, []string{"/users/[id]", "/posts/:slug"}) {
+		t.Errorf("skipped = %v", skipped)
+	}
+}
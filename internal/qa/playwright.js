@@ -0,0 +1,49 @@
+// Visits each route with headless Chromium, saving a screenshot and
+// collecting console and page errors. Run by the browser_qa stage with
+// playwright resolved from the worktree's node_modules.
+const { chromium } = require('playwright');
+const fs = require('fs');
+const path = require('path');
+
+const base = process.env.FACTORY_BASE_URL;
+const routes = (process.env.FACTORY_ROUTES || '/').split('\n').filter(Boolean);
+const artifacts = process.env.FACTORY_ARTIFACTS_DIR;
+const reportPath = process.env.FACTORY_BROWSER_REPORT;
+const timeout = parseInt(process.env.FACTORY_NAV_TIMEOUT_MS || '30000', 10);
+
+function slug(route) {
+  return route.replace(/^\/+|\/+$/g, '').replace(/[^A-Za-z0-9]+/g, '-') || 'root';
+}
+
+(async () => {
+  const browser = await chromium.launch();
+  const results = [];
+  for (const route of routes) {
+    const page = await browser.newPage();
+    const r = { route, url: new URL(route, base).toString(), status: 0, console_errors: [], page_errors: [] };
+    page.on('console', (msg) => {
+      if (msg.type() === 'error') r.console_errors.push(msg.text());
+    });
+    page.on('pageerror', (err) => r.page_errors.push(String(err)));
+    try {
+      const resp = await page.goto(r.url, { waitUntil: 'networkidle', timeout });
+      r.status = resp ? resp.status() : 0;
+    } catch (err) {
+      r.error = String((err && err.message) || err);
+    }
+    const shot = path.join(artifacts, slug(route) + '.png');
+    try {
+      await page.screenshot({ path: shot, fullPage: true });
+      r.screenshot = shot;
+    } catch (err) {
+      // A page that failed to load may not render; the error is already recorded.
+    }
+    await page.close();
+    results.push(r);
+  }
+  await browser.close();
+  fs.writeFileSync(reportPath, JSON.stringify({ routes: results }, null, 2));
+})().catch((err) => {
+  console.error(err);
+  process.exit(2);
+});
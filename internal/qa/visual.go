@@ -0,0 +1,190 @@
+package qa
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// visualTolerance is how far, on a 0-255 scale, a pixel's channels may move
+// before it counts as changed. It absorbs anti-aliasing and font hinting
+// noise between two renders of the same page.
+const visualTolerance = 24
+
+// VisualReport compares a branch's screenshots with the base ref's. It is
+// saved as visual/report.json in the browser_qa attempt directory; image
+// paths in it are relative to that directory.
+type VisualReport struct {
+	BaseRef   string       `json:"base_ref"`
+	Threshold float64      `json:"threshold"` // percent of pixels
+	Routes    []VisualDiff `json:"routes"`
+}
+
+// VisualDiff is the comparison of one route.
+type VisualDiff struct {
+	Route   string  `json:"route"`
+	Base    string  `json:"base,omitempty"`
+	Head    string  `json:"head,omitempty"`
+	Diff    string  `json:"diff,omitempty"`
+	Changed float64 `json:"changed"` // percent of pixels that differ
+	Resized bool    `json:"resized,omitempty"`
+	Error   string  `json:"error,omitempty"` // why the route could not be compared
+}
+
+// Over returns the routes whose change exceeds the report's threshold.
+func (r *VisualReport) Over() []VisualDiff {
+	var over []VisualDiff
+	for _, d := range r.Routes {
+		if d.Error == "" && d.Changed > r.Threshold {
+			over = append(over, d)
+		}
+	}
+	return over
+}
+
+// Summary renders the report for prompts and stage summaries.
+func (r *VisualReport) Summary() string {
+	if len(r.Routes) == 0 {
+		return fmt.Sprintf("No routes could be compared with %s.", r.BaseRef)
+	}
+	lines := []string{fmt.Sprintf("Visual changes against %s (approval needed above %.2f%% of pixels):", r.BaseRef, r.Threshold)}
+	for _, d := range r.Routes {
+		switch {
+		case d.Error != "":
+			lines = append(lines, fmt.Sprintf("- %s: not compared, %s", d.Route, d.Error))
+		case d.Changed == 0:
+			lines = append(lines, fmt.Sprintf("- %s: unchanged", d.Route))
+		default:
+			line := fmt.Sprintf("- %s: %.2f%% of pixels changed", d.Route, d.Changed)
+			if d.Resized {
+				line += ", page size changed"
+			}
+			if d.Changed > r.Threshold {
+				line += " (needs approval)"
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteVisualReport saves r as JSON at path.
+func WriteVisualReport(path string, r *VisualReport) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// ReadVisualReport loads a report saved by WriteVisualReport.
+func ReadVisualReport(path string) (*VisualReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r VisualReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse visual report: %w", err)
+	}
+	return &r, nil
+}
+
+// DiffPNGs compares two PNG screenshots and writes an image of the
+// difference to diffPath. It returns the percent of pixels that changed and
+// whether the images differ in size.
+func DiffPNGs(basePath, headPath, diffPath string) (float64, bool, error) {
+	base, err := readPNG(basePath)
+	if err != nil {
+		return 0, false, err
+	}
+	head, err := readPNG(headPath)
+	if err != nil {
+		return 0, false, err
+	}
+	diff, changed := DiffImages(base, head)
+	if err := os.MkdirAll(filepath.Dir(diffPath), 0o755); err != nil {
+		return 0, false, err
+	}
+	f, err := os.Create(diffPath)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	if err := png.Encode(f, diff); err != nil {
+		return 0, false, err
+	}
+	return changed, base.Bounds().Size() != head.Bounds().Size(), nil
+}
+
+// DiffImages compares base and head pixel by pixel over the area of both.
+// Pixels present in only one image count as changed. The returned image
+// shows head faded to grey with changed pixels in red, and the float is
+// the percent of pixels that changed.
+func DiffImages(base, head image.Image) (*image.RGBA, float64) {
+	bb, hb := base.Bounds(), head.Bounds()
+	w, h := max(bb.Dx(), hb.Dx()), max(bb.Dy(), hb.Dy())
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == 0 || h == 0 {
+		return out, 0
+	}
+	red := color.RGBA{R: 255, A: 255}
+	changed := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			inBase := x < bb.Dx() && y < bb.Dy()
+			inHead := x < hb.Dx() && y < hb.Dy()
+			if !inBase || !inHead {
+				changed++
+				out.SetRGBA(x, y, red)
+				continue
+			}
+			bc := base.At(bb.Min.X+x, bb.Min.Y+y)
+			hc := head.At(hb.Min.X+x, hb.Min.Y+y)
+			if pixelChanged(bc, hc) {
+				changed++
+				out.SetRGBA(x, y, red)
+				continue
+			}
+			g := color.GrayModel.Convert(hc).(color.Gray).Y
+			g = 255 - (255-g)/4 // faded so the red stands out
+			out.SetRGBA(x, y, color.RGBA{R: g, G: g, B: g, A: 255})
+		}
+	}
+	return out, float64(changed) * 100 / float64(w*h)
+}
+
+// pixelChanged reports whether any channel of a and b differs by more than
+// visualTolerance.
+func pixelChanged(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	for _, d := range [][2]uint32{{ar, br}, {ag, bg}, {ab, bb}, {aa, ba}} {
+		x, y := int(d[0]>>8), int(d[1]>>8)
+		if x-y > visualTolerance || y-x > visualTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", filepath.Base(path), err)
+	}
+	return img, nil
+}
@@ -0,0 +1,128 @@
+package qa
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDiffImages(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	base := solid(10, 10, white)
+
+	if _, changed := DiffImages(base, solid(10, 10, white)); changed != 0 {
+		t.Errorf("identical images: changed = %v, want 0", changed)
+	}
+
+	// A slightly different shade is rendering noise, not a change.
+	if _, changed := DiffImages(base, solid(10, 10, color.RGBA{245, 245, 245, 255})); changed != 0 {
+		t.Errorf("within tolerance: changed = %v, want 0", changed)
+	}
+
+	head := solid(10, 10, white)
+	for x := 0; x < 10; x++ {
+		head.Set(x, 0, color.RGBA{0, 0, 0, 255})
+	}
+	diff, changed := DiffImages(base, head)
+	if changed != 10 {
+		t.Errorf("one row changed: changed = %v, want 10", changed)
+	}
+	if got := diff.RGBAAt(0, 0); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("changed pixel = %v, want red", got)
+	}
+	if got := diff.RGBAAt(0, 5); got.R != got.G {
+		t.Errorf("unchanged pixel = %v, want grey", got)
+	}
+}
+
+func TestDiffImages_Resized(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	diff, changed := DiffImages(solid(10, 10, white), solid(10, 20, white))
+	if diff.Bounds().Dy() != 20 {
+		t.Errorf("diff height = %d, want 20", diff.Bounds().Dy())
+	}
+	if math.Abs(changed-50) > 1e-9 {
+		t.Errorf("changed = %v, want 50 (the added half)", changed)
+	}
+}
+
+func TestDiffPNGs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, img image.Image) string {
+		path := filepath.Join(dir, name)
+		f, _ := os.Create(path)
+		defer f.Close()
+		png.Encode(f, img)
+		return path
+	}
+	base := write("base.png", solid(4, 4, color.White))
+	head := write("head.png", solid(4, 5, color.White))
+	diffPath := filepath.Join(dir, "diff", "root.png")
+
+	changed, resized, err := DiffPNGs(base, head, diffPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resized || changed != 20 {
+		t.Errorf("changed = %v resized = %v, want 20 and true", changed, resized)
+	}
+	if _, err := os.Stat(diffPath); err != nil {
+		t.Errorf("diff image not written: %v", err)
+	}
+
+	if _, _, err := DiffPNGs(filepath.Join(dir, "missing.png"), head, diffPath); err == nil {
+		t.Error("expected an error for a missing screenshot")
+	}
+}
+
+func TestVisualReport(t *testing.T) {
+	r := &VisualReport{BaseRef: "origin/main", Threshold: 0.5, Routes: []VisualDiff{
+		{Route: "/"},
+		{Route: "/settings", Changed: 12.5, Resized: true},
+		{Route: "/about", Changed: 0.2},
+		{Route: "/new", Error: "no screenshot on origin/main"},
+	}}
+	over := r.Over()
+	if len(over) != 1 || over[0].Route != "/settings" {
+		t.Errorf("Over() = %+v, want /settings", over)
+	}
+	summary := r.Summary()
+	for _, want := range []string{
+		"against origin/main",
+		"- /: unchanged",
+		"- /settings: 12.50% of pixels changed, page size changed (needs approval)",
+		"- /about: 0.20% of pixels changed\n",
+		"- /new: not compared, no screenshot on origin/main",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "visual", "report.json")
+	if err := WriteVisualReport(path, r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadVisualReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BaseRef != r.BaseRef || len(got.Routes) != 4 || got.Routes[1].Changed != 12.5 {
+		t.Errorf("round trip = %+v", got)
+	}
+}
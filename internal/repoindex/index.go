@@ -0,0 +1,158 @@
+// Package repoindex builds a local symbol index of a repository and ranks its
+// files against free text (an issue) so prompts can point the agent at the
+// code most likely to matter. Go files are parsed with go/parser; other
+// languages use ctags-style line patterns. Everything runs offline.
+package repoindex
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxFileSize skips generated bundles and fixtures that would only add noise.
+const maxFileSize = 512 << 10
+
+// skipDirs are never indexed when the tree is walked without git.
+var skipDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true,
+	"target": true, "__pycache__": true, "worktrees": true,
+}
+
+// Symbol is a named declaration in a file.
+type Symbol struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // func, method, type, class, const, var, ...
+	Line int    `json:"line"`
+}
+
+// File is one indexed source file.
+type File struct {
+	Path    string   `json:"path"` // slash-separated, relative to the root
+	Lang    string   `json:"lang"`
+	Size    int64    `json:"size"`
+	ModTime int64    `json:"mod_time"`
+	Symbols []Symbol `json:"symbols,omitempty"`
+}
+
+// Index is the symbol index of one directory tree.
+type Index struct {
+	Root  string `json:"root"`
+	Files []File `json:"files"`
+}
+
+// Build indexes the tree at root. When cacheDir is non-empty the index is
+// persisted there and files whose size and mtime are unchanged since the
+// previous build are not parsed again.
+func Build(root, cacheDir string) (*Index, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := listFiles(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := make(map[string]File)
+	cachePath := ""
+	if cacheDir != "" {
+		sum := sha1.Sum([]byte(abs))
+		cachePath = filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+".json")
+		if prev, err := readIndex(cachePath); err == nil && prev.Root == abs {
+			for _, f := range prev.Files {
+				cached[f.Path] = f
+			}
+		}
+	}
+
+	idx := &Index{Root: abs}
+	for _, rel := range paths {
+		lang := langFor(rel)
+		if lang == "" {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(abs, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxFileSize {
+			continue
+		}
+		if f, ok := cached[rel]; ok && f.Size == info.Size() && f.ModTime == info.ModTime().UnixNano() {
+			idx.Files = append(idx.Files, f)
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(abs, filepath.FromSlash(rel)))
+		if err != nil || bytes.IndexByte(src, 0) >= 0 {
+			continue // unreadable or binary
+		}
+		idx.Files = append(idx.Files, File{
+			Path:    rel,
+			Lang:    lang,
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+			Symbols: extractSymbols(lang, rel, src),
+		})
+	}
+	sort.Slice(idx.Files, func(i, j int) bool { return idx.Files[i].Path < idx.Files[j].Path })
+
+	if cachePath != "" {
+		if data, err := json.Marshal(idx); err == nil {
+			if err := os.MkdirAll(cacheDir, 0o755); err == nil {
+				_ = os.WriteFile(cachePath, data, 0o644)
+			}
+		}
+	}
+	return idx, nil
+}
+
+func readIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// listFiles returns the tracked and untracked-but-not-ignored files of a git
+// checkout, or walks the tree when root is not one.
+func listFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-co", "--exclude-standard")
+	cmd.Dir = root
+	if out, err := cmd.Output(); err == nil {
+		var paths []string
+		for _, line := range strings.Split(string(out), "\n") {
+			if line != "" {
+				paths = append(paths, line)
+			}
+		}
+		return paths, nil
+	}
+
+	var paths []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != root && (strings.HasPrefix(d.Name(), ".") || skipDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel, err := filepath.Rel(root, p); err == nil {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return paths, err
+}
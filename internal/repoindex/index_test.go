@@ -0,0 +1,201 @@
+package repoindex
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRepo(t *testing.T) string {
+	root := t.TempDir()
+	writeFile(t, root, "auth/session.go", `package auth
+
+type Session struct{ Token string }
+
+func (s *Session) Refresh() error { return nil }
+
+func NewSession(token string) *Session { return &Session{Token: token} }
+
+const defaultTTL = 3600
+`)
+	writeFile(t, root, "billing/invoice.go", `package billing
+
+func RenderInvoice() string { return "" }
+`)
+	writeFile(t, root, "web/login.ts", `export async function submitLogin(user: string) {}
+export class LoginForm {}
+export const refreshSession = async () => {}
+`)
+	writeFile(t, root, "scripts/tokens.py", `class TokenStore:
+    def rotate_tokens(self):
+        pass
+`)
+	writeFile(t, root, "node_modules/lib/index.js", `function sessionRefresh() {}`)
+	return root
+}
+
+func TestBuild_ExtractsSymbols(t *testing.T) {
+	idx, err := Build(testRepo(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	byPath := make(map[string]File)
+	for _, f := range idx.Files {
+		paths = append(paths, f.Path)
+		byPath[f.Path] = f
+	}
+	want := []string{"auth/session.go", "billing/invoice.go", "scripts/tokens.py", "web/login.ts"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("files = %v, want %v (node_modules skipped)", paths, want)
+	}
+
+	var goSyms []string
+	for _, s := range byPath["auth/session.go"].Symbols {
+		goSyms = append(goSyms, s.Kind+" "+s.Name)
+	}
+	if want := []string{"type Session", "method Session.Refresh", "func NewSession", "const defaultTTL"}; !reflect.DeepEqual(goSyms, want) {
+		t.Errorf("go symbols = %v, want %v", goSyms, want)
+	}
+
+	var tsSyms []string
+	for _, s := range byPath["web/login.ts"].Symbols {
+		tsSyms = append(tsSyms, s.Name)
+	}
+	if want := []string{"submitLogin", "LoginForm", "refreshSession"}; !reflect.DeepEqual(tsSyms, want) {
+		t.Errorf("ts symbols = %v, want %v", tsSyms, want)
+	}
+	if syms := byPath["scripts/tokens.py"].Symbols; len(syms) != 2 || syms[1].Name != "rotate_tokens" || syms[1].Line != 2 {
+		t.Errorf("python symbols = %+v", syms)
+	}
+}
+
+func TestBuild_CacheReusesUnchangedFiles(t *testing.T) {
+	root := testRepo(t)
+	cache := t.TempDir()
+	if _, err := Build(root, cache); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(cache)
+	if len(entries) != 1 {
+		t.Fatalf("expected one cache file, got %d", len(entries))
+	}
+
+	// Tamper with the cached symbols: an unchanged file keeps them, an
+	// edited one is parsed again.
+	cachePath := filepath.Join(cache, entries[0].Name())
+	data, _ := os.ReadFile(cachePath)
+	data = []byte(strings.Replace(string(data), `"RenderInvoice"`, `"CachedName"`, 1))
+	if err := os.WriteFile(cachePath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := Build(root, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range idx.Files {
+		if f.Path == "billing/invoice.go" && f.Symbols[0].Name != "CachedName" {
+			t.Errorf("unchanged file should come from the cache, got %q", f.Symbols[0].Name)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := Terms("Refresh the HTTPServer session_tokens when parseJSON fails")
+	want := []string{"refresh", "http", "server", "session", "token", "parse", "json", "fail"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Terms = %v, want %v", got, want)
+	}
+}
+
+func TestSearch_RanksByIssueText(t *testing.T) {
+	idx, err := Build(testRepo(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := idx.Search("Session refresh fails after the token expires", nil, 3)
+	if len(results) == 0 || results[0].Path != "auth/session.go" {
+		t.Fatalf("expected auth/session.go first, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Path == "billing/invoice.go" {
+			t.Errorf("unrelated file ranked: %+v", r)
+		}
+	}
+	var names []string
+	for _, s := range results[0].Symbols {
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Session", "Session.Refresh", "NewSession"}) {
+		t.Errorf("matched symbols = %v", names)
+	}
+
+	// Changed files are excluded but their names feed the query.
+	results = idx.Search("", []string{"auth/session.go"}, 5)
+	if len(results) == 0 || results[0].Path != "web/login.ts" {
+		t.Errorf("expected web/login.ts via changed file names, got %+v", results)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	out := Format(
+		[]Result{{Path: "auth/session.go", Symbols: []Symbol{{Name: "Session.Refresh", Kind: "method", Line: 5}}}},
+		[]Change{{Hash: "abc1234", Subject: "Fix session refresh (#12)"}},
+	)
+	for _, want := range []string{
+		"- `auth/session.go`: method `Session.Refresh` (line 5)",
+		"- abc1234 Fix session refresh (#12)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if Format(nil, nil) != "" {
+		t.Error("empty input should format to empty string")
+	}
+}
+
+func TestRelatedChanges(t *testing.T) {
+	root := testRepo(t)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("add", "auth")
+	git("commit", "-q", "-m", "Add session refresh (#7)")
+	git("add", "billing")
+	git("commit", "-q", "-m", "Render invoices as PDF")
+	git("add", ".")
+	git("commit", "-q", "-m", "Rotate session tokens nightly")
+
+	changes := RelatedChanges(root, "session refresh is broken", []string{"auth/session.go"}, 5)
+	var subjects []string
+	for _, c := range changes {
+		subjects = append(subjects, c.Subject)
+	}
+	want := []string{"Add session refresh (#7)", "Rotate session tokens nightly"}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Errorf("subjects = %v, want %v", subjects, want)
+	}
+}
@@ -0,0 +1,363 @@
+package repoindex
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Result is a file ranked against a query, with the symbols that matched.
+type Result struct {
+	Path    string   `json:"path"`
+	Score   float64  `json:"score"`
+	Symbols []Symbol `json:"symbols,omitempty"`
+}
+
+// Change is a past commit related to a query.
+type Change struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+}
+
+// stopWords are dropped from queries; they match everywhere.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"from": true, "into": true, "when": true, "should": true, "must": true, "will": true,
+	"are": true, "not": true, "but": true, "can": true, "have": true, "has": true,
+	"was": true, "were": true, "been": true, "also": true, "any": true, "all": true,
+	"its": true, "our": true, "you": true, "your": true, "they": true, "them": true,
+	"add": true, "use": true, "make": true, "new": true, "get": true, "set": true,
+	"issue": true, "feature": true, "support": true, "need": true, "needs": true,
+	"which": true, "what": true, "there": true, "then": true, "than": true, "only": true,
+	"func": true, "return": true, "string": true, "error": true, "test": true, "tests": true,
+}
+
+// Terms splits text into lowercase search terms. Identifiers are split on
+// case changes, underscores and digits; stop words and short words are
+// dropped, and a plural "s" is removed.
+func Terms(text string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, word := range splitWords(text) {
+		t := normalize(word)
+		if len(t) < 3 || stopWords[t] || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+func splitWords(text string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+	runes := []rune(text)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) {
+			flush()
+			continue
+		}
+		// Split camelCase and HTTPServer-style acronyms.
+		if len(cur) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return words
+}
+
+func normalize(word string) string {
+	t := strings.ToLower(word)
+	if len(t) > 4 && strings.HasSuffix(t, "s") && !strings.HasSuffix(t, "ss") {
+		t = strings.TrimSuffix(t, "s")
+	}
+	return t
+}
+
+// fileTerms returns the terms of a file's path and of each of its symbols.
+func fileTerms(f File) (pathTerms map[string]bool, symTerms []map[string]bool) {
+	pathTerms = make(map[string]bool)
+	for _, t := range Terms(strings.TrimSuffix(f.Path, path.Ext(f.Path))) {
+		pathTerms[t] = true
+	}
+	symTerms = make([]map[string]bool, len(f.Symbols))
+	for i, s := range f.Symbols {
+		symTerms[i] = make(map[string]bool)
+		for _, t := range Terms(s.Name) {
+			symTerms[i][t] = true
+		}
+	}
+	return pathTerms, symTerms
+}
+
+// Search ranks the index's files against query text and returns the best n.
+// Terms are weighted by rarity across the repository; a match in the path
+// counts more than one in a symbol name. Files next to the changed files get
+// a boost, and the changed files themselves are left out since the agent
+// already sees them.
+func (idx *Index) Search(query string, changed []string, n int) []Result {
+	terms := Terms(query)
+	changedSet := make(map[string]bool)
+	changedDirs := make(map[string]bool)
+	for _, c := range changed {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		changedSet[c] = true
+		changedDirs[path.Dir(c)] = true
+		// Names declared in changed files are good queries for their callers.
+		terms = append(terms, Terms(strings.TrimSuffix(path.Base(c), path.Ext(c)))...)
+	}
+	if len(terms) == 0 && len(changedDirs) == 0 {
+		return nil
+	}
+
+	type fileInfo struct {
+		pathTerms map[string]bool
+		symTerms  []map[string]bool
+	}
+	infos := make([]fileInfo, len(idx.Files))
+	df := make(map[string]int)
+	for i, f := range idx.Files {
+		pt, st := fileTerms(f)
+		infos[i] = fileInfo{pt, st}
+		present := make(map[string]bool)
+		for t := range pt {
+			present[t] = true
+		}
+		for _, s := range st {
+			for t := range s {
+				present[t] = true
+			}
+		}
+		for t := range present {
+			df[t]++
+		}
+	}
+
+	total := float64(len(idx.Files))
+	var results []Result
+	for i, f := range idx.Files {
+		if changedSet[f.Path] {
+			continue
+		}
+		score := 0.0
+		var matched []Symbol
+		for _, t := range uniq(terms) {
+			if df[t] == 0 {
+				continue
+			}
+			idf := math.Log(1 + total/float64(df[t]))
+			if infos[i].pathTerms[t] {
+				score += 2 * idf
+			}
+			hits := 0
+			for j, st := range infos[i].symTerms {
+				if st[t] {
+					hits++
+					if !containsSymbol(matched, f.Symbols[j]) {
+						matched = append(matched, f.Symbols[j])
+					}
+				}
+			}
+			score += idf * float64(min(hits, 3))
+		}
+		if score > 0 && changedDirs[path.Dir(f.Path)] {
+			score += 1
+		}
+		if score <= 0 {
+			continue
+		}
+		if f.Lang == "text" {
+			score /= 2 // docs and config rank below code with the same terms
+		}
+		results = append(results, Result{Path: f.Path, Score: score, Symbols: matched})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if n > 0 && len(results) > n {
+		results = results[:n]
+	}
+	return results
+}
+
+func uniq(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	out := ss[:0:0]
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func containsSymbol(syms []Symbol, s Symbol) bool {
+	for _, x := range syms {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// prRef matches the PR number in squash ("Title (#12)") and merge
+// ("Merge pull request #12 from ...") commit subjects.
+var prRef = regexp.MustCompile(`\(#\d+\)|pull request #\d+`)
+
+// RelatedChanges returns up to n past commits on the default branch whose
+// subjects share terms with query, preferring commits that touched files.
+// Commits that reference a PR rank above plain commits. It reads only the
+// local git history.
+func RelatedChanges(dir, query string, files []string, n int) []Change {
+	terms := make(map[string]bool)
+	for _, t := range Terms(query) {
+		terms[t] = true
+	}
+	if len(terms) == 0 || n <= 0 {
+		return nil
+	}
+
+	rev := "HEAD"
+	for _, b := range []string{"main", "master"} {
+		if exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", b).Run() == nil {
+			rev = b
+			break
+		}
+	}
+
+	type scored struct {
+		Change
+		score int
+	}
+	seen := make(map[string]bool)
+	var all []scored
+	collect := func(bonus int, args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "log", "-n", "300", "--format=%h\x1f%s", rev}, args...)...)
+		out, err := cmd.Output()
+		if err != nil {
+			return
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			hash, subject, ok := strings.Cut(line, "\x1f")
+			if !ok || seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			score := 0
+			for _, t := range Terms(subject) {
+				if terms[t] {
+					score += 2
+				}
+			}
+			if score == 0 {
+				continue
+			}
+			if prRef.MatchString(subject) {
+				score++
+			}
+			all = append(all, scored{Change{Hash: hash, Subject: subject}, score + bonus})
+		}
+	}
+	if len(files) > 0 {
+		collect(2, append([]string{"--"}, files...)...)
+	}
+	collect(0)
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].score > all[j].score })
+	var out []Change
+	for i := 0; i < len(all) && i < n; i++ {
+		out = append(out, all[i].Change)
+	}
+	return out
+}
+
+// maxSymbolsShown caps the symbols listed per file.
+const maxSymbolsShown = 6
+
+// Format renders results and related changes as Markdown for a prompt.
+// Empty when both are empty.
+func Format(results []Result, changes []Change) string {
+	if len(results) == 0 && len(changes) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	if len(results) > 0 {
+		sb.WriteString("Files likely relevant to this issue, best match first:\n")
+		for _, r := range results {
+			fmt.Fprintf(&sb, "- `%s`", r.Path)
+			if len(r.Symbols) > 0 {
+				parts := make([]string, 0, maxSymbolsShown)
+				for i, s := range r.Symbols {
+					if i == maxSymbolsShown {
+						parts = append(parts, fmt.Sprintf("+%d more", len(r.Symbols)-i))
+						break
+					}
+					parts = append(parts, fmt.Sprintf("%s `%s` (line %d)", s.Kind, s.Name, s.Line))
+				}
+				sb.WriteString(": " + strings.Join(parts, ", "))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if len(changes) > 0 {
+		if len(results) > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("Related past changes (inspect with `git show <hash>`):\n")
+		for _, c := range changes {
+			fmt.Fprintf(&sb, "- %s %s\n", c.Hash, c.Subject)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Provider answers relevance queries for a worktree, caching indexes under
+// CacheDir.
+type Provider struct {
+	CacheDir string
+}
+
+// NewProvider returns a Provider that caches indexes under cacheDir.
+func NewProvider(cacheDir string) *Provider {
+	return &Provider{CacheDir: cacheDir}
+}
+
+// Relevant indexes dir and returns the n files most relevant to query and
+// the changed files, plus related past changes, formatted for a prompt.
+func (p *Provider) Relevant(dir, query string, changed []string, n int) (string, error) {
+	idx, err := Build(dir, p.CacheDir)
+	if err != nil {
+		return "", fmt.Errorf("index %s: %w", dir, err)
+	}
+	results := idx.Search(query, changed, n)
+	files := append([]string(nil), changed...)
+	for _, r := range results {
+		files = append(files, r.Path)
+	}
+	return Format(results, RelatedChanges(dir, query, files, max(n/2, 3))), nil
+}
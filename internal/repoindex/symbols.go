@@ -0,0 +1,163 @@
+package repoindex
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// langs maps file extensions to a language name. Files with other extensions
+// are not indexed; languages without patterns are indexed by path only.
+var langs = map[string]string{
+	".go": "go",
+	".py": "python",
+	".js": "js", ".jsx": "js", ".mjs": "js", ".cjs": "js", ".ts": "js", ".tsx": "js",
+	".rb":   "ruby",
+	".rs":   "rust",
+	".java": "java", ".kt": "java", ".cs": "java", ".scala": "java",
+	".c": "c", ".h": "c", ".cc": "c", ".cpp": "c", ".hpp": "c",
+	".php":   "php",
+	".sh":    "shell",
+	".sql":   "sql",
+	".proto": "proto",
+	".vue":   "js", ".svelte": "js",
+	".md": "text", ".yaml": "text", ".yml": "text", ".toml": "text",
+}
+
+func langFor(p string) string {
+	return langs[strings.ToLower(path.Ext(p))]
+}
+
+type pattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// patterns are ctags-style declaration matchers; the first submatch is the
+// symbol name. They are applied line by line.
+var patterns = map[string][]pattern{
+	"python": {
+		{"func", regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`)},
+		{"class", regexp.MustCompile(`^\s*class\s+(\w+)`)},
+	},
+	"js": {
+		{"func", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+(\w+)`)},
+		{"class", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`)},
+		{"type", regexp.MustCompile(`^\s*(?:export\s+)?(?:declare\s+)?(?:interface|type|enum)\s+(\w+)`)},
+		{"func", regexp.MustCompile(`^\s*(?:export\s+)?const\s+(\w+)\s*=\s*(?:async\s+)?(?:\([^)]*\)|\w+)\s*=>`)},
+	},
+	"ruby": {
+		{"func", regexp.MustCompile(`^\s*def\s+(?:self\.)?(\w+[?!]?)`)},
+		{"class", regexp.MustCompile(`^\s*(?:class|module)\s+([\w:]+)`)},
+	},
+	"rust": {
+		{"func", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+(\w+)`)},
+		{"type", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type)\s+(\w+)`)},
+	},
+	"java": {
+		{"class", regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|abstract|final|static|sealed|data|open)\s+)*(?:class|interface|enum|record|object)\s+(\w+)`)},
+		{"func", regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|final|override|suspend|abstract)\s+)+[\w<>\[\],\s]*?\s*(?:fun\s+)?(\w+)\s*\(`)},
+	},
+	"c": {
+		{"type", regexp.MustCompile(`^\s*(?:typedef\s+)?(?:struct|class|enum|union)\s+(\w+)\s*[{:]?\s*$`)},
+		{"func", regexp.MustCompile(`^[A-Za-z_][\w\s\*&:<>,]*?[\s\*&]([A-Za-z_]\w*)\s*\([^;]*$`)},
+	},
+	"php": {
+		{"func", regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|final|abstract)\s+)*function\s+(\w+)`)},
+		{"class", regexp.MustCompile(`^\s*(?:abstract\s+|final\s+)?(?:class|interface|trait)\s+(\w+)`)},
+	},
+	"shell": {
+		{"func", regexp.MustCompile(`^\s*(?:function\s+)?([A-Za-z_][\w-]*)\s*\(\)\s*\{?`)},
+	},
+	"sql": {
+		{"table", regexp.MustCompile(`(?i)^\s*create\s+(?:table|view|index|type)\s+(?:if\s+not\s+exists\s+)?([\w."]+)`)},
+	},
+	"proto": {
+		{"type", regexp.MustCompile(`^\s*(?:message|enum|service)\s+(\w+)`)},
+		{"func", regexp.MustCompile(`^\s*rpc\s+(\w+)`)},
+	},
+}
+
+// extractSymbols returns the declarations in src.
+func extractSymbols(lang, name string, src []byte) []Symbol {
+	if lang == "go" {
+		if syms, ok := goSymbols(name, src); ok {
+			return syms
+		}
+	}
+	pats := patterns[lang]
+	if len(pats) == 0 {
+		return nil
+	}
+	var syms []Symbol
+	for i, line := range strings.Split(string(src), "\n") {
+		for _, p := range pats {
+			if m := p.re.FindStringSubmatch(line); m != nil {
+				syms = append(syms, Symbol{Name: strings.Trim(m[1], `"`), Kind: p.kind, Line: i + 1})
+				break
+			}
+		}
+	}
+	return syms
+}
+
+// goSymbols lists the top-level declarations of a Go file. Methods are named
+// Recv.Method. ok is false when the file does not parse at all.
+func goSymbols(name string, src []byte) ([]Symbol, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+	if f == nil {
+		return nil, false
+	}
+	_ = err // a partial AST still yields the declarations before the error
+
+	var syms []Symbol
+	add := func(n, kind string, pos token.Pos) {
+		if n != "_" {
+			syms = append(syms, Symbol{Name: n, Kind: kind, Line: fset.Position(pos).Line})
+		}
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add(recvName(d.Recv.List[0].Type)+"."+d.Name.Name, "method", d.Pos())
+			} else {
+				add(d.Name.Name, "func", d.Pos())
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name, "type", s.Pos())
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, id := range s.Names {
+						add(id.Name, kind, id.Pos())
+					}
+				}
+			}
+		}
+	}
+	return syms, true
+}
+
+func recvName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return recvName(t.X)
+	case *ast.IndexExpr:
+		return recvName(t.X)
+	case *ast.IndexListExpr:
+		return recvName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
@@ -0,0 +1,243 @@
+// Package sandbox runs agent sessions, check commands and the project
+// commands of migration_check and best-of-N setup inside a rootless container
+// (pipeline.sandbox) so they cannot reach the rest of the host.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+// Home is the container's home directory: a tmpfs, so nothing the agent
+// writes there outlives the container.
+const Home = "/home/factory"
+
+// Spec describes one container run. Host paths are mounted at the same path
+// inside the container, so paths in prompts and hook commands stay valid.
+type Spec struct {
+	Config      *config.SandboxConfig
+	Name        string   // container name
+	Workdir     string   // mounted read-write; the working directory
+	GitDir      string   // the repo's common git directory, mounted read-only (see gitWritable)
+	WorktreeDir string   // the linked worktree's own git directory (.git/worktrees/<name>), mounted read-write
+	Env         []string // NAME (passed through from the runtime's environment) or NAME=value
+	ReadOnly    []string // extra host paths mounted read-only
+	ReadWrite   []string // extra host paths mounted read-write
+	Interactive bool     // allocate a terminal
+}
+
+// gitWritable are the parts of the common git directory a commit in a linked
+// worktree writes to. They are mounted read-write over the read-only git
+// directory; config, hooks and info stay read-only, so nothing in the
+// container can change what git runs on the host. refs is shared by every
+// branch, so a session can still move other branches' refs.
+var gitWritable = []string{"objects", "refs", "logs"}
+
+// Args returns the runtime invocation that runs command in the container.
+func (s Spec) Args(command ...string) []string {
+	rt := s.Config.RuntimeName()
+	args := []string{rt, "run", "--rm", "--name", s.Name}
+	if s.Interactive {
+		args = append(args, "-it")
+	}
+	args = append(args, "--cap-drop", "ALL", "--security-opt", "no-new-privileges")
+	// Run as the host user so files written to the worktree keep its ownership.
+	if rt == config.SandboxPodman {
+		args = append(args, "--userns", "keep-id")
+	} else {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	if s.Config.Network != "" {
+		args = append(args, "--network", s.Config.Network)
+	}
+	if s.Config.CPUs != "" {
+		args = append(args, "--cpus", s.Config.CPUs)
+	}
+	if s.Config.Memory != "" {
+		args = append(args, "--memory", s.Config.Memory)
+	}
+	if s.Config.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(s.Config.PidsLimit))
+	}
+	args = append(args, "--tmpfs", Home+":rw,exec,mode=1777", "-e", "HOME="+Home)
+
+	args = append(args, "-v", s.Workdir+":"+s.Workdir)
+	if s.GitDir != "" {
+		// Also when the git directory is inside the workdir: the read-only
+		// mount covers the read-write one there.
+		args = append(args, "-v", s.GitDir+":"+s.GitDir+":ro")
+		for _, sub := range gitWritable {
+			if p := filepath.Join(s.GitDir, sub); isDir(p) {
+				args = append(args, "-v", p+":"+p)
+			}
+		}
+		if s.WorktreeDir != "" && s.WorktreeDir != s.GitDir {
+			args = append(args, "-v", s.WorktreeDir+":"+s.WorktreeDir)
+		}
+	}
+	for _, p := range s.ReadWrite {
+		args = append(args, "-v", p+":"+p)
+	}
+	for _, p := range s.ReadOnly {
+		args = append(args, "-v", p+":"+p+":ro")
+	}
+	args = append(args, "-w", s.Workdir)
+
+	for _, e := range s.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, s.Config.Image)
+	return append(args, command...)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// GitCommonDir returns the git directory shared by the worktree at dir and
+// its main repo, or "" if dir is not in a git repo.
+func GitCommonDir(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// WorktreeGitDir returns the git directory of the worktree at dir: for a
+// linked worktree its .git/worktrees/<name>, for the main checkout the common
+// git directory. It returns "" if dir is not in a git repo.
+func WorktreeGitDir(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--absolute-git-dir")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// EnvNames returns the sorted keys of env as pass-through -e entries.
+func EnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Runner runs check commands in a container. It implements
+// checks.CommandRunner; each command gets a fresh container for its dir.
+type Runner struct {
+	Config *config.SandboxConfig
+	Name   string   // container name prefix
+	Env    []string // NAME=value entries set in every container, passed by name
+}
+
+// Run executes command with sh -c in a container with dir mounted.
+func (r *Runner) Run(ctx context.Context, dir string, command string) (string, string, int, error) {
+	stdout, stderr, exitCode, _, err := r.RunMetered(ctx, dir, command, cgroup.Limits{})
+	return stdout, stderr, exitCode, err
+}
+
+// RunMetered is Run with limits applied as the container's. The runtime
+// accounts for the container, not factory, so no usage is reported.
+func (r *Runner) RunMetered(ctx context.Context, dir string, command string, limits cgroup.Limits) (string, string, int, cgroup.Usage, error) {
+	stdout, stderr, exitCode, err := r.run(ctx, dir, command, WithLimits(r.Config, limits))
+	return stdout, stderr, exitCode, cgroup.Usage{}, err
+}
+
+func (r *Runner) run(ctx context.Context, dir string, command string, cfg *config.SandboxConfig) (string, string, int, error) {
+	spec := Spec{
+		Config:      cfg,
+		Name:        fmt.Sprintf("%s-%s", r.Name, strconv.FormatInt(time.Now().UnixNano(), 36)),
+		Workdir:     dir,
+		GitDir:      GitCommonDir(dir),
+		WorktreeDir: WorktreeGitDir(dir),
+	}
+	for _, e := range r.Env {
+		name, _, _ := strings.Cut(e, "=")
+		spec.Env = append(spec.Env, name)
+	}
+	args := spec.Args("sh", "-c", command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if len(r.Env) > 0 {
+		// Values reach the container through the runtime's environment,
+		// so they never appear on its command line.
+		cmd.Env = append(os.Environ(), r.Env...)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// Stopping the client does not stop the container; kill it by name.
+		_ = exec.Command(args[0], "kill", spec.Name).Run()
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 2 * time.Second
+
+	var stdoutBuf, stderrBuf strings.Builder
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	err := cmd.Run()
+	exitCode := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return stdoutBuf.String(), stderrBuf.String(), -1, fmt.Errorf("exec %s: %w", args[0], err)
+		}
+		exitCode = exitErr.ExitCode()
+		// 125: the runtime itself failed, e.g. a missing image.
+		if exitCode == 125 && ctx.Err() == nil {
+			return stdoutBuf.String(), stderrBuf.String(), exitCode,
+				fmt.Errorf("%s run: %s", args[0], strings.TrimSpace(stderrBuf.String()))
+		}
+	}
+	return stdoutBuf.String(), stderrBuf.String(), exitCode, nil
+}
+
+// WithLimits returns cfg with limits replacing its cpus, memory and
+// pids_limit, or cfg itself when limits is zero.
+func WithLimits(cfg *config.SandboxConfig, limits cgroup.Limits) *config.SandboxConfig {
+	if limits.IsZero() {
+		return cfg
+	}
+	c := *cfg
+	if limits.CPUs > 0 {
+		c.CPUs = strconv.FormatFloat(limits.CPUs, 'f', -1, 64)
+	}
+	if limits.MemoryBytes > 0 {
+		c.Memory = strconv.FormatInt(limits.MemoryBytes, 10) + "b"
+	}
+	if limits.Pids > 0 {
+		c.PidsLimit = limits.Pids
+	}
+	return &c
+}
+
+// ShellJoin quotes args for a POSIX shell.
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a != "" && strings.Trim(a, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,@") == "" {
+			quoted[i] = a
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", "'\\''") + "'"
+	}
+	return strings.Join(quoted, " ")
+}
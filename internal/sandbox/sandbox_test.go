@@ -0,0 +1,177 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+func TestSpecArgs_Podman(t *testing.T) {
+	spec := Spec{
+		Config:      &config.SandboxConfig{Image: "agent:latest", Network: "none", CPUs: "2", Memory: "4g", PidsLimit: 256},
+		Name:        "factory-42-implement-1",
+		Workdir:     "/repo/worktrees/issue-42",
+		GitDir:      "/repo/.git",
+		Env:         []string{"API_KEY", "MODE=test"},
+		ReadOnly:    []string{"/usr/local/bin/factory"},
+		Interactive: true,
+	}
+	got := strings.Join(spec.Args("claude", "--print"), " ")
+	for _, want := range []string{
+		"podman run --rm --name factory-42-implement-1 -it",
+		"--cap-drop ALL --security-opt no-new-privileges --userns keep-id",
+		"--network none --cpus 2 --memory 4g --pids-limit 256",
+		"-v /repo/worktrees/issue-42:/repo/worktrees/issue-42 -v /repo/.git:/repo/.git:ro",
+		"-v /usr/local/bin/factory:/usr/local/bin/factory:ro",
+		"-w /repo/worktrees/issue-42",
+		"-e API_KEY -e MODE=test agent:latest claude --print",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("args missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestSpecArgs_Docker(t *testing.T) {
+	spec := Spec{
+		Config:  &config.SandboxConfig{Runtime: "docker", Image: "agent"},
+		Name:    "c",
+		Workdir: "/repo",
+		GitDir:  "/repo/.git",
+	}
+	got := strings.Join(spec.Args(), " ")
+	if !strings.HasPrefix(got, "docker run --rm --name c --cap-drop") {
+		t.Errorf("unexpected prefix: %s", got)
+	}
+	if !strings.Contains(got, fmt.Sprintf("--user %d:%d", os.Getuid(), os.Getgid())) {
+		t.Errorf("expected --user: %s", got)
+	}
+	if strings.Contains(got, "--network") || strings.Contains(got, "--memory") {
+		t.Errorf("unexpected limits without config: %s", got)
+	}
+	if !strings.Contains(got, "-v /repo:/repo -v /repo/.git:/repo/.git:ro") {
+		t.Errorf("git dir inside the workdir should be mounted read-only over it: %s", got)
+	}
+}
+
+func TestSpecArgs_GitMounts(t *testing.T) {
+	common := filepath.Join(t.TempDir(), ".git")
+	for _, d := range []string{"objects", "refs", "logs", "hooks", "worktrees/issue-42"} {
+		if err := os.MkdirAll(filepath.Join(common, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wtGit := filepath.Join(common, "worktrees", "issue-42")
+	spec := Spec{
+		Config:      &config.SandboxConfig{Image: "agent"},
+		Name:        "c",
+		Workdir:     "/work/issue-42",
+		GitDir:      common,
+		WorktreeDir: wtGit,
+	}
+	args := spec.Args()
+	mounts := make(map[string]bool)
+	for i, a := range args {
+		if a == "-v" && i+1 < len(args) {
+			mounts[args[i+1]] = true
+		}
+	}
+	for _, want := range []string{
+		common + ":" + common + ":ro",
+		filepath.Join(common, "objects") + ":" + filepath.Join(common, "objects"),
+		filepath.Join(common, "refs") + ":" + filepath.Join(common, "refs"),
+		filepath.Join(common, "logs") + ":" + filepath.Join(common, "logs"),
+		wtGit + ":" + wtGit,
+	} {
+		if !mounts[want] {
+			t.Errorf("missing mount %s in %v", want, args)
+		}
+	}
+	for m := range mounts {
+		if strings.Contains(m, "hooks") || m == common+":"+common {
+			t.Errorf("hooks and the git directory itself must not be writable: %s", m)
+		}
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := ShellJoin([]string{"podman", "-e", "A=b c", "it's", "", "/x/y:/x/y:ro"})
+	want := `podman -e 'A=b c' 'it'\''s' '' /x/y:/x/y:ro`
+	if got != want {
+		t.Errorf("ShellJoin = %s, want %s", got, want)
+	}
+}
+
+// fakeRuntime puts a podman on PATH that runs the trailing sh -c command on
+// the host, after recording its arguments.
+func fakeRuntime(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" > %s
+while [ "$#" -gt 2 ] && [ "$1" != "sh" ]; do shift; done
+[ "$1" = sh ] || { echo "no image" >&2; exit 125; }
+shift 2
+exec sh -c "$1"
+`, argsFile)
+	if err := os.WriteFile(filepath.Join(dir, "podman"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+func TestRunner_Run(t *testing.T) {
+	argsFile := fakeRuntime(t)
+	dir := t.TempDir()
+	r := &Runner{Config: &config.SandboxConfig{Image: "agent"}, Name: "factory-42-checks", Env: []string{"DATABASE_URL=postgres://db/app"}}
+
+	stdout, _, code, err := r.Run(context.Background(), dir, "echo hello $DATABASE_URL; exit 3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if code != 3 || strings.TrimSpace(stdout) != "hello postgres://db/app" {
+		t.Errorf("got code %d stdout %q", code, stdout)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "-v "+dir+":"+dir) || !strings.Contains(string(args), "--name factory-42-checks-") ||
+		!strings.Contains(string(args), "-e DATABASE_URL ") || strings.Contains(string(args), "postgres://") {
+		t.Errorf("unexpected runtime args: %s", args)
+	}
+}
+
+func TestRunner_RuntimeFailure(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'Error: agent: image not known' >&2\nexit 125\n"
+	if err := os.WriteFile(filepath.Join(dir, "podman"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	r := &Runner{Config: &config.SandboxConfig{Image: "agent"}, Name: "x"}
+	_, _, _, err := r.Run(context.Background(), t.TempDir(), "true")
+	if err == nil || !strings.Contains(err.Error(), "image not known") {
+		t.Errorf("expected the runtime's error, got %v", err)
+	}
+}
+
+func TestWithLimits(t *testing.T) {
+	cfg := &config.SandboxConfig{Image: "agent:latest", CPUs: "4", Memory: "8g", PidsLimit: 1024}
+	if got := WithLimits(cfg, cgroup.Limits{}); got != cfg {
+		t.Error("zero limits should return the config unchanged")
+	}
+	got := WithLimits(cfg, cgroup.Limits{MemoryBytes: 512 << 20, CPUs: 1.5})
+	if got.Memory != "536870912b" || got.CPUs != "1.5" || got.PidsLimit != 1024 || got.Image != "agent:latest" {
+		t.Errorf("unexpected config: %+v", got)
+	}
+	if cfg.Memory != "8g" {
+		t.Error("WithLimits modified its argument")
+	}
+}
@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+// RefPrefix marks a pipeline env value as a reference into the secret store.
+const RefPrefix = "secret://"
+
+// Env is a resolved pipeline environment together with the values that must
+// never be persisted in clear text.
+type Env struct {
+	Vars    map[string]string
+	secrets []string
+}
+
+// Redactor returns a Redactor for the secret values in this environment.
+func (e *Env) Redactor() *Redactor {
+	if e == nil {
+		return NewRedactor()
+	}
+	return NewRedactor(e.secrets...)
+}
+
+// Resolve builds the session environment for cfg. Entries from
+// pipeline.env_file come first, then pipeline.env (with secret:// references
+// looked up in store), then DATABASE_URL when a database is configured.
+// Values from the env file, the store, and the database password are
+// recorded as secrets for redaction.
+func Resolve(cfg *config.PipelineConfig, store *Store) (*Env, error) {
+	env := &Env{Vars: make(map[string]string)}
+	p := cfg.Pipeline
+
+	if p.EnvFile != "" {
+		path := p.EnvFile
+		if !filepath.IsAbs(path) && cfg.Dir != "" {
+			path = filepath.Join(cfg.Dir, path)
+		}
+		vars, err := ReadEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range vars {
+			env.Vars[k] = v
+			env.secrets = append(env.secrets, v)
+		}
+	}
+
+	for k, v := range p.Env {
+		name, ok := strings.CutPrefix(v, RefPrefix)
+		if !ok {
+			env.Vars[k] = v
+			continue
+		}
+		if store == nil {
+			return nil, fmt.Errorf("env %s: no secret store for %s", k, v)
+		}
+		val, err := store.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+		env.Vars[k] = val
+		env.secrets = append(env.secrets, val)
+	}
+
+	if p.Database != nil {
+		env.Vars["DATABASE_URL"] = p.Database.URL()
+		env.secrets = append(env.secrets, p.Database.Password)
+	}
+	return env, nil
+}
+
+// RedactorFor resolves cfg against the default store and returns a Redactor
+// for its secrets. Resolution errors yield a Redactor for whatever could be
+// resolved so callers that only need redaction never fail.
+func RedactorFor(cfg *config.PipelineConfig) *Redactor {
+	if cfg == nil {
+		return NewRedactor()
+	}
+	env, err := Resolve(cfg, DefaultStore())
+	if err != nil {
+		if cfg.Pipeline.Database != nil {
+			return NewRedactor(cfg.Pipeline.Database.Password)
+		}
+		return NewRedactor()
+	}
+	return env.Redactor()
+}
+
+// ReadEnvFile parses a dotenv-style file: KEY=VALUE lines, optional
+// "export " prefixes, blank lines and # comments. Values may be wrapped in
+// single or double quotes.
+func ReadEnvFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open env file: %w", err)
+	}
+	defer f.Close()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		k = strings.TrimSpace(k)
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		vars[k] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read env file: %w", err)
+	}
+	return vars, nil
+}
+
+// WriteEnvFile writes vars as sorted `export K='V'` lines to a new 0600 file
+// in dir (os.TempDir() if empty) and returns its path. The caller sources the
+// file in the session shell and removes it.
+func WriteEnvFile(dir string, vars map[string]string) (string, error) {
+	f, err := os.CreateTemp(dir, "factory-env-*.sh")
+	if err != nil {
+		return "", fmt.Errorf("create env file: %w", err)
+	}
+	defer f.Close()
+	if err := f.Chmod(0o600); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("chmod env file: %w", err)
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w := bufio.NewWriter(f)
+	for _, k := range keys {
+		fmt.Fprintf(w, "export %s=%s\n", k, shellQuote(vars[k]))
+	}
+	if err := w.Flush(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write env file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// shellQuote wraps a string in single quotes for safe shell interpolation.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
@@ -0,0 +1,45 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+)
+
+// Mask replaces redacted secret values.
+const Mask = "[REDACTED]"
+
+// minSecretLen skips values too short to redact without mangling ordinary text.
+const minSecretLen = 4
+
+// Redactor replaces known secret values in text.
+type Redactor struct {
+	values []string
+}
+
+// NewRedactor creates a Redactor for the given values. Empty and very short
+// values are ignored; longer values are replaced first so a secret that
+// contains another is masked whole.
+func NewRedactor(values ...string) *Redactor {
+	seen := make(map[string]bool)
+	var vs []string
+	for _, v := range values {
+		if len(v) < minSecretLen || seen[v] {
+			continue
+		}
+		seen[v] = true
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool { return len(vs[i]) > len(vs[j]) })
+	return &Redactor{values: vs}
+}
+
+// Redact returns s with every known secret value replaced by Mask.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+	return s
+}
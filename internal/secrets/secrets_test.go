@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+func TestStore_SetGetListDelete(t *testing.T) {
+	t.Setenv(KeyEnvVar, "")
+	s := NewStore(t.TempDir())
+
+	if err := s.Set("db-password", "hunter2!"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Set("api-key", "sk-123456"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, err := s.Get("db-password")
+	if err != nil || got != "hunter2!" {
+		t.Errorf("Get = %q, %v; want hunter2!", got, err)
+	}
+	names, err := s.List()
+	if err != nil || strings.Join(names, ",") != "api-key,db-password" {
+		t.Errorf("List = %v, %v", names, err)
+	}
+
+	if err := s.Delete("api-key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get("api-key"); err == nil {
+		t.Error("Get after Delete should fail")
+	}
+	if err := s.Delete("api-key"); err == nil {
+		t.Error("Delete of missing secret should fail")
+	}
+}
+
+func TestStore_EncryptedAt0600(t *testing.T) {
+	t.Setenv(KeyEnvVar, "")
+	dir := t.TempDir()
+	s := NewStore(dir)
+	if err := s.Set("token", "plaintext-value"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"secrets.enc", "key"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Errorf("%s mode = %o, want 600", name, perm)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "secrets.enc"))
+	if strings.Contains(string(data), "plaintext-value") {
+		t.Error("secrets file contains the value in clear text")
+	}
+}
+
+func TestStore_KeyFromEnv(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	t.Setenv(KeyEnvVar, key)
+	dir := t.TempDir()
+	if err := NewStore(dir).Set("a", "value-a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "key")); !os.IsNotExist(err) {
+		t.Error("key file should not be written when the key comes from the environment")
+	}
+
+	t.Setenv(KeyEnvVar, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 32))))
+	if _, err := NewStore(dir).Get("a"); err == nil {
+		t.Error("Get with the wrong key should fail")
+	}
+}
+
+func TestResolve_SecretRefsEnvFileAndDatabase(t *testing.T) {
+	t.Setenv(KeyEnvVar, "")
+	t.Setenv("DATABASE_URL", "")
+	store := NewStore(t.TempDir())
+	if err := store.Set("api-key", "sk-live-abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgDir := t.TempDir()
+	envFile := "# comment\nexport STRIPE_KEY='sk_test_xyz'\nPLAIN=\"quoted value\"\n\n"
+	if err := os.WriteFile(filepath.Join(cfgDir, ".env.factory"), []byte(envFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.PipelineConfig{Dir: cfgDir, Pipeline: config.Pipeline{
+		EnvFile:  ".env.factory",
+		Env:      map[string]string{"API_KEY": "secret://api-key", "LOG_LEVEL": "debug"},
+		Database: &config.DatabaseConfig{Name: "app", User: "app", Password: "pg-pass-1"},
+	}}
+	env, err := Resolve(cfg, store)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	want := map[string]string{
+		"API_KEY":    "sk-live-abc",
+		"LOG_LEVEL":  "debug",
+		"STRIPE_KEY": "sk_test_xyz",
+		"PLAIN":      "quoted value",
+	}
+	for k, v := range want {
+		if env.Vars[k] != v {
+			t.Errorf("Vars[%s] = %q, want %q", k, env.Vars[k], v)
+		}
+	}
+	if !strings.Contains(env.Vars["DATABASE_URL"], "pg-pass-1") {
+		t.Errorf("DATABASE_URL = %q", env.Vars["DATABASE_URL"])
+	}
+
+	got := env.Redactor().Redact("key=sk-live-abc stripe=sk_test_xyz db=pg-pass-1 level=debug")
+	if want := "key=[REDACTED] stripe=[REDACTED] db=[REDACTED] level=debug"; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+}
+
+func TestResolve_MissingSecret(t *testing.T) {
+	t.Setenv(KeyEnvVar, "")
+	cfg := &config.PipelineConfig{Pipeline: config.Pipeline{
+		Env: map[string]string{"API_KEY": "secret://nope"},
+	}}
+	if _, err := Resolve(cfg, NewStore(t.TempDir())); err == nil || !strings.Contains(err.Error(), "API_KEY") {
+		t.Errorf("err = %v, want error naming API_KEY", err)
+	}
+}
+
+func TestRedactor_LongestFirstAndShortValuesIgnored(t *testing.T) {
+	r := NewRedactor("abc", "token", "token-with-suffix", "")
+	got := r.Redact("abc token-with-suffix token")
+	if want := "abc [REDACTED] [REDACTED]"; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+	var nilR *Redactor
+	if nilR.Redact("x") != "x" {
+		t.Error("nil Redactor should pass text through")
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path, err := WriteEnvFile(dir, map[string]string{"B": "it's", "A": "1"})
+	if err != nil {
+		t.Fatalf("WriteEnvFile: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("mode = %o, want 600", perm)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "export A='1'\nexport B='it'\\''s'\n"; string(data) != want {
+		t.Errorf("content = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,197 @@
+// Package secrets resolves secret references in pipeline env vars, stores
+// secrets in a local encrypted file, and redacts known secret values from
+// text that is persisted or sent elsewhere.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+// KeyEnvVar holds a base64-encoded 32-byte key that overrides the key file.
+// Useful in containers where the data dir is shared but the key is injected.
+const KeyEnvVar = "FACTORY_SECRET_KEY"
+
+// Store is a local secret store: a JSON map of name → value, encrypted with
+// AES-256-GCM. The key lives next to it in a 0600 file unless KeyEnvVar is set.
+type Store struct {
+	dir string
+}
+
+// NewStore creates a Store rooted at dir.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the Store at {datadir}/secrets.
+func DefaultStore() *Store {
+	return NewStore(filepath.Join(config.DataDir(), "secrets"))
+}
+
+func (s *Store) dataPath() string { return filepath.Join(s.dir, "secrets.enc") }
+func (s *Store) keyPath() string  { return filepath.Join(s.dir, "key") }
+
+// Get returns the value of the named secret.
+func (s *Store) Get(name string) (string, error) {
+	all, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	v, ok := all[name]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found", name)
+	}
+	return v, nil
+}
+
+// Set creates or replaces the named secret.
+func (s *Store) Set(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("secret name cannot be empty")
+	}
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	all[name] = value
+	return s.save(all)
+}
+
+// Delete removes the named secret.
+func (s *Store) Delete(name string) error {
+	all, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := all[name]; !ok {
+		return fmt.Errorf("secret %q not found", name)
+	}
+	delete(all, name)
+	return s.save(all)
+}
+
+// List returns the names of all stored secrets, sorted.
+func (s *Store) List() ([]string, error) {
+	all, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(all))
+	for k := range all {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// load decrypts the store. A missing store is empty.
+func (s *Store) load() (map[string]string, error) {
+	all := make(map[string]string)
+	data, err := os.ReadFile(s.dataPath())
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secrets: %w", err)
+	}
+
+	gcm, err := s.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("secrets file is corrupt")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt secrets (wrong key?): %w", err)
+	}
+	if err := json.Unmarshal(plain, &all); err != nil {
+		return nil, fmt.Errorf("parse secrets: %w", err)
+	}
+	return all, nil
+}
+
+// save encrypts and writes the store atomically with 0600 permissions.
+func (s *Store) save(all map[string]string) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("mkdir %s: %w", s.dir, err)
+	}
+	gcm, err := s.cipher(true)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("marshal secrets: %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	data := gcm.Seal(nonce, nonce, plain, nil)
+
+	tmp := s.dataPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write secrets: %w", err)
+	}
+	if err := os.Rename(tmp, s.dataPath()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename secrets: %w", err)
+	}
+	return nil
+}
+
+// cipher returns the AES-GCM cipher for the store key. When create is true and
+// no key exists yet, a new random key is written to the key file.
+func (s *Store) cipher(create bool) (cipher.AEAD, error) {
+	key, err := s.key(create)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("init cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *Store) key(create bool) ([]byte, error) {
+	if v := os.Getenv(KeyEnvVar); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s must be a base64-encoded 32-byte key", KeyEnvVar)
+		}
+		return key, nil
+	}
+
+	key, err := os.ReadFile(s.keyPath())
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("secret key %s is corrupt", s.keyPath())
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("read secret key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate secret key: %w", err)
+	}
+	if err := os.WriteFile(s.keyPath(), key, 0o600); err != nil {
+		return nil, fmt.Errorf("write secret key: %w", err)
+	}
+	return key, nil
+}
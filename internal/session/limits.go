@@ -0,0 +1,50 @@
+package session
+
+import (
+	"strconv"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
+	"github.com/lucasnoah/taintfactory/internal/sandbox"
+)
+
+// SessionGroup returns the name of the cgroup a session's claude runs in.
+func SessionGroup(name string) string {
+	return "session-" + name
+}
+
+// limitsCommand returns the `factory cgroup exec` prefix that starts claude
+// in the session's cgroup with its limits applied, or "" when the session has
+// no limits or this host cannot enforce them. The parent is passed along
+// because the pane's shell may run outside factory's cgroup.
+func limitsCommand(opts CreateOpts) string {
+	if opts.Limits.IsZero() {
+		return ""
+	}
+	parent, err := cgroup.Parent()
+	if err != nil {
+		return ""
+	}
+	args := []string{resolveFactoryBinary(), "cgroup", "exec", "--parent", parent, "--name", SessionGroup(opts.Name)}
+	if l := opts.Limits; l.MemoryBytes > 0 {
+		args = append(args, "--memory", strconv.FormatInt(l.MemoryBytes, 10))
+	}
+	if l := opts.Limits; l.CPUs > 0 {
+		args = append(args, "--cpu", strconv.FormatFloat(l.CPUs, 'f', -1, 64))
+	}
+	if l := opts.Limits; l.Pids > 0 {
+		args = append(args, "--pids", strconv.Itoa(l.Pids))
+	}
+	return sandbox.ShellJoin(append(args, "--"))
+}
+
+// removeSessionGroup kills whatever is left in a session's cgroup and
+// removes it. Sessions without limits have none, and looking one up does not
+// touch the hierarchy.
+func removeSessionGroup(name string) {
+	g, err := cgroup.Lookup(SessionGroup(name))
+	if err != nil {
+		return
+	}
+	g.Kill()
+	g.Remove()
+}
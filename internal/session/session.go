@@ -4,13 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
-	"sort"
 	"strings"
 	"time"
 
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
 	"github.com/lucasnoah/taintfactory/internal/db"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/sandbox"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
 )
 
 var validSessionName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
@@ -24,7 +27,9 @@ type CreateOpts struct {
 	Issue       int
 	Stage       string
 	Interactive bool
-	Env         map[string]string // extra environment variables to export in tmux
+	Env         map[string]string // extra environment variables, sourced from a 0600 file so values never reach the pane
+	Sandbox     *sandbox.Spec     // run claude in this container instead of on the host
+	Limits      cgroup.Limits     // cap claude and its children; ignored when sandboxed
 }
 
 // SessionInfo represents a session in the list output.
@@ -115,24 +120,32 @@ func (m *Manager) Create(opts CreateOpts) error {
 		return fmt.Errorf("unset CLAUDECODE: %w", err)
 	}
 
-	// Export environment variables in sorted order
+	// Inject environment variables via a private env file that the shell
+	// sources and deletes, so values never appear in the pane or its history.
 	if len(opts.Env) > 0 {
-		keys := make([]string, 0, len(opts.Env))
-		for k := range opts.Env {
-			keys = append(keys, k)
+		path, err := secrets.WriteEnvFile("", opts.Env)
+		if err != nil {
+			return fmt.Errorf("write env file: %w", err)
 		}
-		sort.Strings(keys)
-		for _, k := range keys {
-			cmd := fmt.Sprintf("export %s=%s", k, shellQuote(opts.Env[k]))
-			if err := m.tmux.SendKeys(opts.Name, cmd); err != nil {
-				return fmt.Errorf("export %s: %w", k, err)
-			}
+		cmd := fmt.Sprintf(". %s; rm -f %s", shellQuote(path), shellQuote(path))
+		if err := m.tmux.SendKeys(opts.Name, cmd); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("source env file: %w", err)
 		}
 	}
 
 	// Launch Claude in interactive mode.
 	// Auth is handled via CLAUDE_CODE_OAUTH_TOKEN env var (set in .bashrc by entrypoint).
 	cmd := buildClaudeCommand(opts)
+	if opts.Sandbox != nil {
+		wrapped, err := sandboxCommand(opts)
+		if err != nil {
+			return err
+		}
+		cmd = wrapped + " " + cmd
+	} else if prefix := limitsCommand(opts); prefix != "" {
+		cmd = prefix + " " + cmd
+	}
 	fmt.Fprintf(os.Stderr, "[session] launching: %s\n", cmd)
 	if err := m.tmux.SendKeys(opts.Name, cmd); err != nil {
 		return fmt.Errorf("send claude command: %w", err)
@@ -176,7 +189,7 @@ func (m *Manager) Kill(name string) (string, error) {
 	}
 
 	// Log "exited" event — look up issue/stage from DB
-	state, err := m.db.GetSessionState(name)
+	state, err := m.state(name)
 	if err != nil {
 		return log, fmt.Errorf("get session state: %w", err)
 	}
@@ -189,10 +202,40 @@ func (m *Manager) Kill(name string) (string, error) {
 	if err := m.db.LogSessionEvent(name, issue, stage, "exited", nil, ""); err != nil {
 		return log, fmt.Errorf("log exited event: %w", err)
 	}
+	os.RemoveAll(SpoolDir(name))
+	removeSessionGroup(name)
 
 	return log, nil
 }
 
+// state returns the session's latest event, first moving in any events its
+// sandboxed hooks spooled.
+func (m *Manager) state(name string) (*db.SessionEvent, error) {
+	if err := IngestSpool(m.db, name); err != nil {
+		fmt.Fprintf(os.Stderr, "[session] warning: %v\n", err)
+	}
+	return m.db.GetSessionState(name)
+}
+
+// sandboxCommand returns the container invocation that claude runs under in
+// a sandboxed session. The session's env is passed through by name from the
+// pane's shell, which sourced it, and the factory binary is mounted so hooks
+// can spool their events.
+func sandboxCommand(opts CreateOpts) (string, error) {
+	spec := *opts.Sandbox
+	spool := SpoolDir(opts.Name)
+	if err := os.MkdirAll(spool, 0o755); err != nil {
+		return "", fmt.Errorf("create event spool: %w", err)
+	}
+	spec.Env = append(append([]string{}, spec.Env...), sandbox.EnvNames(opts.Env)...)
+	spec.Env = append(spec.Env, "CLAUDE_CODE_OAUTH_TOKEN", SpoolEnv+"="+spool)
+	spec.ReadWrite = append(append([]string{}, spec.ReadWrite...), spool)
+	if bin := resolveFactoryBinary(); filepath.IsAbs(bin) {
+		spec.ReadOnly = append(append([]string{}, spec.ReadOnly...), bin)
+	}
+	return sandbox.ShellJoin(spec.Args()), nil
+}
+
 // List returns sessions cross-referenced between the DB and live tmux.
 func (m *Manager) List(issueFilter int) ([]SessionInfo, error) {
 	// Get DB sessions that haven't exited
@@ -264,7 +307,7 @@ type StatusInfo struct {
 
 // Status returns the current state of a session from DB + tmux.
 func (m *Manager) Status(name string) (*StatusInfo, error) {
-	state, err := m.db.GetSessionState(name)
+	state, err := m.state(name)
 	if err != nil {
 		return nil, fmt.Errorf("get session state: %w", err)
 	}
@@ -302,7 +345,7 @@ func (m *Manager) Send(name string, prompt string) error {
 	}
 
 	// Log factory_send event (for human intervention detection)
-	state, _ := m.db.GetSessionState(name)
+	state, _ := m.state(name)
 	issue, stage := 0, ""
 	if state != nil {
 		issue = state.Issue
@@ -344,7 +387,8 @@ func (m *Manager) SendFromCheckFailures(name string, namespace string, issue int
 }
 
 // Steer sends a steering message to an active session.
-// Logs a "steer" event instead of "factory_send".
+// Logs a "steer" event instead of "factory_send", with the message as
+// metadata so that it can be harvested as a lesson.
 func (m *Manager) Steer(name string, message string) error {
 	exists, err := m.tmux.HasSession(name)
 	if err != nil {
@@ -354,13 +398,13 @@ func (m *Manager) Steer(name string, message string) error {
 		return fmt.Errorf("session %q does not exist", name)
 	}
 
-	state, _ := m.db.GetSessionState(name)
+	state, _ := m.state(name)
 	issue, stage := 0, ""
 	if state != nil {
 		issue = state.Issue
 		stage = state.Stage
 	}
-	if err := m.db.LogSessionEvent(name, issue, stage, "steer", nil, ""); err != nil {
+	if err := m.db.LogSessionEvent(name, issue, stage, "steer", nil, message); err != nil {
 		return fmt.Errorf("log steer: %w", err)
 	}
 
@@ -424,7 +468,7 @@ func (m *Manager) WaitIdle(name string, timeout time.Duration, pollInterval time
 	const stableThreshold = 2 // consecutive unchanged polls to declare idle
 
 	for {
-		state, err := m.db.GetSessionState(name)
+		state, err := m.state(name)
 		if err != nil {
 			return nil, fmt.Errorf("get session state: %w", err)
 		}
@@ -628,6 +628,9 @@ func TestSteer_HappyPath(t *testing.T) {
 	if state.Event != "steer" {
 		t.Errorf("latest event = %q, want %q", state.Event, "steer")
 	}
+	if state.Metadata != "Focus on auth module" {
+		t.Errorf("steer metadata = %q, want the message", state.Metadata)
+	}
 
 	// Verify message was sent
 	found := false
@@ -879,36 +882,47 @@ func TestCreate_WithEnvVars(t *testing.T) {
 		t.Fatalf("Create: %v", err)
 	}
 
-	// Find export calls — should be in alphabetical order (API_KEY before DATABASE_URL)
-	var exports []string
+	// Values must never be typed into the pane.
 	for _, c := range tmux.calls {
-		if strings.Contains(c, "export ") {
-			exports = append(exports, c)
+		if strings.Contains(c, "postgres://u:p@") || strings.Contains(c, "secret") {
+			t.Errorf("env value leaked into tmux call: %q", c)
 		}
 	}
-	if len(exports) != 2 {
-		t.Fatalf("expected 2 export calls, got %d: %v", len(exports), tmux.calls)
-	}
-	if !strings.Contains(exports[0], "API_KEY") {
-		t.Errorf("first export should be API_KEY, got %q", exports[0])
-	}
-	if !strings.Contains(exports[1], "DATABASE_URL") {
-		t.Errorf("second export should be DATABASE_URL, got %q", exports[1])
-	}
 
-	// Exports should come before the claude command
-	claudeIdx := -1
-	firstExportIdx := -1
+	// A single call sources the env file, before the claude command.
+	sourceIdx, claudeIdx := -1, -1
+	var sourceCall string
 	for i, c := range tmux.calls {
-		if strings.Contains(c, "export ") && firstExportIdx == -1 {
-			firstExportIdx = i
+		if strings.Contains(c, "factory-env-") && sourceIdx == -1 {
+			sourceIdx, sourceCall = i, c
 		}
 		if strings.Contains(c, "claude") {
 			claudeIdx = i
 		}
 	}
-	if firstExportIdx >= claudeIdx {
-		t.Errorf("exports should come before claude command: export@%d, claude@%d", firstExportIdx, claudeIdx)
+	if sourceIdx == -1 {
+		t.Fatalf("no env file sourced: %v", tmux.calls)
+	}
+	if sourceIdx >= claudeIdx {
+		t.Errorf("env should be sourced before claude command: source@%d, claude@%d", sourceIdx, claudeIdx)
+	}
+
+	// The mock shell never runs the rm, so the file is still there to inspect.
+	start := strings.Index(sourceCall, "'")
+	end := strings.Index(sourceCall[start+1:], "'")
+	path := sourceCall[start+1 : start+1+end]
+	defer os.Remove(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat env file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("env file mode = %o, want 600", perm)
+	}
+	data, _ := os.ReadFile(path)
+	want := "export API_KEY='secret'\nexport DATABASE_URL='postgres://u:p@localhost/db'\n"
+	if string(data) != want {
+		t.Errorf("env file = %q, want %q", data, want)
 	}
 }
 
@@ -926,10 +940,10 @@ func TestCreate_EmptyEnvVars(t *testing.T) {
 		t.Fatalf("Create: %v", err)
 	}
 
-	// No export calls when Env is nil
+	// No env file when Env is nil
 	for _, c := range tmux.calls {
-		if strings.Contains(c, "export ") {
-			t.Errorf("unexpected export call: %q", c)
+		if strings.Contains(c, "factory-env-") {
+			t.Errorf("unexpected env file call: %q", c)
 		}
 	}
 }
@@ -0,0 +1,85 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+)
+
+// SpoolEnv names the directory `factory event log` writes to instead of the
+// database. Sandboxed sessions cannot reach the database, so their hooks
+// leave events there and the host moves them in when it next reads the
+// session's state.
+const SpoolEnv = "FACTORY_EVENT_SPOOL"
+
+// SpooledEvent is one hook event waiting in a spool directory.
+type SpooledEvent struct {
+	Session  string `json:"session"`
+	Event    string `json:"event"`
+	Issue    int    `json:"issue"`
+	Stage    string `json:"stage"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Metadata string `json:"metadata,omitempty"`
+}
+
+// SpoolDir returns the host directory a sandboxed session's events go to.
+func SpoolDir(name string) string {
+	return filepath.Join(config.DataDir(), "sandbox", name)
+}
+
+// WriteSpooledEvent stores ev in dir as its own file, named so that events
+// sort in the order they were written.
+func WriteSpooledEvent(dir string, ev SpooledEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%020d-%s.json", time.Now().UnixNano(), ev.Event)
+	tmp := filepath.Join(dir, "."+name)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+// IngestSpool logs the events spooled for session name to the database,
+// oldest first, and removes them. It is a no-op for sessions that are not
+// sandboxed.
+func IngestSpool(d *db.DB, name string) error {
+	dir := SpoolDir(name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") && !strings.HasPrefix(e.Name(), ".") {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var ev SpooledEvent
+		if err := json.Unmarshal(data, &ev); err != nil || ev.Session != name {
+			os.Remove(path) // not ours to log
+			continue
+		}
+		if err := d.LogSessionEvent(ev.Session, ev.Issue, ev.Stage, ev.Event, ev.ExitCode, ev.Metadata); err != nil {
+			return fmt.Errorf("log spooled event: %w", err)
+		}
+		os.Remove(path)
+	}
+	return nil
+}
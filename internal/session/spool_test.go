@@ -0,0 +1,74 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/sandbox"
+)
+
+func TestWriteSpooledEvent(t *testing.T) {
+	dir := t.TempDir()
+	for _, ev := range []string{"active", "idle"} {
+		if err := WriteSpooledEvent(dir, SpooledEvent{Session: "42-implement-1", Event: ev, Issue: 42, Stage: "implement"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 spooled files, got %d", len(entries))
+	}
+	// Names sort in write order.
+	if !strings.HasSuffix(entries[0].Name(), "-active.json") || !strings.HasSuffix(entries[1].Name(), "-idle.json") {
+		t.Errorf("unexpected order: %s, %s", entries[0].Name(), entries[1].Name())
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, entries[1].Name()))
+	var ev SpooledEvent
+	if err := json.Unmarshal(data, &ev); err != nil || ev.Issue != 42 || ev.Event != "idle" {
+		t.Errorf("unexpected event %+v (%v)", ev, err)
+	}
+}
+
+func TestSandboxCommand(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("FACTORY_DATA_DIR", dataDir)
+
+	opts := CreateOpts{
+		Name:    "42-implement-1",
+		Workdir: "/repo/worktrees/issue-42",
+		Env:     map[string]string{"SECRET_TOKEN": "hunter2", "API_URL": "http://api"},
+		Sandbox: &sandbox.Spec{
+			Config:      &config.SandboxConfig{Image: "agent:latest"},
+			Name:        "factory-42-implement-1",
+			Workdir:     "/repo/worktrees/issue-42",
+			Interactive: true,
+		},
+	}
+	cmd, err := sandboxCommand(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool := filepath.Join(dataDir, "sandbox", "42-implement-1")
+	if _, err := os.Stat(spool); err != nil {
+		t.Errorf("spool dir not created: %v", err)
+	}
+	for _, want := range []string{
+		"podman run --rm --name factory-42-implement-1 -it",
+		"-v " + spool + ":" + spool,
+		"-e API_URL -e SECRET_TOKEN -e CLAUDE_CODE_OAUTH_TOKEN -e " + SpoolEnv + "=" + spool,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("command missing %q:\n%s", want, cmd)
+		}
+	}
+	if strings.Contains(cmd, "hunter2") {
+		t.Error("secret value leaked into the pane command")
+	}
+	if len(opts.Sandbox.Env) != 0 {
+		t.Error("sandboxCommand modified the caller's spec")
+	}
+}
@@ -0,0 +1,479 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	appctx "github.com/lucasnoah/taintfactory/internal/context"
+	"github.com/lucasnoah/taintfactory/internal/github"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/qa"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
+)
+
+// browserReadyPoll is how often ready_url is polled while the app starts.
+var browserReadyPoll = 500 * time.Millisecond
+
+// browserStopGrace is how long the app gets to exit after SIGTERM.
+const browserStopGrace = 5 * time.Second
+
+// browserRun collects the outcome of a browser_qa stage.
+type browserRun struct {
+	e        *Engine
+	ps       *pipeline.PipelineState
+	opts     RunOpts
+	result   *RunResult
+	bcfg     *config.BrowserQAConfig
+	dir      string
+	env      []string
+	redactor *secrets.Redactor
+	head     *qa.BrowserReport // the runner's report on the branch
+	findings []pipeline.Finding
+	notes    []string
+}
+
+func (b *browserRun) check(name string, passed bool) {
+	if passed {
+		b.result.FinalCheckState[name] = "pass"
+	} else {
+		b.result.FinalCheckState[name] = "fail"
+	}
+}
+
+func (b *browserRun) fail(check, msg string) {
+	b.check(check, false)
+	b.findings = append(b.findings, pipeline.Finding{Severity: "blocker", Rule: check, Message: msg})
+	b.notes = append(b.notes, strings.SplitN(msg, "\n", 2)[0])
+}
+
+// runBrowserQA handles the browser_qa stage type. It starts the app from the
+// worktree, waits for ready_url, and has the runner visit the configured and
+// detected routes. Screenshots, app logs and the runner's report are saved
+// with the attempt; every broken route becomes a finding. With visual set,
+// a passing run is also compared with the base ref's screenshots.
+func (e *Engine) runBrowserQA(ps *pipeline.PipelineState, stageCfg *config.Stage, opts RunOpts, result *RunResult, start time.Time, cfg *config.PipelineConfig) (*RunResult, error) {
+	bcfg := stageCfg.BrowserQA
+	if bcfg == nil {
+		return nil, fmt.Errorf("browser_qa stage %q has no browser_qa section", opts.Stage)
+	}
+	if cfg.Pipeline.Sandbox.Enabled() {
+		// The app and runner would run on the host, outside the sandbox.
+		return nil, fmt.Errorf("browser_qa stage %q cannot run with pipeline.sandbox", opts.Stage)
+	}
+	dir := e.store.BrowserQADir(opts.Issue, opts.Stage, ps.CurrentAttempt)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create browser_qa dir: %w", err)
+	}
+	vars, err := buildEnvMap(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("resolve env: %w", err)
+	}
+	env := os.Environ()
+	for _, k := range sortedKeys(vars) {
+		env = append(env, fmt.Sprintf("%s=%s", k, vars[k]))
+	}
+	b := &browserRun{e: e, ps: ps, opts: opts, result: result, bcfg: bcfg, dir: dir, env: env, redactor: secrets.RedactorFor(cfg)}
+
+	routes, skipped := qa.VisitableRoutes(append(append([]string{}, bcfg.Routes...), e.detectRoutes(ps, opts)...))
+	if len(routes) == 0 {
+		routes = []string{"/"}
+	}
+	if len(skipped) > 0 {
+		b.notes = append(b.notes, fmt.Sprintf("skipped dynamic routes: %s", strings.Join(skipped, ", ")))
+	}
+
+	if app := b.startApp(); app != nil {
+		b.visit(app, routes)
+		app.stop()
+	}
+
+	passed := true
+	for _, state := range result.FinalCheckState {
+		if state == "fail" {
+			passed = false
+		}
+	}
+	if passed && bcfg.Visual != nil && b.head != nil {
+		e.visualRegression(b, routes, cfg)
+	}
+	result.Findings = b.findings
+	result.TotalDuration = time.Since(start)
+	status := "passed"
+	if passed {
+		result.Outcome = "success"
+		result.ChecksFirstPass = true
+	} else {
+		result.Outcome = "fail"
+		status = "failed"
+	}
+	lines := []string{fmt.Sprintf("Browser QA %s on %d route(s): %s", status, len(routes), strings.Join(routes, ", "))}
+	for _, n := range b.notes {
+		lines = append(lines, "- "+n)
+	}
+	lines = append(lines, "- screenshots and logs: "+dir)
+	result.Summary = strings.Join(lines, "\n")
+
+	e.logf("browser qa: %s", result.Outcome)
+	_ = e.db.LogPipelineEvent(ps.Namespace, opts.Issue, "browser_qa", opts.Stage, ps.CurrentAttempt,
+		fmt.Sprintf("outcome=%s routes=%d findings=%d", result.Outcome, len(routes), len(b.findings)))
+	return result, nil
+}
+
+// visualRegression compares the branch's screenshots with the base ref's.
+// The comparison is given to later stages as {{visual_diff_summary}}, and a
+// route that changed more than the threshold holds the pipeline for
+// approval. Failing to capture the base is noted but does not fail the stage.
+func (e *Engine) visualRegression(b *browserRun, routes []string, cfg *config.PipelineConfig) {
+	report, err := b.compareVisual(routes, cfg.Pipeline.Setup)
+	var summary string
+	if err != nil {
+		e.logf("browser_qa: visual comparison: %v", err)
+		summary = fmt.Sprintf("Visual comparison was not possible: %v", err)
+		b.notes = append(b.notes, summary)
+	} else {
+		summary = report.Summary()
+		if over := report.Over(); len(over) > 0 {
+			var names []string
+			for _, d := range over {
+				names = append(names, fmt.Sprintf("%s (%.2f%%)", d.Route, d.Changed))
+			}
+			b.result.NeedsApproval = fmt.Sprintf("visual changes above %.2f%% against %s: %s", report.Threshold, report.BaseRef, strings.Join(names, ", "))
+			b.notes = append(b.notes, b.result.NeedsApproval)
+		} else {
+			b.notes = append(b.notes, fmt.Sprintf("visual changes against %s within %.2f%%", report.BaseRef, report.Threshold))
+		}
+	}
+	if err := e.store.Update(b.opts.Issue, func(ps *pipeline.PipelineState) {
+		if ps.RuntimeVars == nil {
+			ps.RuntimeVars = make(map[string]string)
+		}
+		ps.RuntimeVars["visual_diff_summary"] = summary
+	}); err != nil {
+		e.logf("warning: save visual_diff_summary: %v", err)
+	}
+}
+
+// detectRoutes returns the routes the issue and the branch's changes point
+// at, using the same detection as `factory qa detect`.
+func (e *Engine) detectRoutes(ps *pipeline.PipelineState, opts RunOpts) []string {
+	var issue *github.Issue
+	if cached, err := github.LoadCachedIssue(fmt.Sprintf("%s/%d", e.store.BaseDir(), opts.Issue)); err == nil {
+		issue = cached
+	}
+	var files []string
+	if out, err := (&appctx.ExecGit{}).FilesChanged(ps.Worktree, ps.BaseRef()); err == nil && strings.TrimSpace(out) != "" {
+		files = strings.Split(strings.TrimRight(out, "\n"), "\n")
+	}
+	return qa.DetectBrowserTest(qa.DetectOpts{Issue: issue, FilesChanged: files, ForceFlag: true}).AffectedRoutes
+}
+
+// startApp serves the branch's app. It returns the running app, or nil when
+// it did not come up.
+func (b *browserRun) startApp() *browserApp {
+	app, err := b.launchApp(b.ps.Worktree, b.dir)
+	if err != nil {
+		b.fail("app_start", err.Error())
+		return nil
+	}
+	b.check("app_start", true)
+	return app
+}
+
+// launchApp runs the start commands in worktree, logging to logDir, and
+// waits for ready_url. Each launch gets a free port as $FACTORY_PORT. A
+// ready_url that answers before anything started is another app's, such as
+// a concurrent pipeline's or a dev server left running, so it is an error.
+func (b *browserRun) launchApp(worktree, logDir string) (*browserApp, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("pick a port: %w", err)
+	}
+	bcfg := b.bcfg.ForPort(port)
+	if answers(bcfg.ReadyURL) {
+		return nil, fmt.Errorf("%s answered before the app started: another server holds its port; serve on $%s to give each run its own", bcfg.ReadyURL, config.BrowserPortVar)
+	}
+	app := &browserApp{
+		exited: make(chan error, len(bcfg.Start)),
+		base:   bcfg.Base(),
+		env:    append(append([]string{}, b.env...), fmt.Sprintf("%s=%d", config.BrowserPortVar, port)),
+	}
+	for i, cmdStr := range bcfg.Start {
+		if err := app.start(worktree, app.env, cmdStr, filepath.Join(logDir, fmt.Sprintf("app-%d.log", i))); err != nil {
+			app.stop()
+			return nil, fmt.Errorf("start %q: %w", cmdStr, err)
+		}
+	}
+	b.e.logf("browser_qa: waiting for %s", bcfg.ReadyURL)
+	if err := app.waitReady(bcfg.ReadyURL, bcfg.ReadyWait()); err != nil {
+		app.stop()
+		return nil, fmt.Errorf("app did not become ready: %v\n%s", err, b.redactor.Redact(tail(app.logs(), 20)))
+	}
+	return app, nil
+}
+
+// visit has the runner load routes on the branch's app and turns its report
+// into findings.
+func (b *browserRun) visit(app *browserApp, routes []string) {
+	r, out, err := b.runRunner(app, b.ps.Worktree, b.dir, routes)
+	if r == nil {
+		b.fail("browser_routes", fmt.Sprintf("browser runner wrote no report: %v\n%s", err, b.redactor.Redact(tail(out, 20))))
+		return
+	}
+	b.head = r
+	findings := r.Findings(b.bcfg.IgnoreConsole)
+	if len(findings) == 0 && err != nil {
+		b.fail("browser_routes", fmt.Sprintf("browser runner failed: %v\n%s", err, b.redactor.Redact(tail(out, 20))))
+		return
+	}
+	b.check("browser_routes", len(findings) == 0)
+	b.findings = append(b.findings, findings...)
+	if len(findings) > 0 {
+		b.notes = append(b.notes, fmt.Sprintf("%d problem(s) found in the browser", len(findings)))
+	}
+}
+
+// runRunner has the runner visit routes of app, served from worktree, with
+// its screenshots, output and report saved in dir. The report is nil when
+// the runner wrote none; the error is the runner's exit status, or why the
+// report could not be read.
+func (b *browserRun) runRunner(app *browserApp, worktree, dir string, routes []string) (*qa.BrowserReport, string, error) {
+	report := filepath.Join(dir, "report.json")
+	_ = os.Remove(report)
+	env := append(append([]string{}, app.env...),
+		"FACTORY_BASE_URL="+app.base,
+		"FACTORY_ROUTES="+strings.Join(routes, "\n"),
+		"FACTORY_ARTIFACTS_DIR="+dir,
+		"FACTORY_BROWSER_REPORT="+report,
+	)
+	cmdStr := b.bcfg.Runner
+	if cmdStr == "" {
+		script := filepath.Join(dir, "playwright.js")
+		if err := os.WriteFile(script, qa.PlaywrightScript, 0o644); err != nil {
+			return nil, "", fmt.Errorf("write runner script: %w", err)
+		}
+		env = append(env, "NODE_PATH="+filepath.Join(worktree, "node_modules"))
+		cmdStr = "node " + script
+	}
+
+	b.e.logf("browser_qa: visiting %s", strings.Join(routes, ", "))
+	out, runErr := runShell(worktree, env, cmdStr, b.opts.Timeout)
+	_ = os.WriteFile(filepath.Join(dir, "runner.log"), []byte(out), 0o644)
+
+	r, err := qa.ReadBrowserReport(report)
+	if err != nil {
+		if runErr != nil {
+			err = runErr
+		}
+		return nil, out, err
+	}
+	return r, out, runErr
+}
+
+// compareVisual captures routes on the base ref, in a temporary worktree
+// prepared with pipeline.setup, and diffs each screenshot with the branch's.
+// Everything is saved under the attempt's visual/ directory.
+func (b *browserRun) compareVisual(routes []string, setup []string) (*qa.VisualReport, error) {
+	ref := b.bcfg.Visual.VisualBaseRef(b.ps.BaseRef())
+	visualDir := filepath.Join(b.dir, "visual")
+	baseDir := filepath.Join(visualDir, "base")
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp("", "factory-visual-base-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "base")
+	if out, err := exec.Command("git", "-C", b.ps.Worktree, "worktree", "add", "--detach", worktree, ref).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("check out %s: %s: %w", ref, strings.TrimSpace(string(out)), err)
+	}
+	defer func() {
+		_ = exec.Command("git", "-C", b.ps.Worktree, "worktree", "remove", "--force", worktree).Run()
+	}()
+
+	b.e.logf("browser_qa: capturing %s", ref)
+	for _, cmdStr := range setup {
+		if out, err := runShell(worktree, b.env, cmdStr, b.opts.Timeout); err != nil {
+			return nil, fmt.Errorf("%s on %s: %s: %w", cmdStr, ref, b.redactor.Redact(tail(out, 5)), err)
+		}
+	}
+	app, err := b.launchApp(worktree, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ref, strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	base, _, err := b.runRunner(app, worktree, baseDir, routes)
+	app.stop()
+	if base == nil {
+		return nil, fmt.Errorf("%s: browser runner wrote no report: %v", ref, err)
+	}
+
+	rel := func(path string) string {
+		if r, err := filepath.Rel(b.dir, path); err == nil {
+			return r
+		}
+		return path
+	}
+	baseShots := make(map[string]string)
+	for _, r := range base.Routes {
+		baseShots[r.Route] = r.Screenshot
+	}
+	report := &qa.VisualReport{BaseRef: ref, Threshold: b.bcfg.Visual.MaxChange()}
+	for _, head := range b.head.Routes {
+		d := qa.VisualDiff{Route: head.Route}
+		baseShot := baseShots[head.Route]
+		switch {
+		case head.Screenshot == "":
+			d.Error = "no screenshot on the branch"
+		case baseShot == "":
+			d.Error = "no screenshot on " + ref
+		default:
+			diffPath := filepath.Join(visualDir, "diff", filepath.Base(head.Screenshot))
+			changed, resized, err := qa.DiffPNGs(baseShot, head.Screenshot, diffPath)
+			if err != nil {
+				d.Error = err.Error()
+				break
+			}
+			d.Base, d.Head, d.Diff = rel(baseShot), rel(head.Screenshot), rel(diffPath)
+			d.Changed, d.Resized = changed, resized
+		}
+		report.Routes = append(report.Routes, d)
+	}
+	if err := qa.WriteVisualReport(filepath.Join(visualDir, "report.json"), report); err != nil {
+		b.e.logf("warning: save visual report: %v", err)
+	}
+	return report, nil
+}
+
+// browserApp is the set of processes serving the app under test.
+type browserApp struct {
+	procs  []*exec.Cmd
+	files  []*os.File
+	paths  []string
+	exited chan error
+	base   string   // URL routes resolve against
+	env    []string // the stage env plus $FACTORY_PORT, for the start commands and the runner
+}
+
+// freePort returns a TCP port that is free on the loopback interface.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// answers reports whether url responds below 500 right now.
+func answers(url string) bool {
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < 500
+}
+
+// start launches cmdStr in its own process group with output to logPath.
+func (a *browserApp) start(dir string, env []string, cmdStr, logPath string) error {
+	f, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", cmdStr)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = f
+	cmd.Stderr = f
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		f.Close()
+		return err
+	}
+	a.procs = append(a.procs, cmd)
+	a.files = append(a.files, f)
+	a.paths = append(a.paths, logPath)
+	go func() {
+		err := cmd.Wait()
+		if err == nil {
+			err = fmt.Errorf("exited")
+		}
+		a.exited <- fmt.Errorf("%q %w", cmdStr, err)
+	}()
+	return nil
+}
+
+// waitReady polls url until it answers below 500, a start command exits,
+// or timeout passes. A start command that has exited fails the wait even if
+// url answers, since something else is then serving it.
+func (a *browserApp) waitReady(url string, timeout time.Duration) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				select {
+				case err := <-a.exited:
+					return err
+				default:
+					return nil
+				}
+			}
+		}
+		select {
+		case err := <-a.exited:
+			return err
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not answer within %s", url, timeout)
+		case <-time.After(browserReadyPoll):
+		}
+	}
+}
+
+// stop sends SIGTERM to each start command's process group, then SIGKILL
+// to whatever is left after browserStopGrace.
+func (a *browserApp) stop() {
+	for _, cmd := range a.procs {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	deadline := time.Now().Add(browserStopGrace)
+	for _, cmd := range a.procs {
+		for time.Now().Before(deadline) && syscall.Kill(-cmd.Process.Pid, 0) == nil {
+			time.Sleep(100 * time.Millisecond)
+		}
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	for _, f := range a.files {
+		f.Close()
+	}
+	a.procs = nil
+	a.files = nil
+}
+
+// logs returns the start commands' combined output so far.
+func (a *browserApp) logs() string {
+	var sb strings.Builder
+	for _, p := range a.paths {
+		data, _ := os.ReadFile(p)
+		sb.Write(data)
+	}
+	return sb.String()
+}
@@ -0,0 +1,237 @@
+package stage
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/qa"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
+)
+
+func newBrowserRun(t *testing.T, bcfg *config.BrowserQAConfig) *browserRun {
+	t.Helper()
+	browserReadyPoll = 10 * time.Millisecond
+	return &browserRun{
+		e:        &Engine{},
+		ps:       &pipeline.PipelineState{Worktree: t.TempDir()},
+		result:   &RunResult{FinalCheckState: make(map[string]string)},
+		bcfg:     bcfg,
+		dir:      t.TempDir(),
+		env:      os.Environ(),
+		redactor: secrets.NewRedactor(),
+	}
+}
+
+func TestBrowserRun_StartAppReady(t *testing.T) {
+	b := newBrowserRun(t, &config.BrowserQAConfig{Start: []string{"echo serving; sleep 30"}, ReadyTimeout: "5s"})
+	logPath := filepath.Join(b.dir, "app-0.log")
+	// Ready once the app has written its output, so the log check below does not race it.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if data, _ := os.ReadFile(logPath); !strings.Contains(string(data), "serving") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	b.bcfg.ReadyURL = srv.URL
+
+	app := b.startApp()
+	if app == nil {
+		t.Fatalf("app did not start: %+v", b.findings)
+	}
+	app.stop()
+	if b.result.FinalCheckState["app_start"] != "pass" {
+		t.Errorf("app_start = %q, want pass", b.result.FinalCheckState["app_start"])
+	}
+	if data, _ := os.ReadFile(filepath.Join(b.dir, "app-0.log")); !strings.Contains(string(data), "serving") {
+		t.Errorf("app log = %q, want the start command's output", data)
+	}
+}
+
+func TestBrowserRun_StartAppExits(t *testing.T) {
+	b := newBrowserRun(t, &config.BrowserQAConfig{Start: []string{"echo missing dependency; exit 1"}, ReadyURL: "http://127.0.0.1:1/", ReadyTimeout: "5s"})
+	if app := b.startApp(); app != nil {
+		app.stop()
+		t.Fatal("expected the app not to start")
+	}
+	if len(b.findings) != 1 || b.findings[0].Rule != "app_start" {
+		t.Fatalf("findings = %+v", b.findings)
+	}
+	if !strings.Contains(b.findings[0].Message, "missing dependency") {
+		t.Errorf("finding should include the app's output, got %q", b.findings[0].Message)
+	}
+}
+
+func TestBrowserRun_Visit(t *testing.T) {
+	runner := `printf '{"routes":[{"route":"/","status":200},{"route":"/settings","status":500}]}' > "$FACTORY_BROWSER_REPORT"; echo "$FACTORY_ROUTES" > "$FACTORY_ARTIFACTS_DIR/routes.txt"`
+	b := newBrowserRun(t, &config.BrowserQAConfig{ReadyURL: "http://localhost:3100/", Runner: runner})
+	b.visit(&browserApp{base: "http://localhost:3100", env: b.env}, []string{"/", "/settings"})
+
+	if b.result.FinalCheckState["browser_routes"] != "fail" {
+		t.Errorf("browser_routes = %q, want fail", b.result.FinalCheckState["browser_routes"])
+	}
+	if len(b.findings) != 1 || b.findings[0].Rule != "browser_http_status" {
+		t.Fatalf("findings = %+v", b.findings)
+	}
+	if data, _ := os.ReadFile(filepath.Join(b.dir, "routes.txt")); string(data) != "/\n/settings\n" {
+		t.Errorf("runner saw routes %q", data)
+	}
+}
+
+func TestBrowserRun_VisitNoReport(t *testing.T) {
+	b := newBrowserRun(t, &config.BrowserQAConfig{ReadyURL: "http://localhost:3100/", Runner: "echo cannot find module playwright; exit 1"})
+	b.visit(&browserApp{base: "http://localhost:3100", env: b.env}, []string{"/"})
+	if len(b.findings) != 1 || !strings.Contains(b.findings[0].Message, "cannot find module playwright") {
+		t.Fatalf("findings = %+v", b.findings)
+	}
+}
+
+func TestBrowserRun_CompareVisual(t *testing.T) {
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	// Ready once the base app has started.
+	started := filepath.Join(t.TempDir(), "started")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := os.Stat(started); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	b := newBrowserRun(t, &config.BrowserQAConfig{
+		Start:    []string{"touch " + started + "; sleep 30"},
+		ReadyURL: srv.URL,
+		Runner:   `cp "$BASE_PNG" "$FACTORY_ARTIFACTS_DIR/root.png" && printf '{"routes":[{"route":"/","status":200,"screenshot":"%s"}]}' "$FACTORY_ARTIFACTS_DIR/root.png" > "$FACTORY_BROWSER_REPORT"`,
+		Visual:   &config.VisualConfig{BaseRef: "HEAD", Threshold: 10},
+	})
+	b.ps.Worktree = repo
+	basePNG := writeTestPNG(t, filepath.Join(t.TempDir(), "base.png"), 4, 4, 0)
+	headPNG := writeTestPNG(t, filepath.Join(b.dir, "root.png"), 4, 4, 2)
+	b.env = append(b.env, "BASE_PNG="+basePNG)
+	b.head = &qa.BrowserReport{Routes: []qa.RouteResult{{Route: "/", Status: 200, Screenshot: headPNG}}}
+
+	report, err := b.compareVisual([]string{"/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Routes) != 1 {
+		t.Fatalf("routes = %+v", report.Routes)
+	}
+	d := report.Routes[0]
+	if d.Error != "" || d.Changed != 50 {
+		t.Errorf("diff = %+v, want half the pixels changed", d)
+	}
+	if d.Base != "visual/base/root.png" || d.Head != "root.png" || d.Diff != "visual/diff/root.png" {
+		t.Errorf("paths = %q %q %q, want relative to the attempt's browser dir", d.Base, d.Head, d.Diff)
+	}
+	if over := report.Over(); len(over) != 1 {
+		t.Errorf("Over() = %+v, want / above the 10%% threshold", over)
+	}
+	if _, err := qa.ReadVisualReport(filepath.Join(b.dir, "visual", "report.json")); err != nil {
+		t.Errorf("report not saved: %v", err)
+	}
+	if out, _ := exec.Command("git", "-C", repo, "worktree", "list").Output(); strings.Count(string(out), "\n") != 1 {
+		t.Errorf("base worktree not removed:\n%s", out)
+	}
+}
+
+// writeTestPNG writes a white w×h PNG whose first dark rows are black.
+func writeTestPNG(t *testing.T, path string, w, h, dark int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if y < dark {
+				c = color.RGBA{0, 0, 0, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBrowserRun_StartAppOwnPort(t *testing.T) {
+	// The runner's base URL and the app's port come from $FACTORY_PORT.
+	b := newBrowserRun(t, &config.BrowserQAConfig{
+		Start:        []string{`echo "$FACTORY_PORT" > port.txt; exec sleep 30`},
+		ReadyURL:     "http://127.0.0.1:${FACTORY_PORT}/",
+		ReadyTimeout: "5s",
+	})
+	portFile := filepath.Join(b.ps.Worktree, "port.txt")
+	// Stand in for the app: serve on the port the start command was given.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			data, err := os.ReadFile(portFile)
+			if err != nil || !strings.HasSuffix(string(data), "\n") {
+				continue
+			}
+			l, err := net.Listen("tcp", "127.0.0.1:"+strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Errorf("listen on the app's port: %v", err)
+				return
+			}
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+			go srv.Serve(l)
+			<-done
+			srv.Close()
+			return
+		}
+	}()
+
+	app := b.startApp()
+	if app == nil {
+		t.Fatalf("app did not start: %+v", b.findings)
+	}
+	defer app.stop()
+	data, _ := os.ReadFile(portFile)
+	if want := "http://127.0.0.1:" + strings.TrimSpace(string(data)); app.base != want {
+		t.Errorf("base = %q, want %q", app.base, want)
+	}
+}
+
+func TestBrowserRun_StartAppPortTaken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	b := newBrowserRun(t, &config.BrowserQAConfig{Start: []string{"sleep 30"}, ReadyURL: srv.URL, ReadyTimeout: "5s"})
+	if app := b.startApp(); app != nil {
+		app.stop()
+		t.Fatal("expected a ready_url that already answers to fail the start")
+	}
+	if len(b.findings) != 1 || !strings.Contains(b.findings[0].Message, "answered before the app started") {
+		t.Fatalf("findings = %+v", b.findings)
+	}
+}
@@ -0,0 +1,325 @@
+package stage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/checks"
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/dbprov"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
+	"github.com/lucasnoah/taintfactory/internal/worktree"
+)
+
+// CandidateResult is how one best-of-N candidate did.
+type CandidateResult struct {
+	Name         string `json:"name"` // c1, c2, ...
+	Branch       string `json:"branch,omitempty"`
+	Head         string `json:"head,omitempty"`
+	Error        string `json:"error,omitempty"` // the candidate did not finish
+	RateLimited  bool   `json:"rate_limited,omitempty"`
+	ChecksPassed int    `json:"checks_passed"`
+	ChecksTotal  int    `json:"checks_total"`
+	Findings     int    `json:"findings"`   // issues reported by the failing checks
+	DiffLines    int    `json:"diff_lines"` // lines added plus removed
+	Winner       bool   `json:"winner,omitempty"`
+}
+
+// usable reports whether the candidate finished and changed something.
+func (c CandidateResult) usable() bool {
+	return c.Error == "" && !c.RateLimited && c.Head != "" && c.DiffLines > 0
+}
+
+// PickCandidate returns the index of the best candidate, or -1 when none
+// finished with changes. Candidates that pass every check win; then more
+// passing checks, fewer findings, and a smaller diff.
+func PickCandidate(cs []CandidateResult) int {
+	order := make([]int, 0, len(cs))
+	for i, c := range cs {
+		if c.usable() {
+			order = append(order, i)
+		}
+	}
+	if len(order) == 0 {
+		return -1
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := cs[order[i]], cs[order[j]]
+		aAll, bAll := a.ChecksPassed == a.ChecksTotal, b.ChecksPassed == b.ChecksTotal
+		switch {
+		case aAll != bAll:
+			return aAll
+		case a.ChecksPassed != b.ChecksPassed:
+			return a.ChecksPassed > b.ChecksPassed
+		case a.Findings != b.Findings:
+			return a.Findings < b.Findings
+		default:
+			return a.DiffLines < b.DiffLines
+		}
+	})
+	return order[0]
+}
+
+// runCandidates runs the stage in stageCfg.Candidates sessions at once, each
+// in its own worktree branched from the pipeline's HEAD with pipeline.setup
+// run in it and the prompt rendered for it, and checks each against the
+// post-check gate. Gates run one at a time: check commands do not get a
+// candidate's database, so parallel test suites would share one. The best
+// candidate's commits are
+// brought into the pipeline worktree; every candidate's session log, diff and
+// result are archived under the attempt's candidates/ directory and the
+// candidate worktrees are removed. It returns the winner's name.
+func (e *Engine) runCandidates(ps *pipeline.PipelineState, opts RunOpts, stageCfg *config.Stage, cfg *config.PipelineConfig) (string, error) {
+	wt := e.wt
+	if ps.RepoDir != "" {
+		wt = wt.WithRepoDir(ps.RepoDir)
+	}
+	base := gitHead(ps.Worktree)
+	if base == "" {
+		return "", fmt.Errorf("resolve HEAD of %s", ps.Worktree)
+	}
+	gateChecks, err := gateConfigs(e.resolvePostChecks(stageCfg), cfg, ps.BaseRef())
+	if err != nil {
+		return "", err
+	}
+
+	n := stageCfg.Candidates
+	e.logf("running %d candidates from %s", n, shortSHA(base))
+	results := make([]CandidateResult, n)
+	var wg sync.WaitGroup
+	var gateMu sync.Mutex
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = e.runCandidate(wt, ps, opts, stageCfg, cfg, fmt.Sprintf("c%d", i+1), base, gateChecks, &gateMu)
+		}(i)
+	}
+	wg.Wait()
+
+	best := PickCandidate(results)
+	var applyErr error
+	if best >= 0 {
+		results[best].Winner = true
+		// Bring the winner's commits in while its branch still exists; the
+		// pipeline worktree is clean at base, so this fast-forwards.
+		if out, err := exec.Command("git", "-C", ps.Worktree, "merge", "--ff-only", results[best].Head).CombinedOutput(); err != nil {
+			applyErr = fmt.Errorf("apply candidate %s: %s: %w", results[best].Name, strings.TrimSpace(string(out)), err)
+		}
+	}
+	for _, c := range results {
+		dir := e.store.CandidateDir(opts.Issue, opts.Stage, ps.CurrentAttempt, c.Name)
+		if err := os.MkdirAll(dir, 0o755); err == nil {
+			_ = pipeline.WriteJSON(filepath.Join(dir, "result.json"), c)
+		}
+		if err := wt.RemoveCandidate(opts.Issue, c.Name); err != nil {
+			e.logf("warning: remove candidate %s: %v", c.Name, err)
+		}
+	}
+
+	if best < 0 {
+		for _, c := range results {
+			if c.RateLimited {
+				return "", errRateLimited
+			}
+		}
+		return "", fmt.Errorf("none of %d candidates produced changes", n)
+	}
+	if applyErr != nil {
+		return "", applyErr
+	}
+
+	winner := results[best]
+	e.logf("kept candidate %s (%d/%d checks, %d findings, %d lines)",
+		winner.Name, winner.ChecksPassed, winner.ChecksTotal, winner.Findings, winner.DiffLines)
+	_ = e.db.LogPipelineEvent(ps.Namespace, opts.Issue, "candidate_selected", opts.Stage, ps.CurrentAttempt,
+		fmt.Sprintf("winner=%s candidates=%d checks_passed=%d/%d findings=%d diff_lines=%d",
+			winner.Name, n, winner.ChecksPassed, winner.ChecksTotal, winner.Findings, winner.DiffLines))
+	return winner.Name, nil
+}
+
+// runCandidate runs one candidate session and gate in its own worktree and
+// archives its prompt, log and diff. The worktree gets pipeline.setup with
+// the pipeline's env. With a per_pipeline database the candidate gets its
+// own clone of the template, migrated after setup, so parallel sessions do
+// not share one; otherwise it uses the pipeline's database, which setup
+// already migrated. The gate runs while holding gateMu.
+func (e *Engine) runCandidate(wt *worktree.Manager, ps *pipeline.PipelineState, opts RunOpts, stageCfg *config.Stage, cfg *config.PipelineConfig, name, base string, gateChecks []checks.GateCheckConfig, gateMu *sync.Mutex) CandidateResult {
+	res := CandidateResult{Name: name, ChecksTotal: len(gateChecks)}
+	_ = wt.RemoveCandidate(opts.Issue, name) // left over from an interrupted run
+	created, err := wt.Create(worktree.CreateOpts{Issue: opts.Issue, Branch: ps.Branch, Suffix: name, Base: base})
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Branch = created.Branch
+
+	dir := e.store.CandidateDir(opts.Issue, opts.Stage, ps.CurrentAttempt, name)
+	_ = os.MkdirAll(dir, 0o755)
+	redactor := secrets.RedactorFor(cfg)
+
+	ccfg, ownDB, err := e.candidateDB(cfg, opts.Issue, name)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if ownDB != "" {
+		defer func() {
+			if err := dbprov.DropScratch(e.dbAdmin, ownDB); err != nil {
+				e.logf("warning: drop candidate database %s: %v", ownDB, err)
+			}
+		}()
+	}
+	cfg = ccfg
+
+	sessionName := fmt.Sprintf("%d-%s-%d-%s", opts.Issue, opts.Stage, ps.CurrentAttempt, name)
+	if err := e.setupCandidate(created.Path, "factory-"+sessionName+"-setup", cfg, ownDB != "", opts.Timeout); err != nil {
+		res.Error = redactor.Redact(err.Error())
+		return res
+	}
+	cps := *ps
+	cps.Worktree, cps.Branch = created.Path, created.Branch
+	prompt, _, err := e.buildAndRenderPrompt(&cps, opts, stageCfg, cfg)
+	if err != nil {
+		res.Error = fmt.Sprintf("build prompt: %v", err)
+		return res
+	}
+	_ = os.WriteFile(filepath.Join(dir, "prompt.md"), []byte(redactor.Redact(prompt)), 0o644)
+
+	if err := e.createAndRunSession(sessionName, &cps, opts, stageCfg, prompt, cfg); err != nil {
+		if err == errRateLimited {
+			res.RateLimited = true
+		} else {
+			res.Error = err.Error()
+		}
+	} else {
+		e.ensureCommitted(sessionName, created.Path, opts.Timeout)
+	}
+	if log, err := e.sessions.Kill(sessionName); err == nil && log != "" {
+		_ = os.WriteFile(filepath.Join(dir, "session.log"), []byte(redactor.Redact(log)), 0o644)
+	}
+	if res.Error != "" || res.RateLimited {
+		return res
+	}
+
+	res.Head = gitHead(created.Path)
+	if out, err := exec.Command("git", "-C", created.Path, "diff", base, "HEAD").Output(); err == nil {
+		_ = os.WriteFile(filepath.Join(dir, "diff.patch"), out, 0o644)
+	}
+	res.DiffLines = diffLines(created.Path, base)
+
+	if len(gateChecks) > 0 {
+		gateMu.Lock()
+		gate, _, err := e.checkerFor(cfg, "factory-"+sessionName+"-checks").RunGate(created.Path, checks.GateOpts{
+			Issue:    opts.Issue,
+			Stage:    opts.Stage,
+			Attempt:  ps.CurrentAttempt,
+			Worktree: created.Path,
+			Checks:   gateChecks,
+			Continue: true,
+		})
+		gateMu.Unlock()
+		if err != nil {
+			res.Error = fmt.Sprintf("checks: %v", err)
+			return res
+		}
+		for _, c := range gate.Checks {
+			if c.Passed {
+				res.ChecksPassed++
+			}
+		}
+		for _, f := range gate.RemainingFailures {
+			res.Findings += max(f.Count, 1)
+		}
+		_ = pipeline.WriteJSON(filepath.Join(dir, "gate.json"), gate)
+	}
+	e.logf("candidate %s: %d/%d checks passed, %d lines changed", name, res.ChecksPassed, res.ChecksTotal, res.DiffLines)
+	return res
+}
+
+// candidateDB returns the config candidate name runs with. With a
+// per_pipeline database and an admin connection, it clones the template into
+// a database of the candidate's own and returns a copy of cfg pointing at it,
+// with the database's name; otherwise cfg itself and "".
+func (e *Engine) candidateDB(cfg *config.PipelineConfig, issue int, name string) (*config.PipelineConfig, string, error) {
+	d := cfg.Pipeline.Database
+	if d == nil || d.Issue == 0 {
+		return cfg, "", nil
+	}
+	if e.dbAdmin == nil {
+		e.logf("warning: candidate %s shares the pipeline database: no admin connection to clone one", name)
+		return cfg, "", nil
+	}
+	dbName := candidateDBName(d, issue, name)
+	if err := dbprov.CloneScratch(e.dbAdmin, d, dbName); err != nil {
+		return nil, "", fmt.Errorf("clone candidate database: %w", err)
+	}
+	own := *d
+	own.Name = dbName
+	ccfg := *cfg
+	ccfg.Pipeline.Database = &own
+	return &ccfg, dbName, nil
+}
+
+// candidateDBName names a candidate's database after the repo's database,
+// the issue and the candidate, within PostgreSQL's 63-byte limit.
+func candidateDBName(dbCfg *config.DatabaseConfig, issue int, name string) string {
+	root := strings.TrimSuffix(dbCfg.Name, fmt.Sprintf("_issue_%d", issue))
+	return fmt.Sprintf("%s_cd%d_%s", root, issue, name)
+}
+
+// setupCandidate runs pipeline.setup in a candidate worktree, then
+// database.migrate when migrate is set, in a container named box when
+// pipeline.sandbox is set.
+func (e *Engine) setupCandidate(dir, box string, cfg *config.PipelineConfig, migrate bool, timeout time.Duration) error {
+	cmds := cfg.Pipeline.Setup
+	if d := cfg.Pipeline.Database; migrate && d != nil && d.Migrate != "" {
+		cmds = append(append([]string{}, cmds...), d.Migrate)
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	vars, err := pipelineVars(cfg)
+	if err != nil {
+		return fmt.Errorf("resolve env: %w", err)
+	}
+	for _, cmdStr := range cmds {
+		e.logf("setup: running %q in %s", cmdStr, dir)
+		if out, err := shellIn(cfg, box, dir, vars, cmdStr, timeout); err != nil {
+			return fmt.Errorf("setup %q: %s: %w", cmdStr, tail(out, 5), err)
+		}
+	}
+	return nil
+}
+
+var shortstatNum = regexp.MustCompile(`(\d+) (?:insertion|deletion)`)
+
+// diffLines returns the lines added plus removed between base and HEAD.
+func diffLines(dir, base string) int {
+	out, err := exec.Command("git", "-C", dir, "diff", "--shortstat", base, "HEAD").Output()
+	if err != nil {
+		return 0
+	}
+	total := 0
+	for _, m := range shortstatNum.FindAllStringSubmatch(string(out), -1) {
+		n, _ := strconv.Atoi(m[1])
+		total += n
+	}
+	return total
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
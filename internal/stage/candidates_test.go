@@ -0,0 +1,113 @@
+package stage
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+func TestPickCandidate(t *testing.T) {
+	cs := []CandidateResult{
+		{Name: "c1", Head: "a", ChecksPassed: 1, ChecksTotal: 2, Findings: 1, DiffLines: 10},
+		{Name: "c2", Head: "b", ChecksPassed: 2, ChecksTotal: 2, DiffLines: 120},
+		{Name: "c3", Head: "c", ChecksPassed: 2, ChecksTotal: 2, DiffLines: 40},
+		{Name: "c4", Error: "session exited unexpectedly"},
+	}
+	if got := PickCandidate(cs); got != 2 {
+		t.Errorf("PickCandidate() = %d, want 2 (all checks pass, smallest diff)", got)
+	}
+
+	cs = []CandidateResult{
+		{Name: "c1", Head: "a", ChecksPassed: 1, ChecksTotal: 3, Findings: 2, DiffLines: 10},
+		{Name: "c2", Head: "b", ChecksPassed: 1, ChecksTotal: 3, Findings: 5, DiffLines: 5},
+	}
+	if got := PickCandidate(cs); got != 0 {
+		t.Errorf("PickCandidate() = %d, want 0 (fewer findings)", got)
+	}
+}
+
+func TestPickCandidate_NoneUsable(t *testing.T) {
+	cs := []CandidateResult{
+		{Name: "c1", RateLimited: true},
+		{Name: "c2", Head: "a", ChecksPassed: 1, ChecksTotal: 1}, // no changes
+	}
+	if got := PickCandidate(cs); got != -1 {
+		t.Errorf("PickCandidate() = %d, want -1", got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	_ = os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0o644)
+	git("add", ".")
+	git("commit", "-qm", "base")
+	base := gitHead(dir)
+	_ = os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\nthree\nfour\n"), 0o644)
+	git("commit", "-qam", "change")
+
+	if got := diffLines(dir, base); got != 3 {
+		t.Errorf("diffLines() = %d, want 3 (2 insertions, 1 deletion)", got)
+	}
+}
+
+func TestSetupCandidate(t *testing.T) {
+	t.Setenv("DATABASE_URL", "")
+	dir := t.TempDir()
+	cfg := &config.PipelineConfig{Pipeline: config.Pipeline{
+		Setup:    []string{`echo "$DATABASE_URL" > db.txt`},
+		Database: &config.DatabaseConfig{Name: "app_issue_7", User: "app"},
+	}}
+	e := &Engine{}
+	if err := e.setupCandidate(dir, "factory-test-setup", cfg, false, 0); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, "db.txt"))
+	if !strings.Contains(string(got), "/app_issue_7") {
+		t.Errorf("setup should see the pipeline's DATABASE_URL, got %q", got)
+	}
+
+	cfg.Pipeline.Database.Migrate = `echo migrated > migrate.txt`
+	if err := e.setupCandidate(dir, "factory-test-setup", cfg, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "migrate.txt")); err != nil {
+		t.Error("migrate should run after setup for a candidate with its own database")
+	}
+
+	cfg.Pipeline.Setup = []string{"exit 3"}
+	if err := e.setupCandidate(dir, "factory-test-setup", cfg, false, 0); err == nil || !strings.Contains(err.Error(), `setup "exit 3"`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCandidateDB(t *testing.T) {
+	shared := &config.PipelineConfig{Pipeline: config.Pipeline{Database: &config.DatabaseConfig{Name: "shop"}}}
+	e := &Engine{}
+	if got, name, err := e.candidateDB(shared, 42, "c1"); err != nil || got != shared || name != "" {
+		t.Errorf("shared database: got %p %q %v, want the pipeline config", got, name, err)
+	}
+	perPipeline := (&config.PipelineConfig{Pipeline: config.Pipeline{
+		Database: &config.DatabaseConfig{Name: "shop", Isolation: config.DatabasePerPipeline},
+	}}).ForIssue(42)
+	if got, name, err := e.candidateDB(perPipeline, 42, "c1"); err != nil || got != perPipeline || name != "" {
+		t.Errorf("no admin connection: got %p %q %v, want the pipeline config", got, name, err)
+	}
+	if got := candidateDBName(perPipeline.Pipeline.Database, 42, "c1"); got != "shop_cd42_c1" {
+		t.Errorf("candidateDBName = %q", got)
+	}
+}
@@ -1,6 +1,7 @@
 package stage
 
 import (
+	"database/sql"
 	"fmt"
 	"io"
 	"os/exec"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
 	"github.com/lucasnoah/taintfactory/internal/checks"
 	"github.com/lucasnoah/taintfactory/internal/config"
 	appctx "github.com/lucasnoah/taintfactory/internal/context"
@@ -15,7 +17,9 @@ import (
 	"github.com/lucasnoah/taintfactory/internal/github"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
 	"github.com/lucasnoah/taintfactory/internal/prompt"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
 	"github.com/lucasnoah/taintfactory/internal/session"
+	"github.com/lucasnoah/taintfactory/internal/worktree"
 )
 
 // errRateLimited is returned by createAndRunSession when the agent hit an
@@ -30,9 +34,11 @@ type Engine struct {
 	store        *pipeline.Store
 	db           *db.DB
 	cfg          *config.PipelineConfig
-	pollInterval time.Duration // for WaitIdle; defaults to 30s
-	bootDelay    time.Duration // delay after session create for Claude to boot; defaults to 15s
-	progress     io.Writer     // live progress output; nil = silent
+	pollInterval time.Duration     // for WaitIdle; defaults to 30s
+	bootDelay    time.Duration     // delay after session create for Claude to boot; defaults to 15s
+	progress     io.Writer         // live progress output; nil = silent
+	wt           *worktree.Manager // creates best-of-N candidate worktrees; nil disables candidates
+	dbAdmin      *sql.DB           // postgres maintenance connection for migration_check scratch databases
 }
 
 // NewEngine creates a stage engine.
@@ -71,6 +77,18 @@ func (e *Engine) SetProgress(w io.Writer) {
 	e.progress = w
 }
 
+// SetWorktrees sets the worktree manager used for best-of-N candidates.
+func (e *Engine) SetWorktrees(wt *worktree.Manager) {
+	e.wt = wt
+}
+
+// SetDatabaseAdmin sets the admin connection migration_check stages use to
+// create scratch databases, and best-of-N candidates use to clone their own
+// per_pipeline databases.
+func (e *Engine) SetDatabaseAdmin(conn *sql.DB) {
+	e.dbAdmin = conn
+}
+
 // logf prints a progress line if a progress writer is configured.
 func (e *Engine) logf(format string, args ...interface{}) {
 	if e.progress != nil {
@@ -86,28 +104,34 @@ type RunOpts struct {
 	Config  *config.PipelineConfig // optional: overrides engine's default config for this run
 }
 
-// cfgFor returns the effective config for a run: RunOpts.Config if set, else e.cfg.
+// cfgFor returns the effective config for a run: RunOpts.Config if set, else
+// e.cfg, with a per_pipeline database resolved to the issue's own.
 func (e *Engine) cfgFor(opts RunOpts) *config.PipelineConfig {
 	if opts.Config != nil {
-		return opts.Config
+		return opts.Config.ForIssue(opts.Issue)
 	}
-	return e.cfg
+	return e.cfg.ForIssue(opts.Issue)
 }
 
 // RunResult captures the outcome of a stage run.
 type RunResult struct {
-	Issue           int               `json:"issue"`
-	Stage           string            `json:"stage"`
-	Attempt         int               `json:"attempt"`
-	Session         string            `json:"session,omitempty"`
-	Outcome         string            `json:"outcome"` // "success", "fail", "escalate"
-	AgentDuration   time.Duration     `json:"agent_duration"`
-	TotalDuration   time.Duration     `json:"total_duration"`
-	FixRounds       int               `json:"fix_rounds"`
-	ChecksFirstPass bool              `json:"checks_first_pass"`
-	AutoFixes       map[string]int    `json:"auto_fixes"`
-	AgentFixes      map[string]int    `json:"agent_fixes"`
-	FinalCheckState map[string]string `json:"final_check_state"`
+	Issue           int                `json:"issue"`
+	Stage           string             `json:"stage"`
+	Attempt         int                `json:"attempt"`
+	Session         string             `json:"session,omitempty"`
+	Outcome         string             `json:"outcome"` // "success", "fail", "escalate"
+	AgentDuration   time.Duration      `json:"agent_duration"`
+	TotalDuration   time.Duration      `json:"total_duration"`
+	FixRounds       int                `json:"fix_rounds"`
+	ChecksFirstPass bool               `json:"checks_first_pass"`
+	AutoFixes       map[string]int     `json:"auto_fixes"`
+	AgentFixes      map[string]int     `json:"agent_fixes"`
+	FinalCheckState map[string]string  `json:"final_check_state"`
+	Variant         string             `json:"variant,omitempty"`        // prompt_variants arm used, if any
+	Summary         string             `json:"summary,omitempty"`        // outcome summary; panel stages set the verdict here
+	Findings        []pipeline.Finding `json:"findings,omitempty"`       // panel stages: merged reviewer findings
+	Candidate       string             `json:"candidate,omitempty"`      // best-of-N: the candidate that was kept
+	NeedsApproval   string             `json:"needs_approval,omitempty"` // set when the stage passed but a human must approve it before the pipeline moves on
 }
 
 // Run executes the full stage lifecycle.
@@ -141,6 +165,14 @@ func (e *Engine) Run(opts RunOpts) (*RunResult, error) {
 		return e.runChecksOnly(ps, stageCfg, opts, result, start, cfg)
 	}
 
+	if stageCfg.Type == "migration_check" {
+		return e.runMigrationCheck(ps, stageCfg, opts, result, start, cfg)
+	}
+
+	if stageCfg.Type == "browser_qa" {
+		return e.runBrowserQA(ps, stageCfg, opts, result, start, cfg)
+	}
+
 	// Run checks_before if configured
 	if len(stageCfg.ChecksBefore) > 0 {
 		e.logf("running checks_before: %v", stageCfg.ChecksBefore)
@@ -158,36 +190,63 @@ func (e *Engine) Run(opts RunOpts) (*RunResult, error) {
 		e.logf("checks_before passed")
 	}
 
+	if stageCfg.Type == "panel" {
+		return e.runPanel(ps, stageCfg, opts, result, start, cfg)
+	}
+
 	// Build context and render prompt
 	e.logf("building context and rendering prompt...")
 	agentStart := time.Now()
-	rendered, err := e.buildAndRenderPrompt(ps, opts, stageCfg, cfg)
+	rendered, variant, err := e.buildAndRenderPrompt(ps, opts, stageCfg, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("build prompt: %w", err)
 	}
+	result.Variant = variant
+	if variant != "" {
+		e.logf("prompt variant: %s", variant)
+	}
 	e.logf("prompt rendered (%d bytes)", len(rendered))
 
-	// Save rendered prompt
-	_ = e.store.SavePrompt(opts.Issue, opts.Stage, ps.CurrentAttempt, rendered)
+	// Save rendered prompt, with known secret values masked
+	_ = e.store.SavePrompt(opts.Issue, opts.Stage, ps.CurrentAttempt, secrets.RedactorFor(cfg).Redact(rendered))
 
-	// Create session and send prompt
-	sessionName := fmt.Sprintf("%d-%s-%d", opts.Issue, opts.Stage, ps.CurrentAttempt)
-	result.Session = sessionName
-	e.logf("creating agent session: %s", sessionName)
-	if err := e.createAndRunSession(sessionName, ps, opts, stageCfg, rendered, cfg); err != nil {
+	// Create session and send prompt. With candidates, several sessions run
+	// in their own worktrees and the best result is brought into this one;
+	// no session is left to continue, so fix rounds start fresh ones.
+	sessionName := ""
+	if stageCfg.Candidates > 1 && e.wt != nil {
+		winner, err := e.runCandidates(ps, opts, stageCfg, cfg)
 		if err == errRateLimited {
 			e.logf("rate limit detected — pausing stage for retry")
 			result.Outcome = "rate_limited"
 			result.TotalDuration = time.Since(start)
 			return result, nil
 		}
-		return nil, fmt.Errorf("run session: %w", err)
+		if err != nil {
+			return nil, fmt.Errorf("run candidates: %w", err)
+		}
+		result.Candidate = winner
+	} else {
+		sessionName = fmt.Sprintf("%d-%s-%d", opts.Issue, opts.Stage, ps.CurrentAttempt)
+		result.Session = sessionName
+		e.logf("creating agent session: %s", sessionName)
+		if err := e.createAndRunSession(sessionName, ps, opts, stageCfg, rendered, cfg); err != nil {
+			if err == errRateLimited {
+				e.logf("rate limit detected — pausing stage for retry")
+				result.Outcome = "rate_limited"
+				result.TotalDuration = time.Since(start)
+				return result, nil
+			}
+			return nil, fmt.Errorf("run session: %w", err)
+		}
 	}
 	result.AgentDuration = time.Since(agentStart)
 	e.logf("agent finished (%s)", result.AgentDuration.Round(time.Second))
 
 	// Steer agent to commit any uncommitted changes
-	e.ensureCommitted(sessionName, ps.Worktree, opts.Timeout)
+	if sessionName != "" {
+		e.ensureCommitted(sessionName, ps.Worktree, opts.Timeout)
+	}
 
 	// Run post-checks
 	checkNames := e.resolvePostChecks(stageCfg)
@@ -218,6 +277,9 @@ func (e *Engine) Run(opts RunOpts) (*RunResult, error) {
 			result.FinalCheckState[c.Check] = "fail"
 		}
 	}
+	for _, sk := range gate.Skipped {
+		result.FinalCheckState[sk.Check] = "skipped"
+	}
 
 	if gate.Passed {
 		e.logf("all post-checks passed on first try")
@@ -254,7 +316,7 @@ func (e *Engine) Run(opts RunOpts) (*RunResult, error) {
 		_ = e.db.LogPipelineEvent(ps.Namespace, opts.Issue, "fix_round_start", opts.Stage, ps.CurrentAttempt, fmt.Sprintf("round=%d", round))
 
 		// Determine if we need a fresh session
-		if round > freshAfter {
+		if round > freshAfter || sessionName == "" {
 			e.logf("creating fresh fix session (round > %d)", freshAfter)
 			e.cleanupSession(sessionName)
 			sessionName = fmt.Sprintf("%d-%s-%d-fix-%d", opts.Issue, opts.Stage, ps.CurrentAttempt, round)
@@ -365,6 +427,9 @@ func (e *Engine) runChecksOnly(ps *pipeline.PipelineState, stageCfg *config.Stag
 			result.FinalCheckState[c.Check] = "fail"
 		}
 	}
+	for _, sk := range gate.Skipped {
+		result.FinalCheckState[sk.Check] = "skipped"
+	}
 
 	if gate.Passed {
 		e.logf("all checks passed")
@@ -380,8 +445,9 @@ func (e *Engine) runChecksOnly(ps *pipeline.PipelineState, stageCfg *config.Stag
 	return result, nil
 }
 
-// buildAndRenderPrompt builds context and renders the stage prompt.
-func (e *Engine) buildAndRenderPrompt(ps *pipeline.PipelineState, opts RunOpts, stageCfg *config.Stage, cfg *config.PipelineConfig) (string, error) {
+// buildAndRenderPrompt builds context and renders the stage prompt. It also
+// returns the prompt variant used, if the stage runs an experiment.
+func (e *Engine) buildAndRenderPrompt(ps *pipeline.PipelineState, opts RunOpts, stageCfg *config.Stage, cfg *config.PipelineConfig) (string, string, error) {
 	// Load cached issue body from pipeline directory
 	var issueBody string
 	pipelineDir := fmt.Sprintf("%s/%d", e.store.BaseDir(), opts.Issue)
@@ -397,15 +463,22 @@ func (e *Engine) buildAndRenderPrompt(ps *pipeline.PipelineState, opts RunOpts,
 		PipelineVars: cfg.Pipeline.Vars,
 	})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	tmplContent, err := prompt.LoadTemplate(buildResult.Template, ps.Worktree)
 	if err != nil {
-		return "", fmt.Errorf("load template %q: %w", buildResult.Template, err)
+		return "", "", fmt.Errorf("load template %q: %w", buildResult.Template, err)
 	}
 
-	return prompt.Render(tmplContent, buildResult.Vars)
+	rendered, err := prompt.RenderWith(tmplContent, buildResult.Vars, prompt.RenderOpts{
+		Data:     buildResult.Data,
+		Partials: prompt.TemplatePartials(buildResult.Template, ps.Worktree),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return rendered + appctx.BudgetNote(buildResult.Budget, buildResult.Truncations), buildResult.Variant, nil
 }
 
 // buildFixPrompt builds a prompt for a fresh fix session.
@@ -435,7 +508,9 @@ func (e *Engine) buildFixPrompt(ps *pipeline.PipelineState, opts RunOpts, stageC
 		return "", err
 	}
 
-	return prompt.Render(tmplContent, vars)
+	return prompt.RenderWith(tmplContent, vars, prompt.RenderOpts{
+		Partials: prompt.TemplatePartials("fix-checks.md", ps.Worktree),
+	})
 }
 
 // createAndRunSession creates a tmux session, sends the prompt, and waits for idle.
@@ -453,10 +528,21 @@ func (e *Engine) createAndRunSession(name string, ps *pipeline.PipelineState, op
 		model = "claude-opus-4-6"
 	}
 
-	// Build merged env map: pipeline.env + auto DATABASE_URL
-	env := buildEnvMap(cfg)
+	// Build merged env map: env_file + pipeline.env (secrets resolved) + auto DATABASE_URL
+	env, err := buildEnvMap(cfg)
+	if err != nil {
+		return fmt.Errorf("resolve env: %w", err)
+	}
 
-	e.logf("creating tmux session %s in %s (model: %s)", name, ps.Worktree, model)
+	limits := cgroup.LimitsFrom(cfg.Pipeline.SessionLimits(stageCfg))
+	var hostLimits cgroup.Limits
+	if cfg.Pipeline.Sandbox.Enabled() {
+		e.logf("creating tmux session %s in %s (model: %s, sandbox: %s)", name, ps.Worktree, model, cfg.Pipeline.Sandbox.Image)
+	} else {
+		e.logf("creating tmux session %s in %s (model: %s)", name, ps.Worktree, model)
+		hostLimits = limits
+		e.warnUnenforced(hostLimits, "session "+name)
+	}
 	if err := e.sessions.Create(session.CreateOpts{
 		Name:        name,
 		Workdir:     ps.Worktree,
@@ -466,6 +552,8 @@ func (e *Engine) createAndRunSession(name string, ps *pipeline.PipelineState, op
 		Stage:       opts.Stage,
 		Interactive: true,
 		Env:         env,
+		Sandbox:     sandboxFor(cfg, name, ps.Worktree, limits),
+		Limits:      hostLimits,
 	}); err != nil {
 		return fmt.Errorf("create session: %w", err)
 	}
@@ -507,29 +595,18 @@ func (e *Engine) createAndRunSession(name string, ps *pipeline.PipelineState, op
 
 // runGate runs the check gate for the given check names.
 func (e *Engine) runGate(ps *pipeline.PipelineState, opts RunOpts, checkNames []string, fixRound int, cfg *config.PipelineConfig) (*checks.GateResult, []*checks.Result, error) {
-	var gateChecks []checks.GateCheckConfig
-	for _, name := range checkNames {
-		chk, ok := cfg.Pipeline.Checks[name]
-		if !ok {
-			return nil, nil, fmt.Errorf("check %q not defined in config", name)
-		}
-		timeout := 2 * time.Minute
-		if chk.Timeout != "" {
-			if d, err := time.ParseDuration(chk.Timeout); err == nil {
-				timeout = d
-			}
+	gateChecks, err := gateConfigs(checkNames, cfg, ps.BaseRef())
+	if err != nil {
+		return nil, nil, err
+	}
+	gateChecks, skipped := e.selectChecks(ps.Worktree, ps.BaseRef(), gateChecks)
+	if !cfg.Pipeline.Sandbox.Enabled() {
+		for _, c := range gateChecks {
+			e.warnUnenforced(c.Limits, "check "+c.Name)
 		}
-		gateChecks = append(gateChecks, checks.GateCheckConfig{
-			Name:       name,
-			Command:    chk.Command,
-			Parser:     chk.Parser,
-			Timeout:    timeout,
-			AutoFix:    chk.AutoFix,
-			FixCommand: chk.FixCommand,
-		})
 	}
 
-	gate, results, err := e.checker.RunGate(ps.Worktree, checks.GateOpts{
+	gate, results, err := e.checkerFor(cfg, fmt.Sprintf("factory-%d-%s-checks", opts.Issue, opts.Stage)).RunGate(ps.Worktree, checks.GateOpts{
 		Issue:    opts.Issue,
 		Stage:    opts.Stage,
 		FixRound: fixRound,
@@ -537,6 +614,7 @@ func (e *Engine) runGate(ps *pipeline.PipelineState, opts RunOpts, checkNames []
 		Worktree: ps.Worktree,
 		Checks:   gateChecks,
 		Continue: true,
+		Skipped:  skipped,
 	})
 
 	// Log individual check results and report progress
@@ -546,10 +624,11 @@ func (e *Engine) runGate(ps *pipeline.PipelineState, opts RunOpts, checkNames []
 			status = "FAIL"
 		}
 		e.logf("check %s: %s (%dms)", r.CheckName, status, r.DurationMs)
-		if dbErr := e.db.LogCheckRun(
+		if dbErr := e.db.LogCheckRunUsage(
 			ps.Namespace, opts.Issue, opts.Stage, ps.CurrentAttempt, fixRound,
 			r.CheckName, r.Passed, r.AutoFixed, r.ExitCode,
 			r.DurationMs, r.Summary, r.Findings,
+			db.CheckUsage{PeakMemoryBytes: r.PeakMemoryBytes, CPUMs: r.CPUMs},
 		); dbErr != nil {
 			return nil, nil, fmt.Errorf("log check run %q: %w", r.CheckName, dbErr)
 		}
@@ -558,6 +637,37 @@ func (e *Engine) runGate(ps *pipeline.PipelineState, opts RunOpts, checkNames []
 	return gate, results, err
 }
 
+// gateConfigs resolves check names to gate configs.
+func gateConfigs(checkNames []string, cfg *config.PipelineConfig, baseRef string) ([]checks.GateCheckConfig, error) {
+	var gateChecks []checks.GateCheckConfig
+	for _, name := range checkNames {
+		chk, ok := cfg.Pipeline.Checks[name]
+		if !ok {
+			return nil, fmt.Errorf("check %q not defined in config", name)
+		}
+		timeout := 2 * time.Minute
+		if chk.Timeout != "" {
+			if d, err := time.ParseDuration(chk.Timeout); err == nil {
+				timeout = d
+			}
+		}
+		gateChecks = append(gateChecks, checks.GateCheckConfig{
+			Name:       name,
+			Command:    chk.Command,
+			Parser:     chk.Parser,
+			Timeout:    timeout,
+			AutoFix:    chk.AutoFix,
+			FixCommand: chk.FixCommand,
+			Limits:     cgroup.LimitsFrom(chk.Limits),
+			Paths:      chk.Paths,
+			Impact:     chk.Impact,
+			Coverage:   checks.CoverageFrom(chk.Coverage, baseRef),
+		})
+	}
+
+	return gateChecks, nil
+}
+
 // resolvePostChecks determines which checks to run after the agent.
 func (e *Engine) resolvePostChecks(stageCfg *config.Stage) []string {
 	if stageCfg.SkipChecks {
@@ -632,7 +742,14 @@ func (e *Engine) saveSessionLog(sessionName string, log string) error {
 	if err != nil {
 		return err
 	}
-	return e.store.SaveSessionLog(state.Issue, state.Stage, ps.CurrentAttempt, log)
+	// Mask secrets using the config the pipeline was started with.
+	cfg := e.cfg
+	if ps.ConfigPath != "" {
+		if loaded, err := config.Load(ps.ConfigPath); err == nil {
+			cfg = loaded
+		}
+	}
+	return e.store.SaveSessionLog(state.Issue, state.Stage, ps.CurrentAttempt, secrets.RedactorFor(cfg).Redact(log))
 }
 
 // findStageConfig finds a stage in the given pipeline config.
@@ -645,20 +762,18 @@ func (e *Engine) findStageConfig(stageID string, cfg *config.PipelineConfig) (*c
 	return nil, fmt.Errorf("stage %q not found in config", stageID)
 }
 
-// buildEnvMap merges pipeline.env with auto-generated DATABASE_URL.
+// buildEnvMap merges pipeline.env_file and pipeline.env (with secret://
+// references resolved) with auto-generated DATABASE_URL.
 // If database is configured, DATABASE_URL overrides any user-provided value.
-func buildEnvMap(cfg *config.PipelineConfig) map[string]string {
-	env := make(map[string]string)
-	for k, v := range cfg.Pipeline.Env {
-		env[k] = v
-	}
-	if cfg.Pipeline.Database != nil {
-		env["DATABASE_URL"] = cfg.Pipeline.Database.URL()
+func buildEnvMap(cfg *config.PipelineConfig) (map[string]string, error) {
+	env, err := secrets.Resolve(cfg, secrets.DefaultStore())
+	if err != nil {
+		return nil, err
 	}
-	if len(env) == 0 {
-		return nil
+	if len(env.Vars) == 0 {
+		return nil, nil
 	}
-	return env
+	return env.Vars, nil
 }
 
 // formatGateFailures formats gate failures into a deterministic readable string.
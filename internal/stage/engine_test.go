@@ -49,6 +49,14 @@ func (m *mockTmux) SendKeys(sess string, keys string) error {
 	return nil
 }
 
+func (m *mockTmux) SendRaw(sess string, key string) error {
+	if !m.sessions[sess] {
+		return fmt.Errorf("session %q not found", sess)
+	}
+	m.sent = append(m.sent, tmuxSend{Session: sess, Keys: key})
+	return nil
+}
+
 func (m *mockTmux) SendBuffer(sess string, content string) error {
 	if !m.sessions[sess] {
 		return fmt.Errorf("session %q not found", sess)
@@ -115,10 +123,10 @@ func (m *mockCheckCmd) Run(ctx context.Context, dir string, command string) (str
 
 type mockGit struct{}
 
-func (m *mockGit) Diff(dir string) (string, error)        { return "", nil }
-func (m *mockGit) DiffSummary(dir string) (string, error)  { return "", nil }
-func (m *mockGit) FilesChanged(dir string) (string, error) { return "", nil }
-func (m *mockGit) Log(dir string) (string, error)          { return "", nil }
+func (m *mockGit) Diff(dir, base string) (string, error)         { return "", nil }
+func (m *mockGit) DiffSummary(dir, base string) (string, error)  { return "", nil }
+func (m *mockGit) FilesChanged(dir, base string) (string, error) { return "", nil }
+func (m *mockGit) Log(dir, base string) (string, error)          { return "", nil }
 
 // --- Test helpers ---
 
@@ -0,0 +1,68 @@
+package stage
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/lucasnoah/taintfactory/internal/checks"
+)
+
+// selectChecks drops the checks the worktree's change cannot affect and
+// narrows impact-aware ones; see checks.SelectChecks. When the change is
+// unknown or empty every check runs.
+func (e *Engine) selectChecks(worktree, baseRef string, gateChecks []checks.GateCheckConfig) ([]checks.GateCheckConfig, []checks.SkippedCheck) {
+	selective := false
+	for _, c := range gateChecks {
+		if len(c.Paths) > 0 || c.Impact != "" {
+			selective = true
+		}
+	}
+	if !selective {
+		return gateChecks, nil
+	}
+	files, err := changedFiles(worktree, baseRef)
+	if err != nil || len(files) == 0 {
+		e.logf("could not tell what changed against %s; running every check", baseRef)
+		return gateChecks, nil
+	}
+	run, skipped := checks.SelectChecks(worktree, files, gateChecks)
+	for _, s := range skipped {
+		e.logf("check %s: skipped (%s)", s.Check, s.Reason)
+	}
+	for _, c := range run {
+		if c.Scope != "" {
+			e.logf("check %s: narrowed to %s", c.Name, c.Scope)
+		}
+	}
+	return run, skipped
+}
+
+// changedFiles lists the files that differ between the merge base of
+// baseRef and the worktree, including uncommitted and untracked ones.
+func changedFiles(worktree, baseRef string) ([]string, error) {
+	git := func(args ...string) (string, error) {
+		out, err := exec.Command("git", append([]string{"-C", worktree}, args...)...).Output()
+		return string(out), err
+	}
+	base, err := git("merge-base", baseRef, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := git("diff", "--name-only", strings.TrimSpace(base))
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git("ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(diff+untracked, "\n") {
+		if f = strings.TrimSpace(f); f != "" && !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,77 @@
+package stage
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/checks"
+)
+
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name string) {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755)
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644)
+	}
+	run("init", "-q", "-b", "main")
+	write("README.md")
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+	run("checkout", "-q", "-b", "feature")
+	write("web/app.tsx")
+	run("add", "-A")
+	run("commit", "-q", "-m", "web")
+	// An untracked file and an uncommitted edit count too.
+	write("docs/guide.md")
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("edit"), 0o644)
+	return dir
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := gitRepo(t)
+	files, err := changedFiles(dir, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"README.md", "web/app.tsx", "docs/guide.md"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("changedFiles = %v, want %v", files, want)
+	}
+	if _, err := changedFiles(dir, "origin/nope"); err == nil {
+		t.Error("expected an error for an unknown base ref")
+	}
+}
+
+func TestSelectChecks(t *testing.T) {
+	dir := gitRepo(t)
+	e := &Engine{}
+	gateChecks := []checks.GateCheckConfig{
+		{Name: "lint", Command: "lint"},
+		{Name: "web", Command: "npm test", Paths: []string{"web/**"}},
+		{Name: "api", Command: "go test ./api/...", Paths: []string{"api/**"}},
+	}
+
+	run, skipped := e.selectChecks(dir, "main", gateChecks)
+	if len(run) != 2 || run[0].Name != "lint" || run[1].Name != "web" {
+		t.Errorf("run = %+v, want lint and web", run)
+	}
+	if len(skipped) != 1 || skipped[0].Check != "api" {
+		t.Errorf("skipped = %+v, want api", skipped)
+	}
+
+	// Without a known base every check runs.
+	if run, skipped := e.selectChecks(dir, "origin/nope", gateChecks); len(run) != 3 || len(skipped) != 0 {
+		t.Errorf("unknown base: run = %d skipped = %d, want all run", len(run), len(skipped))
+	}
+}
@@ -0,0 +1,550 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/dbprov"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
+)
+
+// Schema maps each table of a schema dump to its columns and their types.
+type Schema map[string]map[string]string
+
+// ParseSchema reads the CREATE TABLE statements of a `pg_dump --schema-only`
+// dump. Column types are kept as pg_dump prints them, without constraints
+// or defaults.
+func ParseSchema(dump string) Schema {
+	schema := make(Schema)
+	var cols map[string]string
+	for _, line := range strings.Split(dump, "\n") {
+		if cols == nil {
+			if name, ok := createTableName(line); ok {
+				cols = make(map[string]string)
+				schema[name] = cols
+			}
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, ")") {
+			cols = nil
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "CONSTRAINT ") {
+			continue
+		}
+		if name, typ := parseColumn(line); name != "" {
+			cols[name] = typ
+		}
+	}
+	return schema
+}
+
+var createTableRe = regexp.MustCompile(`^CREATE (?:UNLOGGED )?TABLE (\S+) \($`)
+
+func createTableName(line string) (string, bool) {
+	m := createTableRe.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// columnClauses end the type in a pg_dump column definition.
+var columnClauses = []string{" NOT NULL", " DEFAULT ", " COLLATE ", " GENERATED ", " CONSTRAINT "}
+
+// parseColumn splits a column definition line into its name and type.
+func parseColumn(line string) (string, string) {
+	line = strings.TrimSuffix(line, ",")
+	var name, rest string
+	if strings.HasPrefix(line, `"`) {
+		end := strings.Index(line[1:], `"`)
+		if end < 0 {
+			return "", ""
+		}
+		name, rest = line[1:end+1], line[end+2:]
+	} else {
+		var ok bool
+		name, rest, ok = strings.Cut(line, " ")
+		if !ok {
+			return "", ""
+		}
+	}
+	typ := rest
+	for _, clause := range columnClauses {
+		if i := strings.Index(typ, clause); i >= 0 {
+			typ = typ[:i]
+		}
+	}
+	return name, strings.TrimSpace(typ)
+}
+
+// DestructiveChanges returns findings for what head's schema loses relative
+// to base's: dropped tables, dropped columns, and column types that can no
+// longer hold every value the old type could.
+func DestructiveChanges(base, head Schema) []pipeline.Finding {
+	var findings []pipeline.Finding
+	for _, table := range sortedKeys(base) {
+		headCols, ok := head[table]
+		if !ok {
+			findings = append(findings, pipeline.Finding{
+				Severity: "critical",
+				Rule:     "dropped_table",
+				Message:  fmt.Sprintf("table %s is dropped", table),
+			})
+			continue
+		}
+		for _, col := range sortedKeys(base[table]) {
+			from := base[table][col]
+			to, ok := headCols[col]
+			switch {
+			case !ok:
+				findings = append(findings, pipeline.Finding{
+					Severity: "critical",
+					Rule:     "dropped_column",
+					Message:  fmt.Sprintf("column %s.%s (%s) is dropped", table, col, from),
+				})
+			case typeLosesData(from, to):
+				findings = append(findings, pipeline.Finding{
+					Severity: "major",
+					Rule:     "narrowed_type",
+					Message:  fmt.Sprintf("column %s.%s changes type from %s to %s", table, col, from, to),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// SchemaDiff describes how b differs from a, one line per table or column.
+func SchemaDiff(a, b Schema) []string {
+	var lines []string
+	tables := make(map[string]bool)
+	for t := range a {
+		tables[t] = true
+	}
+	for t := range b {
+		tables[t] = true
+	}
+	for _, table := range sortedKeys(tables) {
+		aCols, inA := a[table]
+		bCols, inB := b[table]
+		switch {
+		case !inB:
+			lines = append(lines, fmt.Sprintf("table %s missing", table))
+			continue
+		case !inA:
+			lines = append(lines, fmt.Sprintf("table %s added", table))
+			continue
+		}
+		cols := make(map[string]bool)
+		for c := range aCols {
+			cols[c] = true
+		}
+		for c := range bCols {
+			cols[c] = true
+		}
+		for _, col := range sortedKeys(cols) {
+			from, inA := aCols[col]
+			to, inB := bCols[col]
+			switch {
+			case !inB:
+				lines = append(lines, fmt.Sprintf("column %s.%s missing", table, col))
+			case !inA:
+				lines = append(lines, fmt.Sprintf("column %s.%s added", table, col))
+			case from != to:
+				lines = append(lines, fmt.Sprintf("column %s.%s is %s instead of %s", table, col, to, from))
+			}
+		}
+	}
+	return lines
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// intRanks orders the integer types by range.
+var intRanks = map[string]int{"smallint": 1, "integer": 2, "bigint": 3}
+
+// intDigits is how many decimal digits each integer type can need.
+var intDigits = map[string]int{"smallint": 5, "integer": 10, "bigint": 19}
+
+var typeArgsRe = regexp.MustCompile(`^(.*?)\((\d+)(?:,(\d+))?\)$`)
+
+// splitType separates a type's parameters: "numeric(10,2)" is numeric, 10, 2.
+// Missing parameters are -1.
+func splitType(t string) (string, int, int) {
+	m := typeArgsRe.FindStringSubmatch(t)
+	if m == nil {
+		return t, -1, -1
+	}
+	p, _ := strconv.Atoi(m[2])
+	s := -1
+	if m[3] != "" {
+		s, _ = strconv.Atoi(m[3])
+	}
+	return m[1], p, s
+}
+
+// typeLosesData reports whether converting a column from one type to another
+// can fail for, or truncate, values the old type holds. Changes it does not
+// recognise as widening count as lossy.
+func typeLosesData(from, to string) bool {
+	if from == to {
+		return false
+	}
+	fromBase, fromP, fromS := splitType(from)
+	toBase, toP, toS := splitType(to)
+
+	if r, ok := intRanks[from]; ok {
+		if r2, ok := intRanks[to]; ok {
+			return r2 < r
+		}
+		if toBase == "numeric" {
+			return toP >= 0 && toP-max(toS, 0) < intDigits[from]
+		}
+		return true
+	}
+	if from == "real" {
+		return to != "double precision"
+	}
+
+	switch fromBase {
+	case "text", "character varying", "character":
+		switch toBase {
+		case "text":
+			return false
+		case "character varying":
+			return toP >= 0 && (fromP < 0 || toP < fromP)
+		case "character":
+			return fromBase != "character" || toP < fromP
+		}
+		return true
+	case "numeric":
+		if toBase != "numeric" {
+			return true
+		}
+		if toP < 0 {
+			return false
+		}
+		return fromP < 0 || toP-max(toS, 0) < fromP-max(fromS, 0) || max(toS, 0) < max(fromS, 0)
+	case "timestamp without time zone":
+		return to != "timestamp with time zone"
+	}
+	return true
+}
+
+// dumpNoise matches the lines of a pg_dump that vary between otherwise
+// identical schemas: comments, session settings and psql meta-commands.
+var dumpNoise = regexp.MustCompile(`^(--|SET |SELECT pg_catalog\.set_config|\\)`)
+
+// normalizeDump strips noise and blank lines from a pg_dump so two dumps of
+// the same schema compare equal.
+func normalizeDump(dump string) string {
+	var lines []string
+	for _, line := range strings.Split(dump, "\n") {
+		if strings.TrimSpace(line) == "" || dumpNoise.MatchString(line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// migrationRun collects the outcome of a migration_check stage.
+type migrationRun struct {
+	e        *Engine
+	ps       *pipeline.PipelineState
+	opts     RunOpts
+	result   *RunResult
+	cfg      *config.PipelineConfig
+	redactor *secrets.Redactor
+	findings []pipeline.Finding
+	notes    []string
+}
+
+// check records a step's outcome in the result's check state.
+func (m *migrationRun) check(name string, passed bool) {
+	if passed {
+		m.result.FinalCheckState[name] = "pass"
+	} else {
+		m.result.FinalCheckState[name] = "fail"
+	}
+}
+
+// runMigrationCheck handles the migration_check stage type. It applies the
+// branch's migrations to a scratch database, rolls them back and applies
+// them again, and requires the schema after each apply to match. It then
+// applies the base ref's migrations to a second scratch database and
+// reports what the branch's schema drops or narrows.
+func (e *Engine) runMigrationCheck(ps *pipeline.PipelineState, stageCfg *config.Stage, opts RunOpts, result *RunResult, start time.Time, cfg *config.PipelineConfig) (*RunResult, error) {
+	if e.dbAdmin == nil {
+		return nil, fmt.Errorf("migration_check needs an admin database connection (set DATABASE_URL)")
+	}
+	dbCfg := cfg.Pipeline.Database
+	if dbCfg == nil || dbCfg.Migrate == "" || dbCfg.Rollback == "" {
+		return nil, fmt.Errorf("migration_check needs pipeline.database.migrate and pipeline.database.rollback")
+	}
+	m := &migrationRun{e: e, ps: ps, opts: opts, result: result, cfg: cfg, redactor: secrets.RedactorFor(cfg)}
+
+	headDump, ok := m.verifyBranch(dbCfg)
+	if ok {
+		baseRef := stageCfg.MigrationCheck.MigrationBaseRef(ps.BaseRef())
+		if baseDump, err := m.baseSchema(dbCfg, baseRef); err != nil {
+			e.logf("migration_check: %v", err)
+			m.notes = append(m.notes, fmt.Sprintf("could not build the %s schema, destructive changes not checked: %v", baseRef, err))
+		} else {
+			destructive := DestructiveChanges(ParseSchema(baseDump), ParseSchema(headDump))
+			allowed := stageCfg.MigrationCheck != nil && stageCfg.MigrationCheck.AllowDestructive
+			m.check("destructive", len(destructive) == 0 || allowed)
+			m.findings = append(m.findings, destructive...)
+			if len(destructive) > 0 {
+				m.notes = append(m.notes, fmt.Sprintf("%d destructive change(s) against %s", len(destructive), baseRef))
+			}
+		}
+	}
+
+	passed := true
+	for _, state := range result.FinalCheckState {
+		if state == "fail" {
+			passed = false
+		}
+	}
+	result.Findings = m.findings
+	result.TotalDuration = time.Since(start)
+	status := "passed"
+	if passed {
+		result.Outcome = "success"
+		result.ChecksFirstPass = true
+	} else {
+		result.Outcome = "fail"
+		status = "failed"
+	}
+	lines := []string{fmt.Sprintf("Migration check %s", status)}
+	for _, n := range m.notes {
+		lines = append(lines, "- "+n)
+	}
+	result.Summary = strings.Join(lines, "\n")
+
+	e.logf("migration check: %s", result.Outcome)
+	_ = e.db.LogPipelineEvent(ps.Namespace, opts.Issue, "migration_check", opts.Stage, ps.CurrentAttempt,
+		fmt.Sprintf("outcome=%s findings=%d", result.Outcome, len(m.findings)))
+	return result, nil
+}
+
+// verifyBranch runs migrate, rollback and migrate again in the pipeline
+// worktree against a scratch database. It returns the schema after the
+// first apply and whether every step succeeded.
+func (m *migrationRun) verifyBranch(dbCfg *config.DatabaseConfig) (string, bool) {
+	name := scratchDBName(dbCfg, m.opts.Issue, "head")
+	if err := dbprov.CreateScratch(m.e.dbAdmin, dbCfg, name); err != nil {
+		m.fail("migrate_up", fmt.Sprintf("create scratch database: %v", err))
+		return "", false
+	}
+	defer m.dropScratch(name)
+	vars, err := migrationVars(m.cfg, dbCfg, name)
+	if err != nil {
+		m.fail("migrate_up", fmt.Sprintf("resolve env: %v", err))
+		return "", false
+	}
+	dir := m.ps.Worktree
+
+	steps := []struct {
+		check, what, command string
+	}{
+		{"migrate_up", "apply", dbCfg.Migrate},
+		{"migrate_down", "roll back", dbCfg.Rollback},
+		{"migrate_reapply", "re-apply after rollback", dbCfg.Migrate},
+	}
+	var first string
+	for i, step := range steps {
+		m.e.logf("migration_check: %s (%s)", step.what, step.command)
+		if out, err := shellIn(m.cfg, m.boxName(), dir, vars, step.command, m.opts.Timeout); err != nil {
+			m.fail(step.check, fmt.Sprintf("migrations fail to %s: %v\n%s", step.what, err, m.redactor.Redact(tail(out, 20))))
+			return first, false
+		}
+		m.check(step.check, true)
+		if i == 0 {
+			if first, err = m.dump(name, "head"); err != nil {
+				m.fail("migrate_up", fmt.Sprintf("dump schema: %v", err))
+				return "", false
+			}
+		}
+	}
+
+	again, err := m.dump(name, "head-reapplied")
+	if err != nil {
+		m.fail("schema_drift", fmt.Sprintf("dump schema: %v", err))
+		return first, false
+	}
+	if normalizeDump(first) == normalizeDump(again) {
+		m.check("schema_drift", true)
+		return first, true
+	}
+	msg := "the schema after rollback and re-apply differs from the first apply; the rollback does not undo the migrations"
+	if diff := SchemaDiff(ParseSchema(first), ParseSchema(again)); len(diff) > 0 {
+		msg += ": " + strings.Join(diff, "; ")
+	}
+	m.fail("schema_drift", msg)
+	return first, false
+}
+
+// baseSchema builds the schema of ref's migrations: it checks ref out in a
+// temporary worktree, runs pipeline.setup and migrate there against a
+// scratch database, and dumps it.
+func (m *migrationRun) baseSchema(dbCfg *config.DatabaseConfig, ref string) (string, error) {
+	tmp, err := os.MkdirTemp("", "factory-migration-base-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "base")
+	if out, err := exec.Command("git", "-C", m.ps.Worktree, "worktree", "add", "--detach", dir, ref).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("check out %s: %s: %w", ref, strings.TrimSpace(string(out)), err)
+	}
+	defer func() {
+		_ = exec.Command("git", "-C", m.ps.Worktree, "worktree", "remove", "--force", dir).Run()
+	}()
+
+	name := scratchDBName(dbCfg, m.opts.Issue, "base")
+	if err := dbprov.CreateScratch(m.e.dbAdmin, dbCfg, name); err != nil {
+		return "", fmt.Errorf("create scratch database: %w", err)
+	}
+	defer m.dropScratch(name)
+	vars, err := migrationVars(m.cfg, dbCfg, name)
+	if err != nil {
+		return "", fmt.Errorf("resolve env: %w", err)
+	}
+
+	m.e.logf("migration_check: applying %s migrations", ref)
+	for _, cmdStr := range append(append([]string{}, m.cfg.Pipeline.Setup...), dbCfg.Migrate) {
+		if out, err := shellIn(m.cfg, m.boxName(), dir, vars, cmdStr, m.opts.Timeout); err != nil {
+			return "", fmt.Errorf("%s on %s: %s: %w", cmdStr, ref, m.redactor.Redact(tail(out, 5)), err)
+		}
+	}
+	return m.dump(name, "base")
+}
+
+// boxName names the containers migration commands run in with
+// pipeline.sandbox.
+func (m *migrationRun) boxName() string {
+	return fmt.Sprintf("factory-%d-%s-migrate", m.opts.Issue, m.opts.Stage)
+}
+
+// fail records a failed step and its finding.
+func (m *migrationRun) fail(check, msg string) {
+	m.check(check, false)
+	m.findings = append(m.findings, pipeline.Finding{Severity: "blocker", Rule: check, Message: msg})
+	m.notes = append(m.notes, strings.SplitN(msg, "\n", 2)[0])
+}
+
+// dump runs pg_dump --schema-only against a scratch database and saves the
+// dump with the attempt.
+func (m *migrationRun) dump(dbName, label string) (string, error) {
+	cmd := exec.Command("pg_dump", "--schema-only", "--no-owner", "--no-privileges", "--dbname", scratchURL(m.cfg.Pipeline.Database, dbName))
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("pg_dump: %s: %w", strings.TrimSpace(string(ee.Stderr)), err)
+		}
+		return "", fmt.Errorf("pg_dump: %w", err)
+	}
+	path := m.e.store.MigrationDumpPath(m.opts.Issue, m.opts.Stage, m.ps.CurrentAttempt, label)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+		_ = os.WriteFile(path, out, 0o644)
+	}
+	return string(out), nil
+}
+
+func (m *migrationRun) dropScratch(name string) {
+	if err := dbprov.DropScratch(m.e.dbAdmin, name); err != nil {
+		m.e.logf("warning: drop scratch database %s: %v", name, err)
+	}
+}
+
+// scratchDBName names a migration_check scratch database after the repo's
+// database and the issue, so concurrent pipelines do not collide.
+func scratchDBName(dbCfg *config.DatabaseConfig, issue int, label string) string {
+	root := strings.TrimSuffix(dbCfg.Name, fmt.Sprintf("_issue_%d", issue))
+	return fmt.Sprintf("%s_mc%d_%s", root, issue, label)
+}
+
+// scratchURL returns dbCfg's connection string pointed at dbName.
+func scratchURL(dbCfg *config.DatabaseConfig, dbName string) string {
+	scratch := *dbCfg
+	scratch.Name = dbName
+	return scratch.URL()
+}
+
+// migrationVars are the variables migrate and rollback commands run with:
+// the pipeline env, with DATABASE_URL pointed at dbName.
+func migrationVars(cfg *config.PipelineConfig, dbCfg *config.DatabaseConfig, dbName string) ([]string, error) {
+	scratch := *dbCfg
+	scratch.Name = dbName
+	scratchCfg := *cfg
+	scratchCfg.Pipeline.Database = &scratch
+	return pipelineVars(&scratchCfg)
+}
+
+// pipelineVars returns cfg's pipeline env (env_file, pipeline.env with
+// secrets resolved, and DATABASE_URL) as NAME=value entries.
+func pipelineVars(cfg *config.PipelineConfig) ([]string, error) {
+	resolved, err := secrets.Resolve(cfg, secrets.DefaultStore())
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for _, k := range sortedKeys(resolved.Vars) {
+		env = append(env, fmt.Sprintf("%s=%s", k, resolved.Vars[k]))
+	}
+	return env, nil
+}
+
+// runShell runs cmdStr with sh in dir and returns its combined output. It
+// is shared by the stages that run project commands (migration_check,
+// browser_qa, best-of-N setup). On timeout the whole process group is
+// stopped, so children such as npm or go run cannot hold the output pipe open.
+func runShell(dir string, env []string, cmdStr string, timeout time.Duration) (string, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", cmdStr)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 2 * time.Second // after SIGTERM, wait 2s then SIGKILL
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// tail returns the last n lines of s.
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,180 @@
+package stage
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+)
+
+const baseDump = `--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE TABLE public.users (
+    id bigint NOT NULL,
+    email character varying(255) NOT NULL,
+    "order" integer DEFAULT 0,
+    bio text,
+    balance numeric(10,2),
+    CONSTRAINT users_id_check CHECK ((id > 0))
+);
+
+CREATE TABLE public.sessions (
+    id bigint NOT NULL,
+    token text
+);
+`
+
+func TestParseSchema(t *testing.T) {
+	got := ParseSchema(baseDump)
+	want := Schema{
+		"public.users": {
+			"id":      "bigint",
+			"email":   "character varying(255)",
+			"order":   "integer",
+			"bio":     "text",
+			"balance": "numeric(10,2)",
+		},
+		"public.sessions": {
+			"id":    "bigint",
+			"token": "text",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDestructiveChanges(t *testing.T) {
+	base := ParseSchema(baseDump)
+	head := Schema{
+		"public.users": {
+			"id":      "integer",                // narrowed
+			"email":   "character varying(100)", // narrowed
+			"order":   "bigint",                 // widened
+			"balance": "numeric(12,2)",          // widened
+			"name":    "text",                   // added
+		},
+	}
+	findings := DestructiveChanges(base, head)
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule+" "+f.Message)
+	}
+	want := []string{
+		"dropped_table table public.sessions is dropped",
+		"dropped_column column public.users.bio (text) is dropped",
+		"narrowed_type column public.users.email changes type from character varying(255) to character varying(100)",
+		"narrowed_type column public.users.id changes type from bigint to integer",
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("got %q\nwant %q", rules, want)
+	}
+}
+
+func TestTypeLosesData(t *testing.T) {
+	tests := []struct {
+		from, to string
+		lossy    bool
+	}{
+		{"integer", "bigint", false},
+		{"bigint", "smallint", true},
+		{"integer", "numeric", false},
+		{"integer", "numeric(5,0)", true},
+		{"integer", "text", true},
+		{"real", "double precision", false},
+		{"double precision", "real", true},
+		{"character varying(10)", "character varying(20)", false},
+		{"character varying(20)", "character varying(10)", true},
+		{"character varying(20)", "text", false},
+		{"text", "character varying(255)", true},
+		{"character varying", "character varying(255)", true},
+		{"character(5)", "character(10)", false},
+		{"text", "character(10)", true},
+		{"numeric(10,2)", "numeric(12,2)", false},
+		{"numeric(10,2)", "numeric(10,1)", true},
+		{"numeric(10,2)", "numeric(8,2)", true},
+		{"numeric", "numeric(10,2)", true},
+		{"numeric(10,2)", "numeric", false},
+		{"timestamp without time zone", "timestamp with time zone", false},
+		{"timestamp with time zone", "date", true},
+		{"jsonb", "jsonb", false},
+		{"jsonb", "json", true},
+	}
+	for _, tt := range tests {
+		if got := typeLosesData(tt.from, tt.to); got != tt.lossy {
+			t.Errorf("typeLosesData(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.lossy)
+		}
+	}
+}
+
+func TestSchemaDiff(t *testing.T) {
+	a := Schema{"t": {"a": "integer", "b": "text"}, "gone": {"x": "integer"}}
+	b := Schema{"t": {"a": "bigint", "c": "text"}, "new": {"y": "integer"}}
+	got := SchemaDiff(a, b)
+	want := []string{
+		"table gone missing",
+		"table new added",
+		"column t.a is bigint instead of integer",
+		"column t.b missing",
+		"column t.c added",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestNormalizeDump(t *testing.T) {
+	a := baseDump
+	b := strings.Replace(baseDump, "-- PostgreSQL database dump", "-- Dumped by pg_dump version 17.2", 1) +
+		"\\restrict abc123\n\n"
+	if normalizeDump(a) != normalizeDump(b) {
+		t.Error("dumps differing only in comments and meta-commands should normalize equal")
+	}
+	c := strings.Replace(baseDump, "bio text", "bio character varying(50)", 1)
+	if normalizeDump(a) == normalizeDump(c) {
+		t.Error("dumps with different columns should not normalize equal")
+	}
+}
+
+func TestScratchDBName(t *testing.T) {
+	shared := &config.DatabaseConfig{Name: "shop"}
+	if got := scratchDBName(shared, 42, "head"); got != "shop_mc42_head" {
+		t.Errorf("shared: got %q", got)
+	}
+	perPipeline := &config.DatabaseConfig{Name: "shop", Isolation: config.DatabasePerPipeline}
+	cfg := (&config.PipelineConfig{Pipeline: config.Pipeline{Database: perPipeline}}).ForIssue(42)
+	if got := scratchDBName(cfg.Pipeline.Database, 42, "base"); got != "shop_mc42_base" {
+		t.Errorf("per_pipeline: got %q", got)
+	}
+}
+
+func TestRunShell_TimeoutStopsChildren(t *testing.T) {
+	start := time.Now()
+	// The backgrounded sleep holds the output pipe after sh is killed.
+	_, err := runShell(t.TempDir(), nil, "sleep 30 & sleep 30", 200*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("runShell took %v; children kept it waiting", d)
+	}
+}
+
+func TestShellIn_HostAddsVars(t *testing.T) {
+	cfg := &config.PipelineConfig{}
+	out, err := shellIn(cfg, "factory-test", t.TempDir(), []string{"FACTORY_TEST_VAR=from-pipeline"}, `echo "$FACTORY_TEST_VAR $HOME"`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "from-pipeline " + os.Getenv("HOME"); strings.TrimSpace(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,399 @@
+package stage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	appctx "github.com/lucasnoah/taintfactory/internal/context"
+	"github.com/lucasnoah/taintfactory/internal/github"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/prompt"
+	"github.com/lucasnoah/taintfactory/internal/secrets"
+)
+
+// PanelReport is what a panel reviewer writes to its report file.
+type PanelReport struct {
+	Verdict  string             `json:"verdict"` // "approve" or "request_changes"
+	Summary  string             `json:"summary"`
+	Findings []pipeline.Finding `json:"findings,omitempty"`
+}
+
+// Blocks reports whether the report objects to the change: it requests
+// changes or has a blocker or critical finding.
+func (r *PanelReport) Blocks() bool {
+	if r.Verdict != "approve" {
+		return true
+	}
+	for _, f := range r.Findings {
+		if blockingSeverity(f.Severity) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockingSeverity(s string) bool {
+	s = strings.ToLower(s)
+	return s == "blocker" || s == "critical"
+}
+
+// PanelVote is one reviewer's contribution to a panel verdict.
+type PanelVote struct {
+	Reviewer string
+	Weight   float64
+	Report   *PanelReport // nil when the reviewer wrote no valid report
+}
+
+// PanelVerdict is the aggregated result of a panel.
+type PanelVerdict struct {
+	Rule     string
+	Passed   bool
+	Approve  int
+	Object   int
+	Abstain  int
+	Notes    []string // one line per reviewer: its vote and summary
+	Findings []pipeline.Finding
+}
+
+// AggregatePanel combines the votes of a panel by rule:
+//
+//   - any_blocker: the panel fails if any reviewer objects.
+//   - majority: the panel passes only if more than half of the reporting
+//     reviewers approve.
+//   - weighted: the panel fails if the objecting reviewers hold at least
+//     threshold of the reporting reviewers' weight.
+//
+// Reviewers without a report abstain; a panel where everyone abstains fails.
+// Findings of all reviewers are merged, blocking ones first.
+func AggregatePanel(rule string, threshold float64, votes []PanelVote) PanelVerdict {
+	v := PanelVerdict{Rule: rule}
+	var totalWeight, objectWeight float64
+	seen := make(map[string]bool)
+	for _, vote := range votes {
+		if vote.Report == nil {
+			v.Abstain++
+			v.Notes = append(v.Notes, vote.Reviewer+": no report")
+			continue
+		}
+		totalWeight += vote.Weight
+		note := vote.Reviewer + ": approves"
+		if vote.Report.Blocks() {
+			v.Object++
+			objectWeight += vote.Weight
+			note = vote.Reviewer + ": requests changes"
+		} else {
+			v.Approve++
+		}
+		if sum := strings.Join(strings.Fields(vote.Report.Summary), " "); sum != "" {
+			note += " — " + sum
+		}
+		v.Notes = append(v.Notes, note)
+		for _, f := range vote.Report.Findings {
+			key := fmt.Sprintf("%s:%d:%s:%s", f.File, f.Line, f.Rule, strings.ToLower(strings.TrimSpace(f.Message)))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			f.Reviewer = vote.Reviewer
+			v.Findings = append(v.Findings, f)
+		}
+	}
+	sort.SliceStable(v.Findings, func(i, j int) bool {
+		return blockingSeverity(v.Findings[i].Severity) && !blockingSeverity(v.Findings[j].Severity)
+	})
+
+	reporting := v.Approve + v.Object
+	switch {
+	case reporting == 0:
+		v.Passed = false
+	case rule == config.PanelMajority:
+		v.Passed = v.Approve*2 > reporting
+	case rule == config.PanelWeighted:
+		v.Passed = totalWeight == 0 || objectWeight/totalWeight < threshold
+	default:
+		v.Passed = v.Object == 0
+	}
+	return v
+}
+
+// Summary describes the verdict for the stage outcome: a headline and one
+// line per reviewer. The findings are stored separately on the outcome.
+func (v PanelVerdict) Summary() string {
+	status := "approved"
+	if !v.Passed {
+		status = "changes requested"
+	}
+	lines := []string{fmt.Sprintf("Review panel (%s): %s; %d approved, %d objected, %d did not report",
+		v.Rule, status, v.Approve, v.Object, v.Abstain)}
+	for _, n := range v.Notes {
+		lines = append(lines, "- "+n)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reviewerResult is what one panel session produced.
+type reviewerResult struct {
+	vote        PanelVote
+	rateLimited bool
+}
+
+// runPanel handles the panel stage type: each reviewer gets its own session
+// in the shared worktree, all run at once, and their reports are aggregated.
+// Reviewers must not change the worktree. Nothing stops them at runtime —
+// the prompt asks them not to, and anything that differs from the state
+// before the panel is reverted before the verdict.
+func (e *Engine) runPanel(ps *pipeline.PipelineState, stageCfg *config.Stage, opts RunOpts, result *RunResult, start time.Time, cfg *config.PipelineConfig) (*RunResult, error) {
+	if stageCfg.Panel == nil || len(stageCfg.Panel.Reviewers) == 0 {
+		return nil, fmt.Errorf("panel stage %q has no reviewers", opts.Stage)
+	}
+	before := snapshotWorktree(ps.Worktree)
+
+	e.logf("starting review panel: %d reviewers", len(stageCfg.Panel.Reviewers))
+	results := make([]reviewerResult, len(stageCfg.Panel.Reviewers))
+	var wg sync.WaitGroup
+	for i, r := range stageCfg.Panel.Reviewers {
+		wg.Add(1)
+		go func(i int, r config.PanelReviewer) {
+			defer wg.Done()
+			results[i] = e.runReviewer(ps, stageCfg, r, opts, cfg)
+		}(i, r)
+	}
+	wg.Wait()
+	result.AgentDuration = time.Since(start)
+
+	if restored := restoreWorktree(ps.Worktree, before); restored {
+		e.logf("reviewers changed the worktree — reverted to %s", before.head)
+		_ = e.db.LogPipelineEvent(ps.Namespace, opts.Issue, "panel_worktree_reverted", opts.Stage, ps.CurrentAttempt, "head="+before.head)
+	}
+
+	votes := make([]PanelVote, len(results))
+	for i, r := range results {
+		if r.rateLimited {
+			e.logf("rate limit detected in review panel — pausing stage")
+			result.Outcome = "rate_limited"
+			result.TotalDuration = time.Since(start)
+			return result, nil
+		}
+		votes[i] = r.vote
+	}
+
+	verdict := AggregatePanel(stageCfg.Panel.PanelRule(), stageCfg.Panel.PanelThreshold(), votes)
+	result.Summary = verdict.Summary()
+	result.Findings = verdict.Findings
+	result.TotalDuration = time.Since(start)
+	if verdict.Passed {
+		result.Outcome = "success"
+		result.ChecksFirstPass = true
+	} else {
+		result.Outcome = "fail"
+	}
+	e.logf("panel verdict (%s): %s", verdict.Rule, result.Outcome)
+	_ = e.db.LogPipelineEvent(ps.Namespace, opts.Issue, "panel_verdict", opts.Stage, ps.CurrentAttempt,
+		fmt.Sprintf("rule=%s outcome=%s approve=%d object=%d abstain=%d findings=%d",
+			verdict.Rule, result.Outcome, verdict.Approve, verdict.Object, verdict.Abstain, len(verdict.Findings)))
+	return result, nil
+}
+
+// runReviewer runs one panel session and reads its report. A reviewer whose
+// session fails or who writes no valid report abstains.
+func (e *Engine) runReviewer(ps *pipeline.PipelineState, stageCfg *config.Stage, r config.PanelReviewer, opts RunOpts, cfg *config.PipelineConfig) reviewerResult {
+	vote := PanelVote{Reviewer: r.Name, Weight: r.ReviewerWeight()}
+
+	reviewerCfg := *stageCfg
+	reviewerCfg.PromptVariants = nil
+	reviewerCfg.PromptTemplate = r.PromptTemplate
+	if reviewerCfg.PromptTemplate == "" {
+		reviewerCfg.PromptTemplate = stageCfg.PromptTemplate
+	}
+	if reviewerCfg.PromptTemplate == "" {
+		reviewerCfg.PromptTemplate = "panel-review.md"
+	}
+	if r.Model != "" {
+		reviewerCfg.Model = r.Model
+	}
+
+	reportPath := e.store.PanelReportPath(opts.Issue, opts.Stage, ps.CurrentAttempt, r.Name)
+	if err := os.MkdirAll(filepath.Dir(reportPath), 0o755); err != nil {
+		e.logf("reviewer %s: %v", r.Name, err)
+		return reviewerResult{vote: vote}
+	}
+	_ = os.Remove(reportPath) // a stale report must not count as this attempt's
+
+	rendered, err := e.renderReviewerPrompt(ps, opts, &reviewerCfg, r, reportPath)
+	if err != nil {
+		e.logf("reviewer %s: build prompt: %v", r.Name, err)
+		return reviewerResult{vote: vote}
+	}
+	promptPath := strings.TrimSuffix(reportPath, ".json") + ".prompt.md"
+	_ = os.WriteFile(promptPath, []byte(secrets.RedactorFor(cfg).Redact(rendered)), 0o644)
+
+	sessionName := fmt.Sprintf("%d-%s-%d-%s", opts.Issue, opts.Stage, ps.CurrentAttempt, r.Name)
+	if err := e.createAndRunSession(sessionName, ps, opts, &reviewerCfg, rendered, cfg); err != nil {
+		if err == errRateLimited {
+			return reviewerResult{vote: vote, rateLimited: true}
+		}
+		e.logf("reviewer %s: %v", r.Name, err)
+		e.cleanupSession(sessionName)
+		return reviewerResult{vote: vote}
+	}
+	e.cleanupSession(sessionName)
+
+	var report PanelReport
+	if err := pipeline.ReadJSON(reportPath, &report); err != nil {
+		e.logf("reviewer %s wrote no valid report: %v", r.Name, err)
+		return reviewerResult{vote: vote}
+	}
+	vote.Report = &report
+	return reviewerResult{vote: vote}
+}
+
+// renderReviewerPrompt renders a reviewer's prompt with the panel variables
+// and the instructions for writing its report.
+func (e *Engine) renderReviewerPrompt(ps *pipeline.PipelineState, opts RunOpts, reviewerCfg *config.Stage, r config.PanelReviewer, reportPath string) (string, error) {
+	var issueBody string
+	pipelineDir := fmt.Sprintf("%s/%d", e.store.BaseDir(), opts.Issue)
+	if issue, err := github.LoadCachedIssue(pipelineDir); err == nil {
+		issueBody = issue.Body
+	}
+
+	buildResult, err := e.builder.Build(ps, appctx.BuildOpts{
+		Issue:        opts.Issue,
+		Stage:        opts.Stage,
+		StageCfg:     reviewerCfg,
+		IssueBody:    issueBody,
+		PipelineVars: e.cfgFor(opts).Pipeline.Vars,
+	})
+	if err != nil {
+		return "", err
+	}
+	buildResult.Vars["reviewer"] = r.Name
+	buildResult.Vars["review_focus"] = r.Focus
+	buildResult.Vars["report_path"] = reportPath
+
+	tmplContent, err := prompt.LoadTemplate(buildResult.Template, ps.Worktree)
+	if err != nil {
+		return "", fmt.Errorf("load template %q: %w", buildResult.Template, err)
+	}
+	rendered, err := prompt.RenderWith(tmplContent, buildResult.Vars, prompt.RenderOpts{
+		Data:     buildResult.Data,
+		Partials: prompt.TemplatePartials(buildResult.Template, ps.Worktree),
+	})
+	if err != nil {
+		return "", err
+	}
+	return rendered + appctx.BudgetNote(buildResult.Budget, buildResult.Truncations) + panelReportNote(reportPath), nil
+}
+
+// panelReportNote tells a reviewer how to report, whatever its template says.
+func panelReportNote(path string) string {
+	return fmt.Sprintf(`
+
+## Reporting (required)
+You are one reviewer on a panel; other reviewers are reading this worktree at the same time. Do not edit, commit, or stash anything in it.
+
+When done, write your report as JSON to %s (outside the worktree):
+
+`+"```json"+`
+{"verdict": "approve", "summary": "one paragraph", "findings": [{"file": "path/to/file.go", "line": 42, "severity": "blocker", "message": "what is wrong and why", "rule": "optional-short-id"}]}
+`+"```"+`
+
+Use "verdict": "request_changes" if anything must be fixed before merging. Severity is one of blocker, critical, major, minor; a blocker or critical finding counts as requesting changes.
+`, path)
+}
+
+// gitHead returns the commit checked out in dir, or "" outside a git repo.
+func gitHead(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// worktreeSnapshot is the state of a worktree before a panel runs, including
+// uncommitted work left by earlier stages.
+type worktreeSnapshot struct {
+	head  string
+	tree  string // every tracked and untracked file's content, "" when it could not be recorded
+	index string // the index's tree, so staged changes stay staged; "" with unmerged entries
+}
+
+// snapshotWorktree records dir's HEAD, content and index without touching
+// the worktree.
+func snapshotWorktree(dir string) worktreeSnapshot {
+	snap := worktreeSnapshot{head: gitHead(dir), tree: worktreeTree(dir)}
+	if out, err := exec.Command("git", "-C", dir, "write-tree").Output(); err == nil {
+		snap.index = strings.TrimSpace(string(out))
+	}
+	return snap
+}
+
+// worktreeTree writes the content of every file in dir that is tracked or
+// untracked but not ignored to a tree object and returns its hash. It stages
+// into a copy of the index so the real one is untouched and unchanged files
+// keep their cached stat data. Returns "" on error.
+func worktreeTree(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--git-path", "index").Output()
+	if err != nil {
+		return ""
+	}
+	index := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(index) {
+		index = filepath.Join(dir, index)
+	}
+	tmp, err := os.CreateTemp("", "panel-index-*")
+	if err != nil {
+		return ""
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if data, err := os.ReadFile(index); err == nil {
+		if os.WriteFile(tmp.Name(), data, 0o600) != nil {
+			return ""
+		}
+	}
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+tmp.Name())
+	add := exec.Command("git", "-C", dir, "add", "-A")
+	add.Env = env
+	if add.Run() != nil {
+		return ""
+	}
+	write := exec.Command("git", "-C", dir, "write-tree")
+	write.Env = env
+	out, err = write.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// restoreWorktree puts dir back to snap when HEAD or any file's content
+// changed: it resets to the snapshot's HEAD, removes untracked files, checks
+// out the recorded content, and restores the index. A worktree whose content
+// matches the snapshot is left alone, even when it was dirty beforehand. It
+// reports whether anything was reset.
+func restoreWorktree(dir string, snap worktreeSnapshot) bool {
+	if snap.head == "" || snap.tree == "" {
+		return false
+	}
+	if gitHead(dir) == snap.head && worktreeTree(dir) == snap.tree {
+		return false
+	}
+	_ = exec.Command("git", "-C", dir, "reset", "-q", "--hard", snap.head).Run()
+	_ = exec.Command("git", "-C", dir, "clean", "-fdq").Run()
+	_ = exec.Command("git", "-C", dir, "read-tree", "-u", "--reset", snap.tree).Run()
+	index := snap.index
+	if index == "" {
+		index = snap.head
+	}
+	_ = exec.Command("git", "-C", dir, "read-tree", index).Run()
+	return true
+}
@@ -0,0 +1,211 @@
+package stage
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/pipeline"
+)
+
+func approve(findings ...pipeline.Finding) *PanelReport {
+	return &PanelReport{Verdict: "approve", Summary: "looks fine", Findings: findings}
+}
+
+func object(findings ...pipeline.Finding) *PanelReport {
+	return &PanelReport{Verdict: "request_changes", Summary: "needs work", Findings: findings}
+}
+
+func TestPanelReport_Blocks(t *testing.T) {
+	if approve(pipeline.Finding{Severity: "minor"}).Blocks() {
+		t.Error("approval with a minor finding blocks")
+	}
+	if !approve(pipeline.Finding{Severity: "Critical"}).Blocks() {
+		t.Error("approval with a critical finding does not block")
+	}
+	if !object().Blocks() {
+		t.Error("request_changes does not block")
+	}
+	if !(&PanelReport{}).Blocks() {
+		t.Error("report without a verdict does not block")
+	}
+}
+
+func TestAggregatePanel(t *testing.T) {
+	votes := []PanelVote{
+		{Reviewer: "security", Weight: 3, Report: object(pipeline.Finding{File: "auth.go", Line: 10, Severity: "blocker", Message: "token logged"})},
+		{Reviewer: "performance", Weight: 1, Report: approve()},
+		{Reviewer: "correctness", Weight: 1, Report: approve()},
+		{Reviewer: "style", Weight: 1},
+	}
+	cases := []struct {
+		rule      string
+		threshold float64
+		want      bool
+	}{
+		{config.PanelAnyBlocker, 0, false},
+		{config.PanelMajority, 0, true},
+		{config.PanelWeighted, 0.5, false}, // 3 of 5 weight objects
+		{config.PanelWeighted, 0.7, true},
+	}
+	for _, tc := range cases {
+		v := AggregatePanel(tc.rule, tc.threshold, votes)
+		if v.Passed != tc.want {
+			t.Errorf("%s (threshold %.1f): passed = %v, want %v", tc.rule, tc.threshold, v.Passed, tc.want)
+		}
+		if v.Approve != 2 || v.Object != 1 || v.Abstain != 1 {
+			t.Errorf("%s: counts = %d/%d/%d, want 2/1/1", tc.rule, v.Approve, v.Object, v.Abstain)
+		}
+	}
+}
+
+func TestAggregatePanel_TieFailsMajority(t *testing.T) {
+	v := AggregatePanel(config.PanelMajority, 0, []PanelVote{
+		{Reviewer: "a", Weight: 1, Report: approve()},
+		{Reviewer: "b", Weight: 1, Report: object()},
+	})
+	if v.Passed {
+		t.Error("majority passed on a tie")
+	}
+}
+
+func TestAggregatePanel_AllAbstainFails(t *testing.T) {
+	v := AggregatePanel(config.PanelAnyBlocker, 0, []PanelVote{{Reviewer: "a", Weight: 1}})
+	if v.Passed {
+		t.Error("panel with no reports passed")
+	}
+}
+
+func TestAggregatePanel_MergesFindings(t *testing.T) {
+	dup := pipeline.Finding{File: "a.go", Line: 3, Severity: "minor", Message: "Unused variable"}
+	v := AggregatePanel(config.PanelAnyBlocker, 0, []PanelVote{
+		{Reviewer: "one", Weight: 1, Report: approve(dup)},
+		{Reviewer: "two", Weight: 1, Report: object(
+			pipeline.Finding{File: "a.go", Line: 3, Severity: "minor", Message: "unused variable "},
+			pipeline.Finding{File: "b.go", Line: 9, Severity: "critical", Message: "nil dereference"},
+		)},
+	})
+	if len(v.Findings) != 2 {
+		t.Fatalf("findings = %+v, want 2 after dedup", v.Findings)
+	}
+	if v.Findings[0].File != "b.go" || v.Findings[0].Reviewer != "two" {
+		t.Errorf("first finding = %+v, want the critical one from reviewer two", v.Findings[0])
+	}
+	if v.Findings[1].Reviewer != "one" {
+		t.Errorf("duplicate attributed to %q, want first reporter", v.Findings[1].Reviewer)
+	}
+
+	sum := v.Summary()
+	for _, want := range []string{"changes requested", "- one: approves — looks fine", "- two: requests changes — needs work"} {
+		if !strings.Contains(sum, want) {
+			t.Errorf("summary missing %q:\n%s", want, sum)
+		}
+	}
+}
+
+func TestRestoreWorktree_KeepsEarlierChanges(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		b, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(b)
+	}
+	git("init", "-q")
+	write("a.txt", "one\n")
+	git("add", ".")
+	git("commit", "-qm", "base")
+	// Uncommitted work from an earlier stage.
+	write("a.txt", "two\n")
+	write("notes.txt", "keep\n")
+
+	before := snapshotWorktree(dir)
+	if restoreWorktree(dir, before) {
+		t.Fatal("an unchanged dirty worktree should not be reset")
+	}
+
+	// A reviewer edits, adds a file and commits.
+	write("a.txt", "reviewer\n")
+	write("stray.txt", "x\n")
+	git("commit", "-qam", "reviewer")
+	if !restoreWorktree(dir, before) {
+		t.Fatal("expected the reviewer's changes to be reverted")
+	}
+	if got := gitHead(dir); got != before.head {
+		t.Errorf("HEAD = %s, want %s", got, before.head)
+	}
+	if read("a.txt") != "two\n" || read("notes.txt") != "keep\n" {
+		t.Errorf("earlier changes lost: a.txt=%q notes.txt=%q", read("a.txt"), read("notes.txt"))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stray.txt")); !os.IsNotExist(err) {
+		t.Error("the reviewer's new file should be removed")
+	}
+}
+
+func TestRestoreWorktree_EditsToDirtyFiles(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		b, _ := os.ReadFile(filepath.Join(dir, name))
+		return string(b)
+	}
+	git("init", "-q")
+	write("a.txt", "one\n")
+	write("b.txt", "one\n")
+	git("add", ".")
+	git("commit", "-qm", "base")
+	// Earlier stages left a.txt modified, b.txt staged and notes.txt untracked.
+	write("a.txt", "two\n")
+	write("b.txt", "staged\n")
+	git("add", "b.txt")
+	write("notes.txt", "keep\n")
+
+	before := snapshotWorktree(dir)
+	status := git("status", "--porcelain")
+
+	// The reviewer's edits leave git status exactly as it was.
+	write("a.txt", "reviewer\n")
+	write("notes.txt", "reviewer\n")
+	if git("status", "--porcelain") != status {
+		t.Fatal("test setup: status should be unchanged")
+	}
+	if !restoreWorktree(dir, before) {
+		t.Fatal("expected edits to already-dirty files to be reverted")
+	}
+	if read("a.txt") != "two\n" || read("b.txt") != "staged\n" || read("notes.txt") != "keep\n" {
+		t.Errorf("content not restored: a.txt=%q b.txt=%q notes.txt=%q", read("a.txt"), read("b.txt"), read("notes.txt"))
+	}
+	if got := git("status", "--porcelain"); got != status {
+		t.Errorf("status = %q, want %q", got, status)
+	}
+}
@@ -0,0 +1,78 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/cgroup"
+	"github.com/lucasnoah/taintfactory/internal/checks"
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/sandbox"
+)
+
+// checkerFor returns the check runner for cfg: e.checker, or one that runs
+// each check in a container named after name when pipeline.sandbox is set.
+func (e *Engine) checkerFor(cfg *config.PipelineConfig, name string) *checks.Runner {
+	if !cfg.Pipeline.Sandbox.Enabled() {
+		return e.checker
+	}
+	return e.checker.WithCommandRunner(&sandbox.Runner{Config: cfg.Pipeline.Sandbox, Name: name})
+}
+
+// sandboxFor returns the container a session in workdir runs in, or nil when
+// pipeline.sandbox is not set. The session's limits replace the container's.
+func sandboxFor(cfg *config.PipelineConfig, name, workdir string, limits cgroup.Limits) *sandbox.Spec {
+	if !cfg.Pipeline.Sandbox.Enabled() {
+		return nil
+	}
+	return &sandbox.Spec{
+		Config:      sandbox.WithLimits(cfg.Pipeline.Sandbox, limits),
+		Name:        "factory-" + name,
+		Workdir:     workdir,
+		GitDir:      sandbox.GitCommonDir(workdir),
+		WorktreeDir: sandbox.WorktreeGitDir(workdir),
+		Interactive: true,
+	}
+}
+
+// warnUnenforced logs that limits configured for what will be ignored
+// because this host cannot enforce them.
+func (e *Engine) warnUnenforced(limits cgroup.Limits, what string) {
+	if limits.IsZero() {
+		return
+	}
+	if _, err := cgroup.Parent(); err != nil {
+		e.logf("warning: limits of %s not enforced: %v", what, err)
+	}
+}
+
+// shellIn runs cmdStr like runShell, or in a fresh container named after name
+// when pipeline.sandbox is set, so project commands the agent can edit
+// (setup scripts, migrations) do not run on the host. vars are the pipeline's
+// own NAME=value entries: on the host they extend the process environment, in
+// a container they are all it gets.
+func shellIn(cfg *config.PipelineConfig, name, dir string, vars []string, cmdStr string, timeout time.Duration) (string, error) {
+	if !cfg.Pipeline.Sandbox.Enabled() {
+		return runShell(dir, append(os.Environ(), vars...), cmdStr, timeout)
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	r := &sandbox.Runner{Config: cfg.Pipeline.Sandbox, Name: name, Env: vars}
+	stdout, stderr, exitCode, err := r.Run(ctx, dir, cmdStr)
+	out := stdout + stderr
+	switch {
+	case err != nil:
+		return out, err
+	case ctx.Err() != nil:
+		return out, ctx.Err()
+	case exitCode != 0:
+		return out, fmt.Errorf("exit status %d", exitCode)
+	}
+	return out, nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/lucasnoah/taintfactory/internal/config"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,8 +17,14 @@ type TriageConfig struct {
 
 // TriageMeta holds repository-level metadata.
 type TriageMeta struct {
-	Name string `yaml:"name"`
-	Repo string `yaml:"repo"` // e.g. "owner/repo" — used as the state directory slug
+	Name        string `yaml:"name"`
+	Repo        string `yaml:"repo"`         // e.g. "owner/repo" — used as the state directory slug
+	MaxParallel int    `yaml:"max_parallel"` // issues advanced concurrently per check-in; default 1 (serial)
+
+	// PipelineConfig is the pipeline.yaml used for issues that triage hands off
+	// to the implementation queue (relative to the repo root). Empty = the
+	// registered repo's config, then {repoRoot}/pipeline.yaml.
+	PipelineConfig string `yaml:"pipeline_config"`
 }
 
 // TriageStage defines one stage in the triage pipeline.
@@ -29,24 +36,51 @@ type TriageStage struct {
 	Mode           string            `yaml:"mode"`   // execution mode: "" (default interactive) or "print" (synchronous, no tmux session)
 	Label          string            `yaml:"label"`  // GitHub label to add when outcome is "yes"; empty = no label
 	Outcomes       map[string]string `yaml:"outcomes"` // e.g. {"stale": "done", "clean": "already_implemented"}
+	MaxParallel    int               `yaml:"max_parallel"` // cap on issues running this print-mode stage at once; 0 = only the global limit
+	Enqueue        bool              `yaml:"enqueue"`      // add the issue to the implementation queue when the outcome matches EnqueueOn
+	EnqueueOn      string            `yaml:"enqueue_on"`   // outcome that triggers the handoff; default "yes"
+	Priority       int               `yaml:"priority"`     // queue priority for handed-off issues; higher runs first
+}
+
+// EnqueueOutcome returns the outcome that hands the issue off to the queue.
+func (s *TriageStage) EnqueueOutcome() string {
+	if s.EnqueueOn == "" {
+		return "yes"
+	}
+	return s.EnqueueOn
 }
 
 // Load reads and parses a triage config from the given YAML file path.
+// Unknown keys are rejected with their line and column.
 func Load(path string) (*TriageConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 
-	var cfg TriageConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("parsing config YAML: %w", err)
 	}
+	var cfg TriageConfig
+	if len(doc.Content) > 0 {
+		if err := config.CheckKnownKeys(path, doc.Content[0], cfg); err != nil {
+			return nil, err
+		}
+		if err := doc.Content[0].Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("parsing config YAML: %w", err)
+		}
+	}
 
 	applyDefaults(&cfg)
 	return &cfg, nil
 }
 
+// Schema returns the JSON Schema for triage.yaml.
+func Schema() map[string]interface{} {
+	return config.JSONSchema(TriageConfig{}, "factory triage.yaml")
+}
+
 // LoadDefault loads triage.yaml from the given directory (typically the repo root).
 func LoadDefault(repoRoot string) (*TriageConfig, error) {
 	path := filepath.Join(repoRoot, "triage.yaml")
@@ -79,8 +113,21 @@ func (cfg *TriageConfig) NextStageID(currentID string) string {
 	return ""
 }
 
-// applyDefaults sets default timeout of "15m" for stages that don't specify one.
+// Parallelism returns the number of issues that may be advanced concurrently.
+// Configs built in code without applyDefaults are treated as serial.
+func (cfg *TriageConfig) Parallelism() int {
+	if cfg.Triage.MaxParallel <= 0 {
+		return 1
+	}
+	return cfg.Triage.MaxParallel
+}
+
+// applyDefaults sets default timeout of "15m" for stages that don't specify one,
+// and runs triage serially (max_parallel 1) unless configured otherwise.
 func applyDefaults(cfg *TriageConfig) {
+	if cfg.Triage.MaxParallel <= 0 {
+		cfg.Triage.MaxParallel = 1
+	}
 	for i := range cfg.Stages {
 		if cfg.Stages[i].Timeout == "" {
 			cfg.Stages[i].Timeout = "15m"
@@ -3,6 +3,7 @@ package triage
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -102,6 +103,38 @@ stages:
 	}
 }
 
+func TestLoad_MaxParallel(t *testing.T) {
+	path := writeTempYAML(t, `
+triage:
+  name: "Test"
+  repo: "owner/test"
+  max_parallel: 4
+
+stages:
+  - id: classify
+    mode: print
+    max_parallel: 2
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if cfg.Parallelism() != 4 {
+		t.Errorf("Parallelism() = %d, want 4", cfg.Parallelism())
+	}
+	if cfg.Stages[0].MaxParallel != 2 {
+		t.Errorf("stages[0].max_parallel = %d, want 2", cfg.Stages[0].MaxParallel)
+	}
+
+	serial, err := Load(writeTempYAML(t, "triage:\n  repo: owner/test\nstages:\n  - id: s\n"))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if serial.Triage.MaxParallel != 1 {
+		t.Errorf("default max_parallel = %d, want 1", serial.Triage.MaxParallel)
+	}
+}
+
 func TestLoad_MissingFile(t *testing.T) {
 	_, err := Load("/nonexistent/path/triage.yaml")
 	if err == nil {
@@ -152,3 +185,28 @@ stages:
 		t.Errorf("PromptTemplate = %q, want %q", cfg.Stages[0].PromptTemplate, "triage/custom.md")
 	}
 }
+
+func TestLoad_UnknownKey(t *testing.T) {
+	path := writeTempYAML(t, `
+triage:
+  repo: owner/test
+stages:
+  - id: classify
+    outcome:
+      yes: done
+`)
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), `6:5: unknown key "outcome" in stages[0] (did you mean "outcomes"?)`) {
+		t.Errorf("Load() error = %v", err)
+	}
+}
+
+func TestSchema_CoversStageFields(t *testing.T) {
+	stages := Schema()["properties"].(map[string]interface{})["stages"].(map[string]interface{})
+	props := stages["items"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, key := range []string{"id", "mode", "outcomes", "enqueue_on"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("stage schema missing %q", key)
+		}
+	}
+}
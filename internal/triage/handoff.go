@@ -0,0 +1,119 @@
+package triage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucasnoah/taintfactory/internal/config"
+	"github.com/lucasnoah/taintfactory/internal/db"
+)
+
+// handoff adds the issue to the implementation queue when the stage declares
+// enqueue: true and the outcome matches its enqueue_on value. The result is
+// persisted on the triage state so the detail page can link to the pipeline.
+// Failures are recorded on the handoff rather than failing the triage stage.
+func (r *Runner) handoff(st *TriageState, stageCfg *TriageStage, outcome *TriageOutcome) {
+	if stageCfg == nil || !stageCfg.Enqueue || outcome.Outcome != stageCfg.EnqueueOutcome() {
+		return
+	}
+
+	h := &TriageHandoff{
+		Stage:         stageCfg.ID,
+		Namespace:     st.Repo,
+		Priority:      stageCfg.Priority,
+		FeatureIntent: strings.TrimSpace(outcome.FeatureIntent),
+	}
+	if h.FeatureIntent == "" {
+		h.FeatureIntent = strings.TrimSpace(outcome.Summary)
+	}
+
+	configPath, ns, err := r.resolvePipelineConfig(st)
+	switch {
+	case err != nil:
+		h.Error = err.Error()
+	case h.FeatureIntent == "":
+		h.Error = "outcome has no feature_intent or summary"
+	default:
+		h.ConfigPath = configPath
+		if ns != "" {
+			h.Namespace = ns
+		}
+		err := r.queueExec(db.QueueAddItem{
+			Namespace:     h.Namespace,
+			Issue:         st.Issue,
+			FeatureIntent: h.FeatureIntent,
+			ConfigPath:    h.ConfigPath,
+			Priority:      h.Priority,
+		})
+		// An issue that is already queued is linked rather than re-added.
+		if err != nil && !strings.Contains(err.Error(), "already in the queue") {
+			h.Error = fmt.Sprintf("queue add: %v", err)
+		} else {
+			h.EnqueuedAt = time.Now().UTC().Format(time.RFC3339)
+		}
+	}
+
+	if h.Error != "" {
+		r.logf("triage issue %d stage %s: warning: enqueue failed: %s", st.Issue, stageCfg.ID, h.Error)
+	} else {
+		r.logf("triage issue %d stage %s: enqueued for implementation (priority %d)", st.Issue, stageCfg.ID, h.Priority)
+	}
+
+	if err := r.store.Update(st.Issue, func(s *TriageState) {
+		s.Handoff = h
+	}); err != nil {
+		r.logf("triage issue %d: warning: record handoff: %v", st.Issue, err)
+	}
+}
+
+// resolvePipelineConfig returns the absolute pipeline.yaml path for handed-off
+// issues and the pipeline namespace it declares. It checks the triage config's
+// pipeline_config, then the registered repo for this namespace, then
+// {repoRoot}/pipeline.yaml.
+func (r *Runner) resolvePipelineConfig(st *TriageState) (path, namespace string, err error) {
+	repoRoot := st.RepoRoot
+	if repoRoot == "" {
+		repoRoot = r.repoRoot
+	}
+
+	switch {
+	case r.cfg.Triage.PipelineConfig != "":
+		path = r.cfg.Triage.PipelineConfig
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(repoRoot, path)
+		}
+	case r.db != nil:
+		if rec, lookupErr := r.db.RepoGetByNamespace(st.Repo); lookupErr == nil && rec != nil {
+			path = rec.ConfigPath
+		}
+	}
+	if path == "" && repoRoot != "" {
+		path = filepath.Join(repoRoot, "pipeline.yaml")
+	}
+	if path == "" {
+		return "", "", fmt.Errorf("no pipeline config: set triage.pipeline_config or register the repo")
+	}
+
+	if abs, absErr := filepath.Abs(path); absErr == nil {
+		path = abs
+	}
+	if _, statErr := os.Stat(path); statErr != nil {
+		return "", "", fmt.Errorf("pipeline config %q not found", path)
+	}
+
+	if cfg, loadErr := config.Load(path); loadErr == nil && cfg.Pipeline.Repo != "" {
+		namespace = config.RepoNamespace(cfg.Pipeline.Repo)
+	}
+	return path, namespace, nil
+}
+
+// defaultQueueAdd is the real implementation of queueExec.
+func (r *Runner) defaultQueueAdd(item db.QueueAddItem) error {
+	if r.db == nil {
+		return fmt.Errorf("no database configured")
+	}
+	return r.db.QueueAdd([]db.QueueAddItem{item})
+}
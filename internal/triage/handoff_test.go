@@ -0,0 +1,174 @@
+package triage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lucasnoah/taintfactory/internal/db"
+)
+
+// testEnqueueConfig: one print-mode stage that hands "yes" outcomes to the queue.
+func testEnqueueConfig() *TriageConfig {
+	cfg := testSinglePrintConfig()
+	cfg.Stages[0].Enqueue = true
+	cfg.Stages[0].Priority = 5
+	return cfg
+}
+
+// setupHandoffRunner builds a print-mode runner for issue 42 whose repo root
+// holds a pipeline.yaml, and records queue inserts instead of hitting the DB.
+func setupHandoffRunner(t *testing.T, cfg *TriageConfig, output string) (*Runner, *Store, *[]db.QueueAddItem) {
+	t.Helper()
+	runner, store := setupParallelPrintRunner(t, cfg, 42)
+	pipelineYAML := "pipeline:\n  name: test\n  repo: github.com/acme/widgets\n"
+	if err := os.WriteFile(filepath.Join(runner.repoRoot, "pipeline.yaml"), []byte(pipelineYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner.printExec = func(stageCfg *TriageStage, prompt string) (string, error) { return output, nil }
+
+	var added []db.QueueAddItem
+	runner.queueExec = func(item db.QueueAddItem) error {
+		added = append(added, item)
+		return nil
+	}
+	return runner, store, &added
+}
+
+func TestRunner_Handoff_EnqueuesOnYes(t *testing.T) {
+	runner, store, added := setupHandoffRunner(t, testEnqueueConfig(),
+		`{"outcome":"yes","summary":"still relevant","feature_intent":"Users can export widgets as CSV"}`)
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+
+	if len(*added) != 1 {
+		t.Fatalf("queue inserts = %d, want 1", len(*added))
+	}
+	item := (*added)[0]
+	if item.Issue != 42 || item.Namespace != "acme/widgets" || item.Priority != 5 {
+		t.Errorf("item = %+v, want issue 42, namespace acme/widgets, priority 5", item)
+	}
+	if item.FeatureIntent != "Users can export widgets as CSV" {
+		t.Errorf("FeatureIntent = %q", item.FeatureIntent)
+	}
+	if want := filepath.Join(runner.repoRoot, "pipeline.yaml"); item.ConfigPath != want {
+		t.Errorf("ConfigPath = %q, want %q", item.ConfigPath, want)
+	}
+
+	st, err := store.Get(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Handoff == nil || st.Handoff.EnqueuedAt == "" || st.Handoff.Error != "" {
+		t.Fatalf("Handoff = %+v, want a successful handoff", st.Handoff)
+	}
+	if st.Status != "completed" {
+		t.Errorf("Status = %q, want completed", st.Status)
+	}
+}
+
+func TestRunner_Handoff_SkipsOtherOutcomes(t *testing.T) {
+	runner, store, added := setupHandoffRunner(t, testEnqueueConfig(), `{"outcome":"no","summary":"obsolete"}`)
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if len(*added) != 0 {
+		t.Errorf("queue inserts = %d, want 0", len(*added))
+	}
+	st, _ := store.Get(42)
+	if st.Handoff != nil {
+		t.Errorf("Handoff = %+v, want nil", st.Handoff)
+	}
+}
+
+func TestRunner_Handoff_IntentFallsBackToSummary(t *testing.T) {
+	runner, _, added := setupHandoffRunner(t, testEnqueueConfig(), `{"outcome":"yes","summary":"Add CSV export"}`)
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if len(*added) != 1 || (*added)[0].FeatureIntent != "Add CSV export" {
+		t.Errorf("inserts = %+v, want intent from summary", *added)
+	}
+}
+
+func TestRunner_Handoff_EnqueueOnCustomOutcome(t *testing.T) {
+	cfg := testEnqueueConfig()
+	cfg.Stages[0].EnqueueOn = "clean"
+	cfg.Stages[0].Outcomes["clean"] = "done"
+	runner, _, added := setupHandoffRunner(t, cfg, `{"outcome":"clean","summary":"Add CSV export"}`)
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if len(*added) != 1 {
+		t.Errorf("queue inserts = %d, want 1", len(*added))
+	}
+}
+
+func TestRunner_Handoff_PipelineConfigOverride(t *testing.T) {
+	cfg := testEnqueueConfig()
+	cfg.Triage.PipelineConfig = "deploy/pipeline.yaml"
+	runner, _, added := setupHandoffRunner(t, cfg, `{"outcome":"yes","summary":"Add CSV export"}`)
+	override := filepath.Join(runner.repoRoot, "deploy", "pipeline.yaml")
+	if err := os.MkdirAll(filepath.Dir(override), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(override, []byte("pipeline:\n  name: other\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if len(*added) != 1 {
+		t.Fatalf("queue inserts = %d, want 1", len(*added))
+	}
+	if (*added)[0].ConfigPath != override {
+		t.Errorf("ConfigPath = %q, want %q", (*added)[0].ConfigPath, override)
+	}
+	// The override declares no repo, so the triage repo is used as namespace.
+	if (*added)[0].Namespace != "owner/test" {
+		t.Errorf("Namespace = %q, want owner/test", (*added)[0].Namespace)
+	}
+}
+
+func TestRunner_Handoff_MissingPipelineConfigRecordsError(t *testing.T) {
+	runner, store, added := setupHandoffRunner(t, testEnqueueConfig(), `{"outcome":"yes","summary":"Add CSV export"}`)
+	if err := os.Remove(filepath.Join(runner.repoRoot, "pipeline.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if len(*added) != 0 {
+		t.Errorf("queue inserts = %d, want 0", len(*added))
+	}
+	st, _ := store.Get(42)
+	if st.Handoff == nil || st.Handoff.Error == "" || st.Handoff.EnqueuedAt != "" {
+		t.Errorf("Handoff = %+v, want a recorded error", st.Handoff)
+	}
+	if st.Status != "completed" {
+		t.Errorf("Status = %q, want completed despite failed handoff", st.Status)
+	}
+}
+
+func TestRunner_Handoff_AlreadyQueuedIsLinked(t *testing.T) {
+	runner, store, _ := setupHandoffRunner(t, testEnqueueConfig(), `{"outcome":"yes","summary":"Add CSV export"}`)
+	runner.queueExec = func(item db.QueueAddItem) error {
+		return fmt.Errorf("issue %d is already in the queue", item.Issue)
+	}
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	st, _ := store.Get(42)
+	if st.Handoff == nil || st.Handoff.Error != "" || st.Handoff.EnqueuedAt == "" {
+		t.Errorf("Handoff = %+v, want linked without error", st.Handoff)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -63,6 +64,14 @@ type Runner struct {
 	// labelExec applies a GitHub label to an issue.
 	// Defaults to the real gh CLI implementation; overridable in tests.
 	labelExec func(repo string, issue int, label string) error
+
+	// queueExec adds a triaged issue to the implementation queue.
+	// Defaults to db.QueueAdd; overridable in tests.
+	queueExec func(item db.QueueAddItem) error
+
+	logMu      sync.Mutex               // serialises progress output from parallel workers
+	slotsMu    sync.Mutex               // guards stageSlots
+	stageSlots map[string]chan struct{} // per-stage max_parallel semaphores
 }
 
 // NewRunner creates a new Runner. bootWait defaults to 15s.
@@ -78,6 +87,7 @@ func NewRunner(cfg *TriageConfig, store *Store, database *db.DB, sessions *sessi
 	}
 	r.printExec = r.defaultExecPrint
 	r.labelExec = r.defaultApplyLabel
+	r.queueExec = r.defaultQueueAdd
 	return r
 }
 
@@ -97,12 +107,15 @@ func (r *Runner) FirstStageID() string {
 // logf prints a formatted message to the progress writer if one is set.
 func (r *Runner) logf(format string, args ...any) {
 	if r.progress != nil {
+		r.logMu.Lock()
+		defer r.logMu.Unlock()
 		fmt.Fprintf(r.progress, format+"\n", args...)
 	}
 }
 
-// Enqueue saves an initial pending state for the issue. If no triage is currently
-// in_progress, it starts the first stage immediately; otherwise it queues for serial pickup.
+// Enqueue saves an initial pending state for the issue. If fewer than
+// max_parallel triages are in_progress, it starts the first stage immediately;
+// otherwise the issue waits for pickup by Advance.
 func (r *Runner) Enqueue(issue int, issueTitle, issueBody string) error {
 	firstStage := r.FirstStageID()
 	if firstStage == "" {
@@ -121,16 +134,32 @@ func (r *Runner) Enqueue(issue int, issueTitle, issueBody string) error {
 		return fmt.Errorf("save initial state for issue %d: %w", issue, err)
 	}
 
-	// Only start immediately if no other triage is in_progress (serial execution).
-	active, err := r.store.List("in_progress")
+	// Only start immediately if a parallel slot is free. The count and the
+	// claim happen under the select lock so concurrent callers agree on it.
+	releaseSelect, err := acquireSelectLock(r.store)
 	if err != nil {
+		r.logf("triage issue %d: queued (%v)", issue, err)
+		return nil
+	}
+	running, err := r.countRunning()
+	if err != nil {
+		releaseSelect()
 		return fmt.Errorf("check active triage: %w", err)
 	}
-	if len(active) > 0 {
-		r.logf("triage issue %d: queued (issue %d in progress)", issue, active[0].Issue)
+	if running >= r.cfg.Parallelism() {
+		releaseSelect()
+		r.logf("triage issue %d: queued (%d in progress)", issue, running)
 		return nil
 	}
 
+	release, err := acquireIssueLock(r.store, issue)
+	releaseSelect()
+	if err != nil {
+		r.logf("triage issue %d: queued (%v)", issue, err)
+		return nil
+	}
+	defer release()
+
 	stageCfg := r.cfg.StageByID(firstStage)
 	if stageCfg != nil && stageCfg.Mode == "print" {
 		// Print-mode: run synchronously inline (no tmux session needed).
@@ -145,12 +174,15 @@ func (r *Runner) Enqueue(issue int, issueTitle, issueBody string) error {
 	return nil
 }
 
-// acquireAdvanceLock creates an exclusive lock file in baseDir to prevent two
-// concurrent Advance() calls from processing the same stages simultaneously.
+// acquireAdvanceLock creates an exclusive lock file in dir to prevent two
+// concurrent callers from processing the same triage state simultaneously.
 // Returns a release function and nil on success, or an error if the lock is
 // already held. Stale lock files (> 30 min old) are removed automatically.
-func acquireAdvanceLock(baseDir string) (release func(), err error) {
-	lockPath := filepath.Join(baseDir, ".advance.lock")
+func acquireAdvanceLock(dir string) (release func(), err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	}
+	lockPath := filepath.Join(dir, ".advance.lock")
 
 	// Remove stale locks (e.g. from a crash).
 	if info, statErr := os.Stat(lockPath); statErr == nil {
@@ -171,81 +203,203 @@ func acquireAdvanceLock(baseDir string) (release func(), err error) {
 	return func() { os.Remove(lockPath) }, nil
 }
 
-// Advance processes at most one triage pipeline per call for async stages.
-// Print-mode stages chain and run to completion within a single call.
-func (r *Runner) Advance() ([]TriageAction, error) {
-	release, err := acquireAdvanceLock(r.store.BaseDir())
+// acquireIssueLock takes the advance lock for a single issue, stored in the
+// issue's outcome directory, so different issues can advance in parallel.
+func acquireIssueLock(store *Store, issue int) (release func(), err error) {
+	return acquireAdvanceLock(store.IssueDir(issue))
+}
+
+// selectLockWait bounds how long a caller waits for another to finish
+// choosing which pending issues to start.
+const selectLockWait = 10 * time.Second
+
+// acquireSelectLock takes the store-wide lock held while counting active
+// triages and claiming pending ones, so concurrent check-ins cannot together
+// start more than max_parallel. It is held only for that step.
+func acquireSelectLock(store *Store) (release func(), err error) {
+	deadline := time.Now().Add(selectLockWait)
+	for {
+		release, err := acquireAdvanceLock(store.BaseDir())
+		if err == nil || time.Now().After(deadline) {
+			return release, err
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// issueLocked reports whether a caller holds the issue's lock.
+func issueLocked(store *Store, issue int) bool {
+	info, err := os.Stat(filepath.Join(store.IssueDir(issue), ".advance.lock"))
+	return err == nil && time.Since(info.ModTime()) <= 30*time.Minute
+}
+
+// countRunning returns the in_progress triages plus the pending ones another
+// caller has claimed and is starting. Call it under the select lock.
+func (r *Runner) countRunning() (int, error) {
+	active, err := r.store.List("in_progress")
 	if err != nil {
-		r.logf("advance: %v — skipping this check-in", err)
-		return nil, nil
+		return 0, err
 	}
-	defer release()
+	pending, err := r.store.List("pending")
+	if err != nil {
+		return 0, err
+	}
+	running := len(active)
+	for _, st := range pending {
+		if issueLocked(r.store, st.Issue) {
+			running++
+		}
+	}
+	return running, nil
+}
 
-	var actions []TriageAction
+// Advance processes every in_progress triage pipeline and starts pending ones
+// until max_parallel pipelines are active. Pending issues are claimed by
+// taking their lock under the select lock, which counts claimed issues as
+// running. Issues are then advanced by a worker pool of max_parallel
+// goroutines, each holding that issue's lock. Within an issue, async stages
+// advance at most one stage per call while print-mode stages chain and run
+// to completion.
+func (r *Runner) Advance() ([]TriageAction, error) {
+	releaseSelect, err := acquireSelectLock(r.store)
+	if err != nil {
+		return nil, fmt.Errorf("select triage issues: %w", err)
+	}
+	active, err := r.store.List("in_progress")
+	if err != nil {
+		releaseSelect()
+		return nil, fmt.Errorf("list in_progress triage states: %w", err)
+	}
+	pending, err := r.store.List("pending")
+	if err != nil {
+		releaseSelect()
+		return nil, fmt.Errorf("list pending triage states: %w", err)
+	}
 
-	for {
-		// Check for an in_progress pipeline.
-		active, err := r.store.List("in_progress")
+	limit := r.cfg.Parallelism()
+	issues := make([]int, 0, len(active)+limit)
+	for _, st := range active {
+		issues = append(issues, st.Issue)
+	}
+	running := len(active)
+	claimed := make(map[int]func())
+	for _, st := range pending {
+		if running >= limit {
+			break
+		}
+		running++ // claimed here, or already being started by another caller
+		release, err := acquireIssueLock(r.store, st.Issue)
 		if err != nil {
-			return actions, fmt.Errorf("list in_progress triage states: %w", err)
+			continue
 		}
-
-		if len(active) > 0 {
-			st := &active[0]
-			stageCfg := r.cfg.StageByID(st.CurrentStage)
-
-			// Print-mode stage with no active session: run synchronously.
-			if stageCfg != nil && stageCfg.Mode == "print" && st.CurrentSession == "" {
-				action := r.runPrintStage(st)
-				actions = append(actions, action)
-				if action.Action == "error" || action.Action == "completed" {
-					return actions, nil
-				}
-				// Loop: the next stage might also be print-mode.
-				continue
+		claimed[st.Issue] = release
+		issues = append(issues, st.Issue)
+	}
+	releaseSelect()
+
+	results := make([][]TriageAction, len(issues))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, issue := range issues {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if release, ok := claimed[issue]; ok {
+				defer release()
+				results[i] = r.advanceLocked(issue)
+				return
 			}
+			results[i] = r.advanceIssue(issue)
+		}()
+	}
+	wg.Wait()
 
-			// Async stage: advance as normal (one stage per call).
-			action := r.advanceOne(st)
-			return append(actions, action), nil
-		}
+	var actions []TriageAction
+	for _, a := range results {
+		actions = append(actions, a...)
+	}
+	return actions, nil
+}
+
+// advanceIssue drives a single triage pipeline as far as one check-in allows,
+// holding the issue's lock throughout. If another caller holds the lock the
+// issue is skipped silently.
+func (r *Runner) advanceIssue(issue int) []TriageAction {
+	release, err := acquireIssueLock(r.store, issue)
+	if err != nil {
+		r.logf("triage issue %d: %v — skipping this check-in", issue, err)
+		return nil
+	}
+	defer release()
+	return r.advanceLocked(issue)
+}
 
-		// No in_progress pipeline — start the next pending one.
-		pending, err := r.store.List("pending")
+// advanceLocked is advanceIssue for a caller that holds the issue's lock.
+func (r *Runner) advanceLocked(issue int) []TriageAction {
+	var actions []TriageAction
+	for {
+		// Re-read under the lock: another caller may have advanced it.
+		st, err := r.store.Get(issue)
 		if err != nil {
-			return actions, fmt.Errorf("list pending triage states: %w", err)
+			return append(actions, TriageAction{Issue: issue, Action: "error", Message: err.Error()})
 		}
-		if len(pending) == 0 {
-			return actions, nil
+		if st.Status != "pending" && st.Status != "in_progress" {
+			return actions
 		}
+		stageCfg := r.cfg.StageByID(st.CurrentStage)
 
-		next := &pending[0]
-		stageCfg := r.cfg.StageByID(next.CurrentStage)
-
-		// Print-mode first stage: run immediately without creating a session.
-		if stageCfg != nil && stageCfg.Mode == "print" {
-			action := r.runPrintStage(next)
+		// Print-mode stage with no active session: run synchronously.
+		if stageCfg != nil && stageCfg.Mode == "print" && st.CurrentSession == "" {
+			action := r.runPrintStage(st)
 			actions = append(actions, action)
 			if action.Action == "error" || action.Action == "completed" {
-				return actions, nil
+				return actions
 			}
-			// Loop: next stage might also be print-mode.
+			// Loop: the next stage might also be print-mode.
 			continue
 		}
 
-		// Async first stage: fetch issue and start session.
-		issueData, err := r.gh.GetIssue(next.Issue)
+		// Async stage: advance as normal (one stage per call).
+		if st.Status == "in_progress" {
+			return append(actions, r.advanceOne(st))
+		}
+
+		// Pending async first stage: fetch issue and start session.
+		issueData, err := r.gh.GetIssue(st.Issue)
 		if err != nil {
-			return append(actions, TriageAction{Issue: next.Issue, Stage: next.CurrentStage, Action: "error", Message: fmt.Sprintf("fetch issue: %v", err)}), nil
+			return append(actions, TriageAction{Issue: st.Issue, Stage: st.CurrentStage, Action: "error", Message: fmt.Sprintf("fetch issue: %v", err)})
 		}
-		if err := r.startStage(next.Issue, next.CurrentStage, issueData.Title, issueData.Body); err != nil {
-			return append(actions, TriageAction{Issue: next.Issue, Stage: next.CurrentStage, Action: "error", Message: fmt.Sprintf("start stage: %v", err)}), nil
+		if err := r.startStage(st.Issue, st.CurrentStage, issueData.Title, issueData.Body); err != nil {
+			return append(actions, TriageAction{Issue: st.Issue, Stage: st.CurrentStage, Action: "error", Message: fmt.Sprintf("start stage: %v", err)})
 		}
-		r.logf("triage issue %d: started from queue", next.Issue)
-		return append(actions, TriageAction{Issue: next.Issue, Stage: next.CurrentStage, Action: "started", Message: "dequeued from pending"}), nil
+		r.logf("triage issue %d: started from queue", st.Issue)
+		return append(actions, TriageAction{Issue: st.Issue, Stage: st.CurrentStage, Action: "started", Message: "dequeued from pending"})
 	}
 }
 
+// acquireStageSlot blocks until the stage's max_parallel allows another issue
+// to run it. Stages without a limit return immediately.
+func (r *Runner) acquireStageSlot(stageCfg *TriageStage) (release func()) {
+	if stageCfg.MaxParallel <= 0 {
+		return func() {}
+	}
+	r.slotsMu.Lock()
+	if r.stageSlots == nil {
+		r.stageSlots = make(map[string]chan struct{})
+	}
+	slots, ok := r.stageSlots[stageCfg.ID]
+	if !ok {
+		slots = make(chan struct{}, stageCfg.MaxParallel)
+		r.stageSlots[stageCfg.ID] = slots
+	}
+	r.slotsMu.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
 // advanceOne checks a single in_progress triage state and advances it if ready.
 func (r *Runner) advanceOne(st *TriageState) TriageAction {
 	base := TriageAction{Issue: st.Issue, Stage: st.CurrentStage}
@@ -292,6 +446,9 @@ func (r *Runner) advanceOne(st *TriageState) TriageAction {
 		nextStageID = stageCfg.Outcomes[outcome.Outcome]
 	}
 
+	// Hand off to the implementation queue if the stage asks for it.
+	r.handoff(st, stageCfg, outcome)
+
 	var stageDuration string
 	if st.StartedAt != "" {
 		if started, err := time.Parse(time.RFC3339, st.StartedAt); err == nil {
@@ -498,8 +655,10 @@ func (r *Runner) runPrintStage(st *TriageState) TriageAction {
 
 	r.logf("triage issue %d: running print stage %s", st.Issue, st.CurrentStage)
 
-	// Execute claude --print.
+	// Execute claude --print, respecting the stage's max_parallel.
+	releaseSlot := r.acquireStageSlot(stageCfg)
 	stdout, err := r.printExec(stageCfg, prompt)
+	releaseSlot()
 	if err != nil {
 		base.Action = "error"
 		base.Message = fmt.Sprintf("exec print: %v", err)
@@ -526,6 +685,9 @@ func (r *Runner) runPrintStage(st *TriageState) TriageAction {
 		}
 	}
 
+	// Hand off to the implementation queue if the stage asks for it.
+	r.handoff(st, stageCfg, outcome)
+
 	// Determine next stage and compute duration.
 	nextStageID := stageCfg.Outcomes[outcome.Outcome]
 	stageDuration := time.Since(stageStart).Round(time.Second).String()
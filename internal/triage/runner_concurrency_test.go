@@ -1,6 +1,7 @@
 package triage
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -69,7 +70,7 @@ func TestAdvanceLock_StaleLockIsIgnored(t *testing.T) {
 }
 
 // TestRunner_Advance_SkipsWhenLockHeld verifies that Advance() returns no
-// actions (and no error) when another process holds the advance lock.
+// actions (and no error) when another process holds the issue's advance lock.
 func TestRunner_Advance_SkipsWhenLockHeld(t *testing.T) {
 	cfg := testSinglePrintConfig()
 	runner, store, _, _, repoRoot := setupPrintRunnerWith(t, cfg)
@@ -93,10 +94,10 @@ func TestRunner_Advance_SkipsWhenLockHeld(t *testing.T) {
 		t.Fatalf("Save: %v", err)
 	}
 
-	// Simulate another process holding the lock.
-	release, err := acquireAdvanceLock(store.BaseDir())
+	// Simulate another process holding this issue's lock.
+	release, err := acquireIssueLock(store, 31)
 	if err != nil {
-		t.Fatalf("acquireAdvanceLock: %v", err)
+		t.Fatalf("acquireIssueLock: %v", err)
 	}
 	defer release()
 
@@ -192,3 +193,233 @@ func TestRunner_Advance_NoConcurrentDuplicates(t *testing.T) {
 		t.Errorf("StageHistory has %d entries, want exactly 2 (concurrent Advance caused duplicates)", len(got.StageHistory))
 	}
 }
+
+// setupParallelPrintRunner builds a Runner for print-mode-only configs. Print
+// stages never touch sessions or the DB, so neither is wired.
+func setupParallelPrintRunner(t *testing.T, cfg *TriageConfig, issues ...int) (*Runner, *Store) {
+	t.Helper()
+	store := NewStore(t.TempDir())
+	repoRoot := t.TempDir()
+	for _, s := range cfg.Stages {
+		writePrintStageTemplate(t, repoRoot, s.ID)
+	}
+	runner := NewRunner(cfg, store, nil, nil, &mockGHClient{}, repoRoot)
+	runner.bootWait = 0
+	runner.labelExec = func(repo string, issue int, label string) error { return nil }
+
+	for _, issue := range issues {
+		st := &TriageState{Issue: issue, Repo: "owner/test", CurrentStage: cfg.Stages[0].ID, Status: "pending", StageHistory: []TriageStageHistoryEntry{}}
+		if err := store.Save(st); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	return runner, store
+}
+
+// concurrencyProbe records the peak number of simultaneous printExec calls.
+type concurrencyProbe struct {
+	mu      sync.Mutex
+	current int
+	peak    int
+}
+
+func (p *concurrencyProbe) exec(stageCfg *TriageStage, prompt string) (string, error) {
+	p.mu.Lock()
+	p.current++
+	if p.current > p.peak {
+		p.peak = p.current
+	}
+	p.mu.Unlock()
+
+	time.Sleep(30 * time.Millisecond)
+
+	p.mu.Lock()
+	p.current--
+	p.mu.Unlock()
+	return `{"outcome":"no","summary":"parallel"}`, nil
+}
+
+func countStatus(t *testing.T, store *Store, status string) int {
+	t.Helper()
+	states, err := store.List(status)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return len(states)
+}
+
+// TestRunner_Advance_SerialByDefault verifies that without max_parallel only
+// one pending issue is started per check-in.
+func TestRunner_Advance_SerialByDefault(t *testing.T) {
+	runner, store := setupParallelPrintRunner(t, testSinglePrintConfig(), 1, 2, 3)
+	probe := &concurrencyProbe{}
+	runner.printExec = probe.exec
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if got := countStatus(t, store, "completed"); got != 1 {
+		t.Errorf("completed = %d, want 1", got)
+	}
+	if got := countStatus(t, store, "pending"); got != 2 {
+		t.Errorf("pending = %d, want 2", got)
+	}
+}
+
+// TestRunner_Advance_MaxParallel verifies that max_parallel pending issues run
+// their print stages concurrently and the rest stay queued.
+func TestRunner_Advance_MaxParallel(t *testing.T) {
+	cfg := testSinglePrintConfig()
+	cfg.Triage.MaxParallel = 3
+	runner, store := setupParallelPrintRunner(t, cfg, 1, 2, 3, 4, 5)
+	probe := &concurrencyProbe{}
+	runner.printExec = probe.exec
+
+	actions, err := runner.Advance()
+	if err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if len(actions) != 3 {
+		t.Errorf("got %d actions, want 3", len(actions))
+	}
+	if got := countStatus(t, store, "completed"); got != 3 {
+		t.Errorf("completed = %d, want 3", got)
+	}
+	if got := countStatus(t, store, "pending"); got != 2 {
+		t.Errorf("pending = %d, want 2", got)
+	}
+	if probe.peak < 2 || probe.peak > 3 {
+		t.Errorf("peak concurrency = %d, want 2..3", probe.peak)
+	}
+	// Actions are reported in issue order regardless of completion order.
+	for i, a := range actions {
+		if a.Issue != i+1 {
+			t.Errorf("actions[%d].Issue = %d, want %d", i, a.Issue, i+1)
+		}
+	}
+}
+
+// TestRunner_Advance_StageMaxParallel verifies that a stage-level max_parallel
+// caps concurrent runs of that stage below the global limit.
+func TestRunner_Advance_StageMaxParallel(t *testing.T) {
+	cfg := testSinglePrintConfig()
+	cfg.Triage.MaxParallel = 4
+	cfg.Stages[0].MaxParallel = 1
+	runner, store := setupParallelPrintRunner(t, cfg, 1, 2, 3, 4)
+	probe := &concurrencyProbe{}
+	runner.printExec = probe.exec
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	if got := countStatus(t, store, "completed"); got != 4 {
+		t.Errorf("completed = %d, want 4", got)
+	}
+	if probe.peak != 1 {
+		t.Errorf("peak concurrency = %d, want 1", probe.peak)
+	}
+}
+
+// TestRunner_Advance_IssueLockIsolated verifies that a held lock on one issue
+// does not block other issues from advancing.
+func TestRunner_Advance_IssueLockIsolated(t *testing.T) {
+	cfg := testSinglePrintConfig()
+	cfg.Triage.MaxParallel = 2
+	runner, store := setupParallelPrintRunner(t, cfg, 1, 2)
+	probe := &concurrencyProbe{}
+	runner.printExec = probe.exec
+
+	release, err := acquireIssueLock(store, 1)
+	if err != nil {
+		t.Fatalf("acquireIssueLock: %v", err)
+	}
+	defer release()
+
+	if _, err := runner.Advance(); err != nil {
+		t.Fatalf("Advance: %v", err)
+	}
+	for issue, want := range map[int]string{1: "pending", 2: "completed"} {
+		st, err := store.Get(issue)
+		if err != nil {
+			t.Fatalf("Get(%d): %v", issue, err)
+		}
+		if st.Status != want {
+			t.Errorf("issue %d status = %q, want %q", issue, st.Status, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(store.IssueDir(2), ".advance.lock")); !os.IsNotExist(err) {
+		t.Errorf("issue 2 lock not released: %v", err)
+	}
+}
+
+// TestRunner_Enqueue_StartsWhileSlotsFree verifies that Enqueue runs the first
+// print stage inline until max_parallel triages are in progress.
+func TestRunner_Enqueue_StartsWhileSlotsFree(t *testing.T) {
+	cfg := testSinglePrintConfig()
+	cfg.Triage.MaxParallel = 2
+	runner, store := setupParallelPrintRunner(t, cfg)
+	runner.printExec = func(stageCfg *TriageStage, prompt string) (string, error) {
+		return "", fmt.Errorf("stay in progress")
+	}
+
+	for _, issue := range []int{1, 2, 3} {
+		if err := runner.Enqueue(issue, "t", "b"); err != nil {
+			t.Fatalf("Enqueue(%d): %v", issue, err)
+		}
+	}
+	if got := countStatus(t, store, "in_progress"); got != 2 {
+		t.Errorf("in_progress = %d, want 2", got)
+	}
+	if got := countStatus(t, store, "pending"); got != 1 {
+		t.Errorf("pending = %d, want 1", got)
+	}
+}
+
+// TestRunner_Advance_ConcurrentCallsRespectMaxParallel verifies that two
+// check-ins running at once start no more than max_parallel issues between them.
+func TestRunner_Advance_ConcurrentCallsRespectMaxParallel(t *testing.T) {
+	cfg := testSinglePrintConfig()
+	cfg.Triage.MaxParallel = 2
+	runner, store := setupParallelPrintRunner(t, cfg, 1, 2, 3, 4, 5, 6)
+	runner.printExec = func(stageCfg *TriageStage, prompt string) (string, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "", fmt.Errorf("stay in progress")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := runner.Advance(); err != nil {
+				t.Errorf("Advance: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := countStatus(t, store, "in_progress"); got != 2 {
+		t.Errorf("in_progress = %d, want 2", got)
+	}
+}
+
+// TestRunner_Enqueue_CountsClaimedPending verifies that a pending issue another
+// caller has locked to start counts toward max_parallel.
+func TestRunner_Enqueue_CountsClaimedPending(t *testing.T) {
+	runner, store := setupParallelPrintRunner(t, testSinglePrintConfig(), 1)
+	runner.printExec = func(stageCfg *TriageStage, prompt string) (string, error) {
+		return "", fmt.Errorf("stay in progress")
+	}
+	release, err := acquireIssueLock(store, 1)
+	if err != nil {
+		t.Fatalf("acquireIssueLock: %v", err)
+	}
+	defer release()
+
+	if err := runner.Enqueue(2, "t", "b"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if got := countStatus(t, store, "pending"); got != 2 {
+		t.Errorf("pending = %d, want 2 (issue 1 holds the only slot)", got)
+	}
+}
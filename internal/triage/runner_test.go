@@ -37,6 +37,11 @@ func (m *mockTmux) SendKeys(session string, keys string) error {
 	return nil
 }
 
+func (m *mockTmux) SendRaw(session string, key string) error {
+	m.sentKeys = append(m.sentKeys, key)
+	return nil
+}
+
 func (m *mockTmux) SendBuffer(session string, content string) error {
 	m.sentBufs = append(m.sentBufs, content)
 	return nil
@@ -24,9 +24,21 @@ type TriageState struct {
 	CurrentSession string                    `json:"current_session,omitempty"`
 	StartedAt      string                    `json:"started_at,omitempty"` // RFC3339 time when current stage started
 	StageHistory   []TriageStageHistoryEntry `json:"stage_history"`
+	Handoff        *TriageHandoff            `json:"handoff,omitempty"` // set once the issue has been handed to the queue
 	UpdatedAt      string                    `json:"updated_at,omitempty"`
 }
 
+// TriageHandoff records an attempt to add a triaged issue to the implementation queue.
+type TriageHandoff struct {
+	Stage         string `json:"stage"`
+	Namespace     string `json:"namespace"`
+	ConfigPath    string `json:"config_path,omitempty"`
+	Priority      int    `json:"priority,omitempty"`
+	FeatureIntent string `json:"feature_intent,omitempty"`
+	EnqueuedAt    string `json:"enqueued_at,omitempty"` // RFC3339; empty when the handoff failed
+	Error         string `json:"error,omitempty"`
+}
+
 // TriageStageHistoryEntry records the outcome of one completed stage.
 type TriageStageHistoryEntry struct {
 	Stage    string `json:"stage"`
@@ -37,8 +49,9 @@ type TriageStageHistoryEntry struct {
 
 // TriageOutcome is the JSON file the agent writes as its final act.
 type TriageOutcome struct {
-	Outcome string `json:"outcome"`
-	Summary string `json:"summary,omitempty"`
+	Outcome       string `json:"outcome"`
+	Summary       string `json:"summary,omitempty"`
+	FeatureIntent string `json:"feature_intent,omitempty"` // used as the queue item's intent on handoff; falls back to Summary
 }
 
 // Store manages triage state files on disk under a single base directory.
@@ -86,9 +99,15 @@ func (s *Store) OutcomePath(issue int, stageID string) string {
 	return filepath.Join(s.baseDir, strconv.Itoa(issue), stageID+".outcome.json")
 }
 
+// IssueDir returns the per-issue subdirectory holding outcome files and the
+// issue's advance lock.
+func (s *Store) IssueDir(issue int) string {
+	return filepath.Join(s.baseDir, strconv.Itoa(issue))
+}
+
 // EnsureOutcomeDir creates the per-issue subdirectory used for outcome files.
 func (s *Store) EnsureOutcomeDir(issue int) error {
-	dir := filepath.Join(s.baseDir, strconv.Itoa(issue))
+	dir := s.IssueDir(issue)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("mkdir %s: %w", dir, err)
 	}
@@ -3,15 +3,19 @@ package web
 import (
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lucasnoah/taintfactory/internal/attemptdiff"
+	"github.com/lucasnoah/taintfactory/internal/config"
 	"github.com/lucasnoah/taintfactory/internal/db"
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
 	"github.com/lucasnoah/taintfactory/internal/prompt"
+	"github.com/lucasnoah/taintfactory/internal/qa"
 )
 
 // ---- view models ----
@@ -142,9 +146,19 @@ type AttemptDetailData struct {
 	Checks       []db.CheckRun
 	Summary      *pipeline.StageSummary
 	Outcome      *pipeline.StageOutcome
+	PrevAttempt  int              // previous attempt number for the compare link; 0 if none
+	Visual       *qa.VisualReport // browser_qa visual regression, if the attempt ran one
 	Sidebar      SidebarData
 }
 
+type AttemptDiffData struct {
+	Issue     int
+	Namespace string
+	Stage     string
+	Diff      *attemptdiff.Result
+	Sidebar   SidebarData
+}
+
 type QueueData struct {
 	Items   []QueueRowView
 	Sidebar SidebarData
@@ -200,8 +214,34 @@ type CheckConfigRow struct {
 	Timeout string
 }
 
+// DiffLine is one line of a unified diff with its display class.
+type DiffLine struct {
+	Class string
+	Text  string
+}
+
 // ---- helpers ----
 
+// diffLines splits a unified diff into lines tagged for syntax colouring.
+func diffLines(diff string) []DiffLine {
+	var lines []DiffLine
+	for _, l := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		class := ""
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			class = "diff-hunk"
+		case strings.HasPrefix(l, "+++"), strings.HasPrefix(l, "---"):
+			class = "diff-hunk"
+		case strings.HasPrefix(l, "+"):
+			class = "diff-add"
+		case strings.HasPrefix(l, "-"):
+			class = "diff-del"
+		}
+		lines = append(lines, DiffLine{Class: class, Text: l})
+	}
+	return lines
+}
+
 var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]|\x1b\][^\x07]*\x07|\x1b[()][012B]`)
 
 func stripANSI(s string) string {
@@ -694,9 +734,13 @@ func (s *Server) handleAttemptDetail(w http.ResponseWriter, r *http.Request, nam
 
 	prompt, _ := s.store.GetPrompt(issue, stage, attempt)
 	logContent, _ := s.store.GetSessionLog(issue, stage, attempt)
-	attemptChecks, _ := s.checkRunsForAttempt(namespace, issue, stage, attempt)
+	var attemptChecks []db.CheckRun
+	if s.db != nil {
+		attemptChecks, _ = s.checkRunsForAttempt(namespace, issue, stage, attempt)
+	}
 	summary, _ := s.store.GetStageSummary(issue, stage, attempt)
 	outcome, _ := s.store.GetStageOutcome(issue, stage, attempt)
+	visual, _ := qa.ReadVisualReport(filepath.Join(s.store.BrowserQADir(issue, stage, attempt), "visual", "report.json"))
 
 	logContent = stripANSI(logContent)
 	const logLineLimit = 200
@@ -718,6 +762,8 @@ func (s *Server) handleAttemptDetail(w http.ResponseWriter, r *http.Request, nam
 		Checks:       attemptChecks,
 		Summary:      summary,
 		Outcome:      outcome,
+		PrevAttempt:  attempt - 1,
+		Visual:       visual,
 		Sidebar:      s.sidebarData(namespace),
 	}
 
@@ -726,6 +772,68 @@ func (s *Server) handleAttemptDetail(w http.ResponseWriter, r *http.Request, nam
 	}
 }
 
+// ---- Attempt Diff ----
+
+func (s *Server) handleAttemptDiff(w http.ResponseWriter, r *http.Request, namespace, issueStr, stage, aStr, bStr string) {
+	issue, err := strconv.Atoi(issueStr)
+	if err != nil {
+		http.Error(w, "invalid issue number", http.StatusBadRequest)
+		return
+	}
+	a, errA := strconv.Atoi(aStr)
+	b, errB := strconv.Atoi(bStr)
+	if errA != nil || errB != nil {
+		http.Error(w, "invalid attempt number", http.StatusBadRequest)
+		return
+	}
+
+	if ps, err := s.store.GetForNamespace(namespace, issue); err == nil {
+		namespace = s.effectiveNamespace(ps)
+	}
+
+	sideA := attemptdiff.Load(s.store, issue, stage, a)
+	sideB := attemptdiff.Load(s.store, issue, stage, b)
+	if s.db != nil {
+		sideA.Checks, _ = s.checkRunsForAttempt(namespace, issue, stage, a)
+		sideB.Checks, _ = s.checkRunsForAttempt(namespace, issue, stage, b)
+	}
+
+	data := AttemptDiffData{
+		Issue:     issue,
+		Namespace: namespace,
+		Stage:     stage,
+		Diff:      attemptdiff.Compare(issue, stage, sideA, sideB),
+		Sidebar:   s.sidebarData(namespace),
+	}
+
+	if err := s.attemptDiffTmpl.ExecuteTemplate(w, "base", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ---- Attempt Browser Artifacts ----
+
+// handleAttemptBrowserFile serves a screenshot saved by a browser_qa stage,
+// e.g. the images of the attempt page's visual diff.
+func (s *Server) handleAttemptBrowserFile(w http.ResponseWriter, r *http.Request, issueStr, stage, attemptStr string, name []string) {
+	issue, err := strconv.Atoi(issueStr)
+	if err != nil {
+		http.Error(w, "invalid issue number", http.StatusBadRequest)
+		return
+	}
+	attempt, err := strconv.Atoi(attemptStr)
+	if err != nil {
+		http.Error(w, "invalid attempt number", http.StatusBadRequest)
+		return
+	}
+	rel := filepath.Join(name...)
+	if !filepath.IsLocal(rel) || filepath.Ext(rel) != ".png" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(s.store.BrowserQADir(issue, stage, attempt), rel))
+}
+
 // ---- Attempt Log (raw text/plain) ----
 
 func (s *Server) handleAttemptLog(w http.ResponseWriter, r *http.Request, namespace, issueStr, stage, attemptStr string) {
@@ -832,7 +940,7 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	if proj != "" {
 		var filtered []repoConfig
 		for _, rc := range repos {
-			if repoToNamespace(rc.Cfg.Pipeline.Repo) == proj {
+			if config.RepoNamespace(rc.Cfg.Pipeline.Repo) == proj {
 				filtered = append(filtered, rc)
 			}
 		}
@@ -37,7 +37,8 @@ var funcMap = template.FuncMap{
 		}
 		return "result-fail"
 	},
-	"relTime": relTime,
+	"relTime":   relTime,
+	"diffLines": diffLines,
 }
 
 // Server is the read-only web UI server.
@@ -52,12 +53,13 @@ type Server struct {
 	cfgCache map[string]*config.PipelineConfig
 	wtCache  map[string]string
 
-	dashboardTmpl *template.Template
-	pipelineTmpl  *template.Template
-	attemptTmpl   *template.Template
-	queueTmpl     *template.Template
-	configTmpl    *template.Template
-	reposTmpl     *template.Template
+	dashboardTmpl   *template.Template
+	pipelineTmpl    *template.Template
+	attemptTmpl     *template.Template
+	attemptDiffTmpl *template.Template
+	queueTmpl       *template.Template
+	configTmpl      *template.Template
+	reposTmpl       *template.Template
 
 	deploysTmpl *template.Template
 
@@ -72,22 +74,23 @@ type Server struct {
 // NewServer creates a Server with parsed templates.
 func NewServer(store *pipeline.Store, database *db.DB, port int, triageDir string) *Server {
 	return &Server{
-		store:          store,
-		db:             database,
-		port:           port,
-		triageDir:      triageDir,
-		cfgCache:       make(map[string]*config.PipelineConfig),
-		wtCache:        make(map[string]string),
-		triageCfgCache: make(map[string]*triage.TriageConfig),
-		dashboardTmpl:  mustParseTmpl("base.html", "dashboard.html"),
-		pipelineTmpl:   mustParseTmpl("base.html", "pipeline.html"),
-		attemptTmpl:    mustParseTmpl("base.html", "attempt.html"),
-		queueTmpl:      mustParseTmpl("base.html", "queue.html"),
-		configTmpl:     mustParseTmpl("base.html", "config.html"),
-		reposTmpl:      mustParseTmpl("base.html", "repos.html"),
-		deploysTmpl:    mustParseTmpl("base.html", "deploys.html"),
-		triageTmpl:     mustParseTmpl("base.html", "triage.html"),
-		triageListTmpl: mustParseTmpl("base.html", "triage-list.html"),
+		store:           store,
+		db:              database,
+		port:            port,
+		triageDir:       triageDir,
+		cfgCache:        make(map[string]*config.PipelineConfig),
+		wtCache:         make(map[string]string),
+		triageCfgCache:  make(map[string]*triage.TriageConfig),
+		dashboardTmpl:   mustParseTmpl("base.html", "dashboard.html"),
+		pipelineTmpl:    mustParseTmpl("base.html", "pipeline.html"),
+		attemptTmpl:     mustParseTmpl("base.html", "attempt.html"),
+		attemptDiffTmpl: mustParseTmpl("base.html", "attempt-diff.html"),
+		queueTmpl:       mustParseTmpl("base.html", "queue.html"),
+		configTmpl:      mustParseTmpl("base.html", "config.html"),
+		reposTmpl:       mustParseTmpl("base.html", "repos.html"),
+		deploysTmpl:     mustParseTmpl("base.html", "deploys.html"),
+		triageTmpl:      mustParseTmpl("base.html", "triage.html"),
+		triageListTmpl:  mustParseTmpl("base.html", "triage-list.html"),
 	}
 }
 
@@ -225,23 +228,6 @@ func currentProject(r *http.Request) string {
 	return r.URL.Query().Get("project")
 }
 
-// repoToNamespace converts a repo URL like "github.com/org/repo",
-// "https://github.com/org/repo", or plain "org/repo" to a namespace string "org/repo".
-func repoToNamespace(repo string) string {
-	if repo == "" {
-		return ""
-	}
-	repo = strings.TrimPrefix(repo, "https://")
-	repo = strings.TrimPrefix(repo, "http://")
-	parts := strings.SplitN(repo, "/", 3)
-	if len(parts) == 3 {
-		// domain/owner/repo → owner/repo
-		return parts[1] + "/" + parts[2]
-	}
-	// Already owner/repo
-	return repo
-}
-
 // effectiveNamespace returns the namespace for a pipeline state.
 // Uses ps.Namespace if set; otherwise derives it from the pipeline's config file.
 func (s *Server) effectiveNamespace(ps *pipeline.PipelineState) string {
@@ -252,7 +238,7 @@ func (s *Server) effectiveNamespace(ps *pipeline.PipelineState) string {
 	if cfg == nil {
 		return ""
 	}
-	return repoToNamespace(cfg.Pipeline.Repo)
+	return config.RepoNamespace(cfg.Pipeline.Repo)
 }
 
 // namespaceFromConfigPath derives the namespace for a config file path by
@@ -269,7 +255,7 @@ func (s *Server) namespaceFromConfigPath(configPath string) string {
 	if cfg == nil {
 		return ""
 	}
-	return repoToNamespace(cfg.Pipeline.Repo)
+	return config.RepoNamespace(cfg.Pipeline.Repo)
 }
 
 // sidebarData returns sidebar state for all known namespaced projects.
@@ -381,6 +367,12 @@ func (s *Server) routePipeline(w http.ResponseWriter, r *http.Request) {
 	case len(suffix) == 5 && suffix[0] == "stage" && suffix[2] == "attempt" && suffix[4] == "log":
 		// /pipeline/{owner}/{repo}/{issue}/stage/{stage}/attempt/{attempt}/log
 		s.handleAttemptLog(w, r, ns, issueStr, suffix[1], suffix[3])
+	case len(suffix) >= 6 && suffix[0] == "stage" && suffix[2] == "attempt" && suffix[4] == "browser":
+		// /pipeline/{owner}/{repo}/{issue}/stage/{stage}/attempt/{attempt}/browser/{file...}
+		s.handleAttemptBrowserFile(w, r, issueStr, suffix[1], suffix[3], suffix[5:])
+	case len(suffix) == 5 && suffix[0] == "stage" && suffix[2] == "diff":
+		// /pipeline/{owner}/{repo}/{issue}/stage/{stage}/diff/{a}/{b}
+		s.handleAttemptDiff(w, r, ns, issueStr, suffix[1], suffix[3], suffix[4])
 	default:
 		http.NotFound(w, r)
 	}
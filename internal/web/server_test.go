@@ -5,9 +5,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/lucasnoah/taintfactory/internal/pipeline"
+	"github.com/lucasnoah/taintfactory/internal/qa"
+	"github.com/lucasnoah/taintfactory/internal/triage"
 )
 
 func TestHealthz(t *testing.T) {
@@ -107,26 +110,6 @@ func TestSidebarData_ExcludesLegacyPipelines(t *testing.T) {
 	}
 }
 
-// ---- repoToNamespace tests ----
-
-func TestRepoToNamespace(t *testing.T) {
-	cases := []struct {
-		repo string
-		want string
-	}{
-		{"github.com/myorg/myapp", "myorg/myapp"},
-		{"https://github.com/myorg/myapp", "myorg/myapp"},
-		{"http://github.com/myorg/myapp", "myorg/myapp"},
-		{"", ""},
-	}
-	for _, c := range cases {
-		got := repoToNamespace(c.repo)
-		if got != c.want {
-			t.Errorf("repoToNamespace(%q) = %q, want %q", c.repo, got, c.want)
-		}
-	}
-}
-
 func TestConfigForPS_UsesConfigPath(t *testing.T) {
 	dir := t.TempDir()
 	cfgContent := `
@@ -235,3 +218,126 @@ pipeline:
 		t.Errorf("expected name 'derived-dir-project', got %q", cfg.Pipeline.Name)
 	}
 }
+
+// ---- attempt diff tests ----
+
+func TestAttemptDiff_RendersPromptAndCodeDiff(t *testing.T) {
+	dir := t.TempDir()
+	store := pipeline.NewStore(dir)
+	store.Create(pipeline.CreateOpts{Issue: 501, Title: "A", Branch: "b", Worktree: "w", FirstStage: "implement", Namespace: "org/app"})
+	store.SavePrompt(501, "implement", 1, "# Implement\nfirst try\n")
+	store.SavePrompt(501, "implement", 2, "# Implement\nsecond try\n")
+	store.SaveAttemptDiff(501, "implement", 1, "+old line\n")
+	store.SaveAttemptDiff(501, "implement", 2, "+new line\n")
+	store.SaveStageOutcome(501, "implement", 1, &pipeline.StageOutcome{Status: "fail"})
+	store.SaveStageOutcome(501, "implement", 2, &pipeline.StageOutcome{Status: "success"})
+
+	s := NewServer(store, nil, 0, "")
+	mux := s.buildMux()
+
+	req := httptest.NewRequest("GET", "/pipeline/org/app/501/stage/implement/diff/1/2", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	// html/template escapes "+" as "&#43;".
+	for _, want := range []string{
+		`<span class="diff-del">-first try</span>`,
+		`<span class="diff-add">&#43;second try</span>`,
+		`<span class="diff-del">-&#43;old line</span>`,
+		`<span class="diff-add">&#43;&#43;new line</span>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q", want)
+		}
+	}
+}
+
+func TestAttemptDiff_InvalidAttempt(t *testing.T) {
+	s := NewServer(pipeline.NewStore(t.TempDir()), nil, 0, "")
+	mux := s.buildMux()
+
+	req := httptest.NewRequest("GET", "/pipeline/org/app/1/stage/implement/diff/x/2", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestAttemptDetail_ShowsVisualDiff(t *testing.T) {
+	store := pipeline.NewStore(t.TempDir())
+	store.Create(pipeline.CreateOpts{Issue: 502, Title: "A", Branch: "b", Worktree: "w", FirstStage: "browser-qa", Namespace: "org/app"})
+	dir := store.BrowserQADir(502, "browser-qa", 1)
+	qa.WriteVisualReport(filepath.Join(dir, "visual", "report.json"), &qa.VisualReport{
+		BaseRef: "origin/main", Threshold: 0.5,
+		Routes: []qa.VisualDiff{{Route: "/settings", Base: "visual/base/settings.png", Head: "settings.png", Diff: "visual/diff/settings.png", Changed: 3.25}},
+	})
+	os.WriteFile(filepath.Join(dir, "settings.png"), []byte("\x89PNG"), 0o644)
+
+	mux := NewServer(store, nil, 0, "").buildMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/pipeline/org/app/502/stage/browser-qa/attempt/1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"against origin/main",
+		"3.25% changed",
+		`src="/pipeline/org/app/502/stage/browser-qa/attempt/1/browser/visual/diff/settings.png"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/pipeline/org/app/502/stage/browser-qa/attempt/1/browser/settings.png", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("screenshot status = %d, want 200", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/pipeline/org/app/502/stage/browser-qa/attempt/1/browser/report.json", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("non-image status = %d, want 404", rec.Code)
+	}
+}
+
+// ---- triage handoff tests ----
+
+func TestTriageDetail_LinksHandoffToPipeline(t *testing.T) {
+	triageDir := t.TempDir()
+	ts := triage.NewStore(filepath.Join(triageDir, "org-app"))
+	ts.Save(&triage.TriageState{
+		Issue: 77, Repo: "org/app", Status: "completed", StageHistory: []triage.TriageStageHistoryEntry{},
+		Handoff: &triage.TriageHandoff{Stage: "classify", Namespace: "org/app", EnqueuedAt: "2026-01-01T00:00:00Z"},
+	})
+	store := pipeline.NewStore(t.TempDir())
+	s := NewServer(store, nil, 0, triageDir)
+	mux := s.buildMux()
+
+	get := func() string {
+		req := httptest.NewRequest("GET", "/triage/org-app/77", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200; body: %s", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	if body := get(); !strings.Contains(body, `<a href="/queue">waiting in queue`) {
+		t.Errorf("before the pipeline starts, body should link to the queue")
+	}
+
+	store.Create(pipeline.CreateOpts{Issue: 77, Title: "A", Branch: "b", Worktree: "w", FirstStage: "implement", Namespace: "org/app"})
+	if body := get(); !strings.Contains(body, `<a href="/pipeline/org/app/77">view pipeline`) {
+		t.Errorf("once the pipeline exists, body should link to it")
+	}
+}
@@ -23,6 +23,10 @@ type TriageDetailData struct {
 	UpdatedAgo        string
 	IssueURL          string
 	ShouldAutoRefresh bool
+	Handoff           *triage.TriageHandoff
+	HandoffURL        string // pipeline page once it exists, otherwise the queue
+	HandoffHasRun     bool   // true when HandoffURL points at a pipeline
+	Sidebar           SidebarData
 }
 
 // TriageHistoryView wraps a TriageStageHistoryEntry with derived fields.
@@ -104,6 +108,19 @@ func (s *Server) handleTriageDetail(w http.ResponseWriter, r *http.Request, slug
 		issueURL = fmt.Sprintf("https://github.com/%s/issues/%d", ts.Repo, issue)
 	}
 
+	// Link a queue handoff to its pipeline, or to the queue until it starts.
+	var handoffURL string
+	var handoffHasRun bool
+	if h := ts.Handoff; h != nil && h.EnqueuedAt != "" {
+		handoffURL = "/queue"
+		if s.store != nil {
+			if ps, err := s.store.GetForNamespace(h.Namespace, ts.Issue); err == nil && ps != nil {
+				handoffURL = fmt.Sprintf("/pipeline/%s/%d", h.Namespace, ts.Issue)
+				handoffHasRun = true
+			}
+		}
+	}
+
 	data := TriageDetailData{
 		State:             ts,
 		Slug:              slug,
@@ -116,6 +133,10 @@ func (s *Server) handleTriageDetail(w http.ResponseWriter, r *http.Request, slug
 		UpdatedAgo:        relTime(ts.UpdatedAt),
 		IssueURL:          issueURL,
 		ShouldAutoRefresh: ts.Status == "in_progress" && !hasLiveStream,
+		Handoff:           ts.Handoff,
+		HandoffURL:        handoffURL,
+		HandoffHasRun:     handoffHasRun,
+		Sidebar:           s.sidebarData(""),
 	}
 
 	if err := s.triageTmpl.ExecuteTemplate(w, "base", data); err != nil {
@@ -0,0 +1,394 @@
+package worktree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// staleBusy is how long a slot may stay marked busy before a refresh assumes
+// the process working on it died and rebuilds the slot.
+const staleBusy = time.Hour
+
+// PoolOpts describes a pool of warm worktrees for one base branch. Pooled
+// worktrees live under <baseDir>/.pool/<remote>_<branch>/slot-<n>, detached
+// at the branch tip, with a slot-<n>.json beside each recording its state.
+type PoolOpts struct {
+	Size      int
+	Remote    string   // defaults to origin
+	Branch    string   // defaults to main
+	Lockfiles []string // hashed to decide whether setup must rerun
+	// FetchEvery is the least time between RefreshPool fetches of the
+	// branch; 0 fetches on every call.
+	FetchEvery time.Duration
+}
+
+func (p PoolOpts) remote() string {
+	if p.Remote == "" {
+		return "origin"
+	}
+	return p.Remote
+}
+
+func (p PoolOpts) branch() string {
+	if p.Branch == "" {
+		return "main"
+	}
+	return p.Branch
+}
+
+// Slot is the recorded state of one pooled worktree.
+type Slot struct {
+	Index     int       `json:"index"`
+	Path      string    `json:"path"`
+	Commit    string    `json:"commit"`
+	LockHash  string    `json:"lock_hash"`
+	Ready     bool      `json:"ready"`           // set up and free to hand out
+	Error     string    `json:"error,omitempty"` // last setup failure; retried when the branch moves
+	UpdatedAt time.Time `json:"updated_at"`
+	Busy      bool      `json:"-"` // being refreshed
+}
+
+// SetupFunc prepares a pooled worktree after it is created or its lockfiles
+// change, e.g. by running pipeline.setup in it.
+type SetupFunc func(dir string) error
+
+// LockHash hashes the named lockfiles in dir. A missing lockfile hashes
+// differently from an empty one, so adding or removing one counts as a change.
+func LockHash(dir string, lockfiles []string) (string, error) {
+	h := sha256.New()
+	for _, name := range lockfiles {
+		fmt.Fprintf(h, "%s\x00", name)
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			h.Write([]byte{0})
+		case err != nil:
+			return "", err
+		default:
+			fmt.Fprintf(h, "%d\x00", len(data))
+			h.Write(data)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *Manager) poolDir(p PoolOpts) string {
+	name := strings.ReplaceAll(p.remote()+"_"+p.branch(), "/", "_")
+	return filepath.Join(m.baseDir, ".pool", name)
+}
+
+func (m *Manager) slotPath(p PoolOpts, i int) string {
+	return filepath.Join(m.poolDir(p), fmt.Sprintf("slot-%d", i))
+}
+
+func (m *Manager) slotMeta(p PoolOpts, i int) string { return m.slotPath(p, i) + ".json" }
+func (m *Manager) slotBusy(p PoolOpts, i int) string { return m.slotPath(p, i) + ".busy" }
+
+func readSlot(path string) (*Slot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Slot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// writeSlot records a slot's state and releases its busy marker.
+func (m *Manager) writeSlot(p PoolOpts, s *Slot) error {
+	s.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	meta := m.slotMeta(p, s.Index)
+	if err := os.WriteFile(meta+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(meta+".tmp", meta); err != nil {
+		return err
+	}
+	return os.Remove(m.slotBusy(p, s.Index))
+}
+
+// slotIndexes returns the indexes of every slot with a state or busy file.
+func (m *Manager) slotIndexes(p PoolOpts) []int {
+	entries, _ := os.ReadDir(m.poolDir(p))
+	seen := make(map[int]bool)
+	var idx []int
+	for _, e := range entries {
+		name := strings.TrimPrefix(e.Name(), "slot-")
+		if name == e.Name() {
+			continue
+		}
+		num, ext, _ := strings.Cut(name, ".")
+		i, err := strconv.Atoi(num)
+		if err != nil || (ext != "json" && ext != "busy") || seen[i] {
+			continue
+		}
+		seen[i] = true
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// PoolStatus returns the recorded state of every slot in the pool.
+func (m *Manager) PoolStatus(p PoolOpts) []Slot {
+	var slots []Slot
+	for _, i := range m.slotIndexes(p) {
+		s, err := readSlot(m.slotMeta(p, i))
+		if err != nil {
+			s = &Slot{Index: i, Path: m.slotPath(p, i)}
+		}
+		s.Busy = m.busy(p, i)
+		slots = append(slots, *s)
+	}
+	return slots
+}
+
+// claimSlot takes a ready slot out of the pool. Renaming its state file is
+// the claim, so two processes cannot take the same slot.
+func (m *Manager) claimSlot(p PoolOpts) (*Slot, bool) {
+	for _, i := range m.slotIndexes(p) {
+		meta := m.slotMeta(p, i)
+		s, err := readSlot(meta)
+		if err != nil || !s.Ready {
+			continue
+		}
+		if err := os.Rename(meta, meta+".claimed"); err != nil {
+			continue
+		}
+		return s, true
+	}
+	return nil, false
+}
+
+// createFromPool moves a ready pooled worktree to path and resets it to a new
+// branch at base. Ignored files such as installed dependencies survive the
+// reset. It reports false when no slot is ready or the handover fails, in
+// which case the caller creates the worktree from scratch.
+func (m *Manager) createFromPool(p PoolOpts, path, branch, base string) (*CreateResult, bool) {
+	slot, ok := m.claimSlot(p)
+	if !ok {
+		return nil, false
+	}
+	defer os.Remove(m.slotMeta(p, slot.Index) + ".claimed")
+
+	if _, err := m.git.Run(m.repoDir, "worktree", "move", slot.Path, path); err != nil {
+		m.discard(slot.Path)
+		return nil, false
+	}
+	if err := m.resetTo(path, branch, base); err != nil {
+		m.discard(path)
+		return nil, false
+	}
+	hash, err := LockHash(path, p.Lockfiles)
+	return &CreateResult{
+		Path:      path,
+		Branch:    branch,
+		Pooled:    true,
+		SetupDone: err == nil && hash == slot.LockHash,
+	}, true
+}
+
+// resetTo discards local changes in dir and switches it to a new branch at
+// base, or to branch as it is when it already exists.
+func (m *Manager) resetTo(dir, branch, base string) error {
+	if _, err := m.git.Run(dir, "reset", "--hard", "-q"); err != nil {
+		return err
+	}
+	if _, err := m.git.Run(dir, "clean", "-fdq"); err != nil {
+		return err
+	}
+	_, err := m.git.Run(dir, "checkout", "-q", "-b", branch, base)
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		_, err = m.git.Run(dir, "checkout", "-q", branch)
+	}
+	return err
+}
+
+// discard removes a worktree regardless of its state.
+func (m *Manager) discard(path string) {
+	_, _ = m.git.Run(m.repoDir, "worktree", "remove", "--force", path)
+	_ = os.RemoveAll(path)
+	_, _ = m.git.Run(m.repoDir, "worktree", "prune")
+}
+
+// RefreshPool does the next unit of pool maintenance and describes it: it
+// creates a missing slot, moves a slot behind the branch tip forward (running
+// setup when its lockfiles changed), or removes a slot beyond p.Size. It
+// returns "" when every slot is current, so callers can spread the work over
+// several calls.
+func (m *Manager) RefreshPool(p PoolOpts, setup SetupFunc) (string, error) {
+	if err := os.MkdirAll(m.poolDir(p), 0o755); err != nil {
+		return "", err
+	}
+	if err := m.fetchPoolBranch(p); err != nil {
+		return "", err
+	}
+	commit, err := m.git.Run(m.repoDir, "rev-parse", p.remote()+"/"+p.branch())
+	if err != nil {
+		return "", fmt.Errorf("resolve %s/%s: %w", p.remote(), p.branch(), err)
+	}
+
+	for i := 0; i < p.Size; i++ {
+		if info, err := os.Stat(m.slotBusy(p, i)); err == nil {
+			if time.Since(info.ModTime()) < staleBusy {
+				continue
+			}
+			// Whoever marked it busy is gone; rebuild the slot.
+			os.Remove(m.slotMeta(p, i))
+			os.Remove(m.slotBusy(p, i))
+		}
+
+		s, err := readSlot(m.slotMeta(p, i))
+		if err != nil {
+			if !m.markBusy(p, i) {
+				continue
+			}
+			return fmt.Sprintf("created slot-%d", i), m.buildSlot(p, i, commit, setup)
+		}
+		if s.Commit == commit && (s.Ready || s.Error != "") {
+			continue
+		}
+		if os.Rename(m.slotMeta(p, i), m.slotBusy(p, i)) != nil {
+			continue // claimed meanwhile
+		}
+		return fmt.Sprintf("refreshed slot-%d", i), m.updateSlot(p, s, commit, setup)
+	}
+
+	for _, i := range m.slotIndexes(p) {
+		if i < p.Size || m.busy(p, i) || os.Rename(m.slotMeta(p, i), m.slotBusy(p, i)) != nil {
+			continue
+		}
+		m.discard(m.slotPath(p, i))
+		os.Remove(m.slotBusy(p, i))
+		return fmt.Sprintf("removed slot-%d", i), nil
+	}
+	return "", nil
+}
+
+// fetchPoolBranch fetches the pool's branch unless it was fetched less than
+// p.FetchEvery ago. The time of the last fetch is the mtime of a marker in
+// the pool directory, so it holds across processes.
+func (m *Manager) fetchPoolBranch(p PoolOpts) error {
+	marker := filepath.Join(m.poolDir(p), ".fetched")
+	if p.FetchEvery > 0 {
+		if info, err := os.Stat(marker); err == nil && time.Since(info.ModTime()) < p.FetchEvery {
+			return nil
+		}
+	}
+	if _, err := m.git.Run(m.repoDir, "fetch", p.remote(), p.branch()); err != nil {
+		return fmt.Errorf("fetch %s %s: %w", p.remote(), p.branch(), err)
+	}
+	if p.FetchEvery > 0 {
+		_ = os.WriteFile(marker, nil, 0o644)
+		now := time.Now()
+		_ = os.Chtimes(marker, now, now)
+	}
+	return nil
+}
+
+// busy reports whether slot i is being worked on.
+func (m *Manager) busy(p PoolOpts, i int) bool {
+	_, err := os.Stat(m.slotBusy(p, i))
+	return err == nil
+}
+
+// markBusy creates slot i's busy marker, reporting false if it exists.
+func (m *Manager) markBusy(p PoolOpts, i int) bool {
+	f, err := os.OpenFile(m.slotBusy(p, i), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// buildSlot checks out a new slot at commit and sets it up.
+func (m *Manager) buildSlot(p PoolOpts, i int, commit string, setup SetupFunc) error {
+	path := m.slotPath(p, i)
+	if _, err := os.Stat(path); err == nil {
+		m.discard(path) // left over from a crash or a failed refresh
+	}
+	if _, err := m.git.Run(m.repoDir, "worktree", "add", "--detach", path, commit); err != nil {
+		os.Remove(m.slotBusy(p, i))
+		return fmt.Errorf("create slot-%d: %w", i, err)
+	}
+	return m.setupSlot(p, &Slot{Index: i, Path: path, Commit: commit}, setup)
+}
+
+// updateSlot moves a slot to commit and reruns setup if its lockfiles
+// changed or its last setup did not finish.
+func (m *Manager) updateSlot(p PoolOpts, s *Slot, commit string, setup SetupFunc) error {
+	for _, args := range [][]string{
+		{"reset", "--hard", "-q"},
+		{"checkout", "-q", "--detach", commit},
+		{"clean", "-fdq"},
+	} {
+		if _, err := m.git.Run(s.Path, args...); err != nil {
+			m.discard(s.Path)
+			os.Remove(m.slotBusy(p, s.Index))
+			return fmt.Errorf("refresh slot-%d: %w", s.Index, err)
+		}
+	}
+	s.Commit = commit
+	hash, err := LockHash(s.Path, p.Lockfiles)
+	if err == nil && s.Ready && hash == s.LockHash {
+		return m.writeSlot(p, s)
+	}
+	return m.setupSlot(p, s, setup)
+}
+
+// setupSlot runs setup in a slot and records the outcome. A slot whose setup
+// fails stays in the pool, not ready, until the branch moves again.
+func (m *Manager) setupSlot(p PoolOpts, s *Slot, setup SetupFunc) error {
+	s.Ready, s.Error = false, ""
+	// Hash before setup: installers may rewrite lockfiles, and the handover
+	// compares against the committed ones.
+	hash, setupErr := LockHash(s.Path, p.Lockfiles)
+	s.LockHash = hash
+	if setupErr == nil && setup != nil {
+		setupErr = setup(s.Path)
+	}
+	if setupErr != nil {
+		s.Error = setupErr.Error()
+	} else {
+		s.Ready = true
+	}
+	if err := m.writeSlot(p, s); err != nil {
+		return err
+	}
+	if setupErr != nil {
+		return fmt.Errorf("set up slot-%d: %w", s.Index, setupErr)
+	}
+	return nil
+}
+
+// DrainPool removes every slot that is not busy and returns how many it
+// removed.
+func (m *Manager) DrainPool(p PoolOpts) int {
+	n := 0
+	for _, i := range m.slotIndexes(p) {
+		if m.busy(p, i) || os.Rename(m.slotMeta(p, i), m.slotBusy(p, i)) != nil {
+			continue
+		}
+		m.discard(m.slotPath(p, i))
+		os.Remove(m.slotBusy(p, i))
+		n++
+	}
+	return n
+}
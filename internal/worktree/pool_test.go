@@ -0,0 +1,196 @@
+package worktree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testLockfiles = []string{"go.sum", "package-lock.json"}
+
+func TestLockHash(t *testing.T) {
+	dir := t.TempDir()
+	missing, err := LockHash(dir, testLockfiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "go.sum"), nil, 0o644)
+	empty, _ := LockHash(dir, testLockfiles)
+	if empty == missing {
+		t.Error("an empty lockfile should hash differently from a missing one")
+	}
+
+	os.WriteFile(filepath.Join(dir, "go.sum"), []byte("example.com/x v1.0.0 h1:abc\n"), 0o644)
+	first, _ := LockHash(dir, testLockfiles)
+	again, _ := LockHash(dir, testLockfiles)
+	if first == empty || first != again {
+		t.Errorf("hash should follow content: empty=%s first=%s again=%s", empty, first, again)
+	}
+}
+
+// writeTestSlot records a ready slot whose lockfiles match a worktree without any.
+func writeTestSlot(t *testing.T, mgr *Manager, p PoolOpts, i int) {
+	t.Helper()
+	hash, _ := LockHash(t.TempDir(), p.Lockfiles)
+	os.MkdirAll(mgr.poolDir(p), 0o755)
+	os.WriteFile(mgr.slotBusy(p, i), nil, 0o644)
+	if err := mgr.writeSlot(p, &Slot{Index: i, Path: mgr.slotPath(p, i), Commit: "abc123", LockHash: hash, Ready: true}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreate_FromPool(t *testing.T) {
+	baseDir := t.TempDir()
+	git := &mockGit{}
+	mgr := NewManager(git, "/repo", baseDir)
+	pool := PoolOpts{Size: 1, Remote: "origin", Branch: "main", Lockfiles: testLockfiles}
+	writeTestSlot(t, mgr, pool, 0)
+
+	result, err := mgr.Create(CreateOpts{Issue: 42, Pool: &PoolOpts{Size: 1, Lockfiles: testLockfiles}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Pooled || !result.SetupDone {
+		t.Errorf("expected a pooled worktree with setup done, got %+v", result)
+	}
+
+	path := filepath.Join(baseDir, "issue-42")
+	if len(git.calls) != 5 {
+		t.Fatalf("expected 5 git calls, got %d: %v", len(git.calls), git.calls)
+	}
+	assertArgs(t, git.calls[0].Args, "fetch", "origin", "main")
+	assertArgs(t, git.calls[1].Args, "worktree", "move", mgr.slotPath(pool, 0), path)
+	assertArgs(t, git.calls[2].Args, "reset", "--hard", "-q")
+	assertArgs(t, git.calls[3].Args, "clean", "-fdq")
+	assertArgs(t, git.calls[4].Args, "checkout", "-q", "-b", "feature/issue-42", "origin/main")
+	if git.calls[4].Dir != path {
+		t.Errorf("checkout ran in %q, want %q", git.calls[4].Dir, path)
+	}
+	if slots := mgr.PoolStatus(pool); len(slots) != 0 {
+		t.Errorf("claimed slot still in pool: %+v", slots)
+	}
+}
+
+func TestCreate_PoolForOtherBranchUnused(t *testing.T) {
+	git := &mockGit{}
+	mgr := NewManager(git, "/repo", t.TempDir())
+	writeTestSlot(t, mgr, PoolOpts{Size: 1, Remote: "origin", Branch: "main", Lockfiles: testLockfiles}, 0)
+
+	result, err := mgr.Create(CreateOpts{Issue: 42, BaseBranch: "release-2.3", Pool: &PoolOpts{Size: 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Pooled {
+		t.Error("expected a fresh worktree for a branch without a pool")
+	}
+	assertArgs(t, git.calls[1].Args, "worktree", "add", filepath.Join(mgr.baseDir, "issue-42"), "-b", "feature/issue-42", "origin/release-2.3")
+}
+
+func TestRefreshPool(t *testing.T) {
+	git := &mockGit{results: []mockResult{
+		{}, {Output: "abc123"}, {}, // fetch, rev-parse, worktree add
+		{}, {Output: "abc123"}, // fetch, rev-parse: nothing to do
+		{}, {Output: "def456"}, {}, {}, {}, // fetch, rev-parse, reset, checkout, clean
+	}}
+	mgr := NewManager(git, "/repo", t.TempDir())
+	pool := PoolOpts{Size: 1, Lockfiles: testLockfiles}
+	var setups []string
+	setup := func(dir string) error {
+		setups = append(setups, dir)
+		return nil
+	}
+
+	action, err := mgr.RefreshPool(pool, setup)
+	if err != nil || action != "created slot-0" {
+		t.Fatalf("first refresh: %q, %v", action, err)
+	}
+	assertArgs(t, git.calls[2].Args, "worktree", "add", "--detach", mgr.slotPath(pool, 0), "abc123")
+	if len(setups) != 1 || setups[0] != mgr.slotPath(pool, 0) {
+		t.Errorf("expected setup in the new slot, got %v", setups)
+	}
+
+	if action, err := mgr.RefreshPool(pool, setup); err != nil || action != "" {
+		t.Fatalf("second refresh: %q, %v", action, err)
+	}
+
+	if action, err := mgr.RefreshPool(pool, setup); err != nil || action != "refreshed slot-0" {
+		t.Fatalf("third refresh: %q, %v", action, err)
+	}
+	assertArgs(t, git.calls[8].Args, "checkout", "-q", "--detach", "def456")
+	if len(setups) != 1 {
+		t.Errorf("setup reran although lockfiles did not change: %v", setups)
+	}
+
+	slots := mgr.PoolStatus(pool)
+	if len(slots) != 1 || !slots[0].Ready || slots[0].Commit != "def456" || slots[0].Busy {
+		t.Errorf("unexpected pool state: %+v", slots)
+	}
+}
+
+func TestRefreshPool_SetupFailure(t *testing.T) {
+	git := &mockGit{results: []mockResult{{}, {Output: "abc123"}}}
+	mgr := NewManager(git, "/repo", t.TempDir())
+	pool := PoolOpts{Size: 1}
+
+	_, err := mgr.RefreshPool(pool, func(string) error { return os.ErrPermission })
+	if err == nil {
+		t.Fatal("expected setup error")
+	}
+	slots := mgr.PoolStatus(pool)
+	if len(slots) != 1 || slots[0].Ready || slots[0].Error == "" {
+		t.Errorf("expected a failed, unready slot: %+v", slots)
+	}
+	if _, ok := mgr.claimSlot(pool); ok {
+		t.Error("claimed a slot whose setup failed")
+	}
+}
+
+func TestRefreshPool_Shrink(t *testing.T) {
+	git := &mockGit{results: []mockResult{{}, {Output: "abc123"}}}
+	mgr := NewManager(git, "/repo", t.TempDir())
+	pool := PoolOpts{Size: 1, Remote: "origin", Branch: "main"}
+	writeTestSlot(t, mgr, pool, 0)
+
+	pool.Size = 0
+	action, err := mgr.RefreshPool(pool, nil)
+	if err != nil || action != "removed slot-0" {
+		t.Fatalf("got %q, %v", action, err)
+	}
+	if slots := mgr.PoolStatus(pool); len(slots) != 0 {
+		t.Errorf("expected an empty pool, got %+v", slots)
+	}
+}
+
+func TestDrainPool(t *testing.T) {
+	mgr := NewManager(&mockGit{}, "/repo", t.TempDir())
+	pool := PoolOpts{Size: 2}
+	writeTestSlot(t, mgr, pool, 0)
+	writeTestSlot(t, mgr, pool, 1)
+	os.WriteFile(mgr.slotBusy(pool, 1), nil, 0o644)
+
+	if n := mgr.DrainPool(pool); n != 1 {
+		t.Errorf("expected the idle slot removed, got %d", n)
+	}
+}
+
+func TestRefreshPool_FetchEvery(t *testing.T) {
+	git := &mockGit{results: []mockResult{
+		{}, {Output: "abc123"}, // fetch, rev-parse
+		{Output: "abc123"}, // rev-parse only: fetched a moment ago
+	}}
+	mgr := NewManager(git, "/repo", t.TempDir())
+	pool := PoolOpts{FetchEvery: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		if _, err := mgr.RefreshPool(pool, nil); err != nil {
+			t.Fatalf("refresh %d: %v", i, err)
+		}
+	}
+	if len(git.calls) != 3 {
+		t.Fatalf("expected one fetch and two rev-parses, got %d calls", len(git.calls))
+	}
+	assertArgs(t, git.calls[0].Args, "fetch", "origin", "main")
+	assertArgs(t, git.calls[2].Args, "rev-parse", "origin/main")
+}
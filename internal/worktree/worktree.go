@@ -51,12 +51,24 @@ type CreateOpts struct {
 	Issue  int
 	Title  string
 	Branch string // override auto-generated branch name
+	Suffix string // appended to the directory and branch names, e.g. "c2" for a best-of-N candidate
+	Base   string // start point; defaults to <Remote>/<BaseBranch> (fetched first)
+
+	Remote     string // remote to fetch the base branch from; defaults to origin
+	BaseBranch string // branch to start from; defaults to main
+
+	// Pool, when set, hands out a warm worktree from the pool of
+	// Remote/BaseBranch if one is ready. Its Remote and Branch are ignored.
+	Pool *PoolOpts
 }
 
 // CreateResult holds the result of creating a worktree.
 type CreateResult struct {
 	Path   string
 	Branch string
+
+	Pooled    bool // taken from the worktree pool
+	SetupDone bool // pooled, and its lockfiles match those it was set up with
 }
 
 // Create creates a new git worktree for an issue.
@@ -67,19 +79,40 @@ func (m *Manager) Create(opts CreateOpts) (*CreateResult, error) {
 
 	branch := opts.Branch
 	if branch == "" {
-		branch = sanitizeBranch(fmt.Sprintf("feature/issue-%d", opts.Issue))
-	} else {
-		branch = sanitizeBranch(branch)
+		branch = fmt.Sprintf("feature/issue-%d", opts.Issue)
 	}
+	if opts.Suffix != "" {
+		branch += "-" + opts.Suffix
+	}
+	branch = sanitizeBranch(branch)
 
-	worktreePath := filepath.Join(m.baseDir, fmt.Sprintf("issue-%d", opts.Issue))
+	worktreePath := m.PathFor(opts.Issue, opts.Suffix)
 
-	// Best-effort fetch to ensure we branch from up-to-date main
-	m.git.Run(m.repoDir, "fetch", "origin", "main")
+	base := opts.Base
+	if base == "" {
+		remote, baseBranch := opts.Remote, opts.BaseBranch
+		if remote == "" {
+			remote = "origin"
+		}
+		if baseBranch == "" {
+			baseBranch = "main"
+		}
+		// Best-effort fetch to ensure we branch from the up-to-date base branch
+		m.git.Run(m.repoDir, "fetch", remote, baseBranch)
+		// Branch explicitly from the remote-tracking ref, not the local branch
+		// (which may lag behind if it hasn't been fast-forwarded).
+		base = remote + "/" + baseBranch
+
+		if opts.Pool != nil && opts.Suffix == "" {
+			pool := *opts.Pool
+			pool.Remote, pool.Branch = remote, baseBranch
+			if result, ok := m.createFromPool(pool, worktreePath, branch, base); ok {
+				return result, nil
+			}
+		}
+	}
 
-	// Create the worktree branching explicitly from origin/main, not the local
-	// HEAD (which may lag behind if the local branch hasn't been fast-forwarded).
-	_, err := m.git.Run(m.repoDir, "worktree", "add", worktreePath, "-b", branch, "origin/main")
+	_, err := m.git.Run(m.repoDir, "worktree", "add", worktreePath, "-b", branch, base)
 	if err != nil {
 		// If branch already exists, try without -b
 		if strings.Contains(err.Error(), "already exists") {
@@ -98,6 +131,22 @@ func (m *Manager) Create(opts CreateOpts) (*CreateResult, error) {
 	}, nil
 }
 
+// RemoveCandidate discards a suffixed worktree made by Create and its branch,
+// including uncommitted and unmerged work.
+func (m *Manager) RemoveCandidate(issue int, suffix string) error {
+	worktreePath := m.PathFor(issue, suffix)
+	branch, _ := m.git.Run(worktreePath, "rev-parse", "--abbrev-ref", "HEAD")
+	if _, err := m.git.Run(m.repoDir, "worktree", "remove", "--force", worktreePath); err != nil {
+		return fmt.Errorf("remove worktree: %w", err)
+	}
+	if branch != "" && branch != "HEAD" && branch != "main" && branch != "master" {
+		if _, err := m.git.Run(m.repoDir, "branch", "-D", branch); err != nil {
+			return fmt.Errorf("delete branch %q: %w", branch, err)
+		}
+	}
+	return nil
+}
+
 // Remove removes a git worktree and optionally deletes the branch.
 func (m *Manager) Remove(issue int, deleteBranch bool) error {
 	if issue <= 0 {
@@ -133,7 +182,16 @@ func (m *Manager) Remove(issue int, deleteBranch bool) error {
 
 // Path returns the worktree path for an issue.
 func (m *Manager) Path(issue int) string {
-	return filepath.Join(m.baseDir, fmt.Sprintf("issue-%d", issue))
+	return m.PathFor(issue, "")
+}
+
+// PathFor returns the worktree path for an issue with an optional suffix.
+func (m *Manager) PathFor(issue int, suffix string) string {
+	name := fmt.Sprintf("issue-%d", issue)
+	if suffix != "" {
+		name += "-" + suffix
+	}
+	return filepath.Join(m.baseDir, name)
 }
 
 var nonAlphaNum = regexp.MustCompile(`[^a-zA-Z0-9/_-]+`)
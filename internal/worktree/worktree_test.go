@@ -91,6 +91,21 @@ func TestCreate_FetchFailsGracefully(t *testing.T) {
 	assertArgs(t, git.calls[0].Args, "fetch", "origin", "main")
 }
 
+func TestCreate_BaseBranchAndRemote(t *testing.T) {
+	git := &mockGit{}
+
+	mgr := NewManager(git, "/repo", "/repo/worktrees")
+	if _, err := mgr.Create(CreateOpts{Issue: 42, Remote: "upstream", BaseBranch: "release/2.3"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(git.calls) != 2 {
+		t.Fatalf("expected 2 git calls, got %d", len(git.calls))
+	}
+	assertArgs(t, git.calls[0].Args, "fetch", "upstream", "release/2.3")
+	assertArgs(t, git.calls[1].Args, "worktree", "add", "/repo/worktrees/issue-42", "-b", "feature/issue-42", "upstream/release/2.3")
+}
+
 func TestCreate_CustomBranch(t *testing.T) {
 	git := &mockGit{
 		results: []mockResult{
@@ -329,3 +344,42 @@ func assertArgs(t *testing.T, got []string, want ...string) {
 		}
 	}
 }
+
+func TestCreate_CandidateFromBase(t *testing.T) {
+	git := &mockGit{}
+	mgr := NewManager(git, "/repo", "/repo/worktrees")
+	result, err := mgr.Create(CreateOpts{Issue: 42, Branch: "feature/issue-42", Suffix: "c2", Base: "abc123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Path != "/repo/worktrees/issue-42-c2" {
+		t.Errorf("expected path /repo/worktrees/issue-42-c2, got %q", result.Path)
+	}
+	if result.Branch != "feature/issue-42-c2" {
+		t.Errorf("expected branch feature/issue-42-c2, got %q", result.Branch)
+	}
+	// No fetch when branching from an explicit base.
+	if len(git.calls) != 1 {
+		t.Fatalf("expected 1 git call, got %d", len(git.calls))
+	}
+	assertArgs(t, git.calls[0].Args, "worktree", "add", "/repo/worktrees/issue-42-c2", "-b", "feature/issue-42-c2", "abc123")
+}
+
+func TestRemoveCandidate(t *testing.T) {
+	git := &mockGit{
+		results: []mockResult{
+			{Output: "feature/issue-42-c2"}, // rev-parse
+			{Output: ""},                    // worktree remove
+			{Output: ""},                    // branch -D
+		},
+	}
+	mgr := NewManager(git, "/repo", "/repo/worktrees")
+	if err := mgr.RemoveCandidate(42, "c2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(git.calls) != 3 {
+		t.Fatalf("expected 3 git calls, got %d", len(git.calls))
+	}
+	assertArgs(t, git.calls[1].Args, "worktree", "remove", "--force", "/repo/worktrees/issue-42-c2")
+	assertArgs(t, git.calls[2].Args, "branch", "-D", "feature/issue-42-c2")
+}